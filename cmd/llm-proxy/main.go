@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 
+	"go-llm-proxy/internal/config"
 	"go-llm-proxy/internal/proxy"
 )
 
@@ -16,21 +21,43 @@ func main() {
 		log.Println("No .env file found, using system environment variables")
 	}
 
-	// Create the refactored proxy server
-	proxyServer := proxy.NewProxyServerV2()
+	// Load configuration from config.yaml, the environment and the command line,
+	// and create the refactored proxy server from it; reloads repeat both steps
+	build := func() (*proxy.ProxyServerV2, error) {
+		cfg, err := config.Load(os.Args[1:])
+		if err != nil {
+			return nil, err
+		}
+		return proxy.BuildProxyServerV2(cfg)
+	}
+	proxyServer, err := build()
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to start: %v", err)
+	}
+
+	// Serve every request with the current server, replaced on SIGHUP, on POST /admin/reload
+	// and, when config_watch_seconds is set, when the configuration file changes
+	reloader := proxy.NewReloader(proxyServer, build)
+	go reloader.WatchSignals(context.Background())
+	go reloader.WatchFile(context.Background())
 
 	// Set Gin mode
-	ginMode := os.Getenv("GIN_MODE")
-	if ginMode == "" {
-		ginMode = gin.ReleaseMode
-	}
-	gin.SetMode(ginMode)
+	gin.SetMode(proxyServer.Config.GinMode)
 
 	// Set up routes
 	router := gin.Default()
 
-	// Add CORS middleware for JetBrains compatibility
+	// Add CORS middleware for JetBrains compatibility. The admin endpoints are left out,
+	// so browsers don't let other sites call them.
 	router.Use(func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, "/admin/") {
+			c.Next()
+			return
+		}
+
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
@@ -48,19 +75,21 @@ func main() {
 	})
 
 	// Ollama API endpoints
-	router.POST("/api/generate", proxyServer.HandleGenerate)
-	router.POST("/api/chat", proxyServer.HandleChat)
-	router.GET("/api/tags", proxyServer.HandleTags)
-	router.GET("/api/version", proxyServer.HandleVersion)
-	router.POST("/api/pull", proxyServer.HandlePull)
-	router.POST("/api/push", proxyServer.HandlePush)
-	router.DELETE("/api/delete", proxyServer.HandleDelete)
-	router.POST("/api/create", proxyServer.HandleCreate)
-	router.POST("/api/copy", proxyServer.HandleCopy)
-	router.POST("/api/embeddings", proxyServer.HandleEmbeddings)
-	router.POST("/api/show", proxyServer.HandleShow)
-	router.POST("/api/ps", proxyServer.HandlePs)
-	router.POST("/api/stop", proxyServer.HandleStop)
+	router.POST("/api/generate", reloader.Handle((*proxy.ProxyServerV2).HandleGenerate))
+	router.POST("/api/chat", reloader.Handle((*proxy.ProxyServerV2).HandleChat))
+	router.GET("/api/tags", reloader.Handle((*proxy.ProxyServerV2).HandleTags))
+	router.GET("/api/version", reloader.Handle((*proxy.ProxyServerV2).HandleVersion))
+	router.POST("/api/pull", reloader.Handle((*proxy.ProxyServerV2).HandlePull))
+	router.POST("/api/push", reloader.Handle((*proxy.ProxyServerV2).HandlePush))
+	router.DELETE("/api/delete", reloader.Handle((*proxy.ProxyServerV2).HandleDelete))
+	router.POST("/api/create", reloader.Handle((*proxy.ProxyServerV2).HandleCreate))
+	router.POST("/api/copy", reloader.Handle((*proxy.ProxyServerV2).HandleCopy))
+	router.POST("/api/embeddings", reloader.Handle((*proxy.ProxyServerV2).HandleEmbeddings))
+	router.POST("/api/embed", reloader.Handle((*proxy.ProxyServerV2).HandleEmbed))
+	router.POST("/api/show", reloader.Handle((*proxy.ProxyServerV2).HandleShow))
+	router.POST("/api/ps", reloader.Handle((*proxy.ProxyServerV2).HandlePs))
+	router.GET("/api/ps", reloader.Handle((*proxy.ProxyServerV2).HandlePs))
+	router.POST("/api/stop", reloader.Handle((*proxy.ProxyServerV2).HandleStop))
 
 	// Root endpoint for JetBrains IDE compatibility
 	router.GET("/", func(c *gin.Context) {
@@ -75,26 +104,27 @@ func main() {
 		})
 	})
 
-	router.GET("/v1/models", func(c *gin.Context) {
-		// OpenAI-style models endpoint
-		proxyServer.HandleTags(c)
-	})
+	// OpenAI-compatible endpoints
+	router.GET("/v1/models", reloader.Handle((*proxy.ProxyServerV2).HandleOpenAIModels))
+	router.POST("/v1/chat/completions", reloader.Handle((*proxy.ProxyServerV2).HandleOpenAIChatCompletions))
+
+	// Anthropic-compatible endpoints
+	router.POST("/v1/messages", reloader.Handle((*proxy.ProxyServerV2).HandleAnthropicMessages))
+	router.POST("/v1/messages/count_tokens", reloader.Handle((*proxy.ProxyServerV2).HandleAnthropicCountTokens))
 
 	// Alternative endpoints that might be expected
-	router.GET("/models", func(c *gin.Context) {
-		proxyServer.HandleTags(c)
-	})
+	router.GET("/models", reloader.Handle((*proxy.ProxyServerV2).HandleTags))
 
-	router.GET("/status", func(c *gin.Context) {
-		status := proxyServer.GetHealthStatus()
-		c.JSON(200, status)
-	})
+	router.GET("/status", reloader.HandleStatus)
 
 	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		status := proxyServer.GetHealthStatus()
+	router.GET("/health", reloader.Handle(func(p *proxy.ProxyServerV2, c *gin.Context) {
+		status := p.GetHealthStatus()
 		c.JSON(200, status)
-	})
+	}))
+
+	// Admin endpoints, which require the admin token or a local request
+	router.POST("/admin/reload", reloader.RequireAdmin, reloader.HandleReload)
 
 	// Get port from configuration
 	port := proxyServer.Config.Port
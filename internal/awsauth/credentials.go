@@ -0,0 +1,451 @@
+package awsauth
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expiryWindow is how long before they expire temporary credentials are replaced
+const expiryWindow = 5 * time.Minute
+
+// Credentials are the AWS access keys requests are signed with
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Expires is when temporary credentials stop working; zero for long-term keys
+	Expires time.Time
+}
+
+// Provider supplies credentials from one source
+type Provider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// errNotConfigured reports a source that holds no credentials, as opposed to one that failed
+var errNotConfigured = errors.New("not configured")
+
+// Chain looks for credentials in each of its providers in turn, like the AWS SDKs'
+// default chain, and keeps the first found until shortly before they expire
+type Chain struct {
+	Providers []Provider
+
+	mu     sync.Mutex
+	cached *Credentials
+}
+
+// DefaultChain returns the standard credential chain: the environment, the shared
+// credentials and config files (static keys of the profile only; SSO, assume-role and
+// credential_process profiles aren't supported), web identity tokens as used on EKS,
+// the ECS container endpoint and the EC2 instance metadata service. An empty profile
+// means AWS_PROFILE, or the default profile.
+func DefaultChain(profile, region string) *Chain {
+	return &Chain{Providers: []Provider{
+		EnvProvider{},
+		SharedFilesProvider{Profile: profile},
+		WebIdentityProvider{Region: region},
+		ContainerProvider{},
+		IMDSProvider{},
+	}}
+}
+
+// Retrieve returns the cached credentials, or looks them up again when they are about to expire
+func (c *Chain) Retrieve(ctx context.Context) (Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached != nil && (c.cached.Expires.IsZero() || time.Until(c.cached.Expires) > expiryWindow) {
+		return *c.cached, nil
+	}
+
+	var failures []string
+	for _, provider := range c.Providers {
+		creds, err := provider.Retrieve(ctx)
+		if err == nil {
+			c.cached = &creds
+			return creds, nil
+		}
+		if !errors.Is(err, errNotConfigured) {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return Credentials{}, fmt.Errorf("no AWS credentials found: %s", strings.Join(failures, "; "))
+	}
+	return Credentials{}, fmt.Errorf("no AWS credentials found in the environment, shared files or instance metadata")
+}
+
+// StaticProvider supplies credentials known up front
+type StaticProvider struct {
+	Credentials Credentials
+}
+
+// Retrieve implements Provider
+func (p StaticProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	return p.Credentials, nil
+}
+
+// EnvProvider reads credentials from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+type EnvProvider struct{}
+
+// Retrieve implements Provider
+func (EnvProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     firstEnv("AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY"),
+		SecretAccessKey: firstEnv("AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	switch {
+	case creds.AccessKeyID == "" && creds.SecretAccessKey == "":
+		return Credentials{}, errNotConfigured
+	case creds.AccessKeyID == "" || creds.SecretAccessKey == "":
+		return Credentials{}, fmt.Errorf("environment: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set together")
+	}
+	return creds, nil
+}
+
+// SharedFilesProvider reads the static keys of a profile from the shared credentials file
+// (~/.aws/credentials or AWS_SHARED_CREDENTIALS_FILE) or, failing that, the shared config
+// file (~/.aws/config or AWS_CONFIG_FILE)
+type SharedFilesProvider struct {
+	// Profile is the profile to read; AWS_PROFILE, or "default", when empty
+	Profile string
+}
+
+// Retrieve implements Provider
+func (p SharedFilesProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	profile := p.Profile
+	if profile == "" {
+		profile = firstEnv("AWS_PROFILE", "AWS_DEFAULT_PROFILE")
+	}
+	explicit := profile != ""
+	if profile == "" {
+		profile = "default"
+	}
+
+	home, _ := os.UserHomeDir()
+	credentialsFile := firstEnv("AWS_SHARED_CREDENTIALS_FILE")
+	if credentialsFile == "" && home != "" {
+		credentialsFile = filepath.Join(home, ".aws", "credentials")
+	}
+	configFile := firstEnv("AWS_CONFIG_FILE")
+	if configFile == "" && home != "" {
+		configFile = filepath.Join(home, ".aws", "config")
+	}
+	// Profiles other than the default one are "profile name" sections in the config file
+	configSection := profile
+	if profile != "default" {
+		configSection = "profile " + profile
+	}
+
+	found := false
+	for _, source := range []struct{ path, section string }{{credentialsFile, profile}, {configFile, configSection}} {
+		if source.path == "" {
+			continue
+		}
+		values, ok, err := readINISection(source.path, source.section)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("shared files: %w", err)
+		}
+		if !ok {
+			continue
+		}
+		found = true
+		if values["aws_access_key_id"] != "" && values["aws_secret_access_key"] != "" {
+			return Credentials{
+				AccessKeyID:     values["aws_access_key_id"],
+				SecretAccessKey: values["aws_secret_access_key"],
+				SessionToken:    values["aws_session_token"],
+			}, nil
+		}
+	}
+
+	switch {
+	case found:
+		return Credentials{}, fmt.Errorf("shared files: profile %s has no access keys", profile)
+	case explicit:
+		return Credentials{}, fmt.Errorf("shared files: profile %s not found", profile)
+	}
+	return Credentials{}, errNotConfigured
+}
+
+// readINISection reads the key-value pairs of one section of an INI file. A missing
+// file, like a missing section, is reported by ok being false.
+func readINISection(path, section string) (map[string]string, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close %s: %v\n", path, err)
+		}
+	}()
+
+	var values map[string]string
+	inSection := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			inSection = strings.Join(strings.Fields(line[1:len(line)-1]), " ") == section
+			if inSection && values == nil {
+				values = make(map[string]string)
+			}
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			values[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, err
+	}
+	return values, values != nil, nil
+}
+
+// WebIdentityProvider exchanges the token in AWS_WEB_IDENTITY_TOKEN_FILE for credentials
+// of the role in AWS_ROLE_ARN, as EKS service accounts are set up to
+type WebIdentityProvider struct {
+	// Region is the region of the STS endpoint; AWS_ENDPOINT_URL_STS overrides the endpoint
+	Region string
+}
+
+// Retrieve implements Provider
+func (p WebIdentityProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	tokenFile, roleARN := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN")
+	if tokenFile == "" || roleARN == "" {
+		return Credentials{}, errNotConfigured
+	}
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("web identity: %w", err)
+	}
+
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("go-llm-proxy-%d", time.Now().UnixNano())
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_STS")
+	if endpoint == "" {
+		endpoint = "https://sts." + p.Region + ".amazonaws.com"
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(endpoint, "/")+"/", strings.NewReader(form.Encode()))
+	if err != nil {
+		return Credentials{}, fmt.Errorf("web identity: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	body, err := fetch(&http.Client{Timeout: 10 * time.Second}, req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("web identity: %w", err)
+	}
+
+	var resp struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &resp); err != nil {
+		return Credentials{}, fmt.Errorf("web identity: failed to parse response: %w", err)
+	}
+	return Credentials{
+		AccessKeyID:     resp.Credentials.AccessKeyID,
+		SecretAccessKey: resp.Credentials.SecretAccessKey,
+		SessionToken:    resp.Credentials.SessionToken,
+		Expires:         resp.Credentials.Expiration,
+	}, nil
+}
+
+// ContainerProvider fetches credentials from the ECS container credentials endpoint named
+// by AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or AWS_CONTAINER_CREDENTIALS_FULL_URI
+type ContainerProvider struct{}
+
+// Retrieve implements Provider
+func (ContainerProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		endpoint = "http://169.254.170.2" + relative
+	} else if endpoint == "" {
+		return Credentials{}, errNotConfigured
+	} else if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "https" && !isLoopback(u.Hostname())) {
+		// Credentials are only fetched in the clear from the local host
+		return Credentials{}, fmt.Errorf("container: AWS_CONTAINER_CREDENTIALS_FULL_URI must be an https or loopback URL")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("container: %w", err)
+	}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("container: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	body, err := fetch(&http.Client{Timeout: 5 * time.Second}, req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("container: %w", err)
+	}
+	return parseJSONCredentials("container", body)
+}
+
+// isLoopback reports whether a host names the local machine
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// IMDSProvider fetches the credentials of the EC2 instance's role from the instance
+// metadata service, using IMDSv2 session tokens. AWS_EC2_METADATA_DISABLED=true turns it
+// off, and AWS_EC2_METADATA_SERVICE_ENDPOINT overrides the endpoint.
+type IMDSProvider struct{}
+
+// Retrieve implements Provider
+func (IMDSProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return Credentials{}, errNotConfigured
+	}
+	endpoint := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "http://169.254.169.254"
+	}
+	endpoint = strings.TrimRight(endpoint, "/")
+	// Off EC2 nothing answers, so the requests give up quickly
+	client := &http.Client{Timeout: 2 * time.Second}
+
+	tokenReq, err := http.NewRequestWithContext(ctx, "PUT", endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("instance metadata: %w", err)
+	}
+	tokenReq.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := fetch(client, tokenReq)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("instance metadata: %w", err)
+	}
+
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", endpoint+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return fetch(client, req)
+	}
+	roles, err := get("/latest/meta-data/iam/security-credentials/")
+	if err != nil {
+		return Credentials{}, fmt.Errorf("instance metadata: no instance role: %w", err)
+	}
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+	if role == "" {
+		return Credentials{}, fmt.Errorf("instance metadata: no instance role")
+	}
+	body, err := get("/latest/meta-data/iam/security-credentials/" + url.PathEscape(role))
+	if err != nil {
+		return Credentials{}, fmt.Errorf("instance metadata: %w", err)
+	}
+	return parseJSONCredentials("instance metadata", body)
+}
+
+// parseJSONCredentials parses credentials in the format of the container and instance
+// metadata endpoints
+func parseJSONCredentials(source string, body []byte) (Credentials, error) {
+	var resp struct {
+		Code            string    `json:"Code"`
+		Message         string    `json:"Message"`
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return Credentials{}, fmt.Errorf("%s: failed to parse credentials: %w", source, err)
+	}
+	if resp.Code != "" && resp.Code != "Success" {
+		return Credentials{}, fmt.Errorf("%s: %s: %s", source, resp.Code, resp.Message)
+	}
+	if resp.AccessKeyID == "" || resp.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("%s: response holds no credentials", source)
+	}
+	return Credentials{
+		AccessKeyID:     resp.AccessKeyID,
+		SecretAccessKey: resp.SecretAccessKey,
+		SessionToken:    resp.Token,
+		Expires:         resp.Expiration,
+	}, nil
+}
+
+// fetch sends a request and returns the body of a successful response
+func fetch(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: status %d: %s", req.Method, req.URL.Redacted(), resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// firstEnv returns the first of the environment variables that is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
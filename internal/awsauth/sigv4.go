@@ -0,0 +1,195 @@
+package awsauth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// algorithm is the Signature Version 4 algorithm name
+const algorithm = "AWS4-HMAC-SHA256"
+
+// timeFormat is the format of the X-Amz-Date header
+const timeFormat = "20060102T150405Z"
+
+// Signer signs requests to one AWS service in one region with Signature Version 4
+type Signer struct {
+	Credentials Provider
+	Region      string
+	Service     string
+	// Now returns the signing time; time.Now is used when nil
+	Now func() time.Time
+}
+
+// Sign adds the X-Amz-Date, X-Amz-Security-Token and Authorization headers to a request
+// whose body is payload. The host, the content type and every x-amz- header are signed.
+func (s *Signer) Sign(req *http.Request, payload []byte) error {
+	creds, err := s.Credentials.Retrieve(req.Context())
+	if err != nil {
+		return fmt.Errorf("failed to get AWS credentials: %w", err)
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	amzDate := now().UTC().Format(timeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	signedHeaders, canonicalHeaders := canonicalHeaders(req)
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req),
+		canonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := strings.Join([]string{amzDate[:8], s.Region, s.Service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{algorithm, amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), amzDate[:8])
+	for _, part := range []string{s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// canonicalHeaders returns the signed header names and their canonical form, one
+// "name:value" line per header, sorted by name
+func canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for name, header := range req.Header {
+		name = strings.ToLower(name)
+		if name != "content-type" && !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(header))
+		for i, value := range header {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + values[name] + "\n")
+	}
+	return strings.Join(names, ";"), canonical.String()
+}
+
+// canonicalURI returns the request path as AWS signs it: the escaped path, escaped again.
+// A model ID such as anthropic.claude-v2:1 is sent as ...claude-v2%3A1 and signed as
+// ...claude-v2%253A1.
+func canonicalURI(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return escape(path, false)
+}
+
+// canonicalQuery returns the query parameters escaped and sorted by name, then value
+func canonicalQuery(req *http.Request) string {
+	var params []string
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			params = append(params, escape(name, true)+"="+escape(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// escape percent-encodes every byte except the unreserved characters, and slashes
+// unless escapeSlash is set
+func escape(value string, escapeSlash bool) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			escaped.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+// EscapePathSegment escapes a value, such as a model ID or ARN, for use as one segment of
+// a request path
+func EscapePathSegment(value string) string {
+	return escape(value, true)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Transport is an http.RoundTripper that signs every request it sends. Placed under a
+// retry.Transport, every retry is signed afresh, with the current time and credentials.
+type Transport struct {
+	// Base performs the requests; http.DefaultTransport is used when nil
+	Base   http.RoundTripper
+	Signer *Signer
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var payload []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if payload, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		if err := req.Body.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	signed := req.Clone(req.Context())
+	if payload != nil {
+		signed.Body = io.NopCloser(bytes.NewReader(payload))
+	}
+	if err := t.Signer.Sign(signed, payload); err != nil {
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(signed)
+}
@@ -3,28 +3,119 @@ package backend
 import (
 	"context"
 	"fmt"
+	"go-llm-proxy/internal/awsauth"
+	"go-llm-proxy/internal/keys"
+	"go-llm-proxy/internal/retry"
 	"go-llm-proxy/internal/types"
 	"go-llm-proxy/pkg/anthropic"
+	"go-llm-proxy/pkg/azure"
+	"go-llm-proxy/pkg/bedrock"
+	"go-llm-proxy/pkg/gemini"
+	"go-llm-proxy/pkg/ollama"
 	"go-llm-proxy/pkg/openai"
+	"log"
+	"sync/atomic"
+	"time"
 )
 
 // BackendManager manages all available backends
 type BackendManager struct {
 	backends map[types.BackendType]types.BackendHandler
+	// rejectUnsupportedOptions makes ResolveOptions fail instead of dropping options a backend can't honor
+	rejectUnsupportedOptions bool
+	// formatRetries is how many times a request is repeated when its response doesn't match the requested format
+	formatRetries int
+	// retryPolicy limits retries of failed upstream requests, and is passed on to the backends
+	retryPolicy retry.Policy
+	// modelLookup resolves the fallbacks of a model
+	modelLookup ModelLookup
+	// requestTimeout bounds each upstream request unless the model sets its own timeout; zero disables it
+	requestTimeout time.Duration
+
+	// disconnects and timeouts count requests abandoned before the backend finished
+	disconnects int64
+	timeouts    int64
+}
+
+// RequestStats counts upstream requests that did not run to completion
+type RequestStats struct {
+	// Disconnects is the number of requests cancelled because the client went away
+	Disconnects int64 `json:"disconnects"`
+	// Timeouts is the number of requests that ran past their deadline
+	Timeouts int64 `json:"timeouts"`
 }
 
+// defaultFormatRetries is used until SetFormatRetries is called
+const defaultFormatRetries = 2
+
 // NewBackendManager creates a new backend manager
 func NewBackendManager() *BackendManager {
 	return &BackendManager{
-		backends: make(map[types.BackendType]types.BackendHandler),
+		backends:      make(map[types.BackendType]types.BackendHandler),
+		formatRetries: defaultFormatRetries,
+		retryPolicy:   retry.DefaultPolicy(),
 	}
 }
 
 // RegisterBackend registers a new backend
 func (bm *BackendManager) RegisterBackend(backendType types.BackendType, handler types.BackendHandler) {
+	if configurable, ok := handler.(retry.Configurable); ok {
+		configurable.SetRetryPolicy(bm.retryPolicy)
+	}
 	bm.backends[backendType] = handler
 }
 
+// SetRejectUnsupportedOptions sets whether generation options a backend can't honor
+// are rejected (true) or dropped (false)
+func (bm *BackendManager) SetRejectUnsupportedOptions(reject bool) {
+	bm.rejectUnsupportedOptions = reject
+}
+
+// SetFormatRetries sets how many times a request is repeated when its response
+// doesn't match the requested format
+func (bm *BackendManager) SetFormatRetries(retries int) {
+	bm.formatRetries = retries
+}
+
+// SetRetryPolicy sets how failed upstream requests are retried, for all registered backends
+func (bm *BackendManager) SetRetryPolicy(policy retry.Policy) {
+	bm.retryPolicy = policy
+	for _, handler := range bm.backends {
+		if configurable, ok := handler.(retry.Configurable); ok {
+			configurable.SetRetryPolicy(policy)
+		}
+	}
+}
+
+// SetModelLookup sets where the fallback models named in model configurations are looked up
+func (bm *BackendManager) SetModelLookup(lookup ModelLookup) {
+	bm.modelLookup = lookup
+}
+
+// SetRequestTimeout sets the timeout for models that don't configure their own; zero disables it
+func (bm *BackendManager) SetRequestTimeout(timeout time.Duration) {
+	bm.requestTimeout = timeout
+}
+
+// Stats returns the counts of abandoned requests
+func (bm *BackendManager) Stats() RequestStats {
+	return RequestStats{
+		Disconnects: atomic.LoadInt64(&bm.disconnects),
+		Timeouts:    atomic.LoadInt64(&bm.timeouts),
+	}
+}
+
+// KeyStats returns the usage of each API key, by backend
+func (bm *BackendManager) KeyStats() map[types.BackendType][]keys.Stats {
+	stats := make(map[types.BackendType][]keys.Stats)
+	for backendType, handler := range bm.backends {
+		if pooled, ok := handler.(keys.Pooled); ok {
+			stats[backendType] = pooled.KeyStats()
+		}
+	}
+	return stats
+}
+
 // GetBackend returns a backend handler by type
 func (bm *BackendManager) GetBackend(backendType types.BackendType) (types.BackendHandler, bool) {
 	handler, exists := bm.backends[backendType]
@@ -44,55 +135,354 @@ func (bm *BackendManager) GetAvailableBackends() []types.BackendType {
 
 // BackendFactory creates backend handlers
 type BackendFactory struct {
-	anthropicAPIKey string
-	openaiAPIKey    string
+	anthropicAPIKeys []string
+	openaiAPIKeys    []string
+	geminiAPIKeys    []string
+	keySelection     keys.Strategy
+	openaiCompatible []openaiCompatibleBackend
+	azure            *azureResource
+	bedrock          *bedrockRegion
+	ollamaBaseURL    string
+}
+
+// bedrockRegion describes where, and with which credentials, Bedrock requests are sent
+type bedrockRegion struct {
+	region   string
+	profile  string
+	endpoint string
+}
+
+// azureResource describes the Azure OpenAI resource requests are sent to
+type azureResource struct {
+	endpoint   string
+	apiVersion string
+	apiKeys    []string
+}
+
+// openaiCompatibleBackend describes a named backend speaking the OpenAI API at its own base URL
+type openaiCompatibleBackend struct {
+	name    types.BackendType
+	baseURL string
+	apiKeys []string
+	headers map[string]string
+	// streamUsage is whether the server takes stream_options
+	streamUsage bool
 }
 
 // NewBackendFactory creates a new backend factory
 func NewBackendFactory(anthropicAPIKey, openaiAPIKey string) *BackendFactory {
+	return NewBackendFactoryWithKeys([]string{anthropicAPIKey}, []string{openaiAPIKey}, keys.RoundRobin)
+}
+
+// NewBackendFactoryWithKeys creates a backend factory whose backends spread requests over
+// several API keys each, choosing keys with the given strategy
+func NewBackendFactoryWithKeys(anthropicAPIKeys, openaiAPIKeys []string, keySelection keys.Strategy) *BackendFactory {
 	return &BackendFactory{
-		anthropicAPIKey: anthropicAPIKey,
-		openaiAPIKey:    openaiAPIKey,
+		anthropicAPIKeys: anthropicAPIKeys,
+		openaiAPIKeys:    openaiAPIKeys,
+		keySelection:     keySelection,
 	}
 }
 
+// AddOpenAICompatible adds a backend, registered under name, for a server that speaks
+// the OpenAI API at baseURL. The API keys are optional; streamUsage is whether the server
+// reports the token usage of streams when asked with stream_options.
+func (bf *BackendFactory) AddOpenAICompatible(name, baseURL string, apiKeys []string, headers map[string]string, streamUsage bool) {
+	bf.openaiCompatible = append(bf.openaiCompatible, openaiCompatibleBackend{
+		name:        types.BackendType(name),
+		baseURL:     baseURL,
+		apiKeys:     apiKeys,
+		headers:     headers,
+		streamUsage: streamUsage,
+	})
+}
+
+// SetGemini sets the API keys of the Gemini backend
+func (bf *BackendFactory) SetGemini(apiKeys []string) {
+	bf.geminiAPIKeys = apiKeys
+}
+
+// SetAzure sets the Azure OpenAI resource at endpoint, whose deployments are served by
+// the azure backend
+func (bf *BackendFactory) SetAzure(endpoint, apiVersion string, apiKeys []string) {
+	bf.azure = &azureResource{endpoint: endpoint, apiVersion: apiVersion, apiKeys: apiKeys}
+}
+
+// SetBedrock enables the Bedrock backend in region, with credentials from the standard AWS
+// chain for profile. An empty endpoint means the region's bedrock-runtime endpoint.
+func (bf *BackendFactory) SetBedrock(region, profile, endpoint string) {
+	bf.bedrock = &bedrockRegion{region: region, profile: profile, endpoint: endpoint}
+}
+
+// SetOllama sets the upstream Ollama server at baseURL, whose models are served by the
+// ollama backend
+func (bf *BackendFactory) SetOllama(baseURL string) {
+	bf.ollamaBaseURL = baseURL
+}
+
 // CreateBackends creates all available backends
 func (bf *BackendFactory) CreateBackends() *BackendManager {
 	manager := NewBackendManager()
 
-	// Create Anthropic backend if API key is available
-	if bf.anthropicAPIKey != "" {
-		anthropicBackend := anthropic.NewAnthropicBackend(bf.anthropicAPIKey)
+	// Create Anthropic backend if an API key is available; the pool supplies its keys
+	if pool := keys.NewPool(bf.anthropicAPIKeys, bf.keySelection); pool.Len() > 0 {
+		anthropicBackend := anthropic.NewAnthropicBackend("")
+		anthropicBackend.SetKeyPool(pool)
 		manager.RegisterBackend(types.BackendAnthropic, anthropicBackend)
+		logKeyPool(types.BackendAnthropic, pool, bf.keySelection)
 	}
 
-	// Create OpenAI backend if API key is available
-	if bf.openaiAPIKey != "" {
-		openaiBackend := openai.NewOpenAIBackend(bf.openaiAPIKey)
+	// Create OpenAI backend if an API key is available; the pool supplies its keys
+	if pool := keys.NewPool(bf.openaiAPIKeys, bf.keySelection); pool.Len() > 0 {
+		openaiBackend := openai.NewOpenAIBackend("")
+		openaiBackend.SetKeyPool(pool)
 		manager.RegisterBackend(types.BackendOpenAI, openaiBackend)
+		logKeyPool(types.BackendOpenAI, pool, bf.keySelection)
+	}
+
+	// Create Gemini backend if an API key is available; the pool supplies its keys
+	if pool := keys.NewPool(bf.geminiAPIKeys, bf.keySelection); pool.Len() > 0 {
+		geminiBackend := gemini.NewGeminiBackend("")
+		geminiBackend.SetKeyPool(pool)
+		manager.RegisterBackend(types.BackendGemini, geminiBackend)
+		logKeyPool(types.BackendGemini, pool, bf.keySelection)
+	}
+
+	// Create the Azure OpenAI backend if its resource has an API key
+	if bf.azure != nil {
+		if pool := keys.NewPool(bf.azure.apiKeys, bf.keySelection); pool.Len() > 0 {
+			azureBackend := azure.NewAzureBackend("", bf.azure.endpoint, bf.azure.apiVersion)
+			azureBackend.SetKeyPool(pool)
+			manager.RegisterBackend(types.BackendAzure, azureBackend)
+			logKeyPool(types.BackendAzure, pool, bf.keySelection)
+		}
+	}
+
+	// Create the Bedrock backend; its credentials are looked up when requests are signed
+	if bf.bedrock != nil {
+		endpoint := bf.bedrock.endpoint
+		if endpoint == "" {
+			endpoint = bedrock.RuntimeEndpoint(bf.bedrock.region)
+		}
+		credentials := awsauth.DefaultChain(bf.bedrock.profile, bf.bedrock.region)
+		manager.RegisterBackend(types.BackendBedrock, bedrock.NewBedrockBackendWithEndpoint(endpoint, bf.bedrock.region, credentials))
+	}
+
+	// Create the Ollama backend, which needs no key
+	if bf.ollamaBaseURL != "" {
+		manager.RegisterBackend(types.BackendOllama, ollama.NewOllamaBackend(bf.ollamaBaseURL))
+	}
+
+	// Create the OpenAI-compatible backends, which need no key
+	for _, compatible := range bf.openaiCompatible {
+		compatibleBackend := openai.NewOpenAICompatibleBackend(string(compatible.name), compatible.baseURL, compatible.headers)
+		compatibleBackend.SetStreamUsage(compatible.streamUsage)
+		if pool := keys.NewPool(compatible.apiKeys, bf.keySelection); pool.Len() > 0 {
+			compatibleBackend.SetKeyPool(pool)
+			logKeyPool(compatible.name, pool, bf.keySelection)
+		}
+		manager.RegisterBackend(compatible.name, compatibleBackend)
 	}
 
 	return manager
 }
 
-// ProcessRequest processes a request using the appropriate backend
+// logKeyPool logs the size of a backend's key pool when it holds more than one key
+func logKeyPool(backendType types.BackendType, pool *keys.Pool, strategy keys.Strategy) {
+	if pool.Len() > 1 {
+		log.Printf("Spreading %s requests over %d API keys (%s)", backendType, pool.Len(), strategy)
+	}
+}
+
+// ProcessRequest processes a request using the appropriate backend.
+// The request is cancelled when ctx is, and when the model's timeout passes.
+// If the model fails with a transient or provider-side error, its fallbacks are tried in order.
 func (bm *BackendManager) ProcessRequest(ctx context.Context, modelConfig types.ModelConfig, req interface{}) (interface{}, error) {
-	backend, exists := bm.GetBackend(modelConfig.Backend)
-	if !exists {
-		return nil, fmt.Errorf("backend %s not available", modelConfig.Backend)
+	var resp interface{}
+	err := bm.withFallbacks(ctx, modelConfig, req, func(model types.ModelConfig, req interface{}) (bool, error) {
+		modelCtx, cancel := bm.requestContext(ctx, model)
+		defer cancel()
+
+		var err error
+		if resp, err = bm.processRequest(modelCtx, model, req); err != nil {
+			return false, bm.checkAbandoned(modelCtx, model, err)
+		}
+		reportServed(ctx, modelConfig, model)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return resp, nil
+}
 
-	if !backend.IsAvailable() {
-		return nil, fmt.Errorf("backend %s is not available", modelConfig.Backend)
+// processRequest routes a request to the model's backend
+func (bm *BackendManager) processRequest(ctx context.Context, modelConfig types.ModelConfig, req interface{}) (interface{}, error) {
+	backend, err := bm.getGenerativeBackend(modelConfig)
+	if err != nil {
+		return nil, err
+	}
+	if req, err = bm.applyModelDefaults(modelConfig, req); err != nil {
+		return nil, err
 	}
 
 	// Route request based on type
 	switch r := req.(type) {
 	case types.GenerateRequest:
-		return backend.Generate(ctx, r)
+		if r.Format == nil {
+			return backend.Generate(ctx, r)
+		}
+		var resp *types.GenerateResponse
+		err := bm.withFormatRetries(r.Format, func() (string, bool, error) {
+			var err error
+			if resp, err = backend.Generate(ctx, r); err != nil {
+				return "", false, err
+			}
+			return resp.Content, true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
 	case types.ChatRequest:
-		return backend.Chat(ctx, r)
+		if r.Format == nil {
+			return backend.Chat(ctx, r)
+		}
+		var resp *types.ChatResponse
+		err := bm.withFormatRetries(r.Format, func() (string, bool, error) {
+			var err error
+			if resp, err = backend.Chat(ctx, r); err != nil {
+				return "", false, err
+			}
+			// A tool call answers the request instead of the formatted content
+			return resp.Message.Content, len(resp.Message.ToolCalls) == 0, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
 	default:
 		return nil, fmt.Errorf("unsupported request type")
 	}
 }
+
+// ProcessStreamRequest processes a streaming request using the appropriate backend,
+// forwarding each upstream delta to onChunk as it arrives.
+// The stream is cancelled when ctx is, and when the model's timeout passes.
+// Until the first delta is forwarded, failures fall back to the model's fallbacks like ProcessRequest.
+func (bm *BackendManager) ProcessStreamRequest(ctx context.Context, modelConfig types.ModelConfig, req interface{}, onChunk types.StreamCallback) error {
+	return bm.withFallbacks(ctx, modelConfig, req, func(model types.ModelConfig, req interface{}) (bool, error) {
+		modelCtx, cancel := bm.requestContext(ctx, model)
+		defer cancel()
+
+		forwarded := false
+		err := bm.processStreamRequest(modelCtx, model, req, func(chunk types.StreamChunk) error {
+			if !forwarded {
+				forwarded = true
+				reportServed(ctx, modelConfig, model)
+			}
+			return onChunk(chunk)
+		})
+		return forwarded, bm.checkAbandoned(modelCtx, model, err)
+	})
+}
+
+// processStreamRequest routes a streaming request to the model's backend
+func (bm *BackendManager) processStreamRequest(ctx context.Context, modelConfig types.ModelConfig, req interface{}, onChunk types.StreamCallback) error {
+	backend, err := bm.getGenerativeBackend(modelConfig)
+	if err != nil {
+		return err
+	}
+	if req, err = bm.applyModelDefaults(modelConfig, req); err != nil {
+		return err
+	}
+
+	// Route request based on type
+	switch r := req.(type) {
+	case types.GenerateRequest:
+		return bm.stream(ctx, r.Format, func(onChunk types.StreamCallback) error {
+			return backend.GenerateStream(ctx, r, onChunk)
+		}, onChunk)
+	case types.ChatRequest:
+		return bm.stream(ctx, r.Format, func(onChunk types.StreamCallback) error {
+			return backend.ChatStream(ctx, r, onChunk)
+		}, onChunk)
+	default:
+		return fmt.Errorf("unsupported streaming request type")
+	}
+}
+
+// ProcessEmbeddingRequest creates embeddings using the appropriate backend
+func (bm *BackendManager) ProcessEmbeddingRequest(ctx context.Context, modelConfig types.ModelConfig, req types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	if !modelConfig.Embedding {
+		return nil, fmt.Errorf("model %s does not support embeddings", modelConfig.Name)
+	}
+
+	backend, err := bm.getAvailableBackend(modelConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	embedder, ok := backend.(types.EmbeddingHandler)
+	if !ok {
+		return nil, fmt.Errorf("backend %s does not support embeddings", modelConfig.Backend)
+	}
+
+	ctx, cancel := bm.requestContext(ctx, modelConfig)
+	defer cancel()
+
+	resp, err := embedder.Embed(ctx, req)
+	if err != nil {
+		return nil, bm.checkAbandoned(ctx, modelConfig, err)
+	}
+	return resp, nil
+}
+
+// ResolveOptions checks generation options against the model's backend.
+// Options the backend can't honor are dropped, or rejected when configured to do so.
+func (bm *BackendManager) ResolveOptions(modelConfig types.ModelConfig, opts types.GenerationOptions) (types.GenerationOptions, error) {
+	backend, exists := bm.GetBackend(modelConfig.Backend)
+	if !exists {
+		// Missing backends are reported when the request is processed
+		return opts, nil
+	}
+
+	supporter, ok := backend.(types.OptionSupporter)
+	if !ok {
+		return opts, nil
+	}
+
+	for _, name := range opts.Names() {
+		if supporter.SupportsOption(name) {
+			continue
+		}
+		if bm.rejectUnsupportedOptions {
+			return opts, fmt.Errorf("option %s is not supported by the %s backend", name, modelConfig.Backend)
+		}
+		log.Printf("Dropping option %s, which the %s backend does not support", name, modelConfig.Backend)
+		opts = opts.Without(name)
+	}
+	return opts, nil
+}
+
+// getAvailableBackend returns the backend for a model if it is registered and available
+func (bm *BackendManager) getAvailableBackend(modelConfig types.ModelConfig) (types.BackendHandler, error) {
+	backend, exists := bm.GetBackend(modelConfig.Backend)
+	if !exists {
+		return nil, fmt.Errorf("backend %s not available", modelConfig.Backend)
+	}
+
+	if !backend.IsAvailable() {
+		return nil, fmt.Errorf("backend %s is not available", modelConfig.Backend)
+	}
+
+	return backend, nil
+}
+
+// getGenerativeBackend returns the backend for a chat or generate request, rejecting embedding-only models
+func (bm *BackendManager) getGenerativeBackend(modelConfig types.ModelConfig) (types.BackendHandler, error) {
+	if modelConfig.Embedding {
+		return nil, fmt.Errorf("model %s only supports embeddings", modelConfig.Name)
+	}
+	return bm.getAvailableBackend(modelConfig)
+}
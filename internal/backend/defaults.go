@@ -0,0 +1,49 @@
+package backend
+
+import (
+	"go-llm-proxy/internal/types"
+)
+
+// applyModelDefaults fills in what a request leaves unset from the model's system prompt
+// and default options, which virtual models configure
+func (bm *BackendManager) applyModelDefaults(modelConfig types.ModelConfig, req interface{}) (interface{}, error) {
+	hasDefaults := len(modelConfig.Options.Names()) > 0
+	switch r := req.(type) {
+	case types.ChatRequest:
+		if modelConfig.System != "" && !hasSystemMessage(r.Messages) {
+			r.Messages = append([]types.ChatMessage{{Role: "system", Content: modelConfig.System}}, r.Messages...)
+		}
+		if hasDefaults {
+			opts, err := bm.ResolveOptions(modelConfig, r.Options.WithDefaults(modelConfig.Options))
+			if err != nil {
+				return nil, err
+			}
+			r.Options = opts
+		}
+		return r, nil
+	case types.GenerateRequest:
+		if r.System == "" {
+			r.System = modelConfig.System
+		}
+		if hasDefaults {
+			opts, err := bm.ResolveOptions(modelConfig, r.Options.WithDefaults(modelConfig.Options))
+			if err != nil {
+				return nil, err
+			}
+			r.Options = opts
+		}
+		return r, nil
+	default:
+		return req, nil
+	}
+}
+
+// hasSystemMessage reports whether a conversation sets its own system prompt
+func hasSystemMessage(messages []types.ChatMessage) bool {
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			return true
+		}
+	}
+	return false
+}
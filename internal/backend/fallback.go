@@ -0,0 +1,150 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+)
+
+// ModelLookup finds model configurations by proxy model name
+type ModelLookup interface {
+	GetModel(name string) (types.ModelConfig, bool)
+}
+
+// ServedModelHeader is the response header naming the model that served a request
+const ServedModelHeader = "X-Served-Model"
+
+// servedModelKey is the context key of the callback told which model served a request
+type servedModelKey struct{}
+
+// WithServedModelCallback returns a context whose requests report the name of the model
+// that served them, which differs from the requested model when a fallback was used.
+// For streams the callback runs before the first chunk is forwarded.
+func WithServedModelCallback(ctx context.Context, callback func(model string)) context.Context {
+	return context.WithValue(ctx, servedModelKey{}, callback)
+}
+
+// fallbackAttempt sends a request to one model of a fallback chain. It reports whether the
+// response was committed to the client, after which no other model may be tried.
+type fallbackAttempt func(model types.ModelConfig, req interface{}) (committed bool, err error)
+
+// withFallbacks sends a request to the model and, when that fails in a way another
+// provider might not, to each of the model's fallbacks in turn. Fallbacks of fallbacks
+// are not followed. If every model fails, the requested model's error is returned.
+func (bm *BackendManager) withFallbacks(ctx context.Context, modelConfig types.ModelConfig, req interface{}, attempt fallbackAttempt) error {
+	committed, err := attempt(modelConfig, req)
+	if err == nil || committed {
+		return err
+	}
+
+	failed, lastErr := modelConfig, err
+	for _, name := range modelConfig.Fallbacks {
+		if !bm.canFallBack(ctx, failed, lastErr) {
+			break
+		}
+
+		fallback, fallbackReq, adaptErr := bm.fallbackRequest(name, req)
+		if adaptErr != nil {
+			log.Printf("Skipping fallback %s for %s: %v", name, modelConfig.Name, adaptErr)
+			continue
+		}
+
+		log.Printf("Request to %s failed, falling back to %s: %v", failed.Name, fallback.Name, lastErr)
+		committed, lastErr = attempt(fallback, fallbackReq)
+		if lastErr == nil || committed {
+			return lastErr
+		}
+		failed = fallback
+	}
+	return err
+}
+
+// canFallBack reports whether a failed request may be sent to a fallback model: the client
+// must still be waiting, and the failure must be one that another provider may not share
+func (bm *BackendManager) canFallBack(ctx context.Context, failed types.ModelConfig, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if backend, exists := bm.GetBackend(failed.Backend); !exists || !backend.IsAvailable() {
+		return true
+	}
+	return retry.Retryable(err) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// fallbackRequest looks up a fallback model and adapts the request to it
+func (bm *BackendManager) fallbackRequest(name string, req interface{}) (types.ModelConfig, interface{}, error) {
+	if bm.modelLookup == nil {
+		return types.ModelConfig{}, nil, fmt.Errorf("no model registry is configured")
+	}
+	fallback, exists := bm.modelLookup.GetModel(name)
+	if !exists {
+		return types.ModelConfig{}, nil, fmt.Errorf("model not found")
+	}
+	if fallback.Embedding {
+		return types.ModelConfig{}, nil, fmt.Errorf("model only supports embeddings")
+	}
+	if _, err := bm.getAvailableBackend(fallback); err != nil {
+		return types.ModelConfig{}, nil, err
+	}
+
+	switch r := req.(type) {
+	case types.ChatRequest:
+		if err := types.ValidateImages(fallback, r.Messages); err != nil {
+			return types.ModelConfig{}, nil, err
+		}
+		opts, err := bm.ResolveOptions(fallback, clientOptions(r.ClientOptions, r.Options))
+		if err != nil {
+			return types.ModelConfig{}, nil, err
+		}
+		r.Model = fallback.BackendModel
+		r.MaxTokens = fallbackMaxTokens(fallback, r.MaxTokens)
+		r.Options = opts
+		return fallback, r, nil
+	case types.GenerateRequest:
+		if err := types.ValidateImages(fallback, r.ToChatRequest().Messages); err != nil {
+			return types.ModelConfig{}, nil, err
+		}
+		opts, err := bm.ResolveOptions(fallback, clientOptions(r.ClientOptions, r.Options))
+		if err != nil {
+			return types.ModelConfig{}, nil, err
+		}
+		r.Model = fallback.BackendModel
+		r.MaxTokens = fallbackMaxTokens(fallback, r.MaxTokens)
+		r.Options = opts
+		return fallback, r, nil
+	default:
+		return types.ModelConfig{}, nil, fmt.Errorf("unsupported request type")
+	}
+}
+
+// clientOptions returns the options a request came with, which are resolved afresh for
+// each fallback model so that options the first backend dropped can reach the others.
+// Requests built without client options have only their resolved ones.
+func clientOptions(client, resolved types.GenerationOptions) types.GenerationOptions {
+	if len(client.Names()) == 0 {
+		return resolved
+	}
+	return client
+}
+
+// fallbackMaxTokens keeps a request's token limit within what the fallback model allows
+func fallbackMaxTokens(fallback types.ModelConfig, maxTokens int) int {
+	if fallback.MaxTokens > 0 && maxTokens > fallback.MaxTokens {
+		return fallback.MaxTokens
+	}
+	return maxTokens
+}
+
+// reportServed tells the request's callback which model served it, and logs fallbacks
+func reportServed(ctx context.Context, requested, served types.ModelConfig) {
+	if requested.Name != served.Name {
+		log.Printf("Request for %s served by fallback %s", requested.Name, served.Name)
+	}
+	if callback, ok := ctx.Value(servedModelKey{}).(func(string)); ok {
+		callback(served.Name)
+	}
+}
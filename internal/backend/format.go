@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"go-llm-proxy/internal/types"
+)
+
+// withFormatRetries runs attempt until its response matches the requested format.
+// attempt returns the response content and whether it should be validated; backend
+// errors are returned as they are, without retrying.
+func (bm *BackendManager) withFormatRetries(format *types.ResponseFormat, attempt func() (string, bool, error)) error {
+	attempts := bm.formatRetries + 1
+	var mismatch error
+	for i := 1; i <= attempts; i++ {
+		content, validate, err := attempt()
+		if err != nil {
+			return err
+		}
+		if !validate {
+			return nil
+		}
+		if mismatch = format.Validate(content); mismatch == nil {
+			return nil
+		}
+		log.Printf("Response did not match the requested format (attempt %d of %d): %v", i, attempts, mismatch)
+	}
+	return fmt.Errorf("response did not match the requested format after %d attempts: %w", attempts, mismatch)
+}
+
+// streamWithFormat buffers a stream until it is complete so the content can be validated
+// and the request retried before anything reaches the client, then replays the chunks
+func (bm *BackendManager) streamWithFormat(format *types.ResponseFormat, stream func(types.StreamCallback) error, onChunk types.StreamCallback) error {
+	var chunks []types.StreamChunk
+	err := bm.withFormatRetries(format, func() (string, bool, error) {
+		chunks = nil
+		var content strings.Builder
+		hasToolCalls := false
+		err := stream(func(chunk types.StreamChunk) error {
+			chunks = append(chunks, chunk)
+			content.WriteString(chunk.Content)
+			if len(chunk.ToolCalls) > 0 {
+				hasToolCalls = true
+			}
+			return nil
+		})
+		return content.String(), !hasToolCalls, err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, chunk := range chunks {
+		if err := onChunk(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
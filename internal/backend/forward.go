@@ -0,0 +1,62 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go-llm-proxy/internal/types"
+)
+
+// Forwarder is implemented by backends that speak the Ollama API themselves, such as
+// an upstream Ollama server, so that Ollama API requests can be passed through unchanged
+type Forwarder interface {
+	// Forward sends a request to path and returns the response whatever its status
+	Forward(ctx context.Context, method, path string, body []byte) (*http.Response, error)
+}
+
+// CanForward reports whether Ollama API requests for a model can be passed through to
+// its backend. Models that add a system prompt or default options, or that have
+// fallbacks, are served by converting their requests instead.
+func (bm *BackendManager) CanForward(modelConfig types.ModelConfig) bool {
+	if modelConfig.System != "" || len(modelConfig.Options.Names()) > 0 || len(modelConfig.Fallbacks) > 0 {
+		return false
+	}
+	backend, exists := bm.GetBackend(modelConfig.Backend)
+	if !exists || !backend.IsAvailable() {
+		return false
+	}
+	_, ok := backend.(Forwarder)
+	return ok
+}
+
+// ForwardRequest passes an Ollama API request for a model through to its backend and
+// hands the response to respond, within the model's timeout. The response body is
+// closed once respond returns.
+func (bm *BackendManager) ForwardRequest(ctx context.Context, modelConfig types.ModelConfig, path string, body []byte, respond func(*http.Response) error) error {
+	backend, err := bm.getAvailableBackend(modelConfig)
+	if err != nil {
+		return err
+	}
+	forwarder, ok := backend.(Forwarder)
+	if !ok {
+		return fmt.Errorf("backend %s does not speak the Ollama API", modelConfig.Backend)
+	}
+
+	modelCtx, cancel := bm.requestContext(ctx, modelConfig)
+	defer cancel()
+
+	resp, err := forwarder.Forward(modelCtx, http.MethodPost, path, body)
+	if err != nil {
+		return bm.checkAbandoned(modelCtx, modelConfig, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	reportServed(ctx, modelConfig, modelConfig)
+	return bm.checkAbandoned(modelCtx, modelConfig, respond(resp))
+}
@@ -0,0 +1,49 @@
+package backend
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+)
+
+// stream runs a backend stream, retrying transient failures and validating the
+// response format when one was requested
+func (bm *BackendManager) stream(ctx context.Context, format *types.ResponseFormat, stream func(types.StreamCallback) error, onChunk types.StreamCallback) error {
+	retrying := func(onChunk types.StreamCallback) error {
+		return bm.streamWithRetries(ctx, stream, onChunk)
+	}
+	if format != nil {
+		return bm.streamWithFormat(format, retrying, onChunk)
+	}
+	return retrying(onChunk)
+}
+
+// streamWithRetries retries a stream that failed with a transient error, but only while
+// nothing has been forwarded; once the client has seen part of a response, a retry would
+// send it a second, different one.
+// Failures before the stream starts are already retried by the backends' HTTP transport.
+func (bm *BackendManager) streamWithRetries(ctx context.Context, stream func(types.StreamCallback) error, onChunk types.StreamCallback) error {
+	start := time.Now()
+	forwarded := false
+	for attempt := 1; ; attempt++ {
+		err := stream(func(chunk types.StreamChunk) error {
+			forwarded = true
+			return onChunk(chunk)
+		})
+		if err == nil || forwarded || !retry.IsTemporary(err) {
+			return err
+		}
+
+		delay := bm.retryPolicy.Backoff(attempt)
+		if !bm.retryPolicy.Allows(attempt, start, delay) {
+			return err
+		}
+		log.Printf("Retrying stream in %v after %v (attempt %d of %d)", delay.Round(time.Millisecond), err, attempt+1, bm.retryPolicy.MaxAttempts)
+		if waitErr := retry.Wait(ctx, delay); waitErr != nil {
+			return err
+		}
+	}
+}
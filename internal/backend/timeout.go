@@ -0,0 +1,42 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"go-llm-proxy/internal/types"
+)
+
+// requestContext bounds a request by the model's timeout, or the default one when the model has none
+func (bm *BackendManager) requestContext(ctx context.Context, modelConfig types.ModelConfig) (context.Context, context.CancelFunc) {
+	timeout := bm.requestTimeout
+	if modelConfig.Timeout > 0 {
+		timeout = modelConfig.Timeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// checkAbandoned logs and counts requests that failed because the client disconnected
+// or the deadline passed, and names the timeout in the error
+func (bm *BackendManager) checkAbandoned(ctx context.Context, modelConfig types.ModelConfig, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		atomic.AddInt64(&bm.disconnects, 1)
+		log.Printf("Client disconnected, abandoned request to %s", modelConfig.Name)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		atomic.AddInt64(&bm.timeouts, 1)
+		log.Printf("Request to %s timed out", modelConfig.Name)
+		return fmt.Errorf("request to %s timed out: %w", modelConfig.Name, err)
+	}
+	return err
+}
@@ -1,9 +1,24 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
+	"time"
+
+	"go-llm-proxy/internal/keys"
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+)
+
+// Policies for generation options a backend can't honor
+const (
+	UnsupportedOptionsDrop   = "drop"
+	UnsupportedOptionsReject = "reject"
 )
 
 // ModelFilterConfig holds configuration for filtering models from APIs
@@ -17,40 +32,293 @@ type ModelFilterConfig struct {
 type ModelFilters struct {
 	Anthropic ModelFilterConfig `yaml:"anthropic"`
 	OpenAI    ModelFilterConfig `yaml:"openai"`
+	Gemini    ModelFilterConfig `yaml:"gemini"`
+}
+
+// OpenAICompatibleConfig defines a named backend that speaks the OpenAI API at its own
+// base URL, such as vLLM, LM Studio, llama.cpp server, Groq or OpenRouter. Its name is
+// the backend name models and embedding models refer to.
+type OpenAICompatibleConfig struct {
+	Name string `yaml:"name"`
+	// BaseURL is the API root the /chat/completions and /models paths are appended to,
+	// usually ending in /v1
+	BaseURL string `yaml:"base_url"`
+	// APIKey and APIKeys are optional, for servers that require no key
+	APIKey  string   `yaml:"api_key"`
+	APIKeys []string `yaml:"api_keys"`
+	// Headers are sent with every request, such as OpenRouter's HTTP-Referer
+	Headers map[string]string `yaml:"headers"`
+	// StreamUsage asks streams for their token usage with stream_options, for servers
+	// that accept it
+	StreamUsage bool `yaml:"stream_usage"`
+	// ModelPrefix is put in front of the listed model IDs to form the proxy model names,
+	// keeping them apart from other backends' models
+	ModelPrefix     string   `yaml:"model_prefix"`
+	IncludePatterns []string `yaml:"include_patterns"`
+	ExcludePatterns []string `yaml:"exclude_patterns"`
+}
+
+// Keys returns every configured API key of the backend
+func (o OpenAICompatibleConfig) Keys() []string {
+	return append([]string{o.APIKey}, o.APIKeys...)
+}
+
+// ModelFilter returns the filter for the models the backend lists
+func (o OpenAICompatibleConfig) ModelFilter() ModelFilterConfig {
+	return ModelFilterConfig{Enabled: true, IncludePatterns: o.IncludePatterns, ExcludePatterns: o.ExcludePatterns}
+}
+
+// AzureOpenAIConfig configures the Azure OpenAI backend, which serves the deployments of
+// one Azure OpenAI resource
+type AzureOpenAIConfig struct {
+	// Endpoint is the resource URL, such as https://my-resource.openai.azure.com
+	Endpoint string   `yaml:"endpoint"`
+	APIKey   string   `yaml:"api_key"`
+	APIKeys  []string `yaml:"api_keys"`
+	// APIVersion is the api-version query parameter sent with every request
+	APIVersion string `yaml:"api_version"`
+	// Deployments maps proxy model names to deployment names. Deployments without a
+	// model name are listed under their own name.
+	Deployments     map[string]string `yaml:"deployments"`
+	IncludePatterns []string          `yaml:"include_patterns"`
+	ExcludePatterns []string          `yaml:"exclude_patterns"`
+}
+
+// Enabled reports whether the Azure OpenAI backend is configured
+func (a AzureOpenAIConfig) Enabled() bool {
+	return a.Endpoint != "" && a.APIKey != ""
+}
+
+// Keys returns every configured API key of the resource
+func (a AzureOpenAIConfig) Keys() []string {
+	return append([]string{a.APIKey}, a.APIKeys...)
+}
+
+// ModelFilter returns the filter for the deployments the resource lists
+func (a AzureOpenAIConfig) ModelFilter() ModelFilterConfig {
+	return ModelFilterConfig{Enabled: true, IncludePatterns: a.IncludePatterns, ExcludePatterns: a.ExcludePatterns}
+}
+
+// BedrockConfig configures the Bedrock backend, which serves Anthropic models through
+// Amazon Bedrock, signing requests with credentials from the standard AWS chain
+type BedrockConfig struct {
+	// Region enables the backend, such as us-east-1
+	Region string `yaml:"region"`
+	// Profile names the shared credentials profile; AWS_PROFILE, or the default profile, when empty
+	Profile string `yaml:"profile"`
+	// RuntimeEndpoint and ControlEndpoint replace the region's bedrock-runtime and bedrock
+	// endpoints, such as with VPC endpoints
+	RuntimeEndpoint string `yaml:"runtime_endpoint"`
+	ControlEndpoint string `yaml:"control_endpoint"`
+	// ModelPrefix is put in front of the model names, keeping them apart from other
+	// backends' models
+	ModelPrefix     string   `yaml:"model_prefix"`
+	IncludePatterns []string `yaml:"include_patterns"`
+	ExcludePatterns []string `yaml:"exclude_patterns"`
+}
+
+// Enabled reports whether the Bedrock backend is configured
+func (b BedrockConfig) Enabled() bool {
+	return b.Region != ""
+}
+
+// ControlURL returns the endpoint the Bedrock models are listed from
+func (b BedrockConfig) ControlURL() string {
+	if b.ControlEndpoint != "" {
+		return b.ControlEndpoint
+	}
+	return "https://bedrock." + b.Region + ".amazonaws.com"
+}
+
+// ModelFilter returns the filter for the Bedrock model IDs listed
+func (b BedrockConfig) ModelFilter() ModelFilterConfig {
+	return ModelFilterConfig{Enabled: true, IncludePatterns: b.IncludePatterns, ExcludePatterns: b.ExcludePatterns}
+}
+
+// OllamaConfig configures the Ollama backend, an upstream Ollama server whose models are
+// served alongside the other backends'. Ollama API requests for its models are passed
+// through to it unchanged.
+type OllamaConfig struct {
+	// BaseURL is the server's root URL, such as http://localhost:11434
+	BaseURL string `yaml:"base_url"`
+	// ModelPrefix is put in front of the listed model names, keeping them apart from
+	// other backends' models
+	ModelPrefix     string   `yaml:"model_prefix"`
+	IncludePatterns []string `yaml:"include_patterns"`
+	ExcludePatterns []string `yaml:"exclude_patterns"`
+}
+
+// Enabled reports whether the Ollama backend is configured
+func (o OllamaConfig) Enabled() bool {
+	return o.BaseURL != ""
+}
+
+// ModelFilter returns the filter for the models the server lists
+func (o OllamaConfig) ModelFilter() ModelFilterConfig {
+	return ModelFilterConfig{Enabled: true, IncludePatterns: o.IncludePatterns, ExcludePatterns: o.ExcludePatterns}
+}
+
+// bedrockRegionPattern matches AWS region names
+var bedrockRegionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+// backendNamePattern matches the names openai_compatible backends may take
+var backendNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// EmbeddingModelConfig maps an embedding model name exposed by the proxy to an upstream model
+type EmbeddingModelConfig struct {
+	Name         string `yaml:"name"`
+	Backend      string `yaml:"backend"`
+	BackendModel string `yaml:"backend_model"`
+}
+
+// VirtualModelConfig defines a model the proxy serves under its own name, such as an
+// Ollama name clients hardcode, with its own display name, system prompt and defaults
+type VirtualModelConfig struct {
+	Name        string `yaml:"name"`
+	DisplayName string `yaml:"display_name"`
+	// Target is the proxy model, or upstream model ID, that serves the virtual model
+	Target string `yaml:"target"`
+	// Backend and BackendModel name the upstream model directly, instead of Target
+	Backend      string `yaml:"backend"`
+	BackendModel string `yaml:"backend_model"`
+	// System is the system prompt for requests that don't bring their own
+	System string `yaml:"system"`
+	// MaxTokens limits the output of requests that set no limit themselves
+	MaxTokens int `yaml:"max_tokens"`
+	// Options are Ollama generation options applied when a request leaves them unset
+	Options map[string]interface{} `yaml:"options"`
+}
+
+// GenerationOptions parses the model's default options
+func (v VirtualModelConfig) GenerationOptions() (types.GenerationOptions, error) {
+	// YAML decodes whole numbers as ints, while the options parser expects JSON numbers
+	options := make(map[string]interface{}, len(v.Options))
+	for name, value := range v.Options {
+		if number, ok := value.(int); ok {
+			value = float64(number)
+		}
+		options[name] = value
+	}
+	opts, err := types.ParseOllamaOptions(options)
+	if err != nil {
+		return types.GenerationOptions{}, err
+	}
+	if opts.NumPredict != nil {
+		return types.GenerationOptions{}, fmt.Errorf("set max_tokens instead of the %s option", types.OptionNumPredict)
+	}
+	return opts, nil
 }
 
 // Config holds all configuration for the proxy
 type Config struct {
 	// Server configuration
-	Port    string `json:"port"`
-	GinMode string `json:"gin_mode"`
+	Port    string `yaml:"port"`
+	GinMode string `yaml:"gin_mode"`
 
 	// API Keys
-	AnthropicAPIKey string `json:"anthropic_api_key"`
-	OpenAIAPIKey    string `json:"openai_api_key"`
+	AnthropicAPIKey string `yaml:"anthropic_api_key"`
+	OpenAIAPIKey    string `yaml:"openai_api_key"`
+	GeminiAPIKey    string `yaml:"gemini_api_key"`
+
+	// Additional API keys that requests are spread over along with the keys above
+	AnthropicAPIKeys []string `yaml:"anthropic_api_keys"`
+	OpenAIAPIKeys    []string `yaml:"openai_api_keys"`
+	GeminiAPIKeys    []string `yaml:"gemini_api_keys"`
+
+	// KeySelection is "round_robin" or "least_used", choosing the key of a backend's pool for each request
+	KeySelection string `yaml:"key_selection"`
 
 	// Model configuration
-	DefaultMaxTokens int `json:"default_max_tokens"`
+	DefaultMaxTokens int `yaml:"default_max_tokens"`
+
+	// UnsupportedOptions is "drop" to ignore generation options a backend can't honor,
+	// or "reject" to fail the request
+	UnsupportedOptions string `yaml:"unsupported_options"`
+
+	// FormatRetries is how many times a request is repeated when its response
+	// doesn't match the requested format
+	FormatRetries int `yaml:"format_retries"`
+
+	// RequestTimeout bounds each upstream request, in seconds; 0 disables it
+	RequestTimeout int `yaml:"request_timeout_seconds"`
+
+	// RetryMaxAttempts is the total number of attempts for an upstream request; 1 disables retries
+	RetryMaxAttempts int `yaml:"retry_max_attempts"`
+
+	// RetryMaxElapsed is the time, in seconds, after which a failed request is no longer retried; 0 means no limit
+	RetryMaxElapsed int `yaml:"retry_max_elapsed_seconds"`
+
+	// ModelRefresh is how often, in seconds, the model lists are fetched again; 0 disables refreshing
+	ModelRefresh int `yaml:"model_refresh_seconds"`
+
+	// ModelCachePath is the file the last fetched model lists are kept in, for starting
+	// when the backends can't be reached; empty disables the cache
+	ModelCachePath string `yaml:"model_cache_path"`
+
+	// ConfigWatch is how often, in seconds, the configuration file is checked for changes
+	// and reloaded; 0 disables watching
+	ConfigWatch int `yaml:"config_watch_seconds"`
 
-	// Streaming configuration
-	StreamingChunkSize int `json:"streaming_chunk_size"`
-	StreamingDelay     int `json:"streaming_delay_ms"`
+	// AdminToken is the bearer token the admin endpoints require; without one they only
+	// accept requests from the loopback interface
+	AdminToken string `yaml:"admin_token"`
+
+	// ModelTimeouts overrides RequestTimeout for individual models, keyed by model name
+	ModelTimeouts map[string]time.Duration `yaml:"model_timeouts"`
+
+	// ModelFallbacks lists, for a model name, the models that serve its requests when it fails
+	ModelFallbacks map[string][]string `yaml:"model_fallbacks"`
 
 	// Model filtering configuration
 	ModelFilters ModelFilters `yaml:"model_filters"`
+
+	// AzureOpenAI configures the Azure OpenAI backend
+	AzureOpenAI AzureOpenAIConfig `yaml:"azure_openai"`
+
+	// Bedrock configures the Bedrock backend
+	Bedrock BedrockConfig `yaml:"bedrock"`
+
+	// Ollama configures the upstream Ollama server
+	Ollama OllamaConfig `yaml:"ollama"`
+
+	// OpenAICompatible lists the named backends that speak the OpenAI API at other base URLs
+	OpenAICompatible []OpenAICompatibleConfig `yaml:"openai_compatible"`
+
+	// Embedding models exposed through /api/embeddings and /api/embed
+	EmbeddingModels []EmbeddingModelConfig `yaml:"embedding_models"`
+
+	// Virtual models served alongside the fetched ones
+	Models []VirtualModelConfig `yaml:"models"`
+
+	// Path is the configuration file the settings were loaded from, if any
+	Path string `yaml:"-"`
 }
 
-// LoadConfig loads configuration from environment variables
-func LoadConfig() *Config {
-	config := &Config{
-		// Default values
-		Port:               GetEnv("PORT", "11434"),
-		GinMode:            GetEnv("GIN_MODE", "release"),
-		AnthropicAPIKey:    GetEnv("ANTHROPIC_API_KEY", ""),
-		OpenAIAPIKey:       GetEnv("OPENAI_API_KEY", ""),
-		DefaultMaxTokens:   GetEnvInt("DEFAULT_MAX_TOKENS", 4096),
-		StreamingChunkSize: GetEnvInt("STREAMING_CHUNK_SIZE", 3),
-		StreamingDelay:     GetEnvInt("STREAMING_DELAY_MS", 50),
+// defaultModelCachePath returns the model catalog cache in the user's cache directory,
+// or no path when there is none
+func defaultModelCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-llm-proxy", "models.json")
+}
+
+// Default returns the configuration used for every setting that isn't configured
+func Default() *Config {
+	return &Config{
+		Port:               "11434",
+		GinMode:            "release",
+		KeySelection:       string(keys.RoundRobin),
+		DefaultMaxTokens:   4096,
+		UnsupportedOptions: UnsupportedOptionsDrop,
+		FormatRetries:      2,
+		RequestTimeout:     600,
+		RetryMaxAttempts:   3,
+		RetryMaxElapsed:    30,
+		ModelRefresh:       3600,
+		ModelCachePath:     defaultModelCachePath(),
+		AzureOpenAI:        AzureOpenAIConfig{APIVersion: "2024-10-21"},
 		ModelFilters: ModelFilters{
 			Anthropic: ModelFilterConfig{
 				Enabled:         true,
@@ -62,10 +330,26 @@ func LoadConfig() *Config {
 				IncludePatterns: []string{"gpt-*"},
 				ExcludePatterns: []string{},
 			},
+			Gemini: ModelFilterConfig{
+				Enabled:         true,
+				IncludePatterns: []string{"gemini-*"},
+				ExcludePatterns: []string{},
+			},
 		},
+		EmbeddingModels: DefaultEmbeddingModels(),
 	}
+}
 
-	return config
+// DefaultEmbeddingModels returns the embedding models registered when config.yaml doesn't list any.
+// Common Ollama embedding model names are mapped to OpenAI embedding models.
+func DefaultEmbeddingModels() []EmbeddingModelConfig {
+	return []EmbeddingModelConfig{
+		{Name: "nomic-embed-text", Backend: "openai", BackendModel: "text-embedding-3-small"},
+		{Name: "all-minilm", Backend: "openai", BackendModel: "text-embedding-3-small"},
+		{Name: "mxbai-embed-large", Backend: "openai", BackendModel: "text-embedding-3-large"},
+		{Name: "text-embedding-3-small", Backend: "openai", BackendModel: "text-embedding-3-small"},
+		{Name: "text-embedding-3-large", Backend: "openai", BackendModel: "text-embedding-3-large"},
+	}
 }
 
 // GetEnv gets an environment variable with a default value
@@ -88,7 +372,7 @@ func GetEnvInt(key string, defaultValue int) int {
 
 // IsValid checks if the configuration is valid
 func (c *Config) IsValid() error {
-	if c.AnthropicAPIKey == "" && c.OpenAIAPIKey == "" {
+	if c.AnthropicAPIKey == "" && c.OpenAIAPIKey == "" && c.GeminiAPIKey == "" && !c.AzureOpenAI.Enabled() && !c.Bedrock.Enabled() && !c.Ollama.Enabled() && len(c.OpenAICompatible) == 0 {
 		return fmt.Errorf("at least one API key must be provided")
 	}
 
@@ -96,9 +380,215 @@ func (c *Config) IsValid() error {
 		return fmt.Errorf("port must be specified")
 	}
 
+	switch c.UnsupportedOptions {
+	case "", UnsupportedOptionsDrop, UnsupportedOptionsReject:
+	default:
+		return fmt.Errorf("unsupported_options must be %q or %q, got %q", UnsupportedOptionsDrop, UnsupportedOptionsReject, c.UnsupportedOptions)
+	}
+
+	if _, err := keys.ParseStrategy(c.KeySelection); err != nil {
+		return err
+	}
+
+	if c.FormatRetries < 0 {
+		return fmt.Errorf("format_retries must not be negative")
+	}
+
+	if c.RequestTimeout < 0 {
+		return fmt.Errorf("request_timeout_seconds must not be negative")
+	}
+
+	if c.RetryMaxAttempts < 0 {
+		return fmt.Errorf("retry_max_attempts must not be negative")
+	}
+
+	if c.RetryMaxElapsed < 0 {
+		return fmt.Errorf("retry_max_elapsed_seconds must not be negative")
+	}
+
+	for model, timeout := range c.ModelTimeouts {
+		if timeout <= 0 {
+			return fmt.Errorf("model_timeouts: timeout for %s must be positive", model)
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, model := range c.Models {
+		if model.Name == "" {
+			return fmt.Errorf("models: every model needs a name")
+		}
+		if names[model.Name] {
+			return fmt.Errorf("models: %s is defined more than once", model.Name)
+		}
+		names[model.Name] = true
+		if (model.Target == "") == (model.BackendModel == "") {
+			return fmt.Errorf("models: %s must set either target or backend and backend_model", model.Name)
+		}
+		if model.BackendModel != "" && model.Backend == "" {
+			return fmt.Errorf("models: %s must set the backend of backend_model", model.Name)
+		}
+		if model.MaxTokens < 0 {
+			return fmt.Errorf("models: max_tokens of %s must not be negative", model.Name)
+		}
+		if _, err := model.GenerationOptions(); err != nil {
+			return fmt.Errorf("models: options of %s: %w", model.Name, err)
+		}
+	}
+
+	for model, fallbacks := range c.ModelFallbacks {
+		for _, fallback := range fallbacks {
+			if fallback == model {
+				return fmt.Errorf("model_fallbacks: %s cannot fall back to itself", model)
+			}
+		}
+	}
+
 	return nil
 }
 
+// Validate checks a loaded configuration strictly: everything IsValid checks, and that
+// every setting is in range. All problems found are reported, one per line.
+func (c *Config) Validate() error {
+	var errs []error
+	if err := c.IsValid(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if port, err := strconv.Atoi(c.Port); c.Port != "" && (err != nil || port < 1 || port > 65535) {
+		errs = append(errs, fmt.Errorf("port must be a number between 1 and 65535, got %q", c.Port))
+	}
+	switch c.GinMode {
+	case "debug", "release", "test":
+	default:
+		errs = append(errs, fmt.Errorf("gin_mode must be debug, release or test, got %q", c.GinMode))
+	}
+	if c.DefaultMaxTokens <= 0 {
+		errs = append(errs, fmt.Errorf("default_max_tokens must be positive"))
+	}
+	if c.ModelRefresh < 0 {
+		errs = append(errs, fmt.Errorf("model_refresh_seconds must not be negative"))
+	}
+	if c.ConfigWatch < 0 {
+		errs = append(errs, fmt.Errorf("config_watch_seconds must not be negative"))
+	}
+
+	for backend, filter := range map[string]ModelFilterConfig{"anthropic": c.ModelFilters.Anthropic, "openai": c.ModelFilters.OpenAI, "gemini": c.ModelFilters.Gemini} {
+		for _, pattern := range append(append([]string{}, filter.IncludePatterns...), filter.ExcludePatterns...) {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("model_filters.%s: invalid pattern %q", backend, pattern))
+			}
+		}
+	}
+
+	if azure := c.AzureOpenAI; azure.Endpoint != "" || azure.APIKey != "" || len(azure.APIKeys) > 0 || len(azure.Deployments) > 0 {
+		if u, err := url.Parse(azure.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("azure_openai: endpoint must be an http or https URL, got %q", azure.Endpoint))
+		}
+		if azure.APIKey == "" {
+			errs = append(errs, fmt.Errorf("azure_openai: api_key is required"))
+		}
+		if azure.APIVersion == "" {
+			errs = append(errs, fmt.Errorf("azure_openai: api_version is required"))
+		}
+		for model, deployment := range azure.Deployments {
+			if model == "" || deployment == "" {
+				errs = append(errs, fmt.Errorf("azure_openai: deployments: model %q needs a model name and a deployment", model))
+			}
+		}
+		for _, pattern := range append(append([]string{}, azure.IncludePatterns...), azure.ExcludePatterns...) {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("azure_openai: invalid pattern %q", pattern))
+			}
+		}
+	}
+
+	if bedrock := c.Bedrock; bedrock.Enabled() || bedrock.Profile != "" || bedrock.RuntimeEndpoint != "" || bedrock.ControlEndpoint != "" {
+		if !bedrockRegionPattern.MatchString(bedrock.Region) {
+			errs = append(errs, fmt.Errorf("bedrock: region must be an AWS region such as us-east-1, got %q", bedrock.Region))
+		}
+		for name, endpoint := range map[string]string{"runtime_endpoint": bedrock.RuntimeEndpoint, "control_endpoint": bedrock.ControlEndpoint} {
+			if u, err := url.Parse(endpoint); endpoint != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+				errs = append(errs, fmt.Errorf("bedrock: %s must be an http or https URL, got %q", name, endpoint))
+			}
+		}
+		for _, pattern := range append(append([]string{}, bedrock.IncludePatterns...), bedrock.ExcludePatterns...) {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("bedrock: invalid pattern %q", pattern))
+			}
+		}
+	}
+
+	if ollama := c.Ollama; ollama.Enabled() || ollama.ModelPrefix != "" || len(ollama.IncludePatterns) > 0 || len(ollama.ExcludePatterns) > 0 {
+		if u, err := url.Parse(ollama.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("ollama: base_url must be an http or https URL, got %q", ollama.BaseURL))
+		}
+		for _, pattern := range append(append([]string{}, ollama.IncludePatterns...), ollama.ExcludePatterns...) {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("ollama: invalid pattern %q", pattern))
+			}
+		}
+	}
+
+	compatibleNames := make(map[string]bool)
+	for i, backend := range c.OpenAICompatible {
+		switch {
+		case !backendNamePattern.MatchString(backend.Name):
+			errs = append(errs, fmt.Errorf("openai_compatible[%d]: name must be lower-case letters, digits, '.', '_' or '-', got %q", i, backend.Name))
+		case backend.Name == string(types.BackendAnthropic) || backend.Name == string(types.BackendOpenAI) ||
+			backend.Name == string(types.BackendAzure) || backend.Name == string(types.BackendGemini) ||
+			backend.Name == string(types.BackendBedrock) || backend.Name == string(types.BackendOllama):
+			errs = append(errs, fmt.Errorf("openai_compatible[%d]: name %q is reserved for the built-in backend", i, backend.Name))
+		case compatibleNames[backend.Name]:
+			errs = append(errs, fmt.Errorf("openai_compatible: %s is defined more than once", backend.Name))
+		}
+		compatibleNames[backend.Name] = true
+		if u, err := url.Parse(backend.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("openai_compatible[%d]: base_url must be an http or https URL, got %q", i, backend.BaseURL))
+		}
+		for _, pattern := range append(append([]string{}, backend.IncludePatterns...), backend.ExcludePatterns...) {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("openai_compatible[%d]: invalid pattern %q", i, pattern))
+			}
+		}
+	}
+
+	embeddingNames := make(map[string]bool)
+	for i, model := range c.EmbeddingModels {
+		switch {
+		case model.Name == "" || model.BackendModel == "":
+			errs = append(errs, fmt.Errorf("embedding_models[%d]: name and backend_model are required", i))
+		case embeddingNames[model.Name]:
+			errs = append(errs, fmt.Errorf("embedding_models: %s is defined more than once", model.Name))
+		}
+		embeddingNames[model.Name] = true
+		if !c.knownBackend(model.Backend) {
+			errs = append(errs, fmt.Errorf("embedding_models[%d]: unknown backend %q", i, model.Backend))
+		}
+	}
+
+	for _, model := range c.Models {
+		if model.Backend != "" && !c.knownBackend(model.Backend) {
+			errs = append(errs, fmt.Errorf("models: unknown backend %q for %s", model.Backend, model.Name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// knownBackend reports whether a backend name from the configuration is supported
+func (c *Config) knownBackend(name string) bool {
+	switch types.BackendType(name) {
+	case types.BackendAnthropic, types.BackendOpenAI, types.BackendAzure, types.BackendGemini, types.BackendBedrock, types.BackendOllama:
+		return true
+	}
+	for _, backend := range c.OpenAICompatible {
+		if backend.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // HasAnthropic returns true if Anthropic API key is configured
 func (c *Config) HasAnthropic() bool {
 	return c.AnthropicAPIKey != ""
@@ -108,3 +598,29 @@ func (c *Config) HasAnthropic() bool {
 func (c *Config) HasOpenAI() bool {
 	return c.OpenAIAPIKey != ""
 }
+
+// AnthropicKeys returns every configured Anthropic API key
+func (c *Config) AnthropicKeys() []string {
+	return append([]string{c.AnthropicAPIKey}, c.AnthropicAPIKeys...)
+}
+
+// OpenAIKeys returns every configured OpenAI API key
+func (c *Config) OpenAIKeys() []string {
+	return append([]string{c.OpenAIAPIKey}, c.OpenAIAPIKeys...)
+}
+
+// GeminiKeys returns every configured Gemini API key
+func (c *Config) GeminiKeys() []string {
+	return append([]string{c.GeminiAPIKey}, c.GeminiAPIKeys...)
+}
+
+// RetryPolicy returns the retry policy for upstream requests
+func (c *Config) RetryPolicy() retry.Policy {
+	policy := retry.DefaultPolicy()
+	policy.MaxAttempts = c.RetryMaxAttempts
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	policy.MaxElapsed = time.Duration(c.RetryMaxElapsed) * time.Second
+	return policy
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is the configuration file read when none is named and it exists
+const DefaultPath = "config.yaml"
+
+// setting is a scalar setting that environment variables and command-line flags can override
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	// field returns a pointer to the setting's field: *string, *int or *[]string
+	field func(c *Config) interface{}
+}
+
+// settings lists every setting outside the structured sections of the configuration file
+var settings = []setting{
+	{"PORT", "port", "port to listen on", func(c *Config) interface{} { return &c.Port }},
+	{"GIN_MODE", "gin-mode", "gin mode: debug, release or test", func(c *Config) interface{} { return &c.GinMode }},
+	{"ANTHROPIC_API_KEY", "anthropic-api-key", "Anthropic API key", func(c *Config) interface{} { return &c.AnthropicAPIKey }},
+	{"OPENAI_API_KEY", "openai-api-key", "OpenAI API key", func(c *Config) interface{} { return &c.OpenAIAPIKey }},
+	{"GEMINI_API_KEY", "gemini-api-key", "Gemini API key", func(c *Config) interface{} { return &c.GeminiAPIKey }},
+	{"ANTHROPIC_API_KEYS", "anthropic-api-keys", "additional Anthropic API keys, comma-separated", func(c *Config) interface{} { return &c.AnthropicAPIKeys }},
+	{"OPENAI_API_KEYS", "openai-api-keys", "additional OpenAI API keys, comma-separated", func(c *Config) interface{} { return &c.OpenAIAPIKeys }},
+	{"GEMINI_API_KEYS", "gemini-api-keys", "additional Gemini API keys, comma-separated", func(c *Config) interface{} { return &c.GeminiAPIKeys }},
+	{"AZURE_OPENAI_ENDPOINT", "azure-openai-endpoint", "Azure OpenAI resource URL", func(c *Config) interface{} { return &c.AzureOpenAI.Endpoint }},
+	{"AZURE_OPENAI_API_KEY", "azure-openai-api-key", "Azure OpenAI API key", func(c *Config) interface{} { return &c.AzureOpenAI.APIKey }},
+	{"AZURE_OPENAI_API_VERSION", "azure-openai-api-version", "Azure OpenAI api-version", func(c *Config) interface{} { return &c.AzureOpenAI.APIVersion }},
+	{"BEDROCK_REGION", "bedrock-region", "AWS region of the Bedrock backend", func(c *Config) interface{} { return &c.Bedrock.Region }},
+	{"BEDROCK_PROFILE", "bedrock-profile", "AWS profile of the Bedrock credentials", func(c *Config) interface{} { return &c.Bedrock.Profile }},
+	{"OLLAMA_BASE_URL", "ollama-base-url", "URL of the upstream Ollama server", func(c *Config) interface{} { return &c.Ollama.BaseURL }},
+	{"KEY_SELECTION", "key-selection", "API key selection: round_robin or least_used", func(c *Config) interface{} { return &c.KeySelection }},
+	{"DEFAULT_MAX_TOKENS", "default-max-tokens", "default output token limit", func(c *Config) interface{} { return &c.DefaultMaxTokens }},
+	{"UNSUPPORTED_OPTIONS", "unsupported-options", "options a backend can't honor: drop or reject", func(c *Config) interface{} { return &c.UnsupportedOptions }},
+	{"FORMAT_RETRIES", "format-retries", "retries of responses not matching the requested format", func(c *Config) interface{} { return &c.FormatRetries }},
+	{"REQUEST_TIMEOUT_SECONDS", "request-timeout-seconds", "upstream request timeout, in seconds; 0 disables it", func(c *Config) interface{} { return &c.RequestTimeout }},
+	{"RETRY_MAX_ATTEMPTS", "retry-max-attempts", "attempts per upstream request; 1 disables retries", func(c *Config) interface{} { return &c.RetryMaxAttempts }},
+	{"RETRY_MAX_ELAPSED_SECONDS", "retry-max-elapsed-seconds", "time after which failed requests are no longer retried; 0 means no limit", func(c *Config) interface{} { return &c.RetryMaxElapsed }},
+	{"MODEL_REFRESH_SECONDS", "model-refresh-seconds", "how often to fetch the model lists again, in seconds; 0 disables it", func(c *Config) interface{} { return &c.ModelRefresh }},
+	{"MODEL_CACHE_PATH", "model-cache-path", "file caching the fetched model lists for offline startup", func(c *Config) interface{} { return &c.ModelCachePath }},
+	{"CONFIG_WATCH_SECONDS", "config-watch-seconds", "how often to check the configuration file for changes, in seconds; 0 disables it", func(c *Config) interface{} { return &c.ConfigWatch }},
+	{"ADMIN_TOKEN", "admin-token", "bearer token required by the admin endpoints; without one they only accept local requests", func(c *Config) interface{} { return &c.AdminToken }},
+}
+
+// assignment is a setting given on the command line, applied once the file and environment are read
+type assignment struct {
+	setting setting
+	value   string
+}
+
+// Load builds the configuration from, in increasing order of precedence: the defaults,
+// the configuration file, environment variables and the command-line arguments.
+// The file is named by the --config flag or MODEL_CONFIG_PATH, and defaults to
+// config.yaml when that exists. Unknown keys in the file, malformed values and
+// invalid settings are reported together in the returned error.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("llm-proxy", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to the YAML configuration file (default $MODEL_CONFIG_PATH or config.yaml)")
+	var assignments []assignment
+	for _, s := range settings {
+		s := s
+		fs.Func(s.flag, fmt.Sprintf("%s (env %s)", s.usage, s.env), func(value string) error {
+			assignments = append(assignments, assignment{setting: s, value: value})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	cfg := Default()
+
+	path, required := *configPath, true
+	if path == "" {
+		path = os.Getenv("MODEL_CONFIG_PATH")
+	}
+	if path == "" {
+		path, required = DefaultPath, false
+	}
+	if err := cfg.loadFile(path, required); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env); ok && value != "" {
+			if err := cfg.set(s, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+	for _, a := range assignments {
+		if err := cfg.set(a.setting, a.value); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", a.setting.flag, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	// With only a key list set, its first key is the one used to list models
+	if cfg.AnthropicAPIKey == "" && len(cfg.AnthropicAPIKeys) > 0 {
+		cfg.AnthropicAPIKey = cfg.AnthropicAPIKeys[0]
+	}
+	if cfg.OpenAIAPIKey == "" && len(cfg.OpenAIAPIKeys) > 0 {
+		cfg.OpenAIAPIKey = cfg.OpenAIAPIKeys[0]
+	}
+	if cfg.GeminiAPIKey == "" && len(cfg.GeminiAPIKeys) > 0 {
+		cfg.GeminiAPIKey = cfg.GeminiAPIKeys[0]
+	}
+	if cfg.AzureOpenAI.APIKey == "" && len(cfg.AzureOpenAI.APIKeys) > 0 {
+		cfg.AzureOpenAI.APIKey = cfg.AzureOpenAI.APIKeys[0]
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// loadFile reads the configuration file over the current settings. Sections the file
+// leaves out keep their values; unknown keys are rejected so typos don't go unnoticed.
+func (c *Config) loadFile(path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !required && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if absolute, err := filepath.Abs(path); err == nil {
+		path = absolute
+	}
+	c.Path = path
+	return nil
+}
+
+// set parses a value into a setting's field
+func (c *Config) set(s setting, value string) error {
+	switch field := s.field(c).(type) {
+	case *string:
+		*field = value
+	case *int:
+		number, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		*field = number
+	case *[]string:
+		*field = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*field = append(*field, item)
+			}
+		}
+	}
+	return nil
+}
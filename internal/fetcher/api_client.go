@@ -6,20 +6,36 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"go-llm-proxy/internal/awsauth"
+	"go-llm-proxy/internal/types"
 )
 
 // APIClient handles API requests for fetching model information
 type APIClient struct {
-	client *http.Client
+	client       *http.Client
+	anthropicURL string
+	openaiURL    string
+	geminiURL    string
 }
 
 // NewAPIClient creates a new API client
 func NewAPIClient() *APIClient {
+	return NewAPIClientWithBaseURLs("https://api.anthropic.com", "https://api.openai.com", "https://generativelanguage.googleapis.com")
+}
+
+// NewAPIClientWithBaseURLs creates a new API client for the given Anthropic, OpenAI and Gemini API base URLs
+func NewAPIClientWithBaseURLs(anthropicURL, openaiURL, geminiURL string) *APIClient {
 	return &APIClient{
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		anthropicURL: anthropicURL,
+		openaiURL:    openaiURL,
+		geminiURL:    geminiURL,
 	}
 }
 
@@ -42,6 +58,21 @@ type OpenAIModel struct {
 	Object  string `json:"object"`
 	Created int64  `json:"created"`
 	OwnedBy string `json:"owned_by"`
+
+	// Context sizes some OpenAI-compatible servers add: vLLM, OpenRouter and Groq respectively
+	MaxModelLen   int `json:"max_model_len,omitempty"`
+	ContextLength int `json:"context_length,omitempty"`
+	ContextWindow int `json:"context_window,omitempty"`
+}
+
+// ContextSize returns the context size the server reported for the model, or 0
+func (m OpenAIModel) ContextSize() int {
+	for _, size := range []int{m.MaxModelLen, m.ContextLength, m.ContextWindow} {
+		if size > 0 {
+			return size
+		}
+	}
+	return 0
 }
 
 // OpenAIModelsResponse represents the response from OpenAI models API
@@ -50,13 +81,120 @@ type OpenAIModelsResponse struct {
 	Data   []OpenAIModel `json:"data"`
 }
 
+// GeminiModel represents a model from the Gemini API
+type GeminiModel struct {
+	// Name is the resource name, such as models/gemini-2.5-flash
+	Name                       string   `json:"name"`
+	DisplayName                string   `json:"displayName"`
+	Description                string   `json:"description"`
+	InputTokenLimit            int      `json:"inputTokenLimit"`
+	OutputTokenLimit           int      `json:"outputTokenLimit"`
+	SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+}
+
+// ID returns the model ID used in requests, such as gemini-2.5-flash
+func (m GeminiModel) ID() string {
+	return strings.TrimPrefix(m.Name, "models/")
+}
+
+// SupportsGenerateContent reports whether the model can serve chat and generation requests
+func (m GeminiModel) SupportsGenerateContent() bool {
+	for _, method := range m.SupportedGenerationMethods {
+		if method == "generateContent" {
+			return true
+		}
+	}
+	return false
+}
+
+// GeminiModelsResponse represents a page of the response from the Gemini models API
+type GeminiModelsResponse struct {
+	Models        []GeminiModel `json:"models"`
+	NextPageToken string        `json:"nextPageToken"`
+}
+
+// azureDeploymentsAPIVersion is the api-version the deployments of an Azure OpenAI resource
+// are listed with; later data-plane versions dropped the list
+const azureDeploymentsAPIVersion = "2022-12-01"
+
+// AzureDeployment represents a deployment from the Azure OpenAI API
+type AzureDeployment struct {
+	ID     string `json:"id"`
+	Model  string `json:"model"`
+	Status string `json:"status"`
+	Object string `json:"object"`
+}
+
+// AzureDeploymentsResponse represents the response from the Azure OpenAI deployments API
+type AzureDeploymentsResponse struct {
+	Object string            `json:"object"`
+	Data   []AzureDeployment `json:"data"`
+}
+
+// BedrockModel represents a foundation model from the Bedrock API
+type BedrockModel struct {
+	ModelID         string   `json:"modelId"`
+	ModelName       string   `json:"modelName"`
+	ProviderName    string   `json:"providerName"`
+	InputModalities []string `json:"inputModalities"`
+	// InferenceTypesSupported holds ON_DEMAND for models invoked by their ID, and
+	// INFERENCE_PROFILE for models invoked through an inference profile
+	InferenceTypesSupported []string `json:"inferenceTypesSupported"`
+	ModelLifecycle          struct {
+		Status string `json:"status"`
+	} `json:"modelLifecycle"`
+}
+
+// SupportsInferenceType reports whether the model can be invoked with an inference type
+func (m BedrockModel) SupportsInferenceType(inferenceType string) bool {
+	for _, supported := range m.InferenceTypesSupported {
+		if supported == inferenceType {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsImages reports whether the model takes images as input
+func (m BedrockModel) AcceptsImages() bool {
+	for _, modality := range m.InputModalities {
+		if modality == "IMAGE" {
+			return true
+		}
+	}
+	return false
+}
+
+// BedrockModelsResponse represents the response from the Bedrock foundation models API
+type BedrockModelsResponse struct {
+	ModelSummaries []BedrockModel `json:"modelSummaries"`
+}
+
+// BedrockInferenceProfile represents an inference profile from the Bedrock API, which
+// routes the requests for a model to several regions
+type BedrockInferenceProfile struct {
+	InferenceProfileID   string `json:"inferenceProfileId"`
+	InferenceProfileName string `json:"inferenceProfileName"`
+	Status               string `json:"status"`
+	Models               []struct {
+		ModelArn string `json:"modelArn"`
+	} `json:"models"`
+}
+
+// BedrockInferenceProfilesResponse represents a page of the response from the Bedrock
+// inference profiles API
+type BedrockInferenceProfilesResponse struct {
+	InferenceProfileSummaries []BedrockInferenceProfile `json:"inferenceProfileSummaries"`
+	NextToken                 string                    `json:"nextToken"`
+}
+
 // FetchAnthropicModels fetches available models from Anthropic API
 func (c *APIClient) FetchAnthropicModels(ctx context.Context, apiKey string) ([]AnthropicModel, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("anthropic API key not provided")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.anthropic.com/v1/models", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.anthropicURL+"/v1/models", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -97,13 +235,238 @@ func (c *APIClient) FetchOpenAIModels(ctx context.Context, apiKey string) ([]Ope
 	if apiKey == "" {
 		return nil, fmt.Errorf("openai API key not provided")
 	}
+	return c.fetchOpenAIModelList(ctx, "openai", c.openaiURL+"/v1/models", apiKey, nil)
+}
+
+// FetchOpenAICompatibleModels fetches the models of a server that speaks the OpenAI API at
+// baseURL. The API key is optional.
+func (c *APIClient) FetchOpenAICompatibleModels(ctx context.Context, name, baseURL, apiKey string, headers map[string]string) ([]OpenAIModel, error) {
+	return c.fetchOpenAIModelList(ctx, name, strings.TrimRight(baseURL, "/")+"/models", apiKey, headers)
+}
+
+// FetchGeminiModels fetches available models from the Gemini API, following every page of the list
+func (c *APIClient) FetchGeminiModels(ctx context.Context, apiKey string) ([]GeminiModel, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("gemini API key not provided")
+	}
+
+	var models []GeminiModel
+	pageToken := ""
+	for {
+		modelsResp, err := c.fetchGeminiModelPage(ctx, apiKey, pageToken)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, modelsResp.Models...)
+		if modelsResp.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = modelsResp.NextPageToken
+	}
+}
+
+// fetchGeminiModelPage fetches one page of the Gemini model list
+func (c *APIClient) fetchGeminiModelPage(ctx context.Context, apiKey, pageToken string) (*GeminiModelsResponse, error) {
+	query := url.Values{"pageSize": {"1000"}}
+	if pageToken != "" {
+		query.Set("pageToken", pageToken)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", c.geminiURL+"/v1beta/models?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("x-goog-api-key", apiKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gemini API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var modelsResp GeminiModelsResponse
+	if err := json.Unmarshal(body, &modelsResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &modelsResp, nil
+}
+
+// FetchAzureDeployments fetches the deployments of the Azure OpenAI resource at endpoint
+func (c *APIClient) FetchAzureDeployments(ctx context.Context, endpoint, apiKey string) ([]AzureDeployment, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("azure API key not provided")
+	}
+
+	deploymentsURL := strings.TrimRight(endpoint, "/") + "/openai/deployments?api-version=" + azureDeploymentsAPIVersion
+	req, err := http.NewRequestWithContext(ctx, "GET", deploymentsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("api-key", apiKey)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
 
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.openai.com/v1/models", nil)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("azure API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var deploymentsResp AzureDeploymentsResponse
+	if err := json.Unmarshal(body, &deploymentsResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return deploymentsResp.Data, nil
+}
+
+// FetchBedrockModels fetches the Anthropic text models Bedrock offers at endpoint
+func (c *APIClient) FetchBedrockModels(ctx context.Context, endpoint string, signer *awsauth.Signer) ([]BedrockModel, error) {
+	query := url.Values{"byProvider": {"anthropic"}, "byOutputModality": {"TEXT"}}
+	var modelsResp BedrockModelsResponse
+	if err := c.fetchBedrock(ctx, strings.TrimRight(endpoint, "/")+"/foundation-models?"+query.Encode(), signer, &modelsResp); err != nil {
+		return nil, err
+	}
+	return modelsResp.ModelSummaries, nil
+}
+
+// FetchBedrockInferenceProfiles fetches the inference profiles AWS defines at endpoint,
+// following every page of the list
+func (c *APIClient) FetchBedrockInferenceProfiles(ctx context.Context, endpoint string, signer *awsauth.Signer) ([]BedrockInferenceProfile, error) {
+	var profiles []BedrockInferenceProfile
+	nextToken := ""
+	for {
+		query := url.Values{"type": {"SYSTEM_DEFINED"}, "maxResults": {"1000"}}
+		if nextToken != "" {
+			query.Set("nextToken", nextToken)
+		}
+		var page BedrockInferenceProfilesResponse
+		if err := c.fetchBedrock(ctx, strings.TrimRight(endpoint, "/")+"/inference-profiles?"+query.Encode(), signer, &page); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, page.InferenceProfileSummaries...)
+		if page.NextToken == "" {
+			return profiles, nil
+		}
+		nextToken = page.NextToken
+	}
+}
+
+// fetchBedrock sends a signed GET request to the Bedrock API and parses the response into v
+func (c *APIClient) fetchBedrock(ctx context.Context, url string, signer *awsauth.Signer, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if err := signer.Sign(req, nil); err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bedrock API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
+
+// FetchOllamaModels fetches the models an Ollama server has pulled, from its /api/tags
+func (c *APIClient) FetchOllamaModels(ctx context.Context, baseURL string) ([]types.OllamaModel, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimRight(baseURL, "/")+"/api/tags", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var tagsResp types.OllamaTagsResponse
+	if err := json.Unmarshal(body, &tagsResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return tagsResp.Models, nil
+}
+
+// fetchOpenAIModelList fetches a model list in the OpenAI format
+func (c *APIClient) fetchOpenAIModelList(ctx context.Context, name, url, apiKey string, headers map[string]string) ([]OpenAIModel, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	for header, value := range headers {
+		req.Header.Set(header, value)
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -122,7 +485,7 @@ func (c *APIClient) FetchOpenAIModels(ctx context.Context, apiKey string) ([]Ope
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("openai API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("%s API error (status %d): %s", name, resp.StatusCode, string(body))
 	}
 
 	var modelsResp OpenAIModelsResponse
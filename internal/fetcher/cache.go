@@ -0,0 +1,131 @@
+package fetcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go-llm-proxy/internal/types"
+)
+
+// cacheMu serializes updates of catalog files, which every fetcher of the process may write
+var cacheMu sync.Mutex
+
+// StaleError reports a backend whose model list couldn't be fetched and was read from the
+// catalog cache instead
+type StaleError struct {
+	Err       error
+	FetchedAt time.Time
+}
+
+func (e *StaleError) Error() string {
+	return fmt.Sprintf("%v (serving the model list cached at %s)", e.Err, e.FetchedAt.Format(time.RFC3339))
+}
+
+func (e *StaleError) Unwrap() error {
+	return e.Err
+}
+
+// cachedModels is a backend's model list as its API returned it
+type cachedModels struct {
+	FetchedAt time.Time       `json:"fetched_at"`
+	Models    json.RawMessage `json:"models"`
+}
+
+// catalog is the content of the catalog cache file. The unfiltered API model lists are
+// kept, so the current filters and model settings apply to cached models too.
+type catalog struct {
+	Backends map[types.BackendType]cachedModels `json:"backends"`
+}
+
+// catalogCache persists the last model list fetched from each backend, so that the proxy
+// can start when the backends can't be reached
+type catalogCache struct {
+	path string
+}
+
+// sync stores a freshly fetched API model list, or, when fetching failed, reads the cached
+// list into apiModels. It returns nil when apiModels holds a fresh list, a *StaleError when
+// it holds a cached one, and the fetch error when nothing is cached.
+func (c *catalogCache) sync(backend types.BackendType, apiModels interface{}, fetchErr error) error {
+	if c.path == "" {
+		return fetchErr
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	cat, err := c.read()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Warning: Ignoring model catalog cache %s: %v", c.path, err)
+		cat = &catalog{}
+	}
+	if cat.Backends == nil {
+		cat.Backends = make(map[types.BackendType]cachedModels)
+	}
+
+	if fetchErr == nil {
+		data, err := json.Marshal(apiModels)
+		if err == nil {
+			cat.Backends[backend] = cachedModels{FetchedAt: time.Now().UTC(), Models: data}
+			err = c.write(cat)
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to update model catalog cache %s: %v", c.path, err)
+		}
+		return nil
+	}
+
+	cached, ok := cat.Backends[backend]
+	if !ok {
+		return fetchErr
+	}
+	if err := json.Unmarshal(cached.Models, apiModels); err != nil {
+		log.Printf("Warning: Ignoring cached %s models: %v", backend, err)
+		return fetchErr
+	}
+	return &StaleError{Err: fetchErr, FetchedAt: cached.FetchedAt}
+}
+
+// read reads the cache file
+func (c *catalogCache) read() (*catalog, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return &catalog{}, err
+	}
+	var cat catalog
+	if err := json.Unmarshal(data, &cat); err != nil {
+		return &catalog{}, err
+	}
+	return &cat, nil
+}
+
+// write replaces the cache file, through a temporary file so readers never see a partial catalog
+func (c *catalogCache) write(cat *catalog) error {
+	data, err := json.MarshalIndent(cat, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
@@ -2,31 +2,54 @@ package fetcher
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"unicode"
 
+	"go-llm-proxy/internal/awsauth"
 	"go-llm-proxy/internal/config"
 	"go-llm-proxy/internal/types"
-
-	"gopkg.in/yaml.v3"
+	"go-llm-proxy/pkg/bedrock"
 )
 
 // ModelFetcher handles fetching and filtering models from APIs
 type ModelFetcher struct {
 	apiClient *APIClient
 	config    *config.Config
+	cache     *catalogCache
+	// bedrockSigner signs the requests listing the Bedrock models, when Bedrock is enabled
+	bedrockSigner *awsauth.Signer
 }
 
+// bedrockVersionSuffix matches the version that follows the date in Bedrock model IDs,
+// such as -v1:0 in anthropic.claude-sonnet-4-20250514-v1:0
+var bedrockVersionSuffix = regexp.MustCompile(`(-\d{8})-v\d+(:\d+)?$`)
+
 // NewModelFetcher creates a new model fetcher
 func NewModelFetcher(cfg *config.Config) *ModelFetcher {
-	return &ModelFetcher{
-		apiClient: NewAPIClient(),
+	return NewModelFetcherWithAPIClient(cfg, NewAPIClient())
+}
+
+// NewModelFetcherWithAPIClient creates a new model fetcher using the given API client
+func NewModelFetcherWithAPIClient(cfg *config.Config, apiClient *APIClient) *ModelFetcher {
+	fetcher := &ModelFetcher{
+		apiClient: apiClient,
 		config:    cfg,
+		cache:     &catalogCache{path: cfg.ModelCachePath},
 	}
+	if cfg.Bedrock.Enabled() {
+		fetcher.bedrockSigner = &awsauth.Signer{
+			Credentials: awsauth.DefaultChain(cfg.Bedrock.Profile, cfg.Bedrock.Region),
+			Region:      cfg.Bedrock.Region,
+			Service:     bedrock.SigningName,
+		}
+	}
+	return fetcher
 }
 
 // titleCase converts a string to title case
@@ -39,88 +62,255 @@ func titleCase(s string) string {
 	return string(runes)
 }
 
-// LoadConfigFromFile loads model filter configuration from a YAML file
-func (f *ModelFetcher) LoadConfigFromFile(configPath string) error {
-	if configPath == "" {
-		// Use default config if no path provided
-		return nil
-	}
-
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return fmt.Errorf("failed to read config file: %w", err)
-	}
-
-	var configData struct {
-		ModelFilters config.ModelFilters `yaml:"model_filters"`
-	}
+// PartialError reports the backends whose models couldn't be fetched. The models returned
+// with it come from the backends that could be reached, the catalog cache and the configuration.
+type PartialError struct {
+	Failures map[types.BackendType]error
+}
 
-	if err := yaml.Unmarshal(data, &configData); err != nil {
-		return fmt.Errorf("failed to parse config file: %w", err)
+func (e *PartialError) Error() string {
+	var failures []string
+	for backend, err := range e.Failures {
+		failures = append(failures, fmt.Sprintf("%s: %v", backend, err))
 	}
-
-	f.config.ModelFilters = configData.ModelFilters
-	return nil
+	sort.Strings(failures)
+	return "failed to fetch models from " + strings.Join(failures, "; ")
 }
 
-// FetchAllModels fetches models from all enabled backends and applies filters
+// FetchAllModels fetches models from all enabled backends and applies filters.
+// A backend that can't be reached is served from the catalog cache when it has been
+// fetched before, and models declared by backend and backend_model in the configuration
+// need no fetch at all. If any backend fails, the models available are returned along
+// with a *PartialError; an error alone means there are no models to serve.
 func (f *ModelFetcher) FetchAllModels(ctx context.Context) ([]types.ModelConfig, error) {
 	var allModels []types.ModelConfig
+	failures := make(map[types.BackendType]error)
 
 	// Fetch models from each enabled backend
-	allModels = append(allModels, f.fetchBackendModels(ctx, types.BackendAnthropic)...)
-	allModels = append(allModels, f.fetchBackendModels(ctx, types.BackendOpenAI)...)
+	backends := []types.BackendType{types.BackendAnthropic, types.BackendOpenAI, types.BackendGemini, types.BackendAzure, types.BackendBedrock, types.BackendOllama}
+	for _, compatible := range f.config.OpenAICompatible {
+		backends = append(backends, types.BackendType(compatible.Name))
+	}
+	for _, backend := range backends {
+		models, err := f.fetchBackendModels(ctx, backend)
+		var stale *StaleError
+		switch {
+		case errors.As(err, &stale):
+			log.Printf("Warning: Failed to fetch %s models, using %d cached models: %v", backend, len(models), err)
+			failures[backend] = err
+		case err != nil:
+			log.Printf("Warning: Failed to fetch %s models: %v", backend, err)
+			failures[backend] = err
+			continue
+		}
+		allModels = append(allModels, models...)
+	}
+
+	// Embedding models come from configuration, since the provider model lists don't flag them
+	allModels = append(allModels, f.embeddingModels()...)
+	allModels = append(allModels, f.virtualModels(allModels)...)
 
 	if len(allModels) == 0 {
+		if len(failures) > 0 {
+			return nil, fmt.Errorf("no models could be fetched from any backend: %w", &PartialError{Failures: failures})
+		}
 		return nil, fmt.Errorf("no models could be fetched from any backend")
 	}
 
+	for i := range allModels {
+		allModels[i].Timeout = f.config.ModelTimeouts[allModels[i].Name]
+		allModels[i].Fallbacks = f.config.ModelFallbacks[allModels[i].Name]
+	}
+
+	if len(failures) > 0 {
+		return allModels, &PartialError{Failures: failures}
+	}
 	return allModels, nil
 }
 
+// embeddingModels converts the configured embedding models for backends that have an API key
+func (f *ModelFetcher) embeddingModels() []types.ModelConfig {
+	var models []types.ModelConfig
+	for _, embeddingModel := range f.config.EmbeddingModels {
+		backend := types.BackendType(embeddingModel.Backend)
+		if !f.hasAPIKey(backend) {
+			continue
+		}
+
+		models = append(models, types.ModelConfig{
+			Name:         embeddingModel.Name,
+			DisplayName:  embeddingModel.Name,
+			Backend:      backend,
+			BackendModel: embeddingModel.BackendModel,
+			Family:       "embedding",
+			Description:  fmt.Sprintf("Embedding model served by %s %s", backend, embeddingModel.BackendModel),
+			MaxTokens:    8191,
+			Enabled:      true,
+			Embedding:    true,
+		})
+	}
+	return models
+}
+
+// virtualModels builds the models defined in configuration on top of the fetched ones.
+// A virtual model inherits the capabilities of its target, and is skipped when the
+// target isn't available.
+func (f *ModelFetcher) virtualModels(fetched []types.ModelConfig) []types.ModelConfig {
+	var models []types.ModelConfig
+	for _, virtual := range f.config.Models {
+		target, ok := f.virtualModelTarget(virtual, fetched)
+		if !ok {
+			log.Printf("Warning: Skipping model %s, whose target is not available", virtual.Name)
+			continue
+		}
+		// Options were checked when the configuration was validated
+		opts, _ := virtual.GenerationOptions()
+
+		model := target
+		model.Name = virtual.Name
+		model.DisplayName = virtual.DisplayName
+		if model.DisplayName == "" {
+			model.DisplayName = virtual.Name
+		}
+		model.Description = fmt.Sprintf("Alias of %s %s", target.Backend, target.BackendModel)
+		model.Enabled = true
+		model.System = virtual.System
+		model.Options = opts
+		model.DefaultMaxTokens = virtual.MaxTokens
+		models = append(models, model)
+	}
+	return models
+}
+
+// virtualModelTarget finds the model a virtual model is served by: a fetched model named
+// by target, or the upstream model named by backend and backend_model
+func (f *ModelFetcher) virtualModelTarget(virtual config.VirtualModelConfig, fetched []types.ModelConfig) (types.ModelConfig, bool) {
+	if virtual.Target != "" {
+		for _, model := range fetched {
+			if model.Name == virtual.Target {
+				return model, true
+			}
+		}
+		for _, model := range fetched {
+			if model.BackendModel == virtual.Target && !model.Embedding {
+				return model, true
+			}
+		}
+		return types.ModelConfig{}, false
+	}
+
+	backend := types.BackendType(virtual.Backend)
+	if !f.hasAPIKey(backend) {
+		return types.ModelConfig{}, false
+	}
+	for _, model := range fetched {
+		if model.Backend == backend && model.BackendModel == virtual.BackendModel && !model.Embedding {
+			return model, true
+		}
+	}
+	// Upstream models excluded by the filters can still be served under a virtual name
+	return types.ModelConfig{
+		Backend:      backend,
+		BackendModel: virtual.BackendModel,
+		Family:       f.extractFamily(virtual.BackendModel, backend),
+		MaxTokens:    f.estimateMaxTokens(virtual.BackendModel, backend),
+		Vision:       f.supportsVision(virtual.BackendModel, backend),
+	}, true
+}
+
+// hasAPIKey reports whether an API key is configured for a backend
+func (f *ModelFetcher) hasAPIKey(backend types.BackendType) bool {
+	switch backend {
+	case types.BackendAnthropic:
+		return f.config.AnthropicAPIKey != ""
+	case types.BackendOpenAI:
+		return f.config.OpenAIAPIKey != ""
+	case types.BackendGemini:
+		return f.config.GeminiAPIKey != ""
+	case types.BackendAzure:
+		return f.config.AzureOpenAI.Enabled()
+	case types.BackendBedrock:
+		// Credentials come from the AWS chain, and are only looked up when used
+		return f.config.Bedrock.Enabled()
+	case types.BackendOllama:
+		return f.config.Ollama.Enabled()
+	}
+	// OpenAI-compatible backends need no key
+	_, ok := f.openaiCompatible(backend)
+	return ok
+}
+
+// openaiCompatible returns the configuration of a named OpenAI-compatible backend
+func (f *ModelFetcher) openaiCompatible(backend types.BackendType) (config.OpenAICompatibleConfig, bool) {
+	for _, compatible := range f.config.OpenAICompatible {
+		if types.BackendType(compatible.Name) == backend {
+			return compatible, true
+		}
+	}
+	return config.OpenAICompatibleConfig{}, false
+}
+
 // fetchBackendModels fetches models from a specific backend if enabled
-func (f *ModelFetcher) fetchBackendModels(ctx context.Context, backend types.BackendType) []types.ModelConfig {
+func (f *ModelFetcher) fetchBackendModels(ctx context.Context, backend types.BackendType) ([]types.ModelConfig, error) {
 	switch backend {
 	case types.BackendAnthropic:
 		return f.fetchAnthropicModelsIfEnabled(ctx)
 	case types.BackendOpenAI:
 		return f.fetchOpenAIModelsIfEnabled(ctx)
+	case types.BackendGemini:
+		return f.fetchGeminiModelsIfEnabled(ctx)
+	case types.BackendAzure:
+		if !f.config.AzureOpenAI.Enabled() {
+			return nil, nil
+		}
+		return f.fetchAzureModels(ctx)
+	case types.BackendBedrock:
+		if !f.config.Bedrock.Enabled() {
+			return nil, nil
+		}
+		return f.fetchBedrockModels(ctx)
+	case types.BackendOllama:
+		if !f.config.Ollama.Enabled() {
+			return nil, nil
+		}
+		return f.fetchOllamaModels(ctx)
 	}
-	return nil
+	if compatible, ok := f.openaiCompatible(backend); ok {
+		return f.fetchOpenAICompatibleModels(ctx, compatible)
+	}
+	return nil, nil
 }
 
 // fetchAnthropicModelsIfEnabled fetches Anthropic models if enabled
-func (f *ModelFetcher) fetchAnthropicModelsIfEnabled(ctx context.Context) []types.ModelConfig {
+func (f *ModelFetcher) fetchAnthropicModelsIfEnabled(ctx context.Context) ([]types.ModelConfig, error) {
 	if !f.config.ModelFilters.Anthropic.Enabled || f.config.AnthropicAPIKey == "" {
-		return nil
+		return nil, nil
 	}
-
-	models, err := f.fetchAnthropicModels(ctx)
-	if err != nil {
-		log.Printf("Warning: Failed to fetch Anthropic models: %v", err)
-		return nil
-	}
-	return models
+	return f.fetchAnthropicModels(ctx)
 }
 
 // fetchOpenAIModelsIfEnabled fetches OpenAI models if enabled
-func (f *ModelFetcher) fetchOpenAIModelsIfEnabled(ctx context.Context) []types.ModelConfig {
+func (f *ModelFetcher) fetchOpenAIModelsIfEnabled(ctx context.Context) ([]types.ModelConfig, error) {
 	if !f.config.ModelFilters.OpenAI.Enabled || f.config.OpenAIAPIKey == "" {
-		return nil
+		return nil, nil
 	}
+	return f.fetchOpenAIModels(ctx)
+}
 
-	models, err := f.fetchOpenAIModels(ctx)
-	if err != nil {
-		log.Printf("Warning: Failed to fetch OpenAI models: %v", err)
-		return nil
+// fetchGeminiModelsIfEnabled fetches Gemini models if enabled
+func (f *ModelFetcher) fetchGeminiModelsIfEnabled(ctx context.Context) ([]types.ModelConfig, error) {
+	if !f.config.ModelFilters.Gemini.Enabled || f.config.GeminiAPIKey == "" {
+		return nil, nil
 	}
-	return models
+	return f.fetchGeminiModels(ctx)
 }
 
-// fetchAnthropicModels fetches and filters Anthropic models
+// fetchAnthropicModels fetches and filters Anthropic models, falling back to the catalog cache
 func (f *ModelFetcher) fetchAnthropicModels(ctx context.Context) ([]types.ModelConfig, error) {
 	apiModels, err := f.apiClient.FetchAnthropicModels(ctx, f.config.AnthropicAPIKey)
-	if err != nil {
+	err = f.cache.sync(types.BackendAnthropic, &apiModels, err)
+	var stale *StaleError
+	if err != nil && !errors.As(err, &stale) {
 		return nil, err
 	}
 
@@ -145,18 +335,21 @@ func (f *ModelFetcher) fetchAnthropicModels(ctx context.Context) ([]types.ModelC
 			Description:  apiModel.Description,
 			MaxTokens:    maxTokens,
 			Enabled:      true,
+			Vision:       f.supportsVision(apiModel.ID, types.BackendAnthropic),
 		}
 
 		models = append(models, model)
 	}
 
-	return models, nil
+	return models, err
 }
 
-// fetchOpenAIModels fetches and filters OpenAI models
+// fetchOpenAIModels fetches and filters OpenAI models, falling back to the catalog cache
 func (f *ModelFetcher) fetchOpenAIModels(ctx context.Context) ([]types.ModelConfig, error) {
 	apiModels, err := f.apiClient.FetchOpenAIModels(ctx, f.config.OpenAIAPIKey)
-	if err != nil {
+	err = f.cache.sync(types.BackendOpenAI, &apiModels, err)
+	var stale *StaleError
+	if err != nil && !errors.As(err, &stale) {
 		return nil, err
 	}
 
@@ -177,14 +370,335 @@ func (f *ModelFetcher) fetchOpenAIModels(ctx context.Context) ([]types.ModelConf
 			Description:  f.generateDescription(apiModel.ID, types.BackendOpenAI),
 			MaxTokens:    f.estimateMaxTokens(apiModel.ID, types.BackendOpenAI),
 			Enabled:      true,
+			Vision:       f.supportsVision(apiModel.ID, types.BackendOpenAI),
+		}
+
+		models = append(models, model)
+	}
+
+	return models, err
+}
+
+// fetchGeminiModels fetches and filters Gemini models, falling back to the catalog cache
+func (f *ModelFetcher) fetchGeminiModels(ctx context.Context) ([]types.ModelConfig, error) {
+	apiModels, err := f.apiClient.FetchGeminiModels(ctx, f.config.GeminiAPIKey)
+	err = f.cache.sync(types.BackendGemini, &apiModels, err)
+	var stale *StaleError
+	if err != nil && !errors.As(err, &stale) {
+		return nil, err
+	}
+
+	var models []types.ModelConfig
+	for _, apiModel := range apiModels {
+		// Embedding and other models that can't generate content aren't served as chat models
+		if !apiModel.SupportsGenerateContent() {
+			continue
+		}
+
+		// Apply filters
+		id := apiModel.ID()
+		if !f.matchesFilters(id, f.config.ModelFilters.Gemini) {
+			continue
+		}
+
+		displayName := apiModel.DisplayName
+		if displayName == "" {
+			displayName = f.generateDisplayName(id, types.BackendGemini)
+		}
+		description := apiModel.Description
+		if description == "" {
+			description = f.generateDescription(id, types.BackendGemini)
+		}
+		// The models endpoint reports the context size
+		maxTokens := apiModel.InputTokenLimit
+		if maxTokens == 0 {
+			maxTokens = f.estimateMaxTokens(id, types.BackendGemini)
+		}
+
+		model := types.ModelConfig{
+			Name:         f.generateModelName(id, types.BackendGemini),
+			DisplayName:  displayName,
+			Backend:      types.BackendGemini,
+			BackendModel: id,
+			Family:       f.extractFamily(id, types.BackendGemini),
+			Description:  description,
+			MaxTokens:    maxTokens,
+			Enabled:      true,
+			Vision:       f.supportsVision(id, types.BackendGemini),
 		}
 
 		models = append(models, model)
 	}
 
+	return models, err
+}
+
+// fetchAzureModels lists the deployments of the Azure OpenAI resource as models, falling
+// back to the catalog cache. A deployment is listed under each model name the configuration
+// maps to it, or under its own name when none is.
+func (f *ModelFetcher) fetchAzureModels(ctx context.Context) ([]types.ModelConfig, error) {
+	azure := f.config.AzureOpenAI
+	deployments, err := f.apiClient.FetchAzureDeployments(ctx, azure.Endpoint, azure.APIKey)
+	err = f.cache.sync(types.BackendAzure, &deployments, err)
+	var stale *StaleError
+	if err != nil && !errors.As(err, &stale) {
+		return nil, err
+	}
+
+	modelNames := make(map[string][]string)
+	for name, deployment := range azure.Deployments {
+		modelNames[deployment] = append(modelNames[deployment], name)
+	}
+
+	var models []types.ModelConfig
+	listed := make(map[string]bool)
+	for _, deployment := range deployments {
+		listed[deployment.ID] = true
+		// Deployments still being created, or that failed, can't serve requests
+		if deployment.Status != "" && deployment.Status != "succeeded" {
+			continue
+		}
+
+		// Apply filters
+		if !f.matchesFilters(deployment.ID, azure.ModelFilter()) {
+			continue
+		}
+
+		names := modelNames[deployment.ID]
+		if len(names) == 0 {
+			names = []string{deployment.ID}
+		}
+		sort.Strings(names)
+
+		// Capabilities follow the OpenAI model the deployment serves
+		apiModelID := deployment.Model
+		if apiModelID == "" {
+			apiModelID = deployment.ID
+		}
+		for _, name := range names {
+			models = append(models, types.ModelConfig{
+				Name:         name,
+				DisplayName:  name,
+				Backend:      types.BackendAzure,
+				BackendModel: deployment.ID,
+				Family:       f.extractFamily(apiModelID, types.BackendOpenAI),
+				Description:  fmt.Sprintf("%s served by Azure OpenAI deployment %s", apiModelID, deployment.ID),
+				MaxTokens:    f.estimateMaxTokens(apiModelID, types.BackendOpenAI),
+				Enabled:      true,
+				Vision:       f.supportsVision(apiModelID, types.BackendOpenAI),
+			})
+		}
+	}
+
+	for name, deployment := range azure.Deployments {
+		if !listed[deployment] {
+			log.Printf("Warning: Skipping model %s, whose Azure OpenAI deployment %s does not exist", name, deployment)
+		}
+	}
+
+	return models, err
+}
+
+// fetchBedrockModels lists the Anthropic models that can be invoked on Bedrock, falling
+// back to the catalog cache. Filters apply to the model names before the prefix, such as
+// claude-sonnet-4-20250514.
+func (f *ModelFetcher) fetchBedrockModels(ctx context.Context) ([]types.ModelConfig, error) {
+	cfg := f.config.Bedrock
+	apiModels, err := f.listBedrockModels(ctx)
+	err = f.cache.sync(types.BackendBedrock, &apiModels, err)
+	var stale *StaleError
+	if err != nil && !errors.As(err, &stale) {
+		return nil, err
+	}
+
+	var models []types.ModelConfig
+	listed := make(map[string]bool)
+	for _, apiModel := range apiModels {
+		baseName := f.generateModelName(apiModel.ModelID, types.BackendBedrock)
+
+		// Apply filters
+		if !f.matchesFilters(baseName, cfg.ModelFilter()) {
+			continue
+		}
+		// A model invoked both on demand and through a profile is listed once, on demand
+		if listed[baseName] {
+			continue
+		}
+		listed[baseName] = true
+
+		displayName := apiModel.ModelName
+		if displayName == "" {
+			displayName = f.generateDisplayName(baseName, types.BackendAnthropic)
+		}
+
+		// Capabilities follow the Claude model
+		models = append(models, types.ModelConfig{
+			Name:         cfg.ModelPrefix + baseName,
+			DisplayName:  displayName,
+			Backend:      types.BackendBedrock,
+			BackendModel: apiModel.ModelID,
+			Family:       f.extractFamily(baseName, types.BackendAnthropic),
+			Description:  fmt.Sprintf("%s served by Amazon Bedrock as %s", displayName, apiModel.ModelID),
+			MaxTokens:    f.estimateMaxTokens(baseName, types.BackendAnthropic),
+			Enabled:      true,
+			Vision:       apiModel.AcceptsImages(),
+		})
+	}
+
+	return models, err
+}
+
+// listBedrockModels lists the Bedrock models that can be invoked, each under the ID
+// requests name: models with on-demand throughput by their own ID, and others by the ID
+// of an inference profile AWS defines for them
+func (f *ModelFetcher) listBedrockModels(ctx context.Context) ([]BedrockModel, error) {
+	endpoint := f.config.Bedrock.ControlURL()
+	foundationModels, err := f.apiClient.FetchBedrockModels(ctx, endpoint, f.bedrockSigner)
+	if err != nil {
+		return nil, err
+	}
+
+	var models []BedrockModel
+	byID := make(map[string]BedrockModel)
+	for _, model := range foundationModels {
+		byID[model.ModelID] = model
+		if model.SupportsInferenceType("ON_DEMAND") {
+			models = append(models, model)
+		}
+	}
+
+	profiles, err := f.apiClient.FetchBedrockInferenceProfiles(ctx, endpoint, f.bedrockSigner)
+	if err != nil {
+		// Policies written before inference profiles may not allow listing them
+		log.Printf("Warning: Failed to list Bedrock inference profiles, skipping the models only offered through them: %v", err)
+		return models, nil
+	}
+	for _, profile := range profiles {
+		if (profile.Status != "" && profile.Status != "ACTIVE") || len(profile.Models) == 0 {
+			continue
+		}
+		// The models of a profile are one foundation model in several regions
+		arn := profile.Models[0].ModelArn
+		model, ok := byID[arn[strings.LastIndex(arn, "/")+1:]]
+		if !ok {
+			// Not an Anthropic text model
+			continue
+		}
+		model.ModelID = profile.InferenceProfileID
+		model.ModelName = profile.InferenceProfileName
+		models = append(models, model)
+	}
 	return models, nil
 }
 
+// fetchOpenAICompatibleModels fetches and filters the models of an OpenAI-compatible backend,
+// falling back to the catalog cache
+func (f *ModelFetcher) fetchOpenAICompatibleModels(ctx context.Context, compatible config.OpenAICompatibleConfig) ([]types.ModelConfig, error) {
+	backend := types.BackendType(compatible.Name)
+	var apiKey string
+	for _, key := range compatible.Keys() {
+		if key != "" {
+			apiKey = key
+			break
+		}
+	}
+
+	apiModels, err := f.apiClient.FetchOpenAICompatibleModels(ctx, compatible.Name, compatible.BaseURL, apiKey, compatible.Headers)
+	err = f.cache.sync(backend, &apiModels, err)
+	var stale *StaleError
+	if err != nil && !errors.As(err, &stale) {
+		return nil, err
+	}
+
+	var models []types.ModelConfig
+	for _, apiModel := range apiModels {
+		// Apply filters
+		if !f.matchesFilters(apiModel.ID, compatible.ModelFilter()) {
+			continue
+		}
+
+		// Servers that don't report a context size get the default estimate
+		maxTokens := apiModel.ContextSize()
+		if maxTokens == 0 {
+			maxTokens = f.estimateMaxTokens(apiModel.ID, backend)
+		}
+
+		model := types.ModelConfig{
+			Name:         compatible.ModelPrefix + apiModel.ID,
+			DisplayName:  f.generateDisplayName(apiModel.ID, backend),
+			Backend:      backend,
+			BackendModel: apiModel.ID,
+			Family:       f.extractFamily(apiModel.ID, backend),
+			Description:  fmt.Sprintf("%s model served by %s", apiModel.ID, compatible.Name),
+			MaxTokens:    maxTokens,
+			Enabled:      true,
+			Vision:       f.supportsVision(apiModel.ID, backend),
+		}
+
+		models = append(models, model)
+	}
+
+	return models, err
+}
+
+// fetchOllamaModels fetches and filters the models an upstream Ollama server has pulled,
+// falling back to the catalog cache
+func (f *ModelFetcher) fetchOllamaModels(ctx context.Context) ([]types.ModelConfig, error) {
+	ollama := f.config.Ollama
+	apiModels, err := f.apiClient.FetchOllamaModels(ctx, ollama.BaseURL)
+	err = f.cache.sync(types.BackendOllama, &apiModels, err)
+	var stale *StaleError
+	if err != nil && !errors.As(err, &stale) {
+		return nil, err
+	}
+
+	var models []types.ModelConfig
+	for _, apiModel := range apiModels {
+		// Apply filters
+		if !f.matchesFilters(apiModel.Name, ollama.ModelFilter()) {
+			continue
+		}
+
+		// Clients asking for the untagged name get the latest tag, as from Ollama itself
+		name := strings.TrimSuffix(apiModel.Name, ":latest")
+		family := f.extractFamily(name, types.BackendOllama)
+		var families []string
+		if apiModel.Details != nil {
+			if apiModel.Details.Family != "" {
+				family = apiModel.Details.Family
+			}
+			families = append([]string{apiModel.Details.Family}, apiModel.Details.Families...)
+		}
+
+		local := apiModel
+		model := types.ModelConfig{
+			Name:         ollama.ModelPrefix + name,
+			DisplayName:  f.generateDisplayName(name, types.BackendOllama),
+			Backend:      types.BackendOllama,
+			BackendModel: apiModel.Name,
+			Family:       family,
+			Description:  fmt.Sprintf("%s model served by Ollama", apiModel.Name),
+			MaxTokens:    f.estimateMaxTokens(name, types.BackendOllama),
+			Enabled:      true,
+			Local:        &local,
+		}
+		// /api/tags doesn't report capabilities, but the weights' families tell BERT
+		// embedding models and models with a vision encoder apart
+		for _, family := range families {
+			switch {
+			case strings.HasSuffix(family, "bert"):
+				model.Embedding = true
+			case family == "clip" || family == "mllama":
+				model.Vision = true
+			}
+		}
+
+		models = append(models, model)
+	}
+
+	return models, err
+}
+
 // matchesFilters checks if a model ID matches the include/exclude patterns
 func (f *ModelFetcher) matchesFilters(modelID string, filter config.ModelFilterConfig) bool {
 	// Check exclude patterns first
@@ -226,6 +740,13 @@ func (f *ModelFetcher) generateModelName(apiModelID string, backend types.Backen
 	case types.BackendOpenAI:
 		// Use OpenAI model ID as-is for cleaner names
 		return apiModelID
+	case types.BackendBedrock:
+		// Convert us.anthropic.claude-sonnet-4-20250514-v1:0 to claude-sonnet-4-20250514
+		name := apiModelID
+		if i := strings.Index(name, "anthropic."); i >= 0 {
+			name = name[i+len("anthropic."):]
+		}
+		return bedrockVersionSuffix.ReplaceAllString(name, "$1")
 	default:
 		return apiModelID
 	}
@@ -252,6 +773,13 @@ func (f *ModelFetcher) generateDisplayName(apiModelID string, backend types.Back
 	case types.BackendOpenAI:
 		// Convert gpt-4o to GPT-4o
 		return strings.ToUpper(apiModelID)
+	case types.BackendGemini:
+		// Convert gemini-2.5-flash to Gemini 2.5 Flash
+		parts := strings.Split(apiModelID, "-")
+		for i, part := range parts {
+			parts[i] = titleCase(part)
+		}
+		return strings.Join(parts, " ")
 	default:
 		return titleCase(apiModelID)
 	}
@@ -275,6 +803,11 @@ func (f *ModelFetcher) extractFamily(apiModelID string, backend types.BackendTyp
 		}
 		return "gpt"
 	default:
+		// Extract llama from meta-llama/llama-3.1-8b-instruct
+		name := apiModelID[strings.LastIndex(apiModelID, "/")+1:]
+		if family := strings.ToLower(strings.Split(name, "-")[0]); family != "" {
+			return family
+		}
 		return "unknown"
 	}
 }
@@ -286,11 +819,36 @@ func (f *ModelFetcher) generateDescription(apiModelID string, backend types.Back
 		return fmt.Sprintf("Anthropic %s model", f.generateDisplayName(apiModelID, backend))
 	case types.BackendOpenAI:
 		return fmt.Sprintf("OpenAI %s model", f.generateDisplayName(apiModelID, backend))
+	case types.BackendGemini:
+		return fmt.Sprintf("Google %s model", f.generateDisplayName(apiModelID, backend))
 	default:
 		return fmt.Sprintf("%s model", f.generateDisplayName(apiModelID, backend))
 	}
 }
 
+// supportsVision reports whether a model accepts image input.
+// The models endpoints don't report capabilities, so this is based on the model ID.
+func (f *ModelFetcher) supportsVision(apiModelID string, backend types.BackendType) bool {
+	switch backend {
+	case types.BackendAnthropic:
+		// Every Claude model from Claude 3 onwards accepts images
+		return !strings.HasPrefix(apiModelID, "claude-2") && !strings.Contains(apiModelID, "instant")
+	case types.BackendOpenAI:
+		visionModels := []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "gpt-4-turbo", "vision"}
+		for _, visionModel := range visionModels {
+			if strings.Contains(apiModelID, visionModel) {
+				return true
+			}
+		}
+		return false
+	case types.BackendGemini:
+		// Every Gemini model accepts images
+		return strings.HasPrefix(apiModelID, "gemini-")
+	default:
+		return false
+	}
+}
+
 // estimateMaxTokens estimates max tokens for models where not provided by API
 func (f *ModelFetcher) estimateMaxTokens(apiModelID string, backend types.BackendType) int {
 	switch backend {
@@ -0,0 +1,226 @@
+package keys
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Strategy selects which key of a pool serves the next request
+type Strategy string
+
+const (
+	// RoundRobin takes the keys in turn
+	RoundRobin Strategy = "round_robin"
+	// LeastUsed takes the key with the fewest requests in flight, then the fewest requests overall
+	LeastUsed Strategy = "least_used"
+)
+
+// Quarantine periods for keys the upstream refused
+const (
+	// InvalidKeyQuarantine applies to keys rejected as unauthorized; they rarely recover by themselves
+	InvalidKeyQuarantine = time.Hour
+	// QuotaQuarantine applies to keys whose quota or credit is exhausted
+	QuotaQuarantine = 15 * time.Minute
+	// RateLimitCooldown applies to rate-limited keys when the upstream doesn't say when the limit resets
+	RateLimitCooldown = 10 * time.Second
+)
+
+// ParseStrategy parses a key selection strategy; the empty string selects RoundRobin
+func ParseStrategy(value string) (Strategy, error) {
+	switch Strategy(value) {
+	case "", RoundRobin:
+		return RoundRobin, nil
+	case LeastUsed:
+		return LeastUsed, nil
+	}
+	return "", fmt.Errorf("key selection must be %q or %q, got %q", RoundRobin, LeastUsed, value)
+}
+
+// key is one API key of a pool and its usage
+type key struct {
+	value            string
+	requests         int64
+	failures         int64
+	rateLimited      int64
+	inFlight         int
+	quarantinedUntil time.Time
+	lastError        string
+}
+
+// Pool spreads requests over several API keys for one backend, setting aside keys
+// the upstream refuses until their quarantine ends
+type Pool struct {
+	mu       sync.Mutex
+	keys     []*key
+	strategy Strategy
+	next     int
+}
+
+// NewPool creates a pool of the given keys, skipping empty and repeated ones
+func NewPool(values []string, strategy Strategy) *Pool {
+	pool := &Pool{strategy: strategy}
+	seen := make(map[string]bool)
+	for _, value := range values {
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		pool.keys = append(pool.keys, &key{value: value})
+	}
+	return pool
+}
+
+// Len returns the number of keys in the pool
+func (p *Pool) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.keys)
+}
+
+// Lease is a key taken from a pool for one request
+type Lease struct {
+	pool *Pool
+	key  *key
+	once sync.Once
+}
+
+// Key returns the leased API key
+func (l *Lease) Key() string {
+	return l.key.value
+}
+
+// Acquire takes a key for a request. Quarantined keys are skipped; if every key is
+// quarantined, the one released soonest is used rather than failing the request.
+// The lease must be released once the request is done.
+func (p *Pool) Acquire() (*Lease, error) {
+	if p.Len() == 0 {
+		return nil, fmt.Errorf("no API keys configured")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var chosen *key
+	switch p.strategy {
+	case LeastUsed:
+		for _, k := range p.keys {
+			if k.quarantinedUntil.After(now) {
+				continue
+			}
+			if chosen == nil || k.inFlight < chosen.inFlight ||
+				(k.inFlight == chosen.inFlight && k.requests < chosen.requests) {
+				chosen = k
+			}
+		}
+	default:
+		for i := range p.keys {
+			k := p.keys[(p.next+i)%len(p.keys)]
+			if !k.quarantinedUntil.After(now) {
+				chosen = k
+				p.next = (p.next + i + 1) % len(p.keys)
+				break
+			}
+		}
+	}
+	if chosen == nil {
+		chosen = p.keys[0]
+		for _, k := range p.keys[1:] {
+			if k.quarantinedUntil.Before(chosen.quarantinedUntil) {
+				chosen = k
+			}
+		}
+	}
+
+	chosen.requests++
+	chosen.inFlight++
+	return &Lease{pool: p, key: chosen}, nil
+}
+
+// Release returns the key to the pool; later calls do nothing
+func (l *Lease) Release() {
+	l.once.Do(func() {
+		l.pool.mu.Lock()
+		l.key.inFlight--
+		l.pool.mu.Unlock()
+	})
+}
+
+// Succeeded clears the key's quarantine after a successful request
+func (l *Lease) Succeeded() {
+	l.pool.mu.Lock()
+	defer l.pool.mu.Unlock()
+	l.key.quarantinedUntil = time.Time{}
+}
+
+// Failed records a request refused because of the key, setting the key aside for the given period
+func (l *Lease) Failed(reason string, quarantine time.Duration, rateLimited bool) {
+	l.pool.mu.Lock()
+	defer l.pool.mu.Unlock()
+	l.key.failures++
+	if rateLimited {
+		l.key.rateLimited++
+	}
+	l.key.lastError = reason
+	if until := time.Now().Add(quarantine); until.After(l.key.quarantinedUntil) {
+		l.key.quarantinedUntil = until
+	}
+}
+
+// Stats describes the usage of one key; the key itself is masked
+type Stats struct {
+	Key              string     `json:"key"`
+	Requests         int64      `json:"requests"`
+	Failures         int64      `json:"failures"`
+	RateLimited      int64      `json:"rate_limited"`
+	InFlight         int        `json:"in_flight"`
+	Quarantined      bool       `json:"quarantined"`
+	QuarantinedUntil *time.Time `json:"quarantined_until,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+}
+
+// Stats returns the usage of every key in the pool
+func (p *Pool) Stats() []Stats {
+	if p.Len() == 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	stats := make([]Stats, 0, len(p.keys))
+	for _, k := range p.keys {
+		s := Stats{
+			Key:         Mask(k.value),
+			Requests:    k.requests,
+			Failures:    k.failures,
+			RateLimited: k.rateLimited,
+			InFlight:    k.inFlight,
+			LastError:   k.lastError,
+		}
+		if k.quarantinedUntil.After(now) {
+			until := k.quarantinedUntil
+			s.Quarantined = true
+			s.QuarantinedUntil = &until
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// Mask hides all but the last four characters of a key, for logs and status output
+func Mask(value string) string {
+	if len(value) <= 8 {
+		return "****"
+	}
+	return "..." + value[len(value)-4:]
+}
+
+// Pooled is implemented by backends that spread requests over a pool of keys
+type Pooled interface {
+	SetKeyPool(pool *Pool)
+	KeyStats() []Stats
+}
@@ -0,0 +1,139 @@
+package keys
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-llm-proxy/internal/retry"
+)
+
+// maxErrorBytes bounds how much of a refused response is read to tell quota errors apart
+const maxErrorBytes = 64 << 10
+
+// quotaMarkers identify error bodies reporting an exhausted quota or credit balance
+var quotaMarkers = []string{"insufficient_quota", "credit balance", "billing"}
+
+// Authorizer sets a key on an upstream request
+type Authorizer func(header http.Header, key string)
+
+// BearerAuth sends the key as a bearer token, as OpenAI expects
+func BearerAuth(header http.Header, key string) {
+	header.Set("Authorization", "Bearer "+key)
+}
+
+// HeaderAuth sends the key in the named header, such as Anthropic's x-api-key
+func HeaderAuth(name string) Authorizer {
+	return func(header http.Header, key string) {
+		header.Set(name, key)
+	}
+}
+
+// Transport is an http.RoundTripper that sends each request with a key from a pool and
+// quarantines keys the upstream refuses. Placed under a retry.Transport, every retry
+// takes a key afresh, so a rate-limited key is not tried again while others are free.
+type Transport struct {
+	// Base performs the requests; http.DefaultTransport is used when nil
+	Base      http.RoundTripper
+	Pool      *Pool
+	Authorize Authorizer
+	// Optional sends requests as they are when the pool is empty, for upstreams that need no key
+	Optional bool
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Optional && t.Pool.Len() == 0 {
+		return t.base().RoundTrip(req)
+	}
+
+	lease, err := t.Pool.Acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	keyed := req.Clone(req.Context())
+	t.Authorize(keyed.Header, lease.Key())
+
+	resp, err := t.base().RoundTrip(keyed)
+	if err != nil {
+		lease.Release()
+		return nil, err
+	}
+	t.observe(lease, resp)
+
+	// The key stays in flight until the response, possibly a long stream, has been read
+	resp.Body = &leasedBody{ReadCloser: resp.Body, lease: lease}
+	return resp, nil
+}
+
+// base returns the transport that performs the requests
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// observe records the outcome of a request against its key
+func (t *Transport) observe(lease *Lease, resp *http.Response) {
+	switch {
+	case resp.StatusCode < http.StatusBadRequest:
+		lease.Succeeded()
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		t.quarantine(lease, resp.Status, InvalidKeyQuarantine, false)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusPaymentRequired:
+		if quotaExhausted(resp) {
+			t.quarantine(lease, resp.Status+": quota exhausted", QuotaQuarantine, resp.StatusCode == http.StatusTooManyRequests)
+		} else if resp.StatusCode == http.StatusTooManyRequests {
+			cooldown, ok := retry.ServerDelay(resp.Header, time.Now())
+			if !ok {
+				cooldown = RateLimitCooldown
+			}
+			t.quarantine(lease, resp.Status, cooldown, true)
+		}
+	}
+}
+
+// quarantine sets a key aside and logs it
+func (t *Transport) quarantine(lease *Lease, reason string, period time.Duration, rateLimited bool) {
+	lease.Failed(reason, period, rateLimited)
+	if t.Pool.Len() > 1 {
+		log.Printf("Quarantining API key %s for %v after %s", Mask(lease.Key()), period.Round(time.Second), reason)
+	}
+}
+
+// quotaExhausted reports whether a refused response says the key's quota or credit ran out.
+// The start of the body is read and put back, so the caller still sees all of it.
+func quotaExhausted(resp *http.Response) bool {
+	peek, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBytes))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peek), resp.Body), resp.Body}
+	if err != nil {
+		return false
+	}
+
+	body := strings.ToLower(string(peek))
+	for _, marker := range quotaMarkers {
+		if strings.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// leasedBody releases its key when the response body is closed
+type leasedBody struct {
+	io.ReadCloser
+	lease *Lease
+}
+
+func (b *leasedBody) Close() error {
+	b.lease.Release()
+	return b.ReadCloser.Close()
+}
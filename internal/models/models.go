@@ -2,8 +2,13 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"go-llm-proxy/internal/backend"
 	"go-llm-proxy/internal/config"
@@ -11,67 +16,258 @@ import (
 	"go-llm-proxy/internal/types"
 )
 
-// ModelRegistry manages all available models
+// refreshTimeout bounds one refresh of the model lists
+const refreshTimeout = 2 * time.Minute
+
+// DegradedRetryInterval is how often the models are fetched again while some backends' can't be
+const DegradedRetryInterval = 30 * time.Second
+
+// ModelSource fetches the models the registry serves, such as a fetcher.ModelFetcher
+type ModelSource interface {
+	FetchAllModels(ctx context.Context) ([]types.ModelConfig, error)
+}
+
+// CatalogStatus describes how current the registry's models are, for the health endpoint
+type CatalogStatus struct {
+	// Degraded is set when the last fetch failed for some backends, whose models then come
+	// from the catalog cache or the configuration, or are missing
+	Degraded bool   `json:"degraded"`
+	Error    string `json:"error,omitempty"`
+	// LastFetched is when every backend's models were last fetched
+	LastFetched *time.Time `json:"last_fetched,omitempty"`
+}
+
+// ModelRegistry manages all available models. It is safe for concurrent use, and can
+// fetch its models again periodically.
 type ModelRegistry struct {
+	mu     sync.RWMutex
 	models map[string]types.ModelConfig
+	// partial is set when the models came from a fetch in which some backends failed
+	partial bool
+	// degraded is the error of the last fetch, if any backend failed
+	degraded    error
+	lastFetched time.Time
+
+	source         ModelSource
+	backendManager *backend.BackendManager
+	stop           chan struct{}
+	stopOnce       sync.Once
 }
 
 // NewTestModelRegistry creates a new empty model registry for testing
 func NewTestModelRegistry() *ModelRegistry {
 	return &ModelRegistry{
 		models: make(map[string]types.ModelConfig),
+		stop:   make(chan struct{}),
 	}
 }
 
 // NewModelRegistryWithDynamicFetching creates a new model registry with dynamically fetched models
-func NewModelRegistryWithDynamicFetching(cfg *config.Config, backendManager *backend.BackendManager, configPath string) (*ModelRegistry, error) {
+func NewModelRegistryWithDynamicFetching(cfg *config.Config, backendManager *backend.BackendManager) (*ModelRegistry, error) {
+	return NewModelRegistryFromSource(fetcher.NewModelFetcher(cfg), backendManager)
+}
+
+// NewModelRegistryFromSource creates a new model registry with the models of a source,
+// keeping only those whose backend is available. If some backends fail, the registry
+// starts degraded with the models that are available; it fails only if there are none.
+func NewModelRegistryFromSource(source ModelSource, backendManager *backend.BackendManager) (*ModelRegistry, error) {
 	registry := &ModelRegistry{
-		models: make(map[string]types.ModelConfig),
+		source:         source,
+		backendManager: backendManager,
+		stop:           make(chan struct{}),
 	}
 
-	// Create model fetcher
-	modelFetcher := fetcher.NewModelFetcher(cfg)
-
-	// Load config from file if provided
-	if configPath != "" {
-		if err := modelFetcher.LoadConfigFromFile(configPath); err != nil {
-			log.Printf("Warning: Failed to load config from file %s: %v", configPath, err)
-		}
+	// Fetch models from APIs
+	models, err := registry.fetch(context.Background())
+	if models == nil {
+		return nil, fmt.Errorf("failed to fetch models: %w", err)
 	}
+	registry.models = models
+	registry.record(err)
 
-	// Fetch models from APIs
-	ctx := context.Background()
-	dynamicModels, err := modelFetcher.FetchAllModels(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch models: %w", err)
+		log.Printf("Starting degraded with %d models: %v", len(registry.models), err)
+		return registry, nil
+	}
+	log.Printf("Loaded %d models dynamically from APIs", len(registry.models))
+	return registry, nil
+}
+
+// fetch fetches the models of the registry's source for the available backends.
+// The models are nil when there are none to serve.
+func (r *ModelRegistry) fetch(ctx context.Context) (map[string]types.ModelConfig, error) {
+	dynamicModels, err := r.source.FetchAllModels(ctx)
+	if dynamicModels == nil {
+		if err == nil {
+			err = fmt.Errorf("no models could be fetched from any backend")
+		}
+		return nil, err
 	}
 
 	// Filter models to only include those for available backends
-	availableBackends := backendManager.GetAvailableBackends()
 	backendMap := make(map[types.BackendType]bool)
-	for _, backendType := range availableBackends {
+	for _, backendType := range r.backendManager.GetAvailableBackends() {
 		backendMap[backendType] = true
 	}
 
 	// Add only models for available backends
+	models := make(map[string]types.ModelConfig)
 	for _, model := range dynamicModels {
 		if backendMap[model.Backend] {
-			registry.models[model.Name] = model
+			models[model.Name] = model
 		}
 	}
+	return models, err
+}
 
-	log.Printf("Loaded %d models dynamically from APIs", len(registry.models))
-	return registry, nil
+// record notes the outcome of the fetch the registry's models come from; the caller
+// holds the lock or owns the registry
+func (r *ModelRegistry) record(err error) {
+	r.partial = err != nil
+	r.degraded = err
+	if err == nil {
+		r.lastFetched = time.Now()
+	}
 }
 
-// GetModel returns a model configuration by name
+// Refresh fetches the models again and replaces the registry's models with them,
+// logging the models added and removed. If any backend fails, the current models are
+// kept, so a passing outage doesn't remove models from clients' lists; only a registry
+// whose models already come from such a fetch takes the models that are available,
+// since they can only improve on what it serves.
+func (r *ModelRegistry) Refresh(ctx context.Context) error {
+	if r.source == nil {
+		return fmt.Errorf("model registry has no source to refresh from")
+	}
+
+	models, err := r.fetch(ctx)
+
+	r.mu.Lock()
+	wasDegraded := r.degraded != nil
+	var partial *fetcher.PartialError
+	if models == nil || (err != nil && !(r.partial && errors.As(err, &partial))) {
+		r.degraded = err
+		count := len(r.models)
+		r.mu.Unlock()
+		log.Printf("Model refresh failed, keeping the current %d models: %v", count, err)
+		return err
+	}
+	previous := r.models
+	r.models = models
+	r.record(err)
+	r.mu.Unlock()
+
+	var added, removed []string
+	for name := range models {
+		if _, exists := previous[name]; !exists {
+			added = append(added, name)
+		}
+	}
+	for name := range previous {
+		if _, exists := models[name]; !exists {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	if len(added) > 0 {
+		log.Printf("Model refresh added %d models: %s", len(added), strings.Join(added, ", "))
+	}
+	if len(removed) > 0 {
+		log.Printf("Model refresh removed %d models: %s", len(removed), strings.Join(removed, ", "))
+	}
+	switch {
+	case err != nil:
+		log.Printf("Model refresh still degraded: %v", err)
+	case wasDegraded:
+		log.Printf("Model refresh recovered: every backend's models were fetched")
+	}
+	return err
+}
+
+// Status reports whether the registry is degraded and when its models were last fetched
+func (r *ModelRegistry) Status() CatalogStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	status := CatalogStatus{Degraded: r.degraded != nil}
+	if r.degraded != nil {
+		status.Error = r.degraded.Error()
+	}
+	if !r.lastFetched.IsZero() {
+		lastFetched := r.lastFetched
+		status.LastFetched = &lastFetched
+	}
+	return status
+}
+
+// StartRefresh refreshes the models in the background until Stop is called: every
+// interval, or every degradedRetry while the registry is degraded. With an interval
+// of 0, refreshing stops once the registry is no longer degraded.
+func (r *ModelRegistry) StartRefresh(interval, degradedRetry time.Duration) {
+	go func() {
+		for {
+			wait := interval
+			if r.Status().Degraded && (wait <= 0 || wait > degradedRetry) {
+				wait = degradedRetry
+			}
+			if wait <= 0 {
+				return
+			}
+
+			select {
+			case <-r.stop:
+				return
+			case <-time.After(wait):
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+			_ = r.Refresh(ctx)
+			cancel()
+		}
+	}()
+}
+
+// Stop ends the background refresh started by StartRefresh; later calls do nothing
+func (r *ModelRegistry) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+}
+
+// GetModel returns a model configuration by name.
+// Ollama clients may add the default ":latest" tag, which matches the untagged name.
 func (r *ModelRegistry) GetModel(name string) (types.ModelConfig, bool) {
-	model, exists := r.models[name]
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if model, exists := r.models[name]; exists {
+		return model, true
+	}
+	model, exists := r.models[strings.TrimSuffix(name, ":latest")]
 	return model, exists
 }
 
+// ResolveModel returns a model configuration by proxy name, falling back to the upstream model ID.
+// This lets clients that use provider model IDs (e.g. claude-3-5-sonnet-20241022) find their model.
+func (r *ModelRegistry) ResolveModel(name string) (types.ModelConfig, bool) {
+	if model, exists := r.GetModel(name); exists {
+		return model, true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, model := range r.models {
+		if model.BackendModel == name {
+			return model, true
+		}
+	}
+	return types.ModelConfig{}, false
+}
+
 // GetModelsByBackend returns all models for a specific backend
 func (r *ModelRegistry) GetModelsByBackend(backend types.BackendType) []types.ModelConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	var models []types.ModelConfig
 	for _, model := range r.models {
 		if model.Backend == backend && model.Enabled {
@@ -83,6 +279,8 @@ func (r *ModelRegistry) GetModelsByBackend(backend types.BackendType) []types.Mo
 
 // GetAllModels returns all enabled models
 func (r *ModelRegistry) GetAllModels() []types.ModelConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	var models []types.ModelConfig
 	for _, model := range r.models {
 		if model.Enabled {
@@ -94,16 +292,22 @@ func (r *ModelRegistry) GetAllModels() []types.ModelConfig {
 
 // AddModel adds a new model to the registry
 func (r *ModelRegistry) AddModel(model types.ModelConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.models[model.Name] = model
 }
 
 // RemoveModel removes a model from the registry
 func (r *ModelRegistry) RemoveModel(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	delete(r.models, name)
 }
 
 // EnableModel enables a model
 func (r *ModelRegistry) EnableModel(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if model, exists := r.models[name]; exists {
 		model.Enabled = true
 		r.models[name] = model
@@ -112,6 +316,8 @@ func (r *ModelRegistry) EnableModel(name string) {
 
 // DisableModel disables a model
 func (r *ModelRegistry) DisableModel(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if model, exists := r.models[name]; exists {
 		model.Enabled = false
 		r.models[name] = model
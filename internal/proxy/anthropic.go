@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"go-llm-proxy/internal/streaming"
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleAnthropicMessages handles the Anthropic-compatible /v1/messages endpoint
+func (p *ProxyServerV2) HandleAnthropicMessages(c *gin.Context) {
+	var req types.AnthropicRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewAnthropicError("invalid_request_error", err.Error()))
+		return
+	}
+
+	if req.Model == "" {
+		c.JSON(http.StatusBadRequest, types.NewAnthropicError("invalid_request_error", "model: Field required"))
+		return
+	}
+
+	// Check if streaming is requested
+	if req.Stream {
+		p.StreamingHandler.HandleAnthropicStreamingMessages(c, req)
+		return
+	}
+
+	modelConfig, chatReq, err := p.StreamingHandler.BuildAnthropicChatRequest(req)
+	if err != nil {
+		streaming.WriteRequestError(c, err)
+		return
+	}
+
+	// Process request
+	ctx := servedModelContext(c)
+	resp, err := p.BackendManager.ProcessRequest(ctx, modelConfig, *chatReq)
+	if err != nil {
+		// Log the error for debugging
+		log.Printf("Error processing Anthropic messages request: %v", err)
+		c.JSON(http.StatusInternalServerError, types.NewAnthropicError("api_error", err.Error()))
+		return
+	}
+
+	chatResp, ok := resp.(*types.ChatResponse)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, types.NewAnthropicError("api_error", "invalid response type"))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.ConvertChatToAnthropicResponse(chatResp, req.Model))
+}
+
+// HandleAnthropicCountTokens handles the Anthropic-compatible /v1/messages/count_tokens endpoint
+func (p *ProxyServerV2) HandleAnthropicCountTokens(c *gin.Context) {
+	var req types.AnthropicCountTokensRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewAnthropicError("invalid_request_error", err.Error()))
+		return
+	}
+
+	if _, exists := p.ModelRegistry.ResolveModel(req.Model); !exists {
+		c.JSON(http.StatusNotFound, types.NewAnthropicError("not_found_error", fmt.Sprintf("model: %s", req.Model)))
+		return
+	}
+
+	// Token counts are estimated locally so they work for every backend
+	messages := types.ConvertAnthropicMessages(req.System, req.Messages)
+	c.JSON(http.StatusOK, types.AnthropicCountTokensResponse{
+		InputTokens: types.EstimateChatTokens(messages),
+	})
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// HandleEmbeddings handles the legacy single-prompt /api/embeddings endpoint
+func (p *ProxyServerV2) HandleEmbeddings(c *gin.Context) {
+	var req types.OllamaEmbeddingsRequest
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Models of an upstream Ollama server get the request as the client sent it
+	if p.forwardModelRequest(c, req.Model, "/api/embeddings") {
+		return
+	}
+
+	modelConfig, ok := p.getEmbeddingModel(c, req.Model)
+	if !ok {
+		return
+	}
+
+	// Ollama answers an empty prompt with an empty embedding rather than an error
+	if req.Prompt == "" {
+		c.JSON(200, types.OllamaEmbeddingsResponse{Embedding: []float32{}})
+		return
+	}
+
+	resp, err := p.embed(c.Request.Context(), modelConfig, []string{req.Prompt})
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, types.OllamaEmbeddingsResponse{Embedding: resp.Embeddings[0]})
+}
+
+// HandleEmbed handles the batched /api/embed endpoint
+func (p *ProxyServerV2) HandleEmbed(c *gin.Context) {
+	var req types.OllamaEmbedRequest
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Models of an upstream Ollama server get the request as the client sent it
+	if p.forwardModelRequest(c, req.Model, "/api/embed") {
+		return
+	}
+
+	modelConfig, ok := p.getEmbeddingModel(c, req.Model)
+	if !ok {
+		return
+	}
+
+	if len(req.Input) == 0 {
+		c.JSON(200, types.OllamaEmbedResponse{Model: req.Model, Embeddings: [][]float32{}})
+		return
+	}
+
+	start := time.Now()
+	resp, err := p.embed(c.Request.Context(), modelConfig, req.Input)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	ollamaResp := types.OllamaEmbedResponse{
+		Model:         req.Model,
+		Embeddings:    resp.Embeddings,
+		TotalDuration: time.Since(start).Nanoseconds(),
+	}
+	if resp.Usage != nil {
+		ollamaResp.PromptEvalCount = resp.Usage.PromptTokens
+	}
+	c.JSON(200, ollamaResp)
+}
+
+// getEmbeddingModel looks up an embedding model, writing an error response if it can't be used
+func (p *ProxyServerV2) getEmbeddingModel(c *gin.Context, name string) (types.ModelConfig, bool) {
+	modelConfig, exists := p.ModelRegistry.GetModel(name)
+	if !exists {
+		c.JSON(400, gin.H{"error": "model not found"})
+		return types.ModelConfig{}, false
+	}
+	if !modelConfig.Embedding {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("model %s does not support embeddings", name)})
+		return types.ModelConfig{}, false
+	}
+	return modelConfig, true
+}
+
+// embed sends the inputs to the model's backend
+func (p *ProxyServerV2) embed(ctx context.Context, modelConfig types.ModelConfig, input []string) (*types.EmbeddingResponse, error) {
+	embeddingReq := types.EmbeddingRequest{
+		Model: modelConfig.BackendModel,
+		Input: input,
+	}
+
+	resp, err := p.BackendManager.ProcessEmbeddingRequest(ctx, modelConfig, embeddingReq)
+	if err != nil {
+		// Log the error for debugging
+		fmt.Printf("Error processing embedding request: %v\n", err)
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go-llm-proxy/internal/backend"
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// forwardModelRequest passes an Ollama API request through to the model's backend when it
+// speaks the Ollama API itself, naming the model as the backend knows it. It reports false,
+// having written nothing, when the request must be converted instead. The request body
+// must have been bound with ShouldBindBodyWith, which keeps it.
+func (p *ProxyServerV2) forwardModelRequest(c *gin.Context, name, path string) bool {
+	modelConfig, exists := p.ModelRegistry.GetModel(name)
+	if !exists || !p.BackendManager.CanForward(modelConfig) {
+		return false
+	}
+
+	bound, _ := c.Get(gin.BodyBytesKey)
+	data, _ := bound.([]byte)
+	body, err := renameModel(data, modelConfig.BackendModel)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return true
+	}
+
+	err = p.BackendManager.ForwardRequest(servedModelContext(c), modelConfig, path, body, func(resp *http.Response) error {
+		rename := name
+		if name == modelConfig.BackendModel {
+			rename = ""
+		}
+		return writeForwarded(c, resp, rename)
+	})
+	if err != nil {
+		// Log the error for debugging
+		fmt.Printf("Error forwarding %s request: %v\n", path, err)
+		if !c.Writer.Written() {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+	}
+	return true
+}
+
+// forwardToOllama passes a model management request through to the upstream Ollama server
+// unchanged, including a body already bound with ShouldBindBodyWith. It reports false,
+// having written nothing, when there is no such server.
+func (p *ProxyServerV2) forwardToOllama(c *gin.Context, method, path string) bool {
+	handler, exists := p.BackendManager.GetBackend(types.BackendOllama)
+	if !exists {
+		return false
+	}
+	forwarder, ok := handler.(backend.Forwarder)
+	if !ok {
+		return false
+	}
+
+	var body []byte
+	if bound, ok := c.Get(gin.BodyBytesKey); ok {
+		body, _ = bound.([]byte)
+	} else if method != http.MethodGet {
+		var err error
+		if body, err = c.GetRawData(); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return true
+		}
+	}
+
+	resp, err := forwarder.Forward(c.Request.Context(), method, path, body)
+	if err != nil {
+		// Log the error for debugging
+		fmt.Printf("Error forwarding %s request: %v\n", path, err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return true
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	if err := writeForwarded(c, resp, ""); err != nil {
+		fmt.Printf("Error forwarding %s response: %v\n", path, err)
+	}
+
+	// Pulled, created, copied and deleted models are served once the catalog is fetched again
+	if resp.StatusCode == http.StatusOK && method != http.MethodGet && path != "/api/show" && path != "/api/stop" {
+		go func() {
+			_ = p.ModelRegistry.Refresh(context.Background())
+		}()
+	}
+	return true
+}
+
+// renameModel returns a request body, a JSON object, with its model field set to model
+func renameModel(data []byte, model string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	var err error
+	if fields["model"], err = json.Marshal(model); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// writeForwarded copies a forwarded response to the client line by line, flushing each line
+// so NDJSON streams arrive as they are produced. When model is set, it replaces the model
+// named in each line, so that the client sees the name it asked for.
+func writeForwarded(c *gin.Context, resp *http.Response, model string) error {
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	c.Status(resp.StatusCode)
+	c.Writer.WriteHeaderNow()
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if model != "" {
+				line = renameLine(line, model)
+			}
+			if _, err := c.Writer.Write(line); err != nil {
+				return fmt.Errorf("failed to write response: %w", err)
+			}
+			c.Writer.Flush()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+	}
+}
+
+// renameLine sets the model field of a JSON object on one line of a response. Lines that
+// aren't JSON objects naming a model are returned unchanged.
+func renameLine(line []byte, model string) []byte {
+	trimmed := bytes.TrimRight(line, "\r\n")
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &fields); err != nil || fields["model"] == nil {
+		return line
+	}
+	fields["model"], _ = json.Marshal(model)
+	renamed, err := json.Marshal(fields)
+	if err != nil {
+		return line
+	}
+	return append(renamed, line[len(trimmed):]...)
+}
@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"go-llm-proxy/internal/streaming"
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleOpenAIChatCompletions handles the OpenAI-compatible /v1/chat/completions endpoint
+func (p *ProxyServerV2) HandleOpenAIChatCompletions(c *gin.Context) {
+	var req types.OpenAIChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewOpenAIError("invalid_request_error", "", err.Error()))
+		return
+	}
+
+	if req.Model == "" {
+		c.JSON(http.StatusBadRequest, types.NewOpenAIError("invalid_request_error", "", "model is required"))
+		return
+	}
+
+	// Check if streaming is requested
+	if req.Stream {
+		p.StreamingHandler.HandleOpenAIStreamingChat(c, req)
+		return
+	}
+
+	modelConfig, chatReq, err := p.StreamingHandler.BuildOpenAIChatRequest(req)
+	if err != nil {
+		streaming.WriteRequestError(c, err)
+		return
+	}
+
+	// Process request
+	ctx := servedModelContext(c)
+	resp, err := p.BackendManager.ProcessRequest(ctx, modelConfig, *chatReq)
+	if err != nil {
+		// Log the error for debugging
+		log.Printf("Error processing OpenAI chat request: %v", err)
+		c.JSON(http.StatusInternalServerError, types.NewOpenAIError("api_error", "", err.Error()))
+		return
+	}
+
+	chatResp, ok := resp.(*types.ChatResponse)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, types.NewOpenAIError("api_error", "", "invalid response type"))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.ConvertChatToOpenAIResponse(chatResp, req.Model, time.Now().Unix()))
+}
+
+// HandleOpenAIModels handles the OpenAI-compatible /v1/models endpoint
+func (p *ProxyServerV2) HandleOpenAIModels(c *gin.Context) {
+	allModels := p.ModelRegistry.GetAllModels()
+	sort.Slice(allModels, func(i, j int) bool {
+		return allModels[i].Name < allModels[j].Name
+	})
+
+	created := time.Now().Unix()
+	data := make([]types.OpenAIModelEntry, 0, len(allModels))
+	for _, model := range allModels {
+		data = append(data, model.ToOpenAIModel(created))
+	}
+
+	c.JSON(http.StatusOK, types.OpenAIModelList{
+		Object: "list",
+		Data:   data,
+	})
+}
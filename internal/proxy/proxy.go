@@ -2,20 +2,25 @@ package proxy
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
-	"os"
+	"net/http"
+	"time"
 
 	"go-llm-proxy/internal/backend"
 	"go-llm-proxy/internal/config"
+	"go-llm-proxy/internal/keys"
 	"go-llm-proxy/internal/models"
 	"go-llm-proxy/internal/streaming"
 	"go-llm-proxy/internal/types"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
-// ProxyServerV2 is the refactored proxy server
+// ProxyServerV2 is the refactored proxy server. It isn't changed once created;
+// a configuration reload replaces it with a new one (see Reloader).
 type ProxyServerV2 struct {
 	Config           *config.Config
 	ModelRegistry    *models.ModelRegistry
@@ -23,26 +28,52 @@ type ProxyServerV2 struct {
 	StreamingHandler *streaming.StreamingHandler
 }
 
-// NewProxyServerV2 creates a new refactored proxy server
-func NewProxyServerV2() *ProxyServerV2 {
-	// Load configuration
-	cfg := config.LoadConfig()
+// NewProxyServerV2 creates a new refactored proxy server from a loaded configuration
+func NewProxyServerV2(cfg *config.Config) *ProxyServerV2 {
+	server, err := BuildProxyServerV2(cfg)
+	if err != nil {
+		// Fail fast if there are no models to serve, fetched, cached or configured
+		log.Fatalf("%v\n", err)
+	}
+	return server
+}
+
+// BuildProxyServerV2 creates a proxy server from a loaded configuration, returning an error
+// rather than exiting when the backends or models can't be set up
+func BuildProxyServerV2(cfg *config.Config) (*ProxyServerV2, error) {
+	keySelection, err := keys.ParseStrategy(cfg.KeySelection)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
 
 	// Create backend factory and manager first
-	backendFactory := backend.NewBackendFactory(cfg.AnthropicAPIKey, cfg.OpenAIAPIKey)
+	backendFactory := backend.NewBackendFactoryWithKeys(cfg.AnthropicKeys(), cfg.OpenAIKeys(), keySelection)
+	backendFactory.SetGemini(cfg.GeminiKeys())
+	if cfg.AzureOpenAI.Enabled() {
+		backendFactory.SetAzure(cfg.AzureOpenAI.Endpoint, cfg.AzureOpenAI.APIVersion, cfg.AzureOpenAI.Keys())
+	}
+	if cfg.Bedrock.Enabled() {
+		backendFactory.SetBedrock(cfg.Bedrock.Region, cfg.Bedrock.Profile, cfg.Bedrock.RuntimeEndpoint)
+	}
+	if cfg.Ollama.Enabled() {
+		backendFactory.SetOllama(cfg.Ollama.BaseURL)
+	}
+	for _, compatible := range cfg.OpenAICompatible {
+		backendFactory.AddOpenAICompatible(compatible.Name, compatible.BaseURL, compatible.Keys(), compatible.Headers, compatible.StreamUsage)
+	}
 	backendManager := backendFactory.CreateBackends()
+	backendManager.SetRejectUnsupportedOptions(cfg.UnsupportedOptions == config.UnsupportedOptionsReject)
+	backendManager.SetFormatRetries(cfg.FormatRetries)
+	backendManager.SetRequestTimeout(time.Duration(cfg.RequestTimeout) * time.Second)
+	backendManager.SetRetryPolicy(cfg.RetryPolicy())
 
 	// Create model registry with dynamic fetching
-	// Try to load from config file first, fall back to environment variables
-	configPath := os.Getenv("MODEL_CONFIG_PATH")
-	if configPath == "" {
-		configPath = "config.yaml" // Default config file
-	}
-	modelRegistry, err := models.NewModelRegistryWithDynamicFetching(cfg, backendManager, configPath)
+	modelRegistry, err := models.NewModelRegistryWithDynamicFetching(cfg, backendManager)
 	if err != nil {
-		// Fail fast if dynamic fetching fails - no fallback
-		log.Fatalf("Failed to fetch models dynamically: %v\n", err)
+		return nil, fmt.Errorf("failed to fetch models dynamically: %w", err)
 	}
+	backendManager.SetModelLookup(modelRegistry)
+	modelRegistry.StartRefresh(time.Duration(cfg.ModelRefresh)*time.Second, models.DegradedRetryInterval)
 
 	// Create streaming handler
 	streamingHandler := streaming.NewStreamingHandler(backendManager, modelRegistry)
@@ -52,20 +83,30 @@ func NewProxyServerV2() *ProxyServerV2 {
 		ModelRegistry:    modelRegistry,
 		BackendManager:   backendManager,
 		StreamingHandler: streamingHandler,
-	}
+	}, nil
+}
+
+// Close stops the server's background work. Requests in flight are unaffected.
+func (p *ProxyServerV2) Close() {
+	p.ModelRegistry.Stop()
 }
 
 // HandleGenerate handles the /api/generate endpoint
 func (p *ProxyServerV2) HandleGenerate(c *gin.Context) {
 	var req types.OllamaGenerateRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Models of an upstream Ollama server get the request as the client sent it
+	if p.forwardModelRequest(c, req.Model, "/api/generate") {
+		return
+	}
+
 	// Check if streaming is requested
 	if req.Stream {
-		c.JSON(400, gin.H{"error": "streaming not supported for generate endpoint"})
+		p.StreamingHandler.HandleStreamingGenerate(c, req)
 		return
 	}
 
@@ -82,16 +123,47 @@ func (p *ProxyServerV2) HandleGenerate(c *gin.Context) {
 	messages = append(messages, types.ChatMessage{
 		Role:    "user",
 		Content: req.Prompt,
+		Images:  req.Images,
 	})
-	maxTokensForRequest := types.CalculateMaxTokensForRequest(modelConfig, messages)
+
+	// Reject images sent to models that can't see them
+	if err := types.ValidateImages(modelConfig, messages); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Constrain the response to JSON when a format is requested
+	format, err := types.ParseOllamaFormat(req.Format)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Map the Ollama options onto the request, letting num_predict override the computed limit
+	clientOpts, err := types.ParseOllamaOptions(req.Options)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := p.BackendManager.ResolveOptions(modelConfig, clientOpts)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	maxTokensForRequest := opts.MaxTokens(types.CalculateMaxTokensForRequest(modelConfig, messages))
 
 	// Create request for backend
 	generateReq := types.ConvertOllamaToGenerateRequest(req, maxTokensForRequest)
 	generateReq.Model = modelConfig.BackendModel
+	generateReq.Options = opts
+	generateReq.ClientOptions = clientOpts
+	generateReq.Format = format
 
 	// Process request
-	ctx := context.Background()
+	ctx := servedModelContext(c)
+	start := time.Now()
 	resp, err := p.BackendManager.ProcessRequest(ctx, modelConfig, generateReq)
+	elapsed := time.Since(start).Nanoseconds()
 	if err != nil {
 		// Log the error for debugging
 		fmt.Printf("Error processing generate request: %v\n", err)
@@ -107,17 +179,25 @@ func (p *ProxyServerV2) HandleGenerate(c *gin.Context) {
 	}
 
 	ollamaResp := types.ConvertGenerateToOllamaResponse(generateResp, req.Model)
+	// Without a stream there is no first token to split on, so the whole request counts as evaluation
+	ollamaResp.TotalDuration = elapsed
+	ollamaResp.EvalDuration = elapsed
 	c.JSON(200, ollamaResp)
 }
 
 // HandleChat handles the /api/chat endpoint
 func (p *ProxyServerV2) HandleChat(c *gin.Context) {
 	var req types.OllamaChatRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Models of an upstream Ollama server get the request as the client sent it
+	if p.forwardModelRequest(c, req.Model, "/api/chat") {
+		return
+	}
+
 	// Check if streaming is requested
 	if req.Stream {
 		p.StreamingHandler.HandleStreamingChat(c, req)
@@ -143,16 +223,44 @@ func (p *ProxyServerV2) HandleChat(c *gin.Context) {
 		return
 	}
 
-	// Calculate appropriate max_tokens for this specific request
-	maxTokensForRequest := types.CalculateMaxTokensForRequest(modelConfig, messages)
+	// Reject images sent to models that can't see them
+	if err := types.ValidateImages(modelConfig, messages); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Constrain the response to JSON when a format is requested
+	format, err := types.ParseOllamaFormat(req.Format)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Map the Ollama options onto the request, letting num_predict override the computed limit
+	clientOpts, err := types.ParseOllamaOptions(req.Options)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := p.BackendManager.ResolveOptions(modelConfig, clientOpts)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	maxTokensForRequest := opts.MaxTokens(types.CalculateMaxTokensForRequest(modelConfig, messages))
 
 	// Create request for backend
 	chatReq := types.ConvertOllamaToChatRequest(req, maxTokensForRequest)
 	chatReq.Model = modelConfig.BackendModel
+	chatReq.Options = opts
+	chatReq.ClientOptions = clientOpts
+	chatReq.Format = format
 
 	// Process request
-	ctx := context.Background()
+	ctx := servedModelContext(c)
+	start := time.Now()
 	resp, err := p.BackendManager.ProcessRequest(ctx, modelConfig, chatReq)
+	elapsed := time.Since(start).Nanoseconds()
 	if err != nil {
 		// Log the error for debugging
 		fmt.Printf("Error processing chat request: %v\n", err)
@@ -168,6 +276,9 @@ func (p *ProxyServerV2) HandleChat(c *gin.Context) {
 	}
 
 	ollamaResp := types.ConvertChatToOllamaResponse(chatResp, req.Model)
+	// Without a stream there is no first token to split on, so the whole request counts as evaluation
+	ollamaResp.TotalDuration = elapsed
+	ollamaResp.EvalDuration = elapsed
 	c.JSON(200, ollamaResp)
 }
 
@@ -201,18 +312,43 @@ func (p *ProxyServerV2) HandleVersion(c *gin.Context) {
 	})
 }
 
-// HandleShow handles the /api/show endpoint
+// HandleShow handles the /api/show endpoint. Models of an upstream Ollama server, and
+// models missing from the catalog while there is one, are described by that server.
 func (p *ProxyServerV2) HandleShow(c *gin.Context) {
-	// Get model from URL parameter
-	modelName := c.Param("model")
+	// The model is named in the body, or in the path by GET /api/show/:model; either way
+	// forwarded requests carry it in the body
+	if name := c.Param("model"); name != "" {
+		body, err := json.Marshal(types.OllamaShowRequest{Model: name})
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(gin.BodyBytesKey, body)
+	}
+	var req types.OllamaShowRequest
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	modelName := req.Model
 	if modelName == "" {
-		c.JSON(400, gin.H{"error": "model parameter is required"})
+		modelName = req.Name
+	}
+	if modelName == "" {
+		c.JSON(400, gin.H{"error": "model is required"})
+		return
+	}
+
+	if p.forwardModelRequest(c, modelName, "/api/show") {
 		return
 	}
 
 	// Get model configuration
 	modelConfig, exists := p.ModelRegistry.GetModel(modelName)
 	if !exists {
+		if p.forwardToOllama(c, http.MethodPost, "/api/show") {
+			return
+		}
 		c.JSON(400, gin.H{"error": "model not found"})
 		return
 	}
@@ -222,43 +358,61 @@ func (p *ProxyServerV2) HandleShow(c *gin.Context) {
 	c.JSON(200, model)
 }
 
-// HandlePull handles the /api/pull endpoint (not applicable for cloud backends)
+// HandlePull handles the /api/pull endpoint, which only an upstream Ollama server serves
 func (p *ProxyServerV2) HandlePull(c *gin.Context) {
+	if p.forwardToOllama(c, http.MethodPost, "/api/pull") {
+		return
+	}
 	c.JSON(200, gin.H{"status": "success", "message": "Models are managed by backends"})
 }
 
-// HandlePush handles the /api/push endpoint (not applicable for cloud backends)
+// HandlePush handles the /api/push endpoint, which only an upstream Ollama server serves
 func (p *ProxyServerV2) HandlePush(c *gin.Context) {
+	if p.forwardToOllama(c, http.MethodPost, "/api/push") {
+		return
+	}
 	c.JSON(200, gin.H{"status": "success", "message": "Models are managed by backends"})
 }
 
-// HandleDelete handles the /api/delete endpoint (not applicable for cloud backends)
+// HandleDelete handles the /api/delete endpoint, which only an upstream Ollama server serves
 func (p *ProxyServerV2) HandleDelete(c *gin.Context) {
+	if p.forwardToOllama(c, http.MethodDelete, "/api/delete") {
+		return
+	}
 	c.JSON(200, gin.H{"status": "success", "message": "Models are managed by backends"})
 }
 
-// HandleCreate handles the /api/create endpoint (not applicable for cloud backends)
+// HandleCreate handles the /api/create endpoint, which only an upstream Ollama server serves
 func (p *ProxyServerV2) HandleCreate(c *gin.Context) {
+	if p.forwardToOllama(c, http.MethodPost, "/api/create") {
+		return
+	}
 	c.JSON(200, gin.H{"status": "success", "message": "Models are managed by backends"})
 }
 
-// HandleCopy handles the /api/copy endpoint (not applicable for cloud backends)
+// HandleCopy handles the /api/copy endpoint, which only an upstream Ollama server serves
 func (p *ProxyServerV2) HandleCopy(c *gin.Context) {
+	if p.forwardToOllama(c, http.MethodPost, "/api/copy") {
+		return
+	}
 	c.JSON(200, gin.H{"status": "success", "message": "Models are managed by backends"})
 }
 
-// HandleEmbeddings handles the /api/embeddings endpoint (not implemented)
-func (p *ProxyServerV2) HandleEmbeddings(c *gin.Context) {
-	c.JSON(501, gin.H{"error": "embeddings not implemented"})
-}
-
-// HandlePs handles the /api/ps endpoint (not applicable for cloud backends)
+// HandlePs handles the /api/ps endpoint, listing the models an upstream Ollama server has loaded
 func (p *ProxyServerV2) HandlePs(c *gin.Context) {
+	if p.forwardToOllama(c, http.MethodGet, "/api/ps") {
+		return
+	}
 	c.JSON(200, gin.H{"status": "success", "message": "No local processes"})
 }
 
-// HandleStop handles the /api/stop endpoint (not applicable for cloud backends)
+// HandleStop handles the /api/stop endpoint, which only an upstream Ollama server serves.
+// Ollama itself also unloads a model with a generate request setting keep_alive to 0,
+// which is passed through to an upstream server.
 func (p *ProxyServerV2) HandleStop(c *gin.Context) {
+	if p.forwardToOllama(c, http.MethodPost, "/api/stop") {
+		return
+	}
 	c.JSON(200, gin.H{"status": "success", "message": "No local processes to stop"})
 }
 
@@ -267,10 +421,27 @@ func (p *ProxyServerV2) GetHealthStatus() gin.H {
 	availableBackends := p.BackendManager.GetAvailableBackends()
 	modelCount := len(p.ModelRegistry.GetAllModels())
 
+	catalog := p.ModelRegistry.Status()
+	status := "healthy"
+	if catalog.Degraded {
+		status = "degraded"
+	}
+
 	return gin.H{
-		"status":             "healthy",
+		"status":             status,
+		"catalog":            catalog,
 		"available_backends": len(availableBackends),
 		"total_models":       modelCount,
 		"backends":           availableBackends,
+		"requests":           p.BackendManager.Stats(),
+		"keys":               p.BackendManager.KeyStats(),
 	}
 }
+
+// servedModelContext returns the context of the client request, which reports the model
+// that served it in the X-Served-Model header
+func servedModelContext(c *gin.Context) context.Context {
+	return backend.WithServedModelCallback(c.Request.Context(), func(model string) {
+		c.Header(backend.ServedModelHeader, model)
+	})
+}
@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BuildFunc loads the configuration and creates a proxy server from it
+type BuildFunc func() (*ProxyServerV2, error)
+
+// ReloadStatus describes the reloads of the configuration, for the status endpoint
+type ReloadStatus struct {
+	Reloads     int        `json:"reloads"`
+	Failures    int        `json:"failures"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// Reloader serves requests with the current proxy server and replaces it when the
+// configuration is reloaded. Each request is handled entirely by the server that was
+// current when it arrived, so in-flight requests and streams finish on the old
+// configuration while new requests use the new one. A reload that fails keeps the
+// current server.
+type Reloader struct {
+	current atomic.Pointer[ProxyServerV2]
+	build   BuildFunc
+
+	// mu serializes reloads and guards status
+	mu     sync.Mutex
+	status ReloadStatus
+
+	// reloaded wakes a file watcher waiting for a reload to turn watching on
+	reloaded chan struct{}
+}
+
+// NewReloader creates a reloader serving with the given server and rebuilding it with build
+func NewReloader(server *ProxyServerV2, build BuildFunc) *Reloader {
+	r := &Reloader{build: build, reloaded: make(chan struct{}, 1)}
+	r.current.Store(server)
+	return r
+}
+
+// Current returns the server new requests are handled by
+func (r *Reloader) Current() *ProxyServerV2 {
+	return r.current.Load()
+}
+
+// Handle adapts a proxy server handler to run on the server current when each request arrives
+func (r *Reloader) Handle(handler func(*ProxyServerV2, *gin.Context)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		handler(r.Current(), c)
+	}
+}
+
+// Reload loads the configuration again and, if it is valid and the backends and models
+// can be set up, swaps in a server built from it
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.status.LastAttempt = &now
+
+	server, err := r.build()
+	if err != nil {
+		r.status.Failures++
+		r.status.LastError = err.Error()
+		log.Printf("Configuration reload failed, keeping the current configuration: %v", err)
+		return err
+	}
+
+	previous := r.current.Swap(server)
+	previous.Close()
+	r.status.Reloads++
+	r.status.LastSuccess = &now
+	r.status.LastError = ""
+	select {
+	case r.reloaded <- struct{}{}:
+	default:
+	}
+
+	if previous.Config.Port != server.Config.Port || previous.Config.GinMode != server.Config.GinMode {
+		log.Printf("The port and gin mode can't change without a restart; still using port %s in %s mode",
+			previous.Config.Port, previous.Config.GinMode)
+	}
+	log.Printf("Configuration reloaded: %d models on backends %v",
+		len(server.ModelRegistry.GetAllModels()), server.BackendManager.GetAvailableBackends())
+	return nil
+}
+
+// Status returns the outcome of the reloads so far
+func (r *Reloader) Status() ReloadStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// RequireAdmin guards the admin endpoints. When the current configuration sets an admin
+// token, requests must send it as a bearer token; otherwise only requests from the
+// loopback interface are let through. The peer address is checked rather than the
+// client IP, which forwarding headers can set.
+func (r *Reloader) RequireAdmin(c *gin.Context) {
+	if token := r.Current().Config.AdminToken; token != "" {
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(401, gin.H{"error": "a valid admin token is required"})
+			return
+		}
+		c.Next()
+		return
+	}
+
+	host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() {
+		c.AbortWithStatusJSON(403, gin.H{"error": "admin endpoints only accept local requests unless admin_token is set"})
+		return
+	}
+	c.Next()
+}
+
+// HandleReload handles the /admin/reload endpoint
+func (r *Reloader) HandleReload(c *gin.Context) {
+	if err := r.Reload(); err != nil {
+		c.JSON(500, gin.H{"error": "configuration reload failed: " + err.Error()})
+		return
+	}
+
+	server := r.Current()
+	c.JSON(200, gin.H{
+		"status":   "reloaded",
+		"models":   len(server.ModelRegistry.GetAllModels()),
+		"backends": server.BackendManager.GetAvailableBackends(),
+	})
+}
+
+// HandleStatus handles the /status endpoint, adding the reload status to the server's
+func (r *Reloader) HandleStatus(c *gin.Context) {
+	status := r.Current().GetHealthStatus()
+	status["reload"] = r.Status()
+	c.JSON(200, status)
+}
+
+// WatchSignals reloads the configuration whenever the process receives SIGHUP, until ctx is done
+func (r *Reloader) WatchSignals(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			log.Printf("Received SIGHUP, reloading configuration")
+			_ = r.Reload()
+		}
+	}
+}
+
+// WatchFile reloads the configuration whenever the modification time of the configuration
+// file changes, until ctx is done. The file and the polling interval are taken from the
+// current configuration; while config_watch_seconds is 0 watching pauses until a reload
+// by other means sets it again.
+func (r *Reloader) WatchFile(ctx context.Context) {
+	var lastModified time.Time
+	if info, err := os.Stat(r.Current().Config.Path); err == nil {
+		lastModified = info.ModTime()
+	}
+
+	for {
+		cfg := r.Current().Config
+		if cfg.ConfigWatch <= 0 || cfg.Path == "" {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.reloaded:
+			}
+			// The reload read the file, so only later changes count
+			if info, err := os.Stat(r.Current().Config.Path); err == nil {
+				lastModified = info.ModTime()
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(cfg.ConfigWatch) * time.Second):
+		}
+
+		info, err := os.Stat(cfg.Path)
+		if err != nil || info.ModTime().Equal(lastModified) {
+			continue
+		}
+		// A failed reload isn't retried until the file changes again
+		lastModified = info.ModTime()
+		log.Printf("Configuration file %s changed, reloading configuration", cfg.Path)
+		_ = r.Reload()
+	}
+}
@@ -0,0 +1,202 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Policy limits how failed upstream requests are retried
+type Policy struct {
+	// MaxAttempts is the total number of attempts, including the first; 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled for every further retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between attempts; a server asking for a longer wait isn't retried
+	MaxDelay time.Duration
+	// MaxElapsed is the time after which no further attempt is started; zero means no limit
+	MaxElapsed time.Duration
+}
+
+// DefaultPolicy returns the policy used when none is configured
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    8 * time.Second,
+		MaxElapsed:  30 * time.Second,
+	}
+}
+
+// Configurable is implemented by backends whose retries can be configured
+type Configurable interface {
+	SetRetryPolicy(policy Policy)
+}
+
+// Backoff returns the delay before the given retry (1 for the first), with jitter.
+// Half of the exponential delay is fixed and the other half random, so retries from
+// many clients spread out without any retry coming immediately.
+func (p Policy) Backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// Allows reports whether another attempt may follow the given one, after waiting delay,
+// for a request first attempted at start
+func (p Policy) Allows(attempt int, start time.Time, delay time.Duration) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	return p.MaxElapsed <= 0 || time.Since(start)+delay <= p.MaxElapsed
+}
+
+// Wait sleeps for delay, returning early with the context's error if it is cancelled
+func Wait(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// temporaryError marks an error as worth retrying
+type temporaryError struct {
+	err error
+}
+
+func (e *temporaryError) Error() string { return e.err.Error() }
+func (e *temporaryError) Unwrap() error { return e.err }
+
+// Temporary marks err as a transient failure that may succeed when retried
+func Temporary(err error) error {
+	return &temporaryError{err: err}
+}
+
+// IsTemporary reports whether err was marked with Temporary
+func IsTemporary(err error) bool {
+	var temporary *temporaryError
+	return errors.As(err, &temporary)
+}
+
+// StatusError is an upstream failure carrying the HTTP status of the response
+type StatusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *StatusError) Error() string { return e.Err.Error() }
+func (e *StatusError) Unwrap() error { return e.Err }
+
+// WithStatus attaches the HTTP status of a failed upstream response to err
+func WithStatus(status int, err error) error {
+	return &StatusError{StatusCode: status, Err: err}
+}
+
+// Retryable reports whether a failed request may succeed when sent again, possibly to
+// another backend: errors marked Temporary, retryable statuses and network errors
+func Retryable(err error) bool {
+	if IsTemporary(err) {
+		return true
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return RetryableStatus(statusErr.StatusCode)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// RetryableStatus reports whether an HTTP status signals a transient upstream failure.
+// 529 is Anthropic's "overloaded" status.
+func RetryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout, 529:
+		return true
+	}
+	return false
+}
+
+// rateLimitHeaders pairs each provider's remaining-quota header with the header telling when it resets
+var rateLimitHeaders = []struct {
+	remaining string
+	reset     string
+}{
+	{"anthropic-ratelimit-requests-remaining", "anthropic-ratelimit-requests-reset"},
+	{"anthropic-ratelimit-tokens-remaining", "anthropic-ratelimit-tokens-reset"},
+	{"anthropic-ratelimit-input-tokens-remaining", "anthropic-ratelimit-input-tokens-reset"},
+	{"anthropic-ratelimit-output-tokens-remaining", "anthropic-ratelimit-output-tokens-reset"},
+	{"x-ratelimit-remaining-requests", "x-ratelimit-reset-requests"},
+	{"x-ratelimit-remaining-tokens", "x-ratelimit-reset-tokens"},
+}
+
+// ServerDelay returns how long the upstream asked us to wait, if it said.
+// Retry-After (and OpenAI's retry-after-ms) take precedence; otherwise the reset time
+// of an exhausted rate limit is used.
+func ServerDelay(header http.Header, now time.Time) (time.Duration, bool) {
+	if ms, err := strconv.ParseInt(header.Get("retry-after-ms"), 10, 64); err == nil && ms >= 0 {
+		return time.Duration(ms) * time.Millisecond, true
+	}
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds * float64(time.Second)), true
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return nonNegative(at.Sub(now)), true
+		}
+	}
+
+	var longest time.Duration
+	found := false
+	for _, limit := range rateLimitHeaders {
+		if header.Get(limit.remaining) != "0" {
+			continue
+		}
+		delay, ok := parseReset(header.Get(limit.reset), now)
+		if ok && delay >= longest {
+			longest, found = delay, true
+		}
+	}
+	return longest, found
+}
+
+// parseReset reads a rate-limit reset header: an RFC 3339 time (Anthropic)
+// or a duration such as "1s" or "6m0s" (OpenAI)
+func parseReset(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return nonNegative(at.Sub(now)), true
+	}
+	if delay, err := time.ParseDuration(value); err == nil {
+		return nonNegative(delay), true
+	}
+	return 0, false
+}
+
+// nonNegative clamps times in the past to zero
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
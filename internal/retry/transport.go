@@ -0,0 +1,118 @@
+package retry
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// maxDrainBytes bounds how much of a failed response is read so its connection can be reused
+const maxDrainBytes = 64 << 10
+
+// Transport is an http.RoundTripper that retries requests failing with a network error
+// or a retryable status. A response is only handed to the caller once it is final, so
+// a stream is never retried after any of it has been read.
+type Transport struct {
+	// Base performs the requests; http.DefaultTransport is used when nil
+	Base   http.RoundTripper
+	Policy Policy
+}
+
+// NewTransport creates a retrying transport over http.DefaultTransport
+func NewTransport(policy Policy) *Transport {
+	return &Transport{Policy: policy}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	attemptReq := req
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base().RoundTrip(attemptReq)
+		if !t.retryable(req, resp, err) {
+			return resp, err
+		}
+
+		delay := t.Policy.Backoff(attempt)
+		if resp != nil {
+			if serverDelay, ok := ServerDelay(resp.Header, time.Now()); ok {
+				// Retrying sooner than the server asks would only fail again, so a
+				// longer wait than the policy allows gives up instead
+				if t.Policy.MaxDelay > 0 && serverDelay > t.Policy.MaxDelay {
+					return resp, err
+				}
+				delay = serverDelay
+			}
+		}
+		if !t.Policy.Allows(attempt, start, delay) {
+			return resp, err
+		}
+
+		// A request whose body can't be replayed can't be sent again
+		nextReq, rewindErr := rewind(req)
+		if rewindErr != nil {
+			return resp, err
+		}
+
+		reason := describe(resp, err)
+		if resp != nil {
+			_, _ = io.CopyN(io.Discard, resp.Body, maxDrainBytes)
+			if closeErr := resp.Body.Close(); closeErr != nil {
+				fmt.Printf("Warning: failed to close response body: %v\n", closeErr)
+			}
+		}
+		log.Printf("Retrying %s %s in %v after %s (attempt %d of %d)", req.Method, req.URL.Host, delay.Round(time.Millisecond), reason, attempt+1, t.Policy.MaxAttempts)
+
+		if waitErr := Wait(req.Context(), delay); waitErr != nil {
+			return nil, waitErr
+		}
+		attemptReq = nextReq
+	}
+}
+
+// base returns the transport that performs the requests
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// retryable reports whether an attempt failed in a way worth retrying
+func (t *Transport) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		// The caller gave up; don't spend more on the request
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return RetryableStatus(resp.StatusCode)
+}
+
+// rewind returns a copy of the request with a fresh body
+func rewind(req *http.Request) (*http.Request, error) {
+	next := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return next, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("request body cannot be replayed")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	next.Body = body
+	return next, nil
+}
+
+// describe summarizes why an attempt failed, for logging
+func describe(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return resp.Status
+}
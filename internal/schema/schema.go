@@ -0,0 +1,513 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validate checks a JSON document against a JSON Schema.
+// It supports the subset of JSON Schema that structured output schemas use: type, enum, const,
+// properties, required, additionalProperties, items, the length, size and range limits,
+// pattern, allOf/anyOf/oneOf and local $ref pointers. Other keywords are ignored.
+func Validate(schema json.RawMessage, document []byte) error {
+	var root interface{}
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	v := validator{root: root, following: make(map[string]bool)}
+	return v.validate(root, value, "$")
+}
+
+// Check reports whether a schema can be used for validation. Its $ref pointers must resolve,
+// and those that apply to the same value, directly or through allOf/anyOf/oneOf, must not
+// lead back to a schema they started from, since validation would never end.
+func Check(schema json.RawMessage) error {
+	var root interface{}
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	if _, ok := root.(map[string]interface{}); !ok {
+		return fmt.Errorf("schema must be a JSON object")
+	}
+
+	c := checker{
+		validator: validator{root: root},
+		checked:   make(map[string]bool),
+		acyclic:   make(map[string]bool),
+	}
+	return c.check(root, "#")
+}
+
+// validator validates values against the schemas of one root document
+type validator struct {
+	root interface{}
+	// following holds the $ref pointers being followed, with the path of the value they
+	// apply to, so that a pointer leading back to itself fails instead of recursing forever
+	following map[string]bool
+}
+
+// validate checks a value against a schema, reporting the first mismatch with its JSON path
+func (v validator) validate(schema interface{}, value interface{}, path string) error {
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			return fmt.Errorf("%s: no value is allowed here", path)
+		}
+		return nil
+	case map[string]interface{}:
+		return v.validateObjectSchema(s, value, path)
+	default:
+		return fmt.Errorf("%s: schema must be an object or a boolean", path)
+	}
+}
+
+// validateObjectSchema applies every supported keyword of a schema object
+func (v validator) validateObjectSchema(s map[string]interface{}, value interface{}, path string) error {
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := v.resolve(ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		key := ref + " " + path
+		if v.following[key] {
+			return fmt.Errorf("%s: circular $ref %q", path, ref)
+		}
+		v.following[key] = true
+		err = v.validate(target, value, path)
+		delete(v.following, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	if expected, ok := s["type"]; ok {
+		if err := checkType(expected, value, path); err != nil {
+			return err
+		}
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if equal(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value is not one of the allowed values", path)
+		}
+	}
+
+	if constant, ok := s["const"]; ok && !equal(constant, value) {
+		return fmt.Errorf("%s: value does not match the constant", path)
+	}
+
+	if err := v.validateCombinators(s, value, path); err != nil {
+		return err
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		return v.validateObject(s, typed, path)
+	case []interface{}:
+		return v.validateArray(s, typed, path)
+	case string:
+		return validateString(s, typed, path)
+	case json.Number:
+		return validateNumber(s, typed, path)
+	}
+	return nil
+}
+
+// validateCombinators applies allOf, anyOf and oneOf
+func (v validator) validateCombinators(s map[string]interface{}, value interface{}, path string) error {
+	if allOf, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			if err := v.validate(sub, value, path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if v.validate(sub, value, path) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value does not match any of the allowed schemas", path)
+		}
+	}
+
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, sub := range oneOf {
+			if v.validate(sub, value, path) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fmt.Errorf("%s: value matches %d schemas, expected exactly one", path, matches)
+		}
+	}
+	return nil
+}
+
+// validateObject applies the object keywords
+func (v validator) validateObject(s map[string]interface{}, object map[string]interface{}, path string) error {
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, exists := object[key]; !exists {
+					return fmt.Errorf("%s: missing required property %q", path, key)
+				}
+			}
+		}
+	}
+
+	properties, _ := s["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		propertyPath := path + "." + key
+		if propertySchema, ok := properties[key]; ok {
+			if err := v.validate(propertySchema, object[key], propertyPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch additional := s["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: property %q is not allowed", path, key)
+			}
+		case map[string]interface{}:
+			if err := v.validate(additional, object[key], propertyPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateArray applies the array keywords
+func (v validator) validateArray(s map[string]interface{}, array []interface{}, path string) error {
+	if minItems, ok := s["minItems"].(float64); ok && float64(len(array)) < minItems {
+		return fmt.Errorf("%s: expected at least %d items, got %d", path, int(minItems), len(array))
+	}
+	if maxItems, ok := s["maxItems"].(float64); ok && float64(len(array)) > maxItems {
+		return fmt.Errorf("%s: expected at most %d items, got %d", path, int(maxItems), len(array))
+	}
+
+	if items, ok := s["items"]; ok {
+		for i, item := range array {
+			if err := v.validate(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateString applies the string keywords
+func validateString(s map[string]interface{}, str string, path string) error {
+	length := float64(utf8.RuneCountInString(str))
+	if minLength, ok := s["minLength"].(float64); ok && length < minLength {
+		return fmt.Errorf("%s: expected at least %d characters", path, int(minLength))
+	}
+	if maxLength, ok := s["maxLength"].(float64); ok && length > maxLength {
+		return fmt.Errorf("%s: expected at most %d characters", path, int(maxLength))
+	}
+
+	if pattern, ok := s["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern %q: %w", path, pattern, err)
+		}
+		if !re.MatchString(str) {
+			return fmt.Errorf("%s: value does not match pattern %q", path, pattern)
+		}
+	}
+	return nil
+}
+
+// validateNumber applies the numeric range keywords
+func validateNumber(s map[string]interface{}, number json.Number, path string) error {
+	n, err := number.Float64()
+	if err != nil {
+		return fmt.Errorf("%s: invalid number %s", path, number)
+	}
+
+	if minimum, ok := s["minimum"].(float64); ok && n < minimum {
+		return fmt.Errorf("%s: %v is less than the minimum %v", path, n, minimum)
+	}
+	if maximum, ok := s["maximum"].(float64); ok && n > maximum {
+		return fmt.Errorf("%s: %v is greater than the maximum %v", path, n, maximum)
+	}
+	if minimum, ok := s["exclusiveMinimum"].(float64); ok && n <= minimum {
+		return fmt.Errorf("%s: %v must be greater than %v", path, n, minimum)
+	}
+	if maximum, ok := s["exclusiveMaximum"].(float64); ok && n >= maximum {
+		return fmt.Errorf("%s: %v must be less than %v", path, n, maximum)
+	}
+	return nil
+}
+
+// resolve follows a local $ref pointer such as "#/$defs/Item"
+func (v validator) resolve(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported $ref %q: only local references are supported", ref)
+	}
+
+	target := v.root
+	pointer := strings.TrimPrefix(ref, "#")
+	if pointer == "" {
+		return target, nil
+	}
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := target.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		if target, ok = object[token]; !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	return target, nil
+}
+
+// checker checks the $ref pointers of a schema before it is used
+type checker struct {
+	validator
+	// checked holds the pointers of the schemas already checked
+	checked map[string]bool
+	// acyclic holds the pointers of the schemas known not to lead back to themselves
+	acyclic map[string]bool
+}
+
+// check checks a schema and its subschemas, identified by their JSON pointers
+func (c checker) check(schema interface{}, pointer string) error {
+	s, ok := schema.(map[string]interface{})
+	if !ok || c.checked[pointer] {
+		return nil
+	}
+	c.checked[pointer] = true
+
+	if err := c.checkCycle(s, pointer, make(map[string]bool)); err != nil {
+		return err
+	}
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := c.resolve(ref)
+		if err != nil {
+			return err
+		}
+		if err := c.check(target, ref); err != nil {
+			return err
+		}
+	}
+
+	for _, sub := range subschemas(s, pointer) {
+		if err := c.check(sub.schema, sub.pointer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCycle follows the $ref pointers and combinators that apply to the same value as a
+// schema, failing when they lead back to a schema on the way. stack holds that way.
+func (c checker) checkCycle(schema interface{}, pointer string, stack map[string]bool) error {
+	s, ok := schema.(map[string]interface{})
+	if !ok || c.acyclic[pointer] {
+		return nil
+	}
+	if stack[pointer] {
+		return fmt.Errorf("circular $ref at %q", pointer)
+	}
+	stack[pointer] = true
+	defer delete(stack, pointer)
+
+	var next []subschema
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := c.resolve(ref)
+		if err != nil {
+			return err
+		}
+		next = append(next, subschema{pointer: ref, schema: target})
+	}
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		list, _ := s[keyword].([]interface{})
+		for i, sub := range list {
+			next = append(next, subschema{pointer: fmt.Sprintf("%s/%s/%d", pointer, keyword, i), schema: sub})
+		}
+	}
+
+	for _, sub := range next {
+		if err := c.checkCycle(sub.schema, sub.pointer, stack); err != nil {
+			return err
+		}
+	}
+	c.acyclic[pointer] = true
+	return nil
+}
+
+// subschema is a schema within a root document, with its JSON pointer
+type subschema struct {
+	pointer string
+	schema  interface{}
+}
+
+// subschemas returns the schemas nested in a schema under the supported keywords
+func subschemas(s map[string]interface{}, pointer string) []subschema {
+	var subs []subschema
+	for _, keyword := range []string{"properties", "$defs", "definitions"} {
+		named, _ := s[keyword].(map[string]interface{})
+		names := make([]string, 0, len(named))
+		for name := range named {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			token := strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+			subs = append(subs, subschema{pointer: pointer + "/" + keyword + "/" + token, schema: named[name]})
+		}
+	}
+	for _, keyword := range []string{"additionalProperties", "items"} {
+		if sub, ok := s[keyword]; ok {
+			subs = append(subs, subschema{pointer: pointer + "/" + keyword, schema: sub})
+		}
+	}
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		list, _ := s[keyword].([]interface{})
+		for i, sub := range list {
+			subs = append(subs, subschema{pointer: fmt.Sprintf("%s/%s/%d", pointer, keyword, i), schema: sub})
+		}
+	}
+	return subs
+}
+
+// checkType checks a value against a type keyword, which may name one type or list several
+func checkType(expected interface{}, value interface{}, path string) error {
+	var names []string
+	switch typed := expected.(type) {
+	case string:
+		names = []string{typed}
+	case []interface{}:
+		for _, name := range typed {
+			if str, ok := name.(string); ok {
+				names = append(names, str)
+			}
+		}
+	}
+
+	for _, name := range names {
+		if hasType(name, value) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(names, " or "), typeName(value))
+}
+
+// hasType reports whether a decoded JSON value has the named JSON Schema type
+func hasType(name string, value interface{}) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		n, err := number.Float64()
+		return err == nil && n == float64(int64(n))
+	}
+	return false
+}
+
+// typeName returns the JSON Schema type name of a decoded value
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case nil:
+		return "null"
+	}
+	return "unknown"
+}
+
+// equal compares a schema value with a document value, treating numbers by value
+func equal(schemaValue interface{}, value interface{}) bool {
+	return reflect.DeepEqual(normalize(schemaValue), normalize(value))
+}
+
+// normalize converts json.Number values to float64 so decoded documents compare with schemas
+func normalize(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case json.Number:
+		n, err := typed.Float64()
+		if err != nil {
+			return typed.String()
+		}
+		return n
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			normalized[key] = normalize(item)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(typed))
+		for i, item := range typed {
+			normalized[i] = normalize(item)
+		}
+		return normalized
+	}
+	return value
+}
@@ -0,0 +1,163 @@
+package streaming
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleAnthropicStreamingMessages handles streaming Anthropic Messages API requests
+// by emitting the message_start / content_block_delta / message_stop event sequence
+func (sh *StreamingHandler) HandleAnthropicStreamingMessages(c *gin.Context, req types.AnthropicRequest) {
+	modelConfig, chatReq, err := sh.BuildAnthropicChatRequest(req)
+	if err != nil {
+		WriteRequestError(c, err)
+		return
+	}
+
+	// Headers and the opening event are only sent with the first chunk, so errors raised
+	// before anything reaches the client can still be returned as a regular JSON error
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+
+		return sh.writeNamedEvent(c, "message_start", gin.H{
+			"type": "message_start",
+			"message": types.AnthropicResponse{
+				ID:      types.NewAnthropicMessageID(),
+				Type:    "message",
+				Role:    "assistant",
+				Content: []types.AnthropicContentBlock{},
+				Model:   req.Model,
+				// Backends report usage once the response is complete, so the counts
+				// follow in message_delta
+				Usage: types.AnthropicUsage{},
+			},
+		})
+	}
+
+	// Content blocks are numbered in order; text is streamed into an open text block
+	// while each tool call is sent as a complete tool_use block
+	blockIndex := 0
+	textBlockOpen := false
+	hasToolCalls := false
+	startBlock := func(block types.AnthropicContentBlock) error {
+		return sh.writeNamedEvent(c, "content_block_start", gin.H{
+			"type":          "content_block_start",
+			"index":         blockIndex,
+			"content_block": block,
+		})
+	}
+	stopBlock := func() error {
+		err := sh.writeNamedEvent(c, "content_block_stop", gin.H{
+			"type":  "content_block_stop",
+			"index": blockIndex,
+		})
+		blockIndex++
+		return err
+	}
+
+	ctx := requestContext(c)
+	err = sh.backendManager.ProcessStreamRequest(ctx, modelConfig, *chatReq, func(chunk types.StreamChunk) error {
+		if err := start(); err != nil {
+			return err
+		}
+
+		if chunk.Content != "" {
+			if !textBlockOpen {
+				if err := startBlock(types.AnthropicContentBlock{Type: "text"}); err != nil {
+					return err
+				}
+				textBlockOpen = true
+			}
+			if err := sh.writeNamedEvent(c, "content_block_delta", gin.H{
+				"type":  "content_block_delta",
+				"index": blockIndex,
+				"delta": gin.H{"type": "text_delta", "text": chunk.Content},
+			}); err != nil {
+				return err
+			}
+		}
+
+		for _, call := range chunk.ToolCalls {
+			if textBlockOpen {
+				if err := stopBlock(); err != nil {
+					return err
+				}
+				textBlockOpen = false
+			}
+			block := types.ConvertToolCallToAnthropic(call)
+			input := string(block.Input)
+			block.Input = json.RawMessage("{}")
+			if err := startBlock(block); err != nil {
+				return err
+			}
+			if err := sh.writeNamedEvent(c, "content_block_delta", gin.H{
+				"type":  "content_block_delta",
+				"index": blockIndex,
+				"delta": gin.H{"type": "input_json_delta", "partial_json": input},
+			}); err != nil {
+				return err
+			}
+			if err := stopBlock(); err != nil {
+				return err
+			}
+			hasToolCalls = true
+		}
+
+		if !chunk.Done {
+			return nil
+		}
+
+		if textBlockOpen {
+			if err := stopBlock(); err != nil {
+				return err
+			}
+			textBlockOpen = false
+		}
+		usage := types.ConvertUsageToAnthropic(chunk.Usage)
+		stopReason := types.AnthropicStopReason(chunk.DoneReason, hasToolCalls)
+		if err := sh.writeNamedEvent(c, "message_delta", gin.H{
+			"type":  "message_delta",
+			"delta": gin.H{"stop_reason": stopReason, "stop_sequence": nil},
+			"usage": gin.H{"input_tokens": usage.InputTokens, "output_tokens": usage.OutputTokens},
+		}); err != nil {
+			return err
+		}
+		return sh.writeNamedEvent(c, "message_stop", gin.H{"type": "message_stop"})
+	})
+	if err != nil {
+		// Log the error for debugging
+		log.Printf("Error processing streaming Anthropic messages request: %v", err)
+		if !started {
+			c.JSON(http.StatusInternalServerError, types.NewAnthropicError("api_error", err.Error()))
+			return
+		}
+		if err := sh.writeNamedEvent(c, "error", types.NewAnthropicError("api_error", err.Error())); err != nil {
+			log.Printf("Warning: failed to write error event: %v", err)
+		}
+	}
+}
+
+// writeNamedEvent writes a JSON payload as a server-sent event with an "event:" line
+func (sh *StreamingHandler) writeNamedEvent(c *gin.Context, event string, payload interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stream event: %w", err)
+	}
+	if _, err := c.Writer.WriteString("event: " + event + "\n"); err != nil {
+		return fmt.Errorf("failed to write stream event: %w", err)
+	}
+	return sh.writeSSE(c, string(jsonData))
+}
@@ -0,0 +1,127 @@
+package streaming
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleOpenAIStreamingChat handles streaming OpenAI-compatible chat completion requests
+// by emitting server-sent events terminated with "data: [DONE]"
+func (sh *StreamingHandler) HandleOpenAIStreamingChat(c *gin.Context, req types.OpenAIChatRequest) {
+	modelConfig, chatReq, err := sh.BuildOpenAIChatRequest(req)
+	if err != nil {
+		WriteRequestError(c, err)
+		return
+	}
+
+	id := types.NewOpenAICompletionID()
+	created := time.Now().Unix()
+	newChunk := func(delta types.OpenAIDelta, finishReason *string) types.OpenAIChatChunk {
+		return types.OpenAIChatChunk{
+			ID:      id,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   req.Model,
+			Choices: []types.OpenAIChunkChoice{
+				{
+					Index:        0,
+					Delta:        delta,
+					FinishReason: finishReason,
+				},
+			},
+		}
+	}
+
+	// Headers are only sent with the first event, so errors raised before
+	// anything reaches the client can still be returned as a regular JSON error
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		return sh.writeEvent(c, newChunk(types.OpenAIDelta{Role: "assistant"}, nil))
+	}
+
+	// Tool calls are numbered across the whole response
+	toolCallCount := 0
+
+	ctx := requestContext(c)
+	err = sh.backendManager.ProcessStreamRequest(ctx, modelConfig, *chatReq, func(chunk types.StreamChunk) error {
+		if err := start(); err != nil {
+			return err
+		}
+
+		if !chunk.Done {
+			if chunk.Content != "" {
+				if err := sh.writeEvent(c, newChunk(types.OpenAIDelta{Content: chunk.Content}, nil)); err != nil {
+					return err
+				}
+			}
+			if len(chunk.ToolCalls) > 0 {
+				firstIndex := toolCallCount
+				toolCallCount += len(chunk.ToolCalls)
+				return sh.writeEvent(c, newChunk(types.OpenAIDelta{
+					ToolCalls: types.ConvertToolCallsToOpenAI(chunk.ToolCalls, &firstIndex),
+				}, nil))
+			}
+			return nil
+		}
+
+		finishReason := types.OpenAIFinishReason(chunk.DoneReason, toolCallCount > 0)
+		if err := sh.writeEvent(c, newChunk(types.OpenAIDelta{}, &finishReason)); err != nil {
+			return err
+		}
+
+		if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+			usage := types.ConvertUsageToOpenAI(chunk.Usage)
+			usageChunk := newChunk(types.OpenAIDelta{}, nil)
+			usageChunk.Choices = []types.OpenAIChunkChoice{}
+			usageChunk.Usage = &usage
+			if err := sh.writeEvent(c, usageChunk); err != nil {
+				return err
+			}
+		}
+
+		return sh.writeSSE(c, "[DONE]")
+	})
+	if err != nil {
+		// Log the error for debugging
+		log.Printf("Error processing streaming OpenAI chat request: %v", err)
+		if !started {
+			c.JSON(http.StatusInternalServerError, types.NewOpenAIError("api_error", "", err.Error()))
+			return
+		}
+		if err := sh.writeEvent(c, types.NewOpenAIError("api_error", "", err.Error())); err != nil {
+			log.Printf("Warning: failed to write error event: %v", err)
+		}
+	}
+}
+
+// writeEvent writes a JSON payload as a server-sent event
+func (sh *StreamingHandler) writeEvent(c *gin.Context, payload interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stream event: %w", err)
+	}
+	return sh.writeSSE(c, string(jsonData))
+}
+
+// writeSSE writes a single "data:" server-sent event and flushes it to the client
+func (sh *StreamingHandler) writeSSE(c *gin.Context, data string) error {
+	if _, err := c.Writer.WriteString("data: " + data + "\n\n"); err != nil {
+		return fmt.Errorf("failed to write stream event: %w", err)
+	}
+	c.Writer.Flush()
+	return nil
+}
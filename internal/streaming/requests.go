@@ -0,0 +1,162 @@
+package streaming
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The OpenAI and Anthropic endpoints build the backend request the same way whether or not
+// the response is streamed, so both the streaming handlers and the proxy's handlers for
+// complete responses use the builders below.
+
+// RequestError is a client request the proxy refuses, with the status and the error body,
+// in the terms of the client's API, to answer it with
+type RequestError struct {
+	Status   int
+	Response interface{}
+	err      error
+}
+
+func (e *RequestError) Error() string { return e.err.Error() }
+func (e *RequestError) Unwrap() error { return e.err }
+
+// WriteRequestError answers a request with the error returned by one of the builders
+func WriteRequestError(c *gin.Context, err error) {
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		c.JSON(requestErr.Status, requestErr.Response)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// openAIRequestError refuses an OpenAI request with a 400 invalid_request_error
+func openAIRequestError(code string, err error) error {
+	return &RequestError{Status: http.StatusBadRequest, Response: types.NewOpenAIError("invalid_request_error", code, err.Error()), err: err}
+}
+
+// anthropicRequestError refuses an Anthropic request with a 400 invalid_request_error
+func anthropicRequestError(err error) error {
+	return &RequestError{Status: http.StatusBadRequest, Response: types.NewAnthropicError("invalid_request_error", err.Error()), err: err}
+}
+
+// BuildOpenAIChatRequest validates an OpenAI chat completion request and converts it to a
+// backend request for the model it names
+func (sh *StreamingHandler) BuildOpenAIChatRequest(req types.OpenAIChatRequest) (types.ModelConfig, *types.ChatRequest, error) {
+	// Get model configuration
+	modelConfig, exists := sh.modelRegistry.GetModel(req.Model)
+	if !exists {
+		err := fmt.Errorf("The model '%s' does not exist", req.Model)
+		return types.ModelConfig{}, nil, &RequestError{
+			Status:   http.StatusNotFound,
+			Response: types.NewOpenAIError("invalid_request_error", "model_not_found", err.Error()),
+			err:      err,
+		}
+	}
+
+	// Convert messages for validation
+	var messages []types.ChatMessage
+	for _, msg := range req.Messages {
+		messages = append(messages, msg.ToChatMessage())
+	}
+
+	// Validate token limits before making the request
+	if err := types.ValidateTokenLimits(modelConfig, messages); err != nil {
+		return types.ModelConfig{}, nil, openAIRequestError("context_length_exceeded", err)
+	}
+
+	// Reject images sent to models that can't see them
+	if err := types.ValidateImages(modelConfig, messages); err != nil {
+		return types.ModelConfig{}, nil, openAIRequestError("", err)
+	}
+
+	// Constrain the response to JSON when a response_format asks for it
+	format, err := req.Format()
+	if err != nil {
+		return types.ModelConfig{}, nil, openAIRequestError("", err)
+	}
+
+	// Map the sampling parameters onto the request
+	clientOpts, err := req.GenerationOptions()
+	if err != nil {
+		return types.ModelConfig{}, nil, openAIRequestError("", err)
+	}
+	opts, err := sh.backendManager.ResolveOptions(modelConfig, clientOpts)
+	if err != nil {
+		return types.ModelConfig{}, nil, openAIRequestError("", err)
+	}
+
+	// Honor the client's output limit, falling back to our estimate
+	maxTokensForRequest := req.MaxOutputTokens()
+	if maxTokensForRequest == 0 {
+		maxTokensForRequest = types.CalculateMaxTokensForRequest(modelConfig, messages)
+	}
+
+	// Create request for backend
+	chatReq := types.ConvertOpenAIToChatRequest(req, maxTokensForRequest)
+	chatReq.Model = modelConfig.BackendModel
+	chatReq.Options = opts
+	chatReq.ClientOptions = clientOpts
+	chatReq.Format = format
+	return modelConfig, &chatReq, nil
+}
+
+// BuildAnthropicChatRequest validates an Anthropic Messages request and converts it to a
+// backend request for the model it names
+func (sh *StreamingHandler) BuildAnthropicChatRequest(req types.AnthropicRequest) (types.ModelConfig, *types.ChatRequest, error) {
+	// Get model configuration, accepting provider model IDs as well as proxy names
+	modelConfig, exists := sh.modelRegistry.ResolveModel(req.Model)
+	if !exists {
+		err := fmt.Errorf("model: %s", req.Model)
+		return types.ModelConfig{}, nil, &RequestError{
+			Status:   http.StatusNotFound,
+			Response: types.NewAnthropicError("not_found_error", err.Error()),
+			err:      err,
+		}
+	}
+
+	// Convert messages for validation
+	messages := types.ConvertAnthropicMessages(req.System, req.Messages)
+
+	// Validate token limits before making the request
+	if err := types.ValidateTokenLimits(modelConfig, messages); err != nil {
+		return types.ModelConfig{}, nil, anthropicRequestError(err)
+	}
+
+	// Reject images sent to models that can't see them
+	if err := types.ValidateImages(modelConfig, messages); err != nil {
+		return types.ModelConfig{}, nil, anthropicRequestError(err)
+	}
+
+	if err := req.ValidateToolChoice(); err != nil {
+		return types.ModelConfig{}, nil, anthropicRequestError(err)
+	}
+
+	// Map the sampling parameters onto the request
+	clientOpts, err := req.GenerationOptions()
+	if err != nil {
+		return types.ModelConfig{}, nil, anthropicRequestError(err)
+	}
+	opts, err := sh.backendManager.ResolveOptions(modelConfig, clientOpts)
+	if err != nil {
+		return types.ModelConfig{}, nil, anthropicRequestError(err)
+	}
+
+	// Honor the client's output limit, falling back to our estimate
+	maxTokensForRequest := req.MaxTokens
+	if maxTokensForRequest <= 0 {
+		maxTokensForRequest = types.CalculateMaxTokensForRequest(modelConfig, messages)
+	}
+
+	// Create request for backend
+	chatReq := types.ConvertAnthropicToChatRequest(req, maxTokensForRequest)
+	chatReq.Model = modelConfig.BackendModel
+	chatReq.Options = opts
+	chatReq.ClientOptions = clientOpts
+	return modelConfig, &chatReq, nil
+}
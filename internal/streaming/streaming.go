@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 	"time"
 
 	"go-llm-proxy/internal/backend"
@@ -28,28 +27,63 @@ func NewStreamingHandler(backendManager *backend.BackendManager, modelRegistry *
 	}
 }
 
+// streamMetrics holds the timing and token counts reported in the final Ollama record
+type streamMetrics struct {
+	TotalDuration      int64
+	PromptEvalCount    int
+	PromptEvalDuration int64
+	EvalCount          int
+	EvalDuration       int64
+}
+
+// streamTimer measures the latency of a streamed response
+type streamTimer struct {
+	start      time.Time
+	firstChunk time.Time
+}
+
+// newStreamTimer starts timing a streamed response
+func newStreamTimer() *streamTimer {
+	return &streamTimer{start: time.Now()}
+}
+
+// markChunk records the arrival of a content chunk
+func (st *streamTimer) markChunk() {
+	if st.firstChunk.IsZero() {
+		st.firstChunk = time.Now()
+	}
+}
+
+// metrics builds the final record metrics from the measured timings and reported usage
+func (st *streamTimer) metrics(usage *types.Usage) streamMetrics {
+	now := time.Now()
+	firstChunk := st.firstChunk
+	if firstChunk.IsZero() {
+		firstChunk = now
+	}
+
+	metrics := streamMetrics{
+		TotalDuration:      now.Sub(st.start).Nanoseconds(),
+		PromptEvalDuration: firstChunk.Sub(st.start).Nanoseconds(),
+		EvalDuration:       now.Sub(firstChunk).Nanoseconds(),
+	}
+	if usage != nil {
+		metrics.PromptEvalCount = usage.PromptTokens
+		metrics.EvalCount = usage.CompletionTokens
+	}
+	return metrics
+}
+
 // HandleStreamingChat handles streaming chat requests
 func (sh *StreamingHandler) HandleStreamingChat(c *gin.Context, req types.OllamaChatRequest) {
 	// Set headers for streaming
-	c.Header("Content-Type", "application/x-ndjson")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
+	sh.setStreamHeaders(c)
 
 	// Get model configuration
 	modelConfig, exists := sh.modelRegistry.GetModel(req.Model)
 	if !exists {
 		// For streaming responses, we need to return an error in streaming format
-		errorResp := types.OllamaChatResponse{
-			Model:     req.Model,
-			CreatedAt: fmt.Sprintf("%d", time.Now().Unix()),
-			Message: types.OllamaMessage{
-				Role:    "assistant",
-				Content: "Error: model not found",
-			},
-			Done:    true,
-			Context: []int{},
-		}
-		sh.streamResponse(c, errorResp)
+		sh.writeChatError(c, req.Model, "model not found")
 		return
 	}
 
@@ -61,79 +95,94 @@ func (sh *StreamingHandler) HandleStreamingChat(c *gin.Context, req types.Ollama
 
 	// Validate token limits before making the request
 	if err := types.ValidateTokenLimits(modelConfig, messages); err != nil {
-		// For streaming responses, we need to return an error in streaming format
-		errorResp := types.OllamaChatResponse{
-			Model:     req.Model,
-			CreatedAt: fmt.Sprintf("%d", time.Now().Unix()),
-			Message: types.OllamaMessage{
-				Role:    "assistant",
-				Content: fmt.Sprintf("Error: %s", err.Error()),
-			},
-			Done:    true,
-			Context: []int{},
-		}
-		sh.streamResponse(c, errorResp)
+		sh.writeChatError(c, req.Model, err.Error())
 		return
 	}
 
-	// Calculate appropriate max_tokens for this specific request
-	maxTokensForRequest := types.CalculateMaxTokensForRequest(modelConfig, messages)
+	// Reject images sent to models that can't see them
+	if err := types.ValidateImages(modelConfig, messages); err != nil {
+		sh.writeChatError(c, req.Model, err.Error())
+		return
+	}
 
-	// Create non-streaming request for backend
-	chatReq := types.ConvertOllamaToChatRequest(req, maxTokensForRequest)
-	chatReq.Model = modelConfig.BackendModel
+	// Constrain the response to JSON when a format is requested
+	format, err := types.ParseOllamaFormat(req.Format)
+	if err != nil {
+		sh.writeChatError(c, req.Model, err.Error())
+		return
+	}
 
-	// Get response from backend
-	ctx := context.Background()
-	resp, err := sh.backendManager.ProcessRequest(ctx, modelConfig, chatReq)
+	// Map the Ollama options onto the request, letting num_predict override the computed limit
+	clientOpts, err := types.ParseOllamaOptions(req.Options)
 	if err != nil {
-		// Log the error for debugging
-		fmt.Printf("Error processing streaming chat request: %v\n", err)
-		// For streaming responses, we need to return an error in streaming format
-		errorResp := types.OllamaChatResponse{
+		sh.writeChatError(c, req.Model, err.Error())
+		return
+	}
+	opts, err := sh.backendManager.ResolveOptions(modelConfig, clientOpts)
+	if err != nil {
+		sh.writeChatError(c, req.Model, err.Error())
+		return
+	}
+	maxTokensForRequest := opts.MaxTokens(types.CalculateMaxTokensForRequest(modelConfig, messages))
+
+	// Create streaming request for backend
+	chatReq := types.ConvertOllamaToChatRequest(req, maxTokensForRequest)
+	chatReq.Model = modelConfig.BackendModel
+	chatReq.Options = opts
+	chatReq.ClientOptions = clientOpts
+	chatReq.Format = format
+
+	// Forward each upstream delta to the client as soon as it arrives
+	ctx := requestContext(c)
+	timer := newStreamTimer()
+	createdAt := fmt.Sprintf("%d", time.Now().Unix())
+	err = sh.backendManager.ProcessStreamRequest(ctx, modelConfig, chatReq, func(chunk types.StreamChunk) error {
+		resp := types.OllamaChatResponse{
 			Model:     req.Model,
-			CreatedAt: fmt.Sprintf("%d", time.Now().Unix()),
+			CreatedAt: createdAt,
 			Message: types.OllamaMessage{
-				Role:    "assistant",
-				Content: fmt.Sprintf("Error: %s", err.Error()),
+				Role:      "assistant",
+				Content:   chunk.Content,
+				ToolCalls: chunk.ToolCalls,
 			},
-			Done:    true,
+			Done:    chunk.Done,
 			Context: []int{},
 		}
-		sh.streamResponse(c, errorResp)
-		return
-	}
 
-	chatResp, ok := resp.(*types.ChatResponse)
-	if !ok {
-		c.JSON(500, gin.H{"error": "invalid response type"})
-		return
-	}
+		if !chunk.Done {
+			if chunk.Content == "" && len(chunk.ToolCalls) == 0 {
+				return nil
+			}
+			timer.markChunk()
+			return sh.writeResponse(c, resp)
+		}
 
-	// Convert to Ollama format and stream
-	ollamaResp := types.ConvertChatToOllamaResponse(chatResp, req.Model)
-	sh.streamResponse(c, ollamaResp)
+		metrics := timer.metrics(chunk.Usage)
+		resp.TotalDuration = metrics.TotalDuration
+		resp.PromptEvalCount = metrics.PromptEvalCount
+		resp.PromptEvalDuration = metrics.PromptEvalDuration
+		resp.EvalCount = metrics.EvalCount
+		resp.EvalDuration = metrics.EvalDuration
+		resp.DoneReason = chunk.DoneReason
+		return sh.writeResponse(c, resp)
+	})
+	if err != nil {
+		// Log the error for debugging
+		fmt.Printf("Error processing streaming chat request: %v\n", err)
+		sh.writeChatError(c, req.Model, err.Error())
+	}
 }
 
 // HandleStreamingGenerate handles streaming generate requests
 func (sh *StreamingHandler) HandleStreamingGenerate(c *gin.Context, req types.OllamaGenerateRequest) {
 	// Set headers for streaming
-	c.Header("Content-Type", "application/x-ndjson")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
+	sh.setStreamHeaders(c)
 
 	// Get model configuration
 	modelConfig, exists := sh.modelRegistry.GetModel(req.Model)
 	if !exists {
 		// For streaming responses, we need to return an error in streaming format
-		errorResp := types.OllamaGenerateResponse{
-			Model:     req.Model,
-			CreatedAt: fmt.Sprintf("%d", time.Now().Unix()),
-			Response:  "Error: model not found",
-			Done:      true,
-			Context:   []int{},
-		}
-		sh.streamResponse(c, errorResp)
+		sh.writeGenerateError(c, req.Model, "model not found")
 		return
 	}
 
@@ -143,144 +192,139 @@ func (sh *StreamingHandler) HandleStreamingGenerate(c *gin.Context, req types.Ol
 	messages = append(messages, types.ChatMessage{
 		Role:    "user",
 		Content: req.Prompt,
+		Images:  req.Images,
 	})
-	maxTokensForRequest := types.CalculateMaxTokensForRequest(modelConfig, messages)
 
-	// Create non-streaming request for backend
-	generateReq := types.ConvertOllamaToGenerateRequest(req, maxTokensForRequest)
-	generateReq.Model = modelConfig.BackendModel
+	// Reject images sent to models that can't see them
+	if err := types.ValidateImages(modelConfig, messages); err != nil {
+		sh.writeGenerateError(c, req.Model, err.Error())
+		return
+	}
 
-	// Get response from backend
-	ctx := context.Background()
-	resp, err := sh.backendManager.ProcessRequest(ctx, modelConfig, generateReq)
+	// Constrain the response to JSON when a format is requested
+	format, err := types.ParseOllamaFormat(req.Format)
 	if err != nil {
-		// Log the error for debugging
-		fmt.Printf("Error processing streaming generate request: %v\n", err)
-		// For streaming responses, we need to return an error in streaming format
-		errorResp := types.OllamaGenerateResponse{
-			Model:     req.Model,
-			CreatedAt: fmt.Sprintf("%d", time.Now().Unix()),
-			Response:  fmt.Sprintf("Error: %s", err.Error()),
-			Done:      true,
-			Context:   []int{},
-		}
-		sh.streamResponse(c, errorResp)
+		sh.writeGenerateError(c, req.Model, err.Error())
 		return
 	}
 
-	generateResp, ok := resp.(*types.GenerateResponse)
-	if !ok {
-		// For streaming responses, we need to return an error in streaming format
-		errorResp := types.OllamaGenerateResponse{
-			Model:     req.Model,
-			CreatedAt: fmt.Sprintf("%d", time.Now().Unix()),
-			Response:  "Error: invalid response type",
-			Done:      true,
-			Context:   []int{},
-		}
-		sh.streamResponse(c, errorResp)
+	// Map the Ollama options onto the request, letting num_predict override the computed limit
+	clientOpts, err := types.ParseOllamaOptions(req.Options)
+	if err != nil {
+		sh.writeGenerateError(c, req.Model, err.Error())
 		return
 	}
-
-	// Convert to Ollama format and stream
-	ollamaResp := types.ConvertGenerateToOllamaResponse(generateResp, req.Model)
-	sh.streamResponse(c, ollamaResp)
-}
-
-// streamResponse streams a response by breaking it into chunks
-func (sh *StreamingHandler) streamResponse(c *gin.Context, response interface{}) {
-	// For now, we'll simulate streaming by breaking the response into chunks
-	// In a real implementation, you might want to use actual streaming from the backend
-
-	content, model, createdAt, err := sh.extractResponseData(response)
+	opts, err := sh.backendManager.ResolveOptions(modelConfig, clientOpts)
 	if err != nil {
-		c.JSON(500, gin.H{"error": err.Error()})
+		sh.writeGenerateError(c, req.Model, err.Error())
 		return
 	}
+	maxTokensForRequest := opts.MaxTokens(types.CalculateMaxTokensForRequest(modelConfig, messages))
+
+	// Create streaming request for backend
+	generateReq := types.ConvertOllamaToGenerateRequest(req, maxTokensForRequest)
+	generateReq.Model = modelConfig.BackendModel
+	generateReq.Options = opts
+	generateReq.ClientOptions = clientOpts
+	generateReq.Format = format
+
+	// Forward each upstream delta to the client as soon as it arrives
+	ctx := requestContext(c)
+	timer := newStreamTimer()
+	createdAt := fmt.Sprintf("%d", time.Now().Unix())
+	err = sh.backendManager.ProcessStreamRequest(ctx, modelConfig, generateReq, func(chunk types.StreamChunk) error {
+		resp := types.OllamaGenerateResponse{
+			Model:     req.Model,
+			CreatedAt: createdAt,
+			Response:  chunk.Content,
+			Done:      chunk.Done,
+			Context:   []int{},
+		}
 
-	// Check if this is an error response (contains "Error:")
-	isError := strings.Contains(content, "Error:")
+		if !chunk.Done {
+			if chunk.Content == "" {
+				return nil
+			}
+			timer.markChunk()
+			return sh.writeResponse(c, resp)
+		}
 
-	if isError {
-		sh.streamErrorResponse(c, response, content, model, createdAt)
-	} else {
-		sh.streamNormalResponse(c, response, content, model, createdAt)
+		metrics := timer.metrics(chunk.Usage)
+		resp.TotalDuration = metrics.TotalDuration
+		resp.PromptEvalCount = metrics.PromptEvalCount
+		resp.PromptEvalDuration = metrics.PromptEvalDuration
+		resp.EvalCount = metrics.EvalCount
+		resp.EvalDuration = metrics.EvalDuration
+		resp.DoneReason = chunk.DoneReason
+		return sh.writeResponse(c, resp)
+	})
+	if err != nil {
+		// Log the error for debugging
+		fmt.Printf("Error processing streaming generate request: %v\n", err)
+		sh.writeGenerateError(c, req.Model, err.Error())
 	}
 }
 
-// extractResponseData extracts content, model, and createdAt from response
-func (sh *StreamingHandler) extractResponseData(response interface{}) (string, string, string, error) {
-	switch resp := response.(type) {
-	case types.OllamaChatResponse:
-		return resp.Message.Content, resp.Model, resp.CreatedAt, nil
-	case types.OllamaGenerateResponse:
-		return resp.Response, resp.Model, resp.CreatedAt, nil
-	default:
-		return "", "", "", fmt.Errorf("unsupported response type")
+// requestContext returns the context of the client request, which is cancelled when the client
+// disconnects, and which reports the model serving the stream in the X-Served-Model header.
+// Handlers invoked without an HTTP request get a background context.
+func requestContext(c *gin.Context) context.Context {
+	ctx := context.Background()
+	if c.Request != nil {
+		ctx = c.Request.Context()
 	}
+	return backend.WithServedModelCallback(ctx, func(model string) {
+		c.Header(backend.ServedModelHeader, model)
+	})
 }
 
-// streamErrorResponse streams an error response as a single chunk
-func (sh *StreamingHandler) streamErrorResponse(c *gin.Context, response interface{}, content, model, createdAt string) {
-	streamResp := sh.createStreamResponse(response, content, model, createdAt, true)
-	sh.writeResponse(c, streamResp)
+// setStreamHeaders sets the headers for an NDJSON stream
+func (sh *StreamingHandler) setStreamHeaders(c *gin.Context) {
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
 }
 
-// streamNormalResponse streams a normal response by breaking it into chunks
-func (sh *StreamingHandler) streamNormalResponse(c *gin.Context, response interface{}, content, model, createdAt string) {
-	chunkSize := 3 // Small chunks for demonstration
-	for i := 0; i < len(content); i += chunkSize {
-		end := i + chunkSize
-		if end > len(content) {
-			end = len(content)
-		}
-
-		chunk := content[i:end]
-		done := end >= len(content)
-
-		streamResp := sh.createStreamResponse(response, chunk, model, createdAt, done)
-		sh.writeResponse(c, streamResp)
-
-		// Small delay to simulate streaming
-		time.Sleep(50 * time.Millisecond)
+// writeChatError writes an error as the final record of a chat stream
+func (sh *StreamingHandler) writeChatError(c *gin.Context, model, message string) {
+	errorResp := types.OllamaChatResponse{
+		Model:     model,
+		CreatedAt: fmt.Sprintf("%d", time.Now().Unix()),
+		Message: types.OllamaMessage{
+			Role:    "assistant",
+			Content: fmt.Sprintf("Error: %s", message),
+		},
+		Done:    true,
+		Context: []int{},
+	}
+	if err := sh.writeResponse(c, errorResp); err != nil {
+		fmt.Printf("Warning: failed to write error response: %v\n", err)
 	}
 }
 
-// createStreamResponse creates a streaming response based on the original response type
-func (sh *StreamingHandler) createStreamResponse(response interface{}, content, model, createdAt string, done bool) interface{} {
-	switch response.(type) {
-	case types.OllamaChatResponse:
-		return types.OllamaChatResponse{
-			Model:     model,
-			CreatedAt: createdAt,
-			Message: types.OllamaMessage{
-				Role:    "assistant",
-				Content: content,
-			},
-			Done:    done,
-			Context: []int{},
-		}
-	case types.OllamaGenerateResponse:
-		return types.OllamaGenerateResponse{
-			Model:     model,
-			CreatedAt: createdAt,
-			Response:  content,
-			Done:      done,
-			Context:   []int{},
-		}
-	default:
-		return nil
+// writeGenerateError writes an error as the final record of a generate stream
+func (sh *StreamingHandler) writeGenerateError(c *gin.Context, model, message string) {
+	errorResp := types.OllamaGenerateResponse{
+		Model:     model,
+		CreatedAt: fmt.Sprintf("%d", time.Now().Unix()),
+		Response:  fmt.Sprintf("Error: %s", message),
+		Done:      true,
+		Context:   []int{},
+	}
+	if err := sh.writeResponse(c, errorResp); err != nil {
+		fmt.Printf("Warning: failed to write error response: %v\n", err)
 	}
 }
 
-// writeResponse writes a response to the client
-func (sh *StreamingHandler) writeResponse(c *gin.Context, response interface{}) {
-	jsonData, _ := json.Marshal(response)
-	if _, err := c.Writer.Write(jsonData); err != nil {
-		fmt.Printf("Warning: failed to write JSON data: %v\n", err)
+// writeResponse writes a response to the client as a single NDJSON line
+func (sh *StreamingHandler) writeResponse(c *gin.Context, response interface{}) error {
+	jsonData, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stream response: %w", err)
 	}
-	if _, err := c.Writer.WriteString("\n"); err != nil {
-		fmt.Printf("Warning: failed to write newline: %v\n", err)
+	if _, err := c.Writer.Write(append(jsonData, '\n')); err != nil {
+		return fmt.Errorf("failed to write stream response: %w", err)
 	}
 	c.Writer.Flush()
+	return nil
 }
@@ -0,0 +1,379 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Anthropic API Structures
+type AnthropicRequest struct {
+	Model         string             `json:"model"`
+	MaxTokens     int                `json:"max_tokens"`
+	Messages      []AnthropicMessage `json:"messages"`
+	System        AnthropicContent   `json:"system"`
+	Tools         []AnthropicTool    `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice        `json:"tool_choice,omitempty"`
+	Stream        bool               `json:"stream"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	TopK          *int               `json:"top_k,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+}
+
+type AnthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type AnthropicMessage struct {
+	Role    string           `json:"role"`
+	Content AnthropicContent `json:"content"`
+}
+
+// AnthropicContent holds message content, which Anthropic allows to be either
+// a plain string or an array of content blocks
+type AnthropicContent struct {
+	Text   string
+	Blocks []AnthropicContentBlock
+}
+
+// AnthropicContentBlock is a text, image, tool_use or tool_result content block
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+
+	// image fields
+	Source *AnthropicImageSource `json:"source,omitempty"`
+
+	// tool_use fields
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result fields
+	ToolUseID string            `json:"tool_use_id,omitempty"`
+	Content   *AnthropicContent `json:"content,omitempty"`
+	IsError   bool              `json:"is_error,omitempty"`
+}
+
+// AnthropicImageSource holds the data of an image block, either inline ("base64") or as a "url"
+type AnthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// Image returns the image as a data URL, or its URL for url sources, which ParseImage rejects
+func (src AnthropicImageSource) Image() string {
+	if src.Type == "url" {
+		return src.URL
+	}
+	return "data:" + src.MediaType + ";base64," + src.Data
+}
+
+// MarshalJSON always writes the text of text blocks, even when it is empty,
+// since content_block_start events open text blocks with "text": ""
+func (b AnthropicContentBlock) MarshalJSON() ([]byte, error) {
+	type block AnthropicContentBlock
+	if b.Type == "text" {
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{Type: b.Type, Text: b.Text})
+	}
+	return json.Marshal(block(b))
+}
+
+// UnmarshalJSON accepts both the string and the content block array forms
+func (ac *AnthropicContent) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "null" {
+		*ac = AnthropicContent{}
+		return nil
+	}
+	if data[0] == '"' {
+		ac.Blocks = nil
+		return json.Unmarshal(data, &ac.Text)
+	}
+
+	var blocks []AnthropicContentBlock
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return fmt.Errorf("content must be a string or an array of content blocks: %w", err)
+	}
+	ac.Text = ""
+	ac.Blocks = blocks
+	return nil
+}
+
+// MarshalJSON writes the array form when content blocks are present and the string form otherwise
+func (ac AnthropicContent) MarshalJSON() ([]byte, error) {
+	if len(ac.Blocks) > 0 {
+		return json.Marshal(ac.Blocks)
+	}
+	return json.Marshal(ac.Text)
+}
+
+// String returns the text of the content, joining text blocks together
+func (ac AnthropicContent) String() string {
+	if len(ac.Blocks) == 0 {
+		return ac.Text
+	}
+
+	var texts []string
+	for _, block := range ac.Blocks {
+		if block.Type == "text" {
+			texts = append(texts, block.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+type AnthropicResponse struct {
+	ID           string                  `json:"id"`
+	Type         string                  `json:"type"`
+	Role         string                  `json:"role"`
+	Content      []AnthropicContentBlock `json:"content"`
+	Model        string                  `json:"model"`
+	StopReason   *string                 `json:"stop_reason"`
+	StopSequence *string                 `json:"stop_sequence"`
+	Usage        AnthropicUsage          `json:"usage"`
+}
+
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type AnthropicCountTokensRequest struct {
+	Model    string             `json:"model"`
+	Messages []AnthropicMessage `json:"messages"`
+	System   AnthropicContent   `json:"system"`
+}
+
+type AnthropicCountTokensResponse struct {
+	InputTokens int `json:"input_tokens"`
+}
+
+type AnthropicErrorResponse struct {
+	Type  string         `json:"type"`
+	Error AnthropicError `json:"error"`
+}
+
+type AnthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// NewAnthropicError creates an Anthropic-style error body
+func NewAnthropicError(errType, message string) AnthropicErrorResponse {
+	return AnthropicErrorResponse{
+		Type: "error",
+		Error: AnthropicError{
+			Type:    errType,
+			Message: message,
+		},
+	}
+}
+
+// NewAnthropicMessageID generates a random message ID
+func NewAnthropicMessageID() string {
+	return newRandomID("msg_")
+}
+
+// ToChatMessages converts an AnthropicMessage to chat messages.
+// Each tool_result block becomes its own "tool" message, ahead of any text in the same turn,
+// tool_use blocks become tool calls on the assistant message and image blocks its images.
+func (am AnthropicMessage) ToChatMessages() []ChatMessage {
+	var chatMessages []ChatMessage
+	var toolCalls []ToolCall
+	var images []string
+	for _, block := range am.Content.Blocks {
+		switch block.Type {
+		case "image":
+			if block.Source != nil {
+				images = append(images, block.Source.Image())
+			}
+		case "tool_result":
+			var result string
+			if block.Content != nil {
+				result = block.Content.String()
+			}
+			chatMessages = append(chatMessages, ChatMessage{
+				Role:       "tool",
+				Content:    result,
+				ToolCallID: block.ToolUseID,
+			})
+		case "tool_use":
+			input := block.Input
+			if len(input) == 0 {
+				input = json.RawMessage("{}")
+			}
+			toolCalls = append(toolCalls, ToolCall{
+				ID: block.ID,
+				Function: ToolCallFunction{
+					Name:      block.Name,
+					Arguments: input,
+				},
+			})
+		}
+	}
+
+	// Skip the text message when the turn only carried tool results
+	text := am.Content.String()
+	if text != "" || len(toolCalls) > 0 || len(images) > 0 || len(chatMessages) == 0 {
+		chatMessages = append(chatMessages, ChatMessage{
+			Role:      am.Role,
+			Content:   text,
+			Images:    images,
+			ToolCalls: toolCalls,
+		})
+	}
+	return chatMessages
+}
+
+// ConvertAnthropicMessages converts an Anthropic system prompt and conversation to chat messages.
+// The system prompt becomes a leading "system" message.
+func ConvertAnthropicMessages(system AnthropicContent, messages []AnthropicMessage) []ChatMessage {
+	var chatMessages []ChatMessage
+	if systemText := system.String(); systemText != "" {
+		chatMessages = append(chatMessages, ChatMessage{
+			Role:    "system",
+			Content: systemText,
+		})
+	}
+	for _, msg := range messages {
+		chatMessages = append(chatMessages, msg.ToChatMessages()...)
+	}
+	return AssignToolCallIDs(chatMessages)
+}
+
+// GenerationOptions returns the sampling parameters of the request as generation options
+func (req AnthropicRequest) GenerationOptions() (GenerationOptions, error) {
+	if req.Temperature != nil && (*req.Temperature < 0 || *req.Temperature > 1) {
+		return GenerationOptions{}, fmt.Errorf("temperature: must be between 0 and 1")
+	}
+	if req.TopP != nil && (*req.TopP < 0 || *req.TopP > 1) {
+		return GenerationOptions{}, fmt.Errorf("top_p: must be between 0 and 1")
+	}
+	if req.TopK != nil && *req.TopK < 1 {
+		return GenerationOptions{}, fmt.Errorf("top_k: must be at least 1")
+	}
+
+	return GenerationOptions{
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		TopK:        req.TopK,
+		Stop:        req.StopSequences,
+	}, nil
+}
+
+// ValidateToolChoice checks that the tool_choice of the request is known and names a declared tool
+func (req AnthropicRequest) ValidateToolChoice() error {
+	if req.ToolChoice == nil {
+		return nil
+	}
+
+	switch req.ToolChoice.Type {
+	case "auto", "any", "none":
+		return nil
+	case "tool":
+		for _, tool := range req.Tools {
+			if tool.Name == req.ToolChoice.Name {
+				return nil
+			}
+		}
+		return fmt.Errorf("tool_choice: tool %q is not defined in tools", req.ToolChoice.Name)
+	default:
+		return fmt.Errorf("tool_choice: unsupported type %q", req.ToolChoice.Type)
+	}
+}
+
+// AnthropicStopReason returns the stop_reason of a response that stopped for an Ollama
+// done reason (see OllamaDoneReason)
+func AnthropicStopReason(doneReason string, hasToolCalls bool) string {
+	switch {
+	case hasToolCalls:
+		return "tool_use"
+	case doneReason == "length":
+		return "max_tokens"
+	default:
+		return "end_turn"
+	}
+}
+
+// ConvertAnthropicToChatRequest converts an Anthropic Messages request to our format
+func ConvertAnthropicToChatRequest(req AnthropicRequest, maxTokens int) ChatRequest {
+	var tools []Tool
+	for _, tool := range req.Tools {
+		tools = append(tools, Tool{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			},
+		})
+	}
+
+	return ChatRequest{
+		Model:      req.Model,
+		Messages:   ConvertAnthropicMessages(req.System, req.Messages),
+		Tools:      tools,
+		MaxTokens:  maxTokens,
+		ToolChoice: req.ToolChoice,
+	}
+}
+
+// ConvertToolCallToAnthropic converts a tool call to a tool_use content block
+func ConvertToolCallToAnthropic(call ToolCall) AnthropicContentBlock {
+	input := call.Function.Arguments
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+	}
+	return AnthropicContentBlock{
+		Type:  "tool_use",
+		ID:    call.ID,
+		Name:  call.Function.Name,
+		Input: input,
+	}
+}
+
+// ConvertUsageToAnthropic converts backend usage to the Anthropic usage format
+func ConvertUsageToAnthropic(usage *Usage) AnthropicUsage {
+	if usage == nil {
+		return AnthropicUsage{}
+	}
+	return AnthropicUsage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+	}
+}
+
+// ConvertChatToAnthropicResponse converts our chat response to the Anthropic Messages format
+func ConvertChatToAnthropicResponse(resp *ChatResponse, model string) AnthropicResponse {
+	stopReason := AnthropicStopReason(resp.DoneReason, len(resp.Message.ToolCalls) > 0)
+	var content []AnthropicContentBlock
+	if resp.Message.Content != "" || len(resp.Message.ToolCalls) == 0 {
+		content = append(content, AnthropicContentBlock{
+			Type: "text",
+			Text: resp.Message.Content,
+		})
+	}
+	for _, call := range resp.Message.ToolCalls {
+		content = append(content, ConvertToolCallToAnthropic(call))
+	}
+
+	return AnthropicResponse{
+		ID:         NewAnthropicMessageID(),
+		Type:       "message",
+		Role:       "assistant",
+		Content:    content,
+		Model:      model,
+		StopReason: &stopReason,
+		Usage:      ConvertUsageToAnthropic(resp.Usage),
+	}
+}
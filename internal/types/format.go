@@ -0,0 +1,61 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go-llm-proxy/internal/schema"
+)
+
+// ResponseFormat constrains a response to JSON, optionally matching a JSON Schema
+type ResponseFormat struct {
+	// Schema is empty when any JSON value is acceptable
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+// ParseOllamaFormat reads the Ollama format field, which is either "json" or a JSON Schema object.
+// It returns nil when no format was requested.
+func ParseOllamaFormat(format json.RawMessage) (*ResponseFormat, error) {
+	format = bytes.TrimSpace(format)
+	if len(format) == 0 || string(format) == "null" || string(format) == `""` {
+		return nil, nil
+	}
+
+	if format[0] == '"' {
+		var name string
+		if err := json.Unmarshal(format, &name); err != nil {
+			return nil, fmt.Errorf("invalid format: %w", err)
+		}
+		if name != "json" {
+			return nil, fmt.Errorf(`format must be "json" or a JSON schema, got %q`, name)
+		}
+		return &ResponseFormat{}, nil
+	}
+
+	if err := schema.Check(format); err != nil {
+		return nil, fmt.Errorf("invalid format: %w", err)
+	}
+	return &ResponseFormat{Schema: format}, nil
+}
+
+// HasSchema reports whether the format carries a JSON Schema
+func (f *ResponseFormat) HasSchema() bool {
+	return f != nil && len(f.Schema) > 0
+}
+
+// Validate checks that content is JSON and matches the schema, if there is one
+func (f *ResponseFormat) Validate(content string) error {
+	content = strings.TrimSpace(content)
+	if !json.Valid([]byte(content)) {
+		return fmt.Errorf("response is not valid JSON")
+	}
+	if !f.HasSchema() {
+		return nil
+	}
+	if err := schema.Validate(f.Schema, []byte(content)); err != nil {
+		return fmt.Errorf("response does not match the schema: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// supportedImageTypes lists the image media types accepted by the vision backends
+var supportedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Image is a decoded image attachment
+type Image struct {
+	MediaType string
+	// Data is the base64-encoded image without any data URL prefix
+	Data string
+}
+
+// DataURL returns the image as a data URL, as expected by OpenAI image_url parts
+func (img Image) DataURL() string {
+	return "data:" + img.MediaType + ";base64," + img.Data
+}
+
+// ParseImage parses a base64-encoded image as sent by Ollama clients.
+// Data URLs are accepted too, and the media type is detected from the image bytes.
+func ParseImage(image string) (Image, error) {
+	data := strings.TrimSpace(image)
+	if strings.HasPrefix(data, "http://") || strings.HasPrefix(data, "https://") {
+		return Image{}, fmt.Errorf("image URLs are not supported, send the image as a base64 data URL")
+	}
+	if strings.HasPrefix(data, "data:") {
+		comma := strings.Index(data, ",")
+		if comma < 0 || !strings.HasSuffix(data[:comma], ";base64") {
+			return Image{}, fmt.Errorf("image data URL must be base64-encoded")
+		}
+		data = data[comma+1:]
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return Image{}, fmt.Errorf("image is not valid base64: %w", err)
+	}
+
+	mediaType := http.DetectContentType(decoded)
+	if !supportedImageTypes[mediaType] {
+		return Image{}, fmt.Errorf("unsupported image type %s (supported: jpeg, png, gif, webp)", mediaType)
+	}
+
+	return Image{MediaType: mediaType, Data: data}, nil
+}
+
+// HasImages reports whether any message carries images
+func HasImages(messages []ChatMessage) bool {
+	for _, msg := range messages {
+		if len(msg.Images) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateImages checks that a request carrying images targets a vision-capable model
+// and that every image can be decoded
+func ValidateImages(modelConfig ModelConfig, messages []ChatMessage) error {
+	if !HasImages(messages) {
+		return nil
+	}
+
+	if !modelConfig.Vision {
+		return fmt.Errorf("model %s does not support image input", modelConfig.Name)
+	}
+
+	for _, msg := range messages {
+		for i, image := range msg.Images {
+			if _, err := ParseImage(image); err != nil {
+				return fmt.Errorf("invalid image %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,420 @@
+package types
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go-llm-proxy/internal/schema"
+)
+
+// OpenAI-compatible API Structures
+type OpenAIChatRequest struct {
+	Model               string                `json:"model"`
+	Messages            []OpenAIMessage       `json:"messages"`
+	Stream              bool                  `json:"stream"`
+	StreamOptions       *OpenAIStreamOptions  `json:"stream_options,omitempty"`
+	MaxTokens           int                   `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int                   `json:"max_completion_tokens,omitempty"`
+	Tools               []Tool                `json:"tools,omitempty"`
+	Temperature         *float64              `json:"temperature,omitempty"`
+	TopP                *float64              `json:"top_p,omitempty"`
+	Stop                OpenAIStop            `json:"stop,omitempty"`
+	Seed                *int                  `json:"seed,omitempty"`
+	ResponseFormat      *OpenAIResponseFormat `json:"response_format,omitempty"`
+}
+
+// OpenAIStop holds the stop sequences, which OpenAI allows to be a single string or an array
+type OpenAIStop []string
+
+// UnmarshalJSON accepts both the string and the array forms
+func (o *OpenAIStop) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var stop string
+		if err := json.Unmarshal(data, &stop); err != nil {
+			return err
+		}
+		*o = OpenAIStop{stop}
+		return nil
+	}
+
+	var stop []string
+	if err := json.Unmarshal(data, &stop); err != nil {
+		return fmt.Errorf("stop must be a string or an array of strings: %w", err)
+	}
+	*o = stop
+	return nil
+}
+
+// OpenAIResponseFormat is the response_format of a chat completion request: text, json_object
+// or json_schema
+type OpenAIResponseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema *struct {
+		Name   string          `json:"name"`
+		Schema json.RawMessage `json:"schema"`
+		Strict bool            `json:"strict,omitempty"`
+	} `json:"json_schema,omitempty"`
+}
+
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type OpenAIMessage struct {
+	Role       string           `json:"role"`
+	Content    OpenAIContent    `json:"content"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type OpenAIToolCall struct {
+	// Index is only set in streamed chunks
+	Index    *int               `json:"index,omitempty"`
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+// OpenAIFunctionCall holds a function name and its JSON-encoded arguments
+type OpenAIFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// OpenAIContent holds message content, which OpenAI allows to be either
+// a plain string or an array of typed content parts
+type OpenAIContent struct {
+	Text  string
+	Parts []OpenAIContentPart
+}
+
+type OpenAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+}
+
+// OpenAIImageURL is the image of an image_url content part, given as a URL or a data URL
+type OpenAIImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// UnmarshalJSON accepts both the string and the content part array forms
+func (oc *OpenAIContent) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "null" {
+		*oc = OpenAIContent{}
+		return nil
+	}
+	if data[0] == '"' {
+		oc.Parts = nil
+		return json.Unmarshal(data, &oc.Text)
+	}
+
+	var parts []OpenAIContentPart
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("content must be a string or an array of content parts: %w", err)
+	}
+	oc.Text = ""
+	oc.Parts = parts
+	return nil
+}
+
+// MarshalJSON writes the array form when content parts are present and the string form otherwise
+func (oc OpenAIContent) MarshalJSON() ([]byte, error) {
+	if len(oc.Parts) > 0 {
+		return json.Marshal(oc.Parts)
+	}
+	return json.Marshal(oc.Text)
+}
+
+// String returns the text of the content, joining text parts together
+func (oc OpenAIContent) String() string {
+	if len(oc.Parts) == 0 {
+		return oc.Text
+	}
+
+	var texts []string
+	for _, part := range oc.Parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Images returns the images of the image_url content parts
+func (oc OpenAIContent) Images() []string {
+	var images []string
+	for _, part := range oc.Parts {
+		if part.Type == "image_url" && part.ImageURL != nil {
+			images = append(images, part.ImageURL.URL)
+		}
+	}
+	return images
+}
+
+type OpenAIChatResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []OpenAIChoice `json:"choices"`
+	Usage   OpenAIUsage    `json:"usage"`
+}
+
+type OpenAIChoice struct {
+	Index        int           `json:"index"`
+	Message      OpenAIMessage `json:"message"`
+	FinishReason string        `json:"finish_reason"`
+}
+
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type OpenAIChatChunk struct {
+	ID      string              `json:"id"`
+	Object  string              `json:"object"`
+	Created int64               `json:"created"`
+	Model   string              `json:"model"`
+	Choices []OpenAIChunkChoice `json:"choices"`
+	Usage   *OpenAIUsage        `json:"usage,omitempty"`
+}
+
+type OpenAIChunkChoice struct {
+	Index        int         `json:"index"`
+	Delta        OpenAIDelta `json:"delta"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+type OpenAIDelta struct {
+	Role      string           `json:"role,omitempty"`
+	Content   string           `json:"content,omitempty"`
+	ToolCalls []OpenAIToolCall `json:"tool_calls,omitempty"`
+}
+
+type OpenAIModelEntry struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+type OpenAIModelList struct {
+	Object string             `json:"object"`
+	Data   []OpenAIModelEntry `json:"data"`
+}
+
+type OpenAIErrorResponse struct {
+	Error OpenAIError `json:"error"`
+}
+
+type OpenAIError struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Code    *string `json:"code"`
+}
+
+// NewOpenAIError creates an OpenAI-style error body
+func NewOpenAIError(errType, code, message string) OpenAIErrorResponse {
+	resp := OpenAIErrorResponse{
+		Error: OpenAIError{
+			Message: message,
+			Type:    errType,
+		},
+	}
+	if code != "" {
+		resp.Error.Code = &code
+	}
+	return resp
+}
+
+// NewOpenAICompletionID generates a random chat completion ID
+func NewOpenAICompletionID() string {
+	return newRandomID("chatcmpl-")
+}
+
+// newRandomID generates a random hex identifier with the given prefix
+func newRandomID(prefix string) string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return prefix + "proxy"
+	}
+	return prefix + hex.EncodeToString(buf)
+}
+
+// ToChatMessage converts an OpenAIMessage to ChatMessage
+func (om OpenAIMessage) ToChatMessage() ChatMessage {
+	var toolCalls []ToolCall
+	for _, call := range om.ToolCalls {
+		toolCalls = append(toolCalls, ToolCall{
+			ID: call.ID,
+			Function: ToolCallFunction{
+				Name:      call.Function.Name,
+				Arguments: ToolArgumentsFromString(call.Function.Arguments),
+			},
+		})
+	}
+
+	return ChatMessage{
+		Role:       om.Role,
+		Content:    om.Content.String(),
+		Images:     om.Content.Images(),
+		ToolCalls:  toolCalls,
+		ToolCallID: om.ToolCallID,
+	}
+}
+
+// MaxOutputTokens returns the output token limit requested by the client, or 0 if none was given
+func (req OpenAIChatRequest) MaxOutputTokens() int {
+	if req.MaxCompletionTokens > 0 {
+		return req.MaxCompletionTokens
+	}
+	return req.MaxTokens
+}
+
+// GenerationOptions returns the sampling parameters of the request as generation options
+func (req OpenAIChatRequest) GenerationOptions() (GenerationOptions, error) {
+	if req.Temperature != nil && (*req.Temperature < 0 || *req.Temperature > 2) {
+		return GenerationOptions{}, fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if req.TopP != nil && (*req.TopP < 0 || *req.TopP > 1) {
+		return GenerationOptions{}, fmt.Errorf("top_p must be between 0 and 1")
+	}
+
+	return GenerationOptions{
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+		Seed:        req.Seed,
+	}, nil
+}
+
+// Format returns the format the response_format of the request asks for, or nil for text
+func (req OpenAIChatRequest) Format() (*ResponseFormat, error) {
+	if req.ResponseFormat == nil {
+		return nil, nil
+	}
+
+	switch req.ResponseFormat.Type {
+	case "", "text":
+		return nil, nil
+	case "json_object":
+		return &ResponseFormat{}, nil
+	case "json_schema":
+		if req.ResponseFormat.JSONSchema == nil || len(req.ResponseFormat.JSONSchema.Schema) == 0 {
+			return nil, fmt.Errorf("response_format json_schema requires a schema")
+		}
+		if err := schema.Check(req.ResponseFormat.JSONSchema.Schema); err != nil {
+			return nil, fmt.Errorf("invalid response_format: %w", err)
+		}
+		return &ResponseFormat{Schema: req.ResponseFormat.JSONSchema.Schema}, nil
+	default:
+		return nil, fmt.Errorf("unsupported response_format type %q", req.ResponseFormat.Type)
+	}
+}
+
+// OpenAIFinishReason returns the finish_reason of a response that stopped for an Ollama
+// done reason (see OllamaDoneReason)
+func OpenAIFinishReason(doneReason string, hasToolCalls bool) string {
+	switch {
+	case hasToolCalls:
+		return "tool_calls"
+	case doneReason == "length":
+		return "length"
+	default:
+		return "stop"
+	}
+}
+
+// ConvertOpenAIToChatRequest converts an OpenAI chat completion request to our format
+func ConvertOpenAIToChatRequest(req OpenAIChatRequest, maxTokens int) ChatRequest {
+	var messages []ChatMessage
+	for _, msg := range req.Messages {
+		messages = append(messages, msg.ToChatMessage())
+	}
+
+	return ChatRequest{
+		Model:     req.Model,
+		Messages:  AssignToolCallIDs(messages),
+		Tools:     req.Tools,
+		MaxTokens: maxTokens,
+	}
+}
+
+// ConvertToolCallsToOpenAI converts tool calls to the OpenAI format.
+// When firstIndex is not nil the calls are numbered from it, as streamed chunks require.
+func ConvertToolCallsToOpenAI(calls []ToolCall, firstIndex *int) []OpenAIToolCall {
+	var openaiCalls []OpenAIToolCall
+	for i, call := range calls {
+		openaiCall := OpenAIToolCall{
+			ID:   call.ID,
+			Type: "function",
+			Function: OpenAIFunctionCall{
+				Name:      call.Function.Name,
+				Arguments: call.ArgumentsString(),
+			},
+		}
+		if firstIndex != nil {
+			index := *firstIndex + i
+			openaiCall.Index = &index
+		}
+		openaiCalls = append(openaiCalls, openaiCall)
+	}
+	return openaiCalls
+}
+
+// ConvertUsageToOpenAI converts backend usage to the OpenAI usage format
+func ConvertUsageToOpenAI(usage *Usage) OpenAIUsage {
+	if usage == nil {
+		return OpenAIUsage{}
+	}
+	return OpenAIUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.PromptTokens + usage.CompletionTokens,
+	}
+}
+
+// ConvertChatToOpenAIResponse converts our chat response to the OpenAI chat completion format
+func ConvertChatToOpenAIResponse(resp *ChatResponse, model string, created int64) OpenAIChatResponse {
+	finishReason := OpenAIFinishReason(resp.DoneReason, len(resp.Message.ToolCalls) > 0)
+
+	return OpenAIChatResponse{
+		ID:      NewOpenAICompletionID(),
+		Object:  "chat.completion",
+		Created: created,
+		Model:   model,
+		Choices: []OpenAIChoice{
+			{
+				Index: 0,
+				Message: OpenAIMessage{
+					Role:      "assistant",
+					Content:   OpenAIContent{Text: resp.Message.Content},
+					ToolCalls: ConvertToolCallsToOpenAI(resp.Message.ToolCalls, nil),
+				},
+				FinishReason: finishReason,
+			},
+		},
+		Usage: ConvertUsageToOpenAI(resp.Usage),
+	}
+}
+
+// ToOpenAIModel converts a ModelConfig to the OpenAI model list format
+func (m ModelConfig) ToOpenAIModel(created int64) OpenAIModelEntry {
+	return OpenAIModelEntry{
+		ID:      m.Name,
+		Object:  "model",
+		Created: created,
+		OwnedBy: string(m.Backend),
+	}
+}
@@ -0,0 +1,192 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Generation option names, as used in the Ollama options object
+const (
+	OptionTemperature = "temperature"
+	OptionTopP        = "top_p"
+	OptionTopK        = "top_k"
+	OptionStop        = "stop"
+	OptionSeed        = "seed"
+	OptionNumPredict  = "num_predict"
+)
+
+// GenerationOptions holds the sampling options a client asked for.
+// Unset options are nil so backends keep their own defaults.
+type GenerationOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	TopK        *int     `json:"top_k,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	// NumPredict limits the number of generated tokens, overriding the computed max tokens
+	NumPredict *int `json:"num_predict,omitempty"`
+}
+
+// OptionSupporter is implemented by backends that only support some generation options.
+// Backends that don't implement it are assumed to support all of them.
+type OptionSupporter interface {
+	// SupportsOption reports whether the backend can honor the named option
+	SupportsOption(name string) bool
+}
+
+// ParseOllamaOptions reads the supported options from an Ollama options object.
+// Options the proxy doesn't map (num_ctx, mirostat, ...) are ignored.
+func ParseOllamaOptions(options map[string]interface{}) (GenerationOptions, error) {
+	var opts GenerationOptions
+	var err error
+
+	if opts.Temperature, err = optionFloat(options, OptionTemperature); err != nil {
+		return GenerationOptions{}, err
+	}
+	if opts.Temperature != nil && *opts.Temperature < 0 {
+		return GenerationOptions{}, fmt.Errorf("option %s must not be negative", OptionTemperature)
+	}
+
+	if opts.TopP, err = optionFloat(options, OptionTopP); err != nil {
+		return GenerationOptions{}, err
+	}
+	if opts.TopP != nil && (*opts.TopP < 0 || *opts.TopP > 1) {
+		return GenerationOptions{}, fmt.Errorf("option %s must be between 0 and 1", OptionTopP)
+	}
+
+	if opts.TopK, err = optionInt(options, OptionTopK); err != nil {
+		return GenerationOptions{}, err
+	}
+	if opts.TopK != nil && *opts.TopK < 0 {
+		return GenerationOptions{}, fmt.Errorf("option %s must not be negative", OptionTopK)
+	}
+
+	if opts.Seed, err = optionInt(options, OptionSeed); err != nil {
+		return GenerationOptions{}, err
+	}
+	if opts.NumPredict, err = optionInt(options, OptionNumPredict); err != nil {
+		return GenerationOptions{}, err
+	}
+
+	if value, exists := options[OptionStop]; exists && value != nil {
+		switch stop := value.(type) {
+		case string:
+			opts.Stop = []string{stop}
+		case []interface{}:
+			for _, item := range stop {
+				sequence, ok := item.(string)
+				if !ok {
+					return GenerationOptions{}, fmt.Errorf("option %s must be a string or an array of strings", OptionStop)
+				}
+				opts.Stop = append(opts.Stop, sequence)
+			}
+		default:
+			return GenerationOptions{}, fmt.Errorf("option %s must be a string or an array of strings", OptionStop)
+		}
+	}
+
+	return opts, nil
+}
+
+// Names returns the names of the options that are set, in sorted order
+func (o GenerationOptions) Names() []string {
+	var names []string
+	if o.Temperature != nil {
+		names = append(names, OptionTemperature)
+	}
+	if o.TopP != nil {
+		names = append(names, OptionTopP)
+	}
+	if o.TopK != nil {
+		names = append(names, OptionTopK)
+	}
+	if len(o.Stop) > 0 {
+		names = append(names, OptionStop)
+	}
+	if o.Seed != nil {
+		names = append(names, OptionSeed)
+	}
+	if o.NumPredict != nil {
+		names = append(names, OptionNumPredict)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Without returns a copy of the options with the named option unset
+func (o GenerationOptions) Without(name string) GenerationOptions {
+	switch name {
+	case OptionTemperature:
+		o.Temperature = nil
+	case OptionTopP:
+		o.TopP = nil
+	case OptionTopK:
+		o.TopK = nil
+	case OptionStop:
+		o.Stop = nil
+	case OptionSeed:
+		o.Seed = nil
+	case OptionNumPredict:
+		o.NumPredict = nil
+	}
+	return o
+}
+
+// WithDefaults returns a copy of the options with every unset option taken from defaults
+func (o GenerationOptions) WithDefaults(defaults GenerationOptions) GenerationOptions {
+	if o.Temperature == nil {
+		o.Temperature = defaults.Temperature
+	}
+	if o.TopP == nil {
+		o.TopP = defaults.TopP
+	}
+	if o.TopK == nil {
+		o.TopK = defaults.TopK
+	}
+	if len(o.Stop) == 0 {
+		o.Stop = defaults.Stop
+	}
+	if o.Seed == nil {
+		o.Seed = defaults.Seed
+	}
+	if o.NumPredict == nil {
+		o.NumPredict = defaults.NumPredict
+	}
+	return o
+}
+
+// MaxTokens returns num_predict when the client set a positive limit, and fallback otherwise.
+// Ollama uses -1 for "no limit" and -2 for "fill the context", which both keep the computed limit.
+func (o GenerationOptions) MaxTokens(fallback int) int {
+	if o.NumPredict != nil && *o.NumPredict > 0 {
+		return *o.NumPredict
+	}
+	return fallback
+}
+
+// optionFloat reads a numeric option
+func optionFloat(options map[string]interface{}, name string) (*float64, error) {
+	value, exists := options[name]
+	if !exists || value == nil {
+		return nil, nil
+	}
+	number, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("option %s must be a number", name)
+	}
+	return &number, nil
+}
+
+// optionInt reads an integer option
+func optionInt(options map[string]interface{}, name string) (*int, error) {
+	number, err := optionFloat(options, name)
+	if err != nil || number == nil {
+		return nil, err
+	}
+	if *number != math.Trunc(*number) {
+		return nil, fmt.Errorf("option %s must be an integer", name)
+	}
+	value := int(*number)
+	return &value, nil
+}
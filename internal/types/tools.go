@@ -0,0 +1,94 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Tool describes a function the model may call. Ollama and OpenAI share this format.
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is a function call requested by the model. It uses the Ollama wire format,
+// where arguments are a JSON object rather than an encoded string.
+type ToolCall struct {
+	ID       string           `json:"id,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type ToolCallFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// ToolArgumentsFromString converts JSON-encoded arguments, as sent by OpenAI, to a raw JSON value.
+// Empty arguments become an empty object and invalid JSON is kept as a JSON string.
+func ToolArgumentsFromString(arguments string) json.RawMessage {
+	if arguments == "" {
+		return json.RawMessage("{}")
+	}
+	if !json.Valid([]byte(arguments)) {
+		encoded, _ := json.Marshal(arguments)
+		return encoded
+	}
+	return json.RawMessage(arguments)
+}
+
+// ArgumentsString returns the call arguments as a JSON-encoded string, as expected by OpenAI
+func (tc ToolCall) ArgumentsString() string {
+	if len(tc.Function.Arguments) == 0 {
+		return "{}"
+	}
+	return string(tc.Function.Arguments)
+}
+
+// AssignToolCallIDs gives tool calls and tool results matching IDs.
+// Ollama clients don't send IDs, so calls without one get an ID derived from their
+// position and each tool message is paired with the earliest unanswered call of the
+// same tool name (or the earliest unanswered call if the message has no tool name).
+// Positional IDs stay stable across turns, so a resent history doesn't change.
+func AssignToolCallIDs(messages []ChatMessage) []ChatMessage {
+	result := make([]ChatMessage, len(messages))
+	var pending []ToolCall
+	for i, msg := range messages {
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]ToolCall, len(msg.ToolCalls))
+			for j, call := range msg.ToolCalls {
+				if call.ID == "" {
+					call.ID = fmt.Sprintf("call_%d_%d", i, j)
+				}
+				calls[j] = call
+			}
+			msg.ToolCalls = calls
+			pending = append([]ToolCall(nil), calls...)
+		}
+
+		if msg.Role == "tool" {
+			for j, call := range pending {
+				matches := call.ID == msg.ToolCallID
+				if msg.ToolCallID == "" {
+					matches = msg.ToolName == "" || call.Function.Name == msg.ToolName
+				}
+				if matches {
+					msg.ToolCallID = call.ID
+					if msg.ToolName == "" {
+						msg.ToolName = call.Function.Name
+					}
+					pending = append(pending[:j], pending[j+1:]...)
+					break
+				}
+			}
+		}
+
+		result[i] = msg
+	}
+	return result
+}
@@ -2,6 +2,7 @@ package types
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -13,12 +14,19 @@ type BackendType string
 const (
 	BackendAnthropic BackendType = "anthropic"
 	BackendOpenAI    BackendType = "openai"
+	BackendAzure     BackendType = "azure"
+	BackendGemini    BackendType = "gemini"
+	BackendBedrock   BackendType = "bedrock"
+	BackendOllama    BackendType = "ollama"
 )
 
 // Ollama API Structures
 type OllamaGenerateRequest struct {
 	Model   string                 `json:"model"`
 	Prompt  string                 `json:"prompt"`
+	Images  []string               `json:"images,omitempty"`
+	System  string                 `json:"system,omitempty"`
+	Format  json.RawMessage        `json:"format,omitempty"`
 	Stream  bool                   `json:"stream"`
 	Options map[string]interface{} `json:"options,omitempty"`
 }
@@ -35,23 +43,39 @@ type OllamaGenerateResponse struct {
 	PromptEvalDuration int64  `json:"prompt_eval_duration,omitempty"`
 	EvalCount          int    `json:"eval_count,omitempty"`
 	EvalDuration       int64  `json:"eval_duration,omitempty"`
+	DoneReason         string `json:"done_reason,omitempty"`
 }
 
 type OllamaChatRequest struct {
 	Model    string                 `json:"model"`
 	Messages []OllamaMessage        `json:"messages"`
+	Tools    []Tool                 `json:"tools,omitempty"`
+	Format   json.RawMessage        `json:"format,omitempty"`
 	Stream   bool                   `json:"stream"`
 	Options  map[string]interface{} `json:"options,omitempty"`
 }
 
 type OllamaMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Images    []string   `json:"images,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolName names the tool whose result a "tool" message carries
+	ToolName string `json:"tool_name,omitempty"`
+	// ToolCallID is not part of the Ollama API but is accepted from clients that send it
+	ToolCallID string `json:"tool_call_id,omitempty"`
 }
 
 // ToChatMessage converts an OllamaMessage to ChatMessage
 func (om OllamaMessage) ToChatMessage() ChatMessage {
-	return ChatMessage(om)
+	return ChatMessage{
+		Role:       om.Role,
+		Content:    om.Content,
+		Images:     om.Images,
+		ToolCalls:  om.ToolCalls,
+		ToolCallID: om.ToolCallID,
+		ToolName:   om.ToolName,
+	}
 }
 
 type OllamaChatResponse struct {
@@ -66,47 +90,81 @@ type OllamaChatResponse struct {
 	PromptEvalDuration int64         `json:"prompt_eval_duration,omitempty"`
 	EvalCount          int           `json:"eval_count,omitempty"`
 	EvalDuration       int64         `json:"eval_duration,omitempty"`
+	DoneReason         string        `json:"done_reason,omitempty"`
 }
 
 type OllamaModel struct {
-	Name       string `json:"name"`
-	Model      string `json:"model"`
-	ModifiedAt string `json:"modified_at"`
-	Size       int64  `json:"size"`
-	Digest     string `json:"digest"`
+	Name       string              `json:"name"`
+	Model      string              `json:"model"`
+	ModifiedAt string              `json:"modified_at"`
+	Size       int64               `json:"size"`
+	Digest     string              `json:"digest"`
+	Details    *OllamaModelDetails `json:"details,omitempty"`
+}
+
+// OllamaModelDetails describes the weights of a model an Ollama server has pulled
+type OllamaModelDetails struct {
+	ParentModel       string   `json:"parent_model,omitempty"`
+	Format            string   `json:"format,omitempty"`
+	Family            string   `json:"family,omitempty"`
+	Families          []string `json:"families,omitempty"`
+	ParameterSize     string   `json:"parameter_size,omitempty"`
+	QuantizationLevel string   `json:"quantization_level,omitempty"`
 }
 
 type OllamaTagsResponse struct {
 	Models []OllamaModel `json:"models"`
 }
 
-// Anthropic API Structures
-type AnthropicRequest struct {
-	Model     string             `json:"model"`
-	MaxTokens int                `json:"max_tokens"`
-	Messages  []AnthropicMessage `json:"messages"`
+// OllamaShowRequest names the model /api/show describes; older clients send it as name
+type OllamaShowRequest struct {
+	Model string `json:"model,omitempty"`
+	Name  string `json:"name,omitempty"`
 }
 
-type AnthropicMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+// OllamaEmbeddingsRequest is the legacy single-prompt /api/embeddings request
+type OllamaEmbeddingsRequest struct {
+	Model   string                 `json:"model"`
+	Prompt  string                 `json:"prompt"`
+	Options map[string]interface{} `json:"options,omitempty"`
 }
 
-type AnthropicResponse struct {
-	ID      string `json:"id"`
-	Type    string `json:"type"`
-	Role    string `json:"role"`
-	Content []struct {
-		Type string `json:"type"`
-		Text string `json:"text"`
-	} `json:"content"`
-	Model        string      `json:"model"`
-	StopReason   string      `json:"stop_reason"`
-	StopSequence interface{} `json:"stop_sequence"`
-	Usage        struct {
-		InputTokens  int `json:"input_tokens"`
-		OutputTokens int `json:"output_tokens"`
-	} `json:"usage"`
+type OllamaEmbeddingsResponse struct {
+	Embedding []float32 `json:"embedding"`
+}
+
+// OllamaEmbedRequest is the batched /api/embed request
+type OllamaEmbedRequest struct {
+	Model   string                 `json:"model"`
+	Input   OllamaEmbedInput       `json:"input"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+// OllamaEmbedInput holds the /api/embed input, which Ollama allows to be
+// either a single string or an array of strings
+type OllamaEmbedInput []string
+
+// UnmarshalJSON accepts both the string and the string array forms
+func (in *OllamaEmbedInput) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*in = OllamaEmbedInput{single}
+		return nil
+	}
+
+	var batch []string
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return fmt.Errorf("input must be a string or an array of strings: %w", err)
+	}
+	*in = batch
+	return nil
+}
+
+type OllamaEmbedResponse struct {
+	Model           string      `json:"model"`
+	Embeddings      [][]float32 `json:"embeddings"`
+	TotalDuration   int64       `json:"total_duration,omitempty"`
+	PromptEvalCount int         `json:"prompt_eval_count,omitempty"`
 }
 
 // ConvertOllamaToGenerateRequest converts an Ollama generate request to our format
@@ -114,6 +172,8 @@ func ConvertOllamaToGenerateRequest(req OllamaGenerateRequest, maxTokens int) Ge
 	return GenerateRequest{
 		Model:     req.Model,
 		Prompt:    req.Prompt,
+		Images:    req.Images,
+		System:    req.System,
 		MaxTokens: maxTokens,
 	}
 }
@@ -127,20 +187,27 @@ func ConvertOllamaToChatRequest(req OllamaChatRequest, maxTokens int) ChatReques
 
 	return ChatRequest{
 		Model:     req.Model,
-		Messages:  messages,
+		Messages:  AssignToolCallIDs(messages),
+		Tools:     req.Tools,
 		MaxTokens: maxTokens,
 	}
 }
 
 // ConvertGenerateToOllamaResponse converts our generate response to Ollama format
 func ConvertGenerateToOllamaResponse(resp *GenerateResponse, model string) OllamaGenerateResponse {
-	return OllamaGenerateResponse{
+	ollamaResp := OllamaGenerateResponse{
 		Model:     model,
 		CreatedAt: resp.CreatedAt,
 		Response:  resp.Content,
 		Done:      true,
 		Context:   []int{},
 	}
+	if resp.Usage != nil {
+		ollamaResp.PromptEvalCount = resp.Usage.PromptTokens
+		ollamaResp.EvalCount = resp.Usage.CompletionTokens
+	}
+	ollamaResp.DoneReason = resp.DoneReason
+	return ollamaResp
 }
 
 // BackendHandler defines the interface that all backends must implement
@@ -151,6 +218,12 @@ type BackendHandler interface {
 	// Chat handles chat completion requests
 	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
 
+	// GenerateStream handles streaming text generation requests, calling onChunk for every upstream delta
+	GenerateStream(ctx context.Context, req GenerateRequest, onChunk StreamCallback) error
+
+	// ChatStream handles streaming chat completion requests, calling onChunk for every upstream delta
+	ChatStream(ctx context.Context, req ChatRequest, onChunk StreamCallback) error
+
 	// IsAvailable checks if the backend is available (has API key, etc.)
 	IsAvailable() bool
 
@@ -158,11 +231,47 @@ type BackendHandler interface {
 	GetName() string
 }
 
+// EmbeddingHandler is implemented by backends that can create embeddings
+type EmbeddingHandler interface {
+	// Embed returns one embedding vector per input, in input order
+	Embed(ctx context.Context, req EmbeddingRequest) (*EmbeddingResponse, error)
+}
+
 // GenerateRequest represents a text generation request
 type GenerateRequest struct {
-	Model     string `json:"model"`
-	Prompt    string `json:"prompt"`
-	MaxTokens int    `json:"max_tokens,omitempty"`
+	Model     string            `json:"model"`
+	Prompt    string            `json:"prompt"`
+	Images    []string          `json:"images,omitempty"`
+	System    string            `json:"system,omitempty"`
+	MaxTokens int               `json:"max_tokens,omitempty"`
+	Options   GenerationOptions `json:"options,omitempty"`
+	// ClientOptions are the options as the client sent them, before Options were resolved
+	// for the model's backend. Fallback models resolve them for their own backends.
+	ClientOptions GenerationOptions `json:"-"`
+	// Format constrains the output to JSON, if set
+	Format *ResponseFormat `json:"format,omitempty"`
+}
+
+// ToChatRequest converts a generate request to a single-turn chat request,
+// with the system prompt as a leading system message
+func (req GenerateRequest) ToChatRequest() ChatRequest {
+	var messages []ChatMessage
+	if req.System != "" {
+		messages = append(messages, ChatMessage{Role: "system", Content: req.System})
+	}
+	messages = append(messages, ChatMessage{
+		Role:    "user",
+		Content: req.Prompt,
+		Images:  req.Images,
+	})
+	return ChatRequest{
+		Model:         req.Model,
+		Messages:      messages,
+		MaxTokens:     req.MaxTokens,
+		Options:       req.Options,
+		ClientOptions: req.ClientOptions,
+		Format:        req.Format,
+	}
 }
 
 // GenerateResponse represents a text generation response
@@ -170,19 +279,44 @@ type GenerateResponse struct {
 	Model     string `json:"model"`
 	Content   string `json:"content"`
 	CreatedAt string `json:"created_at"`
+	Usage     *Usage `json:"usage,omitempty"`
+	// DoneReason is why generation stopped, in Ollama terms (see OllamaDoneReason)
+	DoneReason string `json:"done_reason,omitempty"`
 }
 
 // ChatRequest represents a chat completion request
 type ChatRequest struct {
-	Model     string        `json:"model"`
-	Messages  []ChatMessage `json:"messages"`
-	MaxTokens int           `json:"max_tokens,omitempty"`
+	Model     string            `json:"model"`
+	Messages  []ChatMessage     `json:"messages"`
+	Tools     []Tool            `json:"tools,omitempty"`
+	MaxTokens int               `json:"max_tokens,omitempty"`
+	Options   GenerationOptions `json:"options,omitempty"`
+	// ClientOptions are the options as the client sent them, before Options were resolved
+	// for the model's backend. Fallback models resolve them for their own backends.
+	ClientOptions GenerationOptions `json:"-"`
+	// Format constrains the output to JSON, if set
+	Format *ResponseFormat `json:"format,omitempty"`
+	// ToolChoice controls whether the model calls tools, leaving the choice to it if unset
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+}
+
+// ToolChoice controls whether and which tool the model calls.
+// Type is "auto", "any" (some tool must be called), "tool" (the named tool must be called) or "none".
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
 }
 
-// ChatMessage represents a single message in a chat
+// ChatMessage represents a single message in a chat.
+// Assistant messages may carry tool calls, and "tool" messages carry the result of one.
 type ChatMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// Images holds base64-encoded images attached to the message
+	Images     []string   `json:"images,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	ToolName   string     `json:"tool_name,omitempty"`
 }
 
 // ChatResponse represents a chat completion response
@@ -190,8 +324,46 @@ type ChatResponse struct {
 	Model     string      `json:"model"`
 	Message   ChatMessage `json:"message"`
 	CreatedAt string      `json:"created_at"`
+	Usage     *Usage      `json:"usage,omitempty"`
+	// DoneReason is why generation stopped, in Ollama terms (see OllamaDoneReason)
+	DoneReason string `json:"done_reason,omitempty"`
 }
 
+// Usage represents token usage reported by a backend
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// EmbeddingRequest represents an embedding request for one or more inputs
+type EmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// EmbeddingResponse represents an embedding response
+type EmbeddingResponse struct {
+	Model      string      `json:"model"`
+	Embeddings [][]float32 `json:"embeddings"`
+	Usage      *Usage      `json:"usage,omitempty"`
+}
+
+// StreamChunk represents a single delta received from a streaming backend
+type StreamChunk struct {
+	Content string `json:"content"`
+	// ToolCalls holds tool calls that finished streaming; each call is sent once, complete
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Done      bool       `json:"done"`
+	// DoneReason is only set on the final chunk
+	DoneReason string `json:"done_reason,omitempty"`
+	// Usage is only set on the final chunk
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// StreamCallback is called for each chunk of a streaming response.
+// Returning an error aborts the stream.
+type StreamCallback func(chunk StreamChunk) error
+
 // ModelConfig represents configuration for a model
 type ModelConfig struct {
 	Name         string      `json:"name"`
@@ -202,10 +374,33 @@ type ModelConfig struct {
 	Description  string      `json:"description"`
 	MaxTokens    int         `json:"max_tokens"`
 	Enabled      bool        `json:"enabled"`
+	// Embedding marks models that serve embeddings rather than chat or generation
+	Embedding bool `json:"embedding"`
+	// Vision marks models that accept image input
+	Vision bool `json:"vision"`
+	// Timeout bounds requests to this model, overriding the default request timeout when set
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Fallbacks names the models, in order, that serve requests this model fails on
+	Fallbacks []string `json:"fallbacks,omitempty"`
+	// System is the system prompt for requests that don't bring their own
+	System string `json:"system,omitempty"`
+	// Options are defaults for the generation options a request leaves unset
+	Options GenerationOptions `json:"options,omitempty"`
+	// DefaultMaxTokens limits the output of requests that set no limit; zero keeps the estimate
+	DefaultMaxTokens int `json:"default_max_tokens,omitempty"`
+	// Local is the model as an upstream Ollama server lists it, for models served by one
+	Local *OllamaModel `json:"local,omitempty"`
 }
 
-// ToOllamaModel converts a ModelConfig to OllamaModel format
+// ToOllamaModel converts a ModelConfig to OllamaModel format.
+// Models of an upstream Ollama server keep the size, digest and details it reports.
 func (m ModelConfig) ToOllamaModel() OllamaModel {
+	if m.Local != nil {
+		model := *m.Local
+		model.Name = m.Name
+		model.Model = m.Name
+		return model
+	}
 	return OllamaModel{
 		Name:       m.Name,
 		Model:      m.Name,
@@ -217,16 +412,34 @@ func (m ModelConfig) ToOllamaModel() OllamaModel {
 
 // ConvertChatToOllamaResponse converts our chat response to Ollama format
 func ConvertChatToOllamaResponse(resp *ChatResponse, model string) OllamaChatResponse {
-	return OllamaChatResponse{
+	ollamaResp := OllamaChatResponse{
 		Model:     model,
 		CreatedAt: resp.CreatedAt,
 		Message: OllamaMessage{
-			Role:    resp.Message.Role,
-			Content: resp.Message.Content,
+			Role:      resp.Message.Role,
+			Content:   resp.Message.Content,
+			ToolCalls: resp.Message.ToolCalls,
 		},
 		Done:    true,
 		Context: []int{},
 	}
+	if resp.Usage != nil {
+		ollamaResp.PromptEvalCount = resp.Usage.PromptTokens
+		ollamaResp.EvalCount = resp.Usage.CompletionTokens
+	}
+	ollamaResp.DoneReason = resp.DoneReason
+	return ollamaResp
+}
+
+// OllamaDoneReason maps an Anthropic stop_reason or OpenAI finish_reason to an Ollama done_reason.
+// Ollama only distinguishes hitting the token limit ("length") from stopping on its own ("stop").
+func OllamaDoneReason(reason string) string {
+	switch reason {
+	case "max_tokens", "length":
+		return "length"
+	default:
+		return "stop"
+	}
 }
 
 // EstimateTokens provides a rough estimation of token count for text
@@ -246,6 +459,9 @@ func EstimateChatTokens(messages []ChatMessage) int {
 	for _, msg := range messages {
 		// Add tokens for role and content
 		total += EstimateTokens(msg.Role) + EstimateTokens(msg.Content)
+		for _, call := range msg.ToolCalls {
+			total += EstimateTokens(call.Function.Name) + EstimateTokens(string(call.Function.Arguments))
+		}
 		// Add some overhead for message formatting
 		total += 4
 	}
@@ -268,8 +484,11 @@ func CalculateMaxTokensForRequest(modelConfig ModelConfig, messages []ChatMessag
 		availableForOutput = 100 // Minimum 100 tokens for output
 	}
 
-	// Cap at a reasonable maximum to avoid very long responses
+	// Cap at the model's default, or a reasonable maximum to avoid very long responses
 	maxOutputTokens := 4000
+	if modelConfig.DefaultMaxTokens > 0 {
+		maxOutputTokens = modelConfig.DefaultMaxTokens
+	}
 	if availableForOutput > maxOutputTokens {
 		availableForOutput = maxOutputTokens
 	}
@@ -5,64 +5,89 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"go-llm-proxy/internal/keys"
+	"go-llm-proxy/internal/retry"
 	"go-llm-proxy/internal/types"
 	"io"
+	"math"
 	"net/http"
+	"strings"
 )
 
+// DefaultBaseURL is the base URL of the public Anthropic API
+const DefaultBaseURL = "https://api.anthropic.com"
+
 // AnthropicBackend implements the BackendHandler interface for Anthropic
 type AnthropicBackend struct {
-	apiKey string
-	client *http.Client
+	baseURL   string
+	client    *http.Client
+	transport *retry.Transport
+	keys      *keys.Transport
 }
 
 // NewAnthropicBackend creates a new Anthropic backend
 func NewAnthropicBackend(apiKey string) *AnthropicBackend {
+	return NewAnthropicBackendWithBaseURL(apiKey, DefaultBaseURL)
+}
+
+// NewAnthropicBackendWithBaseURL creates a new Anthropic backend that talks to the given base URL
+func NewAnthropicBackendWithBaseURL(apiKey, baseURL string) *AnthropicBackend {
+	// Keys are chosen below the retries, so a retry can move to another key
+	keyTransport := &keys.Transport{
+		Pool:      keys.NewPool([]string{apiKey}, keys.RoundRobin),
+		Authorize: keys.HeaderAuth("x-api-key"),
+	}
+	transport := retry.NewTransport(retry.DefaultPolicy())
+	transport.Base = keyTransport
 	return &AnthropicBackend{
-		apiKey: apiKey,
-		client: &http.Client{},
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		client:    &http.Client{Transport: transport},
+		transport: transport,
+		keys:      keyTransport,
 	}
 }
 
+// SetKeyPool sets the API keys requests are spread over
+func (ab *AnthropicBackend) SetKeyPool(pool *keys.Pool) {
+	ab.keys.Pool = pool
+}
+
+// KeyStats returns the usage of each API key
+func (ab *AnthropicBackend) KeyStats() []keys.Stats {
+	return ab.keys.Pool.Stats()
+}
+
+// SetRetryPolicy sets how requests failing with overload, rate-limit or network errors are retried
+func (ab *AnthropicBackend) SetRetryPolicy(policy retry.Policy) {
+	ab.transport.Policy = policy
+}
+
 // Generate handles text generation requests
 func (ab *AnthropicBackend) Generate(ctx context.Context, req types.GenerateRequest) (*types.GenerateResponse, error) {
-	anthropicReq := AnthropicRequest{
-		Model:     req.Model,
-		MaxTokens: req.MaxTokens,
-		Messages: []AnthropicMessage{
-			{
-				Role:    "user",
-				Content: req.Prompt,
-			},
-		},
-	}
-
-	resp, err := ab.makeRequest(ctx, anthropicReq)
+	resp, err := ab.Chat(ctx, req.ToChatRequest())
 	if err != nil {
 		return nil, err
 	}
 
 	return &types.GenerateResponse{
-		Model:     req.Model,
-		Content:   resp.Content[0].Text,
-		CreatedAt: resp.ID,
+		Model:      req.Model,
+		Content:    resp.Message.Content,
+		CreatedAt:  resp.CreatedAt,
+		Usage:      resp.Usage,
+		DoneReason: resp.DoneReason,
 	}, nil
 }
 
+// GenerateStream handles streaming text generation requests
+func (ab *AnthropicBackend) GenerateStream(ctx context.Context, req types.GenerateRequest, onChunk types.StreamCallback) error {
+	return ab.ChatStream(ctx, req.ToChatRequest(), onChunk)
+}
+
 // Chat handles chat completion requests
 func (ab *AnthropicBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.ChatResponse, error) {
-	var anthropicMessages []AnthropicMessage
-	for _, msg := range req.Messages {
-		anthropicMessages = append(anthropicMessages, AnthropicMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
-	}
-
-	anthropicReq := AnthropicRequest{
-		Model:     req.Model,
-		MaxTokens: req.MaxTokens,
-		Messages:  anthropicMessages,
+	anthropicReq, err := BuildChatRequest(req)
+	if err != nil {
+		return nil, err
 	}
 
 	resp, err := ab.makeRequest(ctx, anthropicReq)
@@ -70,19 +95,34 @@ func (ab *AnthropicBackend) Chat(ctx context.Context, req types.ChatRequest) (*t
 		return nil, err
 	}
 
-	return &types.ChatResponse{
-		Model: req.Model,
-		Message: types.ChatMessage{
-			Role:    "assistant",
-			Content: resp.Content[0].Text,
-		},
-		CreatedAt: resp.ID,
-	}, nil
+	return resp.ChatResponse(req), nil
+}
+
+// ChatStream handles streaming chat completion requests using the Messages SSE stream
+func (ab *AnthropicBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	anthropicReq, err := BuildChatRequest(req)
+	if err != nil {
+		return err
+	}
+	anthropicReq.Stream = true
+
+	httpResp, err := ab.doRequest(ctx, anthropicReq)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := httpResp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	return readStream(httpResp.Body, req.Format, onChunk)
 }
 
 // IsAvailable checks if the backend is available
 func (ab *AnthropicBackend) IsAvailable() bool {
-	return ab.apiKey != ""
+	return ab.keys.Pool.Len() > 0
 }
 
 // GetName returns the backend name
@@ -90,23 +130,60 @@ func (ab *AnthropicBackend) GetName() string {
 	return "anthropic"
 }
 
-// makeRequest makes a request to the Anthropic API
-func (ab *AnthropicBackend) makeRequest(ctx context.Context, req AnthropicRequest) (*AnthropicResponse, error) {
-	jsonData, err := json.Marshal(req)
+// SupportsOption reports whether the Messages API can honor a generation option
+func (ab *AnthropicBackend) SupportsOption(name string) bool {
+	// The Messages API has no seed parameter
+	return name != types.OptionSeed
+}
+
+// BuildChatRequest converts a chat request to the Anthropic Messages format
+func BuildChatRequest(req types.ChatRequest) (AnthropicRequest, error) {
+	system, anthropicMessages, err := NormalizeMessages(req.Messages)
 	if err != nil {
-		return nil, err
+		return AnthropicRequest{}, err
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
+	var tools []AnthropicTool
+	for _, tool := range req.Tools {
+		inputSchema := tool.Function.Parameters
+		if len(inputSchema) == 0 {
+			// Anthropic requires a schema even for tools without parameters
+			inputSchema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		tools = append(tools, AnthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: inputSchema,
+		})
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-api-key", ab.apiKey)
-	httpReq.Header.Set("anthropic-version", "2023-06-01")
+	anthropicReq := AnthropicRequest{
+		Model:         req.Model,
+		MaxTokens:     req.MaxTokens,
+		System:        system,
+		Messages:      anthropicMessages,
+		Tools:         tools,
+		TopP:          req.Options.TopP,
+		TopK:          req.Options.TopK,
+		StopSequences: req.Options.Stop,
+	}
+	if req.ToolChoice != nil && len(tools) > 0 {
+		anthropicReq.ToolChoice = &ToolChoice{Type: req.ToolChoice.Type, Name: req.ToolChoice.Name}
+	}
+	if req.Format != nil {
+		applyFormat(&anthropicReq, req.Format)
+	}
+	if req.Options.Temperature != nil {
+		// Anthropic accepts temperatures from 0 to 1, while Ollama and OpenAI go up to 2
+		temperature := math.Min(*req.Options.Temperature, 1)
+		anthropicReq.Temperature = &temperature
+	}
+	return anthropicReq, nil
+}
 
-	resp, err := ab.client.Do(httpReq)
+// makeRequest makes a request to the Anthropic API
+func (ab *AnthropicBackend) makeRequest(ctx context.Context, req AnthropicRequest) (*AnthropicResponse, error) {
+	resp, err := ab.doRequest(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -122,10 +199,6 @@ func (ab *AnthropicBackend) makeRequest(ctx context.Context, req AnthropicReques
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("anthropic API error: %s", string(body))
-	}
-
 	var anthropicResp AnthropicResponse
 	if err := json.Unmarshal(body, &anthropicResp); err != nil {
 		return nil, err
@@ -134,23 +207,150 @@ func (ab *AnthropicBackend) makeRequest(ctx context.Context, req AnthropicReques
 	return &anthropicResp, nil
 }
 
+// doRequest sends a request to the Messages endpoint and returns the successful HTTP response.
+// The caller is responsible for closing the response body.
+func (ab *AnthropicBackend) doRequest(ctx context.Context, req AnthropicRequest) (*http.Response, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", ab.baseURL+"/v1/messages", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+	if req.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := ab.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		if err := resp.Body.Close(); err != nil {
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+		return nil, retry.WithStatus(resp.StatusCode, fmt.Errorf("anthropic API error: %s", string(body)))
+	}
+
+	return resp, nil
+}
+
 // AnthropicRequest represents a request to the Anthropic API
 type AnthropicRequest struct {
-	Model     string             `json:"model"`
-	MaxTokens int                `json:"max_tokens"`
-	Messages  []AnthropicMessage `json:"messages"`
+	Model         string             `json:"model,omitempty"`
+	MaxTokens     int                `json:"max_tokens"`
+	System        string             `json:"system,omitempty"`
+	Messages      []AnthropicMessage `json:"messages"`
+	Tools         []AnthropicTool    `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice        `json:"tool_choice,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	TopK          *int               `json:"top_k,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+}
+
+// AnthropicTool represents a tool definition in the Anthropic API
+type AnthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// ToolChoice controls which tool the model calls
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
 }
 
 // AnthropicMessage represents a message in the Anthropic API
 type AnthropicMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string         `json:"role"`
+	Content []ContentBlock `json:"content"`
+}
+
+// ContentBlock represents a text, image, tool_use or tool_result content block
+type ContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Source    *ImageSource    `json:"source,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+// ImageSource holds the base64 data of an image content block
+type ImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
 }
 
 // AnthropicResponse represents a response from the Anthropic API
 type AnthropicResponse struct {
-	ID      string `json:"id"`
-	Content []struct {
-		Text string `json:"text"`
-	} `json:"content"`
+	ID         string         `json:"id"`
+	Content    []ContentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      AnthropicUsage `json:"usage"`
+}
+
+// Text returns the text of all text blocks in the response
+func (r *AnthropicResponse) Text() string {
+	var text strings.Builder
+	for _, block := range r.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String()
+}
+
+// ToolCalls returns the tool_use blocks in the response as tool calls
+func (r *AnthropicResponse) ToolCalls() []types.ToolCall {
+	var toolCalls []types.ToolCall
+	for _, block := range r.Content {
+		if block.Type == "tool_use" {
+			toolCalls = append(toolCalls, types.ToolCall{
+				ID: block.ID,
+				Function: types.ToolCallFunction{
+					Name:      block.Name,
+					Arguments: block.Input,
+				},
+			})
+		}
+	}
+	return toolCalls
+}
+
+// ChatResponse converts a Messages response to a chat response for the request it answers
+func (r *AnthropicResponse) ChatResponse(req types.ChatRequest) *types.ChatResponse {
+	return &types.ChatResponse{
+		Model: req.Model,
+		Message: unwrapFormatToolCall(types.ChatMessage{
+			Role:      "assistant",
+			Content:   r.Text(),
+			ToolCalls: r.ToolCalls(),
+		}, req.Format),
+		CreatedAt: r.ID,
+		Usage: &types.Usage{
+			PromptTokens:     r.Usage.InputTokens,
+			CompletionTokens: r.Usage.OutputTokens,
+		},
+		DoneReason: types.OllamaDoneReason(r.StopReason),
+	}
+}
+
+// AnthropicUsage represents token usage reported by the Messages API
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
 }
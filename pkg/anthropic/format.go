@@ -0,0 +1,93 @@
+package anthropic
+
+import (
+	"encoding/json"
+
+	"go-llm-proxy/internal/types"
+)
+
+// The Messages API has no JSON mode, so structured output is requested as a call of a
+// tool whose input schema is the requested format. The tool input is then returned as
+// the message content. Alongside the request's own tools, the format tool carries only
+// the final answer, so the model may still call the others.
+
+// formatToolName names the tool used to request structured output
+const formatToolName = "json_response"
+
+// formatTool returns the tool definition for a response format.
+// Tool inputs must be objects, so other schemas are wrapped in a "value" property.
+func formatTool(format *types.ResponseFormat) AnthropicTool {
+	inputSchema := json.RawMessage(`{"type":"object"}`)
+	if format.HasSchema() {
+		inputSchema = format.Schema
+		if formatNeedsWrapping(format) {
+			inputSchema = json.RawMessage(`{"type":"object","properties":{"value":` + string(format.Schema) + `},"required":["value"]}`)
+		}
+	}
+
+	return AnthropicTool{
+		Name:        formatToolName,
+		Description: "Respond with the final answer as JSON matching this schema",
+		InputSchema: inputSchema,
+	}
+}
+
+// applyFormat adds the format tool to a request. Without tools of its own, or when those
+// may not be called, the request is answered by a forced call of the format tool.
+// Otherwise the request's tool choice is kept, except that a choice left to the model
+// becomes "any", so that the answer comes as a tool call rather than free text.
+func applyFormat(anthropicReq *AnthropicRequest, format *types.ResponseFormat) {
+	choice := anthropicReq.ToolChoice
+	if len(anthropicReq.Tools) == 0 || (choice != nil && choice.Type == "none") {
+		anthropicReq.Tools = []AnthropicTool{formatTool(format)}
+		anthropicReq.ToolChoice = &ToolChoice{Type: "tool", Name: formatToolName}
+		return
+	}
+
+	anthropicReq.Tools = append(anthropicReq.Tools, formatTool(format))
+	if choice == nil || choice.Type == "auto" {
+		anthropicReq.ToolChoice = &ToolChoice{Type: "any"}
+	}
+}
+
+// formatNeedsWrapping reports whether a schema describes something other than an object
+func formatNeedsWrapping(format *types.ResponseFormat) bool {
+	var schema struct {
+		Type interface{} `json:"type"`
+	}
+	if err := json.Unmarshal(format.Schema, &schema); err != nil {
+		return false
+	}
+	return schema.Type != "object"
+}
+
+// formatContent converts the input of the format tool call back into the requested JSON
+func formatContent(format *types.ResponseFormat, input json.RawMessage) string {
+	if format.HasSchema() && formatNeedsWrapping(format) {
+		var wrapper struct {
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(input, &wrapper); err == nil && len(wrapper.Value) > 0 {
+			return string(wrapper.Value)
+		}
+	}
+	return string(input)
+}
+
+// unwrapFormatToolCall moves the format tool call of a message into its content
+func unwrapFormatToolCall(message types.ChatMessage, format *types.ResponseFormat) types.ChatMessage {
+	if format == nil {
+		return message
+	}
+
+	var toolCalls []types.ToolCall
+	for _, call := range message.ToolCalls {
+		if call.Function.Name == formatToolName {
+			message.Content = formatContent(format, call.Function.Arguments)
+			continue
+		}
+		toolCalls = append(toolCalls, call)
+	}
+	message.ToolCalls = toolCalls
+	return message
+}
@@ -0,0 +1,151 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go-llm-proxy/internal/types"
+)
+
+// leadingUserPlaceholder opens conversations that would otherwise start with an assistant turn
+const leadingUserPlaceholder = "Continue."
+
+// NormalizeMessages rewrites an Ollama or OpenAI style conversation into a valid Anthropic one.
+// The Messages API differs from both in a few ways that it enforces:
+//   - system prompts go in the top-level system field, not in the conversation
+//   - user and assistant turns must alternate, so consecutive turns of one role are merged
+//   - the conversation must start with a user turn
+//   - text blocks must not be empty, and a final assistant turn must not end with whitespace
+//
+// Tool results become tool_result blocks in a user turn, placed ahead of any other content.
+func NormalizeMessages(messages []types.ChatMessage) (string, []AnthropicMessage, error) {
+	var systemParts []string
+	var turns []AnthropicMessage
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system", "developer":
+			if text := strings.TrimSpace(msg.Content); text != "" {
+				systemParts = append(systemParts, text)
+			}
+			continue
+		case "tool", "function":
+			turns = appendTurn(turns, "user", []ContentBlock{{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   msg.Content,
+			}})
+			continue
+		}
+
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "assistant"
+		}
+
+		blocks, err := messageBlocks(msg)
+		if err != nil {
+			return "", nil, err
+		}
+		turns = appendTurn(turns, role, blocks)
+	}
+
+	if len(turns) == 0 {
+		return "", nil, fmt.Errorf("conversation must contain at least one user or assistant message")
+	}
+
+	if turns[0].Role != "user" {
+		turns = append([]AnthropicMessage{{
+			Role:    "user",
+			Content: []ContentBlock{{Type: "text", Text: leadingUserPlaceholder}},
+		}}, turns...)
+	}
+
+	turns = trimFinalAssistantTurn(turns)
+	for i := range turns {
+		if turns[i].Role == "user" {
+			turns[i].Content = toolResultsFirst(turns[i].Content)
+		}
+	}
+
+	return strings.Join(systemParts, "\n\n"), turns, nil
+}
+
+// messageBlocks converts the images, text and tool calls of a message to content blocks.
+// Images go ahead of the text that refers to them, and empty text is dropped.
+func messageBlocks(msg types.ChatMessage) ([]ContentBlock, error) {
+	var blocks []ContentBlock
+	for _, data := range msg.Images {
+		image, err := types.ParseImage(data)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, ContentBlock{
+			Type: "image",
+			Source: &ImageSource{
+				Type:      "base64",
+				MediaType: image.MediaType,
+				Data:      image.Data,
+			},
+		})
+	}
+	if strings.TrimSpace(msg.Content) != "" {
+		blocks = append(blocks, ContentBlock{Type: "text", Text: msg.Content})
+	}
+	for _, call := range msg.ToolCalls {
+		input := call.Function.Arguments
+		if len(input) == 0 {
+			input = json.RawMessage("{}")
+		}
+		blocks = append(blocks, ContentBlock{
+			Type:  "tool_use",
+			ID:    call.ID,
+			Name:  call.Function.Name,
+			Input: input,
+		})
+	}
+	return blocks, nil
+}
+
+// appendTurn adds content blocks to the conversation, merging them into the last turn
+// when it has the same role. Messages without content are skipped.
+func appendTurn(turns []AnthropicMessage, role string, blocks []ContentBlock) []AnthropicMessage {
+	if len(blocks) == 0 {
+		return turns
+	}
+	if len(turns) > 0 && turns[len(turns)-1].Role == role {
+		last := &turns[len(turns)-1]
+		last.Content = append(last.Content, blocks...)
+		return turns
+	}
+	return append(turns, AnthropicMessage{Role: role, Content: blocks})
+}
+
+// trimFinalAssistantTurn strips trailing whitespace from a final assistant turn,
+// which Anthropic treats as a prefill and rejects when it ends with whitespace
+func trimFinalAssistantTurn(turns []AnthropicMessage) []AnthropicMessage {
+	last := &turns[len(turns)-1]
+	if last.Role != "assistant" {
+		return turns
+	}
+
+	i := len(last.Content) - 1
+	if last.Content[i].Type != "text" {
+		return turns
+	}
+	last.Content[i].Text = strings.TrimRight(last.Content[i].Text, " \t\r\n")
+	return turns
+}
+
+// toolResultsFirst moves tool_result blocks to the start of a user turn, keeping their order
+func toolResultsFirst(blocks []ContentBlock) []ContentBlock {
+	var results, others []ContentBlock
+	for _, block := range blocks {
+		if block.Type == "tool_result" {
+			results = append(results, block)
+		} else {
+			others = append(others, block)
+		}
+	}
+	return append(results, others...)
+}
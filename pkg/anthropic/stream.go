@@ -0,0 +1,152 @@
+package anthropic
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+)
+
+// StreamEvent represents a single server-sent event from the Messages stream
+type StreamEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message struct {
+		Usage AnthropicUsage `json:"usage"`
+	} `json:"message"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage AnthropicUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// retryableStreamError reports whether an error event in a stream signals a transient failure
+func retryableStreamError(errorType string) bool {
+	switch errorType {
+	case "overloaded_error", "api_error", "rate_limit_error":
+		return true
+	}
+	return false
+}
+
+// StreamDecoder turns the events of a Messages stream into stream chunks. Text deltas are
+// forwarded as they arrive; tool input arrives as partial JSON and is forwarded once its
+// content block closes. When a response format was requested, the format tool input is
+// forwarded as content.
+type StreamDecoder struct {
+	format     *types.ResponseFormat
+	onChunk    types.StreamCallback
+	usage      types.Usage
+	stopReason string
+	toolCall   *types.ToolCall
+	toolInput  strings.Builder
+}
+
+// NewStreamDecoder creates a decoder forwarding the chunks of a stream to onChunk
+func NewStreamDecoder(format *types.ResponseFormat, onChunk types.StreamCallback) *StreamDecoder {
+	return &StreamDecoder{format: format, onChunk: onChunk}
+}
+
+// readStream parses an Anthropic SSE stream and forwards its chunks to onChunk
+func readStream(body io.Reader, format *types.ResponseFormat, onChunk types.StreamCallback) error {
+	decoder := NewStreamDecoder(format, onChunk)
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return retry.Temporary(fmt.Errorf("failed to read anthropic stream: %w", err))
+		}
+		if err == io.EOF && line == "" {
+			return retry.Temporary(fmt.Errorf("anthropic stream ended unexpectedly"))
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if !strings.HasPrefix(line, "data:") {
+			// Skip "event:" lines, comments and the blank lines separating events;
+			// the event type is repeated in the data payload
+			continue
+		}
+
+		done, err := decoder.Decode([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))))
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+// Decode handles one event, given as JSON. done is set once the message_stop event has
+// been handled and the final chunk forwarded.
+func (d *StreamDecoder) Decode(data []byte) (bool, error) {
+	var event StreamEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return false, fmt.Errorf("failed to parse anthropic stream event: %w", err)
+	}
+
+	switch event.Type {
+	case "message_start":
+		d.usage.PromptTokens = event.Message.Usage.InputTokens
+	case "message_delta":
+		// Output tokens in message_delta are cumulative
+		d.usage.CompletionTokens = event.Usage.OutputTokens
+		if event.Delta.StopReason != "" {
+			d.stopReason = event.Delta.StopReason
+		}
+	case "content_block_start":
+		if event.ContentBlock.Type == "tool_use" {
+			d.toolCall = &types.ToolCall{
+				ID:       event.ContentBlock.ID,
+				Function: types.ToolCallFunction{Name: event.ContentBlock.Name},
+			}
+			d.toolInput.Reset()
+		}
+	case "content_block_delta":
+		if event.Delta.Type == "input_json_delta" {
+			d.toolInput.WriteString(event.Delta.PartialJSON)
+			return false, nil
+		}
+		if event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+			return false, nil
+		}
+		return false, d.onChunk(types.StreamChunk{Content: event.Delta.Text})
+	case "content_block_stop":
+		if d.toolCall == nil {
+			return false, nil
+		}
+		d.toolCall.Function.Arguments = types.ToolArgumentsFromString(d.toolInput.String())
+		chunk := types.StreamChunk{ToolCalls: []types.ToolCall{*d.toolCall}}
+		if d.format != nil && d.toolCall.Function.Name == formatToolName {
+			chunk = types.StreamChunk{Content: formatContent(d.format, d.toolCall.Function.Arguments)}
+		}
+		d.toolCall = nil
+		return false, d.onChunk(chunk)
+	case "message_stop":
+		usage := d.usage
+		return true, d.onChunk(types.StreamChunk{Done: true, DoneReason: types.OllamaDoneReason(d.stopReason), Usage: &usage})
+	case "error":
+		if event.Error != nil {
+			err := fmt.Errorf("anthropic API error: %s: %s", event.Error.Type, event.Error.Message)
+			if retryableStreamError(event.Error.Type) {
+				return false, retry.Temporary(err)
+			}
+			return false, err
+		}
+		return false, fmt.Errorf("anthropic API error: unknown stream error")
+	}
+	return false, nil
+}
@@ -0,0 +1,54 @@
+package azure
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go-llm-proxy/internal/keys"
+	"go-llm-proxy/pkg/openai"
+
+	goopenai "github.com/sashabaranov/go-openai"
+)
+
+// DefaultAPIVersion is the api-version sent when none is configured
+const DefaultAPIVersion = "2024-10-21"
+
+// streamUsageAPIVersion is the first api-version whose streams take stream_options
+const streamUsageAPIVersion = "2024-09-01"
+
+// AzureBackend implements the BackendHandler interface for Azure OpenAI. Requests take the
+// OpenAI shape, but are sent to a deployment of the resource, addressed by the model of
+// the request, with an api-version query parameter and the key in the api-key header.
+type AzureBackend struct {
+	*openai.OpenAIBackend
+}
+
+// NewAzureBackend creates a backend for the Azure OpenAI resource at endpoint, such as
+// https://my-resource.openai.azure.com. Registry models map to deployments through
+// their backend model, which names the deployment.
+func NewAzureBackend(apiKey, endpoint, apiVersion string) *AzureBackend {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if apiVersion == "" {
+		apiVersion = DefaultAPIVersion
+	}
+
+	return &AzureBackend{
+		OpenAIBackend: openai.NewOpenAIBackendWithEndpoint(apiKey, openai.Endpoint{
+			Name:      "azure",
+			Authorize: keys.HeaderAuth(goopenai.AzureAPIKeyHeader),
+			// Deployment names are used as they are, rather than derived from model names
+			URL: func(path, deployment string) string {
+				return deploymentURL(endpoint, deployment, path, apiVersion)
+			},
+			// api-versions are dates, optionally followed by -preview, so they sort as strings
+			StreamUsage: apiVersion >= streamUsageAPIVersion,
+		}),
+	}
+}
+
+// deploymentURL returns the URL of an API path, such as /chat/completions, of a deployment
+func deploymentURL(endpoint, deployment, path, apiVersion string) string {
+	return fmt.Sprintf("%s/openai/deployments/%s%s?api-version=%s",
+		strings.TrimRight(endpoint, "/"), url.PathEscape(deployment), path, url.QueryEscape(apiVersion))
+}
@@ -0,0 +1,208 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go-llm-proxy/internal/awsauth"
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/anthropic"
+)
+
+// anthropicVersion is the Messages API version Bedrock expects in the request body
+const anthropicVersion = "bedrock-2023-05-31"
+
+// SigningName is the service name Bedrock requests are signed for, on both the
+// bedrock-runtime endpoint and the bedrock endpoint that lists the models
+const SigningName = "bedrock"
+
+// RuntimeEndpoint returns the bedrock-runtime endpoint of a region, which serves InvokeModel
+func RuntimeEndpoint(region string) string {
+	return "https://bedrock-runtime." + region + ".amazonaws.com"
+}
+
+// BedrockBackend implements the BackendHandler interface for Anthropic models on Amazon
+// Bedrock. Requests are sent with InvokeModel and InvokeModelWithResponseStream in the
+// Anthropic Messages format, signed with Signature Version 4.
+type BedrockBackend struct {
+	endpoint  string
+	client    *http.Client
+	transport *retry.Transport
+}
+
+// NewBedrockBackend creates a new Bedrock backend for a region, with credentials from the
+// standard AWS chain; an empty profile means AWS_PROFILE or the default profile
+func NewBedrockBackend(region, profile string) *BedrockBackend {
+	return NewBedrockBackendWithEndpoint(RuntimeEndpoint(region), region, awsauth.DefaultChain(profile, region))
+}
+
+// NewBedrockBackendWithEndpoint creates a new Bedrock backend that talks to the given
+// runtime endpoint, such as a VPC endpoint, signing requests for region with credentials
+func NewBedrockBackendWithEndpoint(endpoint, region string, credentials awsauth.Provider) *BedrockBackend {
+	// Requests are signed below the retries, so every attempt carries a fresh signature
+	signing := &awsauth.Transport{
+		Signer: &awsauth.Signer{Credentials: credentials, Region: region, Service: SigningName},
+	}
+	transport := retry.NewTransport(retry.DefaultPolicy())
+	transport.Base = signing
+	return &BedrockBackend{
+		endpoint:  strings.TrimRight(endpoint, "/"),
+		client:    &http.Client{Transport: transport},
+		transport: transport,
+	}
+}
+
+// SetRetryPolicy sets how requests failing with throttling, server or network errors are retried
+func (bb *BedrockBackend) SetRetryPolicy(policy retry.Policy) {
+	bb.transport.Policy = policy
+}
+
+// Generate handles text generation requests
+func (bb *BedrockBackend) Generate(ctx context.Context, req types.GenerateRequest) (*types.GenerateResponse, error) {
+	resp, err := bb.Chat(ctx, req.ToChatRequest())
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.GenerateResponse{
+		Model:      req.Model,
+		Content:    resp.Message.Content,
+		CreatedAt:  resp.CreatedAt,
+		Usage:      resp.Usage,
+		DoneReason: resp.DoneReason,
+	}, nil
+}
+
+// GenerateStream handles streaming text generation requests
+func (bb *BedrockBackend) GenerateStream(ctx context.Context, req types.GenerateRequest, onChunk types.StreamCallback) error {
+	return bb.ChatStream(ctx, req.ToChatRequest(), onChunk)
+}
+
+// Chat handles chat completion requests using InvokeModel
+func (bb *BedrockBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.ChatResponse, error) {
+	bedrockReq, err := buildRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpResp, err := bb.doRequest(ctx, req.Model, "invoke", bedrockReq)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := httpResp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp anthropic.AnthropicResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.ChatResponse(req), nil
+}
+
+// ChatStream handles streaming chat completion requests using InvokeModelWithResponseStream
+func (bb *BedrockBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	bedrockReq, err := buildRequest(req)
+	if err != nil {
+		return err
+	}
+
+	httpResp, err := bb.doRequest(ctx, req.Model, "invoke-with-response-stream", bedrockReq)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := httpResp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	return readStream(httpResp.Body, req.Format, onChunk)
+}
+
+// IsAvailable checks if the backend is available. Credentials are looked up when a
+// request is signed, so a missing one fails the request.
+func (bb *BedrockBackend) IsAvailable() bool {
+	return bb.endpoint != ""
+}
+
+// GetName returns the backend name
+func (bb *BedrockBackend) GetName() string {
+	return "bedrock"
+}
+
+// SupportsOption reports whether Anthropic models on Bedrock can honor a generation option
+func (bb *BedrockBackend) SupportsOption(name string) bool {
+	// Like the Messages API, Bedrock's Anthropic format has no seed parameter
+	return name != types.OptionSeed
+}
+
+// buildRequest converts a chat request to the Anthropic format on Bedrock: a Messages
+// request naming the API version instead of the model, which goes in the URL
+func buildRequest(req types.ChatRequest) (BedrockRequest, error) {
+	anthropicReq, err := anthropic.BuildChatRequest(req)
+	if err != nil {
+		return BedrockRequest{}, err
+	}
+	anthropicReq.Model = ""
+	return BedrockRequest{AnthropicVersion: anthropicVersion, AnthropicRequest: anthropicReq}, nil
+}
+
+// doRequest invokes a model and returns the successful HTTP response. The model ID may
+// also be an inference profile or a provisioned model ARN. The caller is responsible for
+// closing the response body.
+func (bb *BedrockBackend) doRequest(ctx context.Context, modelID, action string, req BedrockRequest) (*http.Response, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	url := bb.endpoint + "/model/" + awsauth.EscapePathSegment(modelID) + "/" + action
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	if action == "invoke-with-response-stream" {
+		httpReq.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	}
+
+	resp, err := bb.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		if err := resp.Body.Close(); err != nil {
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+		return nil, retry.WithStatus(resp.StatusCode, fmt.Errorf("bedrock API error: %s", string(body)))
+	}
+
+	return resp, nil
+}
+
+// BedrockRequest represents an InvokeModel request body for an Anthropic model
+type BedrockRequest struct {
+	AnthropicVersion string `json:"anthropic_version"`
+	anthropic.AnthropicRequest
+}
@@ -0,0 +1,190 @@
+package bedrock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// Streamed Bedrock responses use the AWS event stream encoding (application/vnd.amazon.eventstream),
+// a sequence of binary messages. Each message is framed as:
+//
+//	total length    uint32, the whole message including this prelude and the trailing CRC
+//	headers length  uint32
+//	prelude CRC     uint32, CRC-32 of the two lengths
+//	headers         name length (uint8), name, value type (uint8), value; repeated
+//	payload         total length - headers length - 16 bytes
+//	message CRC     uint32, CRC-32 of everything before it
+//
+// All integers are big-endian.
+
+// preludeLength is the size of the two lengths and the prelude CRC
+const preludeLength = 12
+
+// maxMessageLength bounds the messages read, as the event stream specification does
+const maxMessageLength = 16 << 20
+
+// Header value types
+const (
+	headerTrue      = 0
+	headerFalse     = 1
+	headerByte      = 2
+	headerShort     = 3
+	headerInt       = 4
+	headerLong      = 5
+	headerBytes     = 6
+	headerString    = 7
+	headerTimestamp = 8
+	headerUUID      = 9
+)
+
+// Message is one message of an event stream
+type Message struct {
+	// Headers holds the header values as bool, int8, int16, int32, int64, []byte, string,
+	// time.Time, or [16]byte for UUIDs
+	Headers map[string]interface{}
+	Payload []byte
+}
+
+// Header returns a string header, such as :event-type, or "" when it is missing
+func (m *Message) Header(name string) string {
+	value, _ := m.Headers[name].(string)
+	return value
+}
+
+// Decoder reads the messages of an event stream
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder creates a decoder reading messages from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Decode reads the next message. It returns io.EOF at the end of the stream, and
+// io.ErrUnexpectedEOF when the stream ends within a message.
+func (d *Decoder) Decode() (*Message, error) {
+	prelude := make([]byte, preludeLength)
+	if _, err := io.ReadFull(d.r, prelude); err != nil {
+		return nil, err
+	}
+
+	totalLength := binary.BigEndian.Uint32(prelude[0:4])
+	headersLength := binary.BigEndian.Uint32(prelude[4:8])
+	if crc := crc32.ChecksumIEEE(prelude[0:8]); crc != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, fmt.Errorf("event stream: prelude checksum mismatch")
+	}
+	if totalLength > maxMessageLength || totalLength < preludeLength+4 || headersLength > totalLength-preludeLength-4 {
+		return nil, fmt.Errorf("event stream: invalid message length %d with %d bytes of headers", totalLength, headersLength)
+	}
+
+	message := make([]byte, totalLength)
+	copy(message, prelude)
+	if _, err := io.ReadFull(d.r, message[preludeLength:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	crcOffset := totalLength - 4
+	if crc := crc32.ChecksumIEEE(message[:crcOffset]); crc != binary.BigEndian.Uint32(message[crcOffset:]) {
+		return nil, fmt.Errorf("event stream: message checksum mismatch")
+	}
+
+	headersEnd := preludeLength + headersLength
+	headers, err := decodeHeaders(message[preludeLength:headersEnd])
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Headers: headers, Payload: message[headersEnd:crcOffset]}, nil
+}
+
+// errTruncatedHeaders reports a headers section that ends within a header
+var errTruncatedHeaders = fmt.Errorf("event stream: truncated headers")
+
+// decodeHeaders parses the headers section of a message
+func decodeHeaders(data []byte) (map[string]interface{}, error) {
+	headers := make(map[string]interface{})
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		nameLength, err := r.ReadByte()
+		if err != nil {
+			return nil, errTruncatedHeaders
+		}
+		name := make([]byte, nameLength)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, errTruncatedHeaders
+		}
+		valueType, err := r.ReadByte()
+		if err != nil {
+			return nil, errTruncatedHeaders
+		}
+
+		value, err := decodeHeaderValue(r, valueType)
+		if err != nil {
+			return nil, fmt.Errorf("event stream: header %s: %w", name, err)
+		}
+		headers[string(name)] = value
+	}
+	return headers, nil
+}
+
+// decodeHeaderValue reads a header value of the given type
+func decodeHeaderValue(r *bytes.Reader, valueType byte) (interface{}, error) {
+	// Variable-length values start with their length
+	var length int
+	switch valueType {
+	case headerTrue:
+		return true, nil
+	case headerFalse:
+		return false, nil
+	case headerByte:
+		length = 1
+	case headerShort:
+		length = 2
+	case headerInt:
+		length = 4
+	case headerLong, headerTimestamp:
+		length = 8
+	case headerUUID:
+		length = 16
+	case headerBytes, headerString:
+		var prefix uint16
+		if err := binary.Read(r, binary.BigEndian, &prefix); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		length = int(prefix)
+	default:
+		return nil, fmt.Errorf("unknown value type %d", valueType)
+	}
+
+	value := make([]byte, length)
+	if _, err := io.ReadFull(r, value); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	switch valueType {
+	case headerByte:
+		return int8(value[0]), nil
+	case headerShort:
+		return int16(binary.BigEndian.Uint16(value)), nil
+	case headerInt:
+		return int32(binary.BigEndian.Uint32(value)), nil
+	case headerLong:
+		return int64(binary.BigEndian.Uint64(value)), nil
+	case headerTimestamp:
+		// Timestamps are milliseconds since the epoch
+		return time.UnixMilli(int64(binary.BigEndian.Uint64(value))).UTC(), nil
+	case headerUUID:
+		var uuid [16]byte
+		copy(uuid[:], value)
+		return uuid, nil
+	case headerString:
+		return string(value), nil
+	}
+	return value, nil
+}
@@ -0,0 +1,77 @@
+package bedrock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/anthropic"
+)
+
+// retryableExceptions are the stream exceptions that signal a transient failure
+var retryableExceptions = map[string]bool{
+	"throttlingException":         true,
+	"internalServerException":     true,
+	"serviceUnavailableException": true,
+	"modelStreamErrorException":   true,
+	"modelTimeoutException":       true,
+}
+
+// readStream parses an InvokeModelWithResponseStream event stream and forwards its chunks
+// to onChunk. Each "chunk" event carries one Messages stream event, base64-encoded in its
+// JSON payload; an exception message ends the stream.
+func readStream(body io.Reader, format *types.ResponseFormat, onChunk types.StreamCallback) error {
+	messages := NewDecoder(body)
+	events := anthropic.NewStreamDecoder(format, onChunk)
+	for {
+		message, err := messages.Decode()
+		if errors.Is(err, io.EOF) {
+			return retry.Temporary(fmt.Errorf("bedrock stream ended unexpectedly"))
+		}
+		if err != nil {
+			return retry.Temporary(fmt.Errorf("failed to read bedrock stream: %w", err))
+		}
+
+		switch message.Header(":message-type") {
+		case "event":
+			if message.Header(":event-type") != "chunk" {
+				continue
+			}
+			var chunk struct {
+				Bytes []byte `json:"bytes"`
+			}
+			if err := json.Unmarshal(message.Payload, &chunk); err != nil {
+				return fmt.Errorf("failed to parse bedrock stream chunk: %w", err)
+			}
+			done, err := events.Decode(chunk.Bytes)
+			if done || err != nil {
+				return err
+			}
+		case "exception":
+			return streamException(message.Header(":exception-type"), message.Payload)
+		case "error":
+			return streamException(message.Header(":error-code"), []byte(message.Header(":error-message")))
+		}
+	}
+}
+
+// streamException converts an exception sent in a stream to an error
+func streamException(exceptionType string, payload []byte) error {
+	detail := strings.TrimSpace(string(payload))
+	var body struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(payload, &body) == nil && body.Message != "" {
+		detail = body.Message
+	}
+
+	err := fmt.Errorf("bedrock API error: %s: %s", exceptionType, detail)
+	if retryableExceptions[exceptionType] {
+		return retry.Temporary(err)
+	}
+	return err
+}
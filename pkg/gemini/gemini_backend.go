@@ -0,0 +1,470 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-llm-proxy/internal/keys"
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+)
+
+// DefaultBaseURL is the base URL of the public Gemini API
+const DefaultBaseURL = "https://generativelanguage.googleapis.com"
+
+// maxStopSequences is the number of stop sequences generateContent accepts
+const maxStopSequences = 5
+
+// GeminiBackend implements the BackendHandler interface for Google Gemini
+type GeminiBackend struct {
+	baseURL   string
+	client    *http.Client
+	transport *retry.Transport
+	keys      *keys.Transport
+}
+
+// NewGeminiBackend creates a new Gemini backend
+func NewGeminiBackend(apiKey string) *GeminiBackend {
+	return NewGeminiBackendWithBaseURL(apiKey, DefaultBaseURL)
+}
+
+// NewGeminiBackendWithBaseURL creates a new Gemini backend that talks to the given base URL
+func NewGeminiBackendWithBaseURL(apiKey, baseURL string) *GeminiBackend {
+	// Keys are chosen below the retries, so a retry can move to another key
+	keyTransport := &keys.Transport{
+		Pool:      keys.NewPool([]string{apiKey}, keys.RoundRobin),
+		Authorize: keys.HeaderAuth("x-goog-api-key"),
+	}
+	transport := retry.NewTransport(retry.DefaultPolicy())
+	transport.Base = keyTransport
+	return &GeminiBackend{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		client:    &http.Client{Transport: transport},
+		transport: transport,
+		keys:      keyTransport,
+	}
+}
+
+// SetKeyPool sets the API keys requests are spread over
+func (gb *GeminiBackend) SetKeyPool(pool *keys.Pool) {
+	gb.keys.Pool = pool
+}
+
+// KeyStats returns the usage of each API key
+func (gb *GeminiBackend) KeyStats() []keys.Stats {
+	return gb.keys.Pool.Stats()
+}
+
+// SetRetryPolicy sets how requests failing with rate-limit, server or network errors are retried
+func (gb *GeminiBackend) SetRetryPolicy(policy retry.Policy) {
+	gb.transport.Policy = policy
+}
+
+// Generate handles text generation requests
+func (gb *GeminiBackend) Generate(ctx context.Context, req types.GenerateRequest) (*types.GenerateResponse, error) {
+	resp, err := gb.Chat(ctx, req.ToChatRequest())
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.GenerateResponse{
+		Model:      req.Model,
+		Content:    resp.Message.Content,
+		CreatedAt:  resp.CreatedAt,
+		Usage:      resp.Usage,
+		DoneReason: resp.DoneReason,
+	}, nil
+}
+
+// GenerateStream handles streaming text generation requests
+func (gb *GeminiBackend) GenerateStream(ctx context.Context, req types.GenerateRequest, onChunk types.StreamCallback) error {
+	return gb.ChatStream(ctx, req.ToChatRequest(), onChunk)
+}
+
+// Chat handles chat completion requests
+func (gb *GeminiBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.ChatResponse, error) {
+	geminiReq, err := buildRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := gb.makeRequest(ctx, req.Model, geminiReq)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.blocked(); err != nil {
+		return nil, err
+	}
+
+	candidate := resp.Candidates[0]
+	return &types.ChatResponse{
+		Model: req.Model,
+		Message: types.ChatMessage{
+			Role:      "assistant",
+			Content:   candidate.Content.text(),
+			ToolCalls: candidate.Content.toolCalls(0),
+		},
+		CreatedAt:  time.Now().Format(time.RFC3339),
+		Usage:      resp.UsageMetadata.usage(),
+		DoneReason: doneReason(candidate.FinishReason),
+	}, nil
+}
+
+// ChatStream handles streaming chat completion requests using the streamGenerateContent SSE stream
+func (gb *GeminiBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	geminiReq, err := buildRequest(req)
+	if err != nil {
+		return err
+	}
+
+	httpResp, err := gb.doRequest(ctx, req.Model, "streamGenerateContent", geminiReq)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := httpResp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	return readStream(httpResp.Body, onChunk)
+}
+
+// IsAvailable checks if the backend is available
+func (gb *GeminiBackend) IsAvailable() bool {
+	return gb.keys.Pool.Len() > 0
+}
+
+// GetName returns the backend name
+func (gb *GeminiBackend) GetName() string {
+	return "gemini"
+}
+
+// buildToolConfig converts a tool choice to a tool config, or nil when the model may choose
+func buildToolConfig(choice *types.ToolChoice) *ToolConfig {
+	if choice == nil {
+		return nil
+	}
+
+	switch choice.Type {
+	case "any":
+		return &ToolConfig{FunctionCallingConfig: FunctionCallingConfig{Mode: "ANY"}}
+	case "none":
+		return &ToolConfig{FunctionCallingConfig: FunctionCallingConfig{Mode: "NONE"}}
+	case "tool":
+		return &ToolConfig{FunctionCallingConfig: FunctionCallingConfig{
+			Mode:                 "ANY",
+			AllowedFunctionNames: []string{choice.Name},
+		}}
+	default:
+		return nil
+	}
+}
+
+// buildRequest converts a chat request to the generateContent format
+func buildRequest(req types.ChatRequest) (GeminiRequest, error) {
+	system, contents, err := NormalizeMessages(req.Messages)
+	if err != nil {
+		return GeminiRequest{}, err
+	}
+
+	geminiReq := GeminiRequest{
+		Contents:          contents,
+		SystemInstruction: system,
+	}
+
+	var declarations []FunctionDeclaration
+	for _, tool := range req.Tools {
+		declarations = append(declarations, FunctionDeclaration{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  tool.Function.Parameters,
+		})
+	}
+	if len(declarations) > 0 {
+		geminiReq.Tools = []GeminiTool{{FunctionDeclarations: declarations}}
+		geminiReq.ToolConfig = buildToolConfig(req.ToolChoice)
+	}
+
+	config := GenerationConfig{
+		TopP:            req.Options.TopP,
+		TopK:            req.Options.TopK,
+		Seed:            req.Options.Seed,
+		StopSequences:   req.Options.Stop,
+		MaxOutputTokens: req.MaxTokens,
+	}
+	if req.Options.Temperature != nil {
+		// Gemini accepts temperatures from 0 to 2, like Ollama and OpenAI
+		temperature := math.Min(*req.Options.Temperature, 2)
+		config.Temperature = &temperature
+	}
+	if len(config.StopSequences) > maxStopSequences {
+		log.Printf("Sending only the first %d of %d stop sequences to Gemini", maxStopSequences, len(config.StopSequences))
+		config.StopSequences = config.StopSequences[:maxStopSequences]
+	}
+	if req.Format != nil {
+		config.ResponseMimeType = "application/json"
+		if req.Format.HasSchema() {
+			config.ResponseSchema = req.Format.Schema
+		}
+	}
+	geminiReq.GenerationConfig = &config
+	return geminiReq, nil
+}
+
+// makeRequest makes a generateContent request to the Gemini API
+func (gb *GeminiBackend) makeRequest(ctx context.Context, model string, req GeminiRequest) (*GeminiResponse, error) {
+	resp, err := gb.doRequest(ctx, model, "generateContent", req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var geminiResp GeminiResponse
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		return nil, err
+	}
+
+	return &geminiResp, nil
+}
+
+// doRequest sends a request to a method of the model and returns the successful HTTP response.
+// The caller is responsible for closing the response body.
+func (gb *GeminiBackend) doRequest(ctx context.Context, model, method string, req GeminiRequest) (*http.Response, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s:%s", gb.baseURL, url.PathEscape(model), method)
+	if method == "streamGenerateContent" {
+		endpoint += "?alt=sse"
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := gb.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		if err := resp.Body.Close(); err != nil {
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+		return nil, retry.WithStatus(resp.StatusCode, fmt.Errorf("gemini API error: %s", string(body)))
+	}
+
+	return resp, nil
+}
+
+// GeminiRequest represents a generateContent request
+type GeminiRequest struct {
+	Contents          []Content         `json:"contents"`
+	SystemInstruction *Content          `json:"systemInstruction,omitempty"`
+	Tools             []GeminiTool      `json:"tools,omitempty"`
+	ToolConfig        *ToolConfig       `json:"toolConfig,omitempty"`
+	GenerationConfig  *GenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// Content is a turn of the conversation: role "user" or "model", and its parts
+type Content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []Part `json:"parts"`
+}
+
+// Part is a piece of content: text, inline image data, a function call or a function response
+type Part struct {
+	Text             string            `json:"text,omitempty"`
+	InlineData       *Blob             `json:"inlineData,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+	// Thought marks the reasoning summaries of thinking models, which aren't part of the answer
+	Thought bool `json:"thought,omitempty"`
+}
+
+// Blob holds base64-encoded inline data
+type Blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// FunctionCall is a call of a declared function requested by the model
+type FunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// FunctionResponse returns the result of a function call to the model
+type FunctionResponse struct {
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+// GeminiTool holds the functions the model may call
+type GeminiTool struct {
+	FunctionDeclarations []FunctionDeclaration `json:"functionDeclarations"`
+}
+
+// ToolConfig controls whether the model calls the declared functions
+type ToolConfig struct {
+	FunctionCallingConfig FunctionCallingConfig `json:"functionCallingConfig"`
+}
+
+// FunctionCallingConfig sets the calling mode (AUTO, ANY or NONE) and, for ANY,
+// the functions the model may call
+type FunctionCallingConfig struct {
+	Mode                 string   `json:"mode"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
+// FunctionDeclaration describes a function, with its parameters as JSON Schema
+type FunctionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parametersJsonSchema,omitempty"`
+}
+
+// GenerationConfig holds the sampling options, output limit and response format
+type GenerationConfig struct {
+	Temperature      *float64        `json:"temperature,omitempty"`
+	TopP             *float64        `json:"topP,omitempty"`
+	TopK             *int            `json:"topK,omitempty"`
+	Seed             *int            `json:"seed,omitempty"`
+	StopSequences    []string        `json:"stopSequences,omitempty"`
+	MaxOutputTokens  int             `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType string          `json:"responseMimeType,omitempty"`
+	ResponseSchema   json.RawMessage `json:"responseJsonSchema,omitempty"`
+}
+
+// GeminiResponse represents a generateContent response, or one chunk of a stream
+type GeminiResponse struct {
+	Candidates     []Candidate     `json:"candidates"`
+	PromptFeedback *PromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *UsageMetadata  `json:"usageMetadata,omitempty"`
+}
+
+// Candidate is a generated response
+type Candidate struct {
+	Content      Content `json:"content"`
+	FinishReason string  `json:"finishReason,omitempty"`
+}
+
+// PromptFeedback reports whether the prompt was blocked
+type PromptFeedback struct {
+	BlockReason string `json:"blockReason,omitempty"`
+}
+
+// UsageMetadata represents token usage reported by the Gemini API
+type UsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+}
+
+// usage converts the usage metadata, counting the tokens thinking models spend on reasoning as output
+func (u *UsageMetadata) usage() *types.Usage {
+	if u == nil {
+		return &types.Usage{}
+	}
+	return &types.Usage{
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: u.CandidatesTokenCount + u.ThoughtsTokenCount,
+	}
+}
+
+// text returns the text of all answer parts
+func (c Content) text() string {
+	var text strings.Builder
+	for _, part := range c.Parts {
+		if !part.Thought {
+			text.WriteString(part.Text)
+		}
+	}
+	return text.String()
+}
+
+// toolCalls returns the function calls of the content as tool calls. Calls without an ID
+// get one from their position, counted from first.
+func (c Content) toolCalls(first int) []types.ToolCall {
+	var toolCalls []types.ToolCall
+	for _, part := range c.Parts {
+		if part.FunctionCall == nil {
+			continue
+		}
+		id := part.FunctionCall.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", first+len(toolCalls))
+		}
+		args := part.FunctionCall.Args
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		toolCalls = append(toolCalls, types.ToolCall{
+			ID:       id,
+			Function: types.ToolCallFunction{Name: part.FunctionCall.Name, Arguments: args},
+		})
+	}
+	return toolCalls
+}
+
+// blockedReasons are the finish reasons of candidates withheld by Gemini's safety and
+// content policies, rather than generated to completion
+var blockedReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+	"IMAGE_SAFETY":       true,
+}
+
+// blocked returns an error when the prompt was blocked, or the response was withheld
+// before any of it was generated
+func (r *GeminiResponse) blocked() error {
+	if len(r.Candidates) == 0 {
+		if r.PromptFeedback != nil && r.PromptFeedback.BlockReason != "" {
+			return fmt.Errorf("gemini blocked the prompt: %s", r.PromptFeedback.BlockReason)
+		}
+		return fmt.Errorf("gemini returned no candidates")
+	}
+	candidate := r.Candidates[0]
+	if blockedReasons[candidate.FinishReason] && candidate.Content.text() == "" && len(candidate.Content.toolCalls(0)) == 0 {
+		return fmt.Errorf("gemini blocked the response: %s", candidate.FinishReason)
+	}
+	return nil
+}
+
+// doneReason maps a Gemini finish reason to an Ollama done_reason
+func doneReason(finishReason string) string {
+	if finishReason == "MAX_TOKENS" {
+		return "length"
+	}
+	return "stop"
+}
@@ -0,0 +1,114 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go-llm-proxy/internal/types"
+)
+
+// NormalizeMessages rewrites an Ollama or OpenAI style conversation into Gemini contents.
+// Gemini differs from both in a few ways:
+//   - system prompts go in the separate system instruction, not in the conversation
+//   - the assistant role is called "model"
+//   - tool results are functionResponse parts of a user turn, naming the function they
+//     answer, and their response must be a JSON object
+//
+// Consecutive turns of one role are merged, as Gemini expects the roles to alternate.
+func NormalizeMessages(messages []types.ChatMessage) (*Content, []Content, error) {
+	var systemParts []Part
+	var contents []Content
+	callNames := make(map[string]string)
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system", "developer":
+			if text := strings.TrimSpace(msg.Content); text != "" {
+				systemParts = append(systemParts, Part{Text: text})
+			}
+			continue
+		case "tool", "function":
+			name := msg.ToolName
+			if name == "" {
+				name = callNames[msg.ToolCallID]
+			}
+			contents = appendContent(contents, "user", []Part{{
+				FunctionResponse: &FunctionResponse{Name: name, Response: functionResponse(msg.Content)},
+			}})
+			continue
+		}
+
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+
+		parts, err := messageParts(msg)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, call := range msg.ToolCalls {
+			callNames[call.ID] = call.Function.Name
+		}
+		contents = appendContent(contents, role, parts)
+	}
+
+	if len(contents) == 0 {
+		return nil, nil, fmt.Errorf("conversation must contain at least one user or assistant message")
+	}
+
+	var system *Content
+	if len(systemParts) > 0 {
+		system = &Content{Parts: systemParts}
+	}
+	return system, contents, nil
+}
+
+// messageParts converts the images, text and tool calls of a message to parts.
+// Images go ahead of the text that refers to them, and empty text is dropped.
+func messageParts(msg types.ChatMessage) ([]Part, error) {
+	var parts []Part
+	for _, data := range msg.Images {
+		image, err := types.ParseImage(data)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, Part{InlineData: &Blob{MimeType: image.MediaType, Data: image.Data}})
+	}
+	if strings.TrimSpace(msg.Content) != "" {
+		parts = append(parts, Part{Text: msg.Content})
+	}
+	for _, call := range msg.ToolCalls {
+		args := call.Function.Arguments
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		parts = append(parts, Part{FunctionCall: &FunctionCall{Name: call.Function.Name, Args: args}})
+	}
+	return parts, nil
+}
+
+// functionResponse converts a tool result to a functionResponse response. Results that
+// aren't JSON objects are wrapped in a "content" field.
+func functionResponse(content string) json.RawMessage {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(content), &object); err == nil && object != nil {
+		return json.RawMessage(content)
+	}
+	wrapped, _ := json.Marshal(map[string]string{"content": content})
+	return wrapped
+}
+
+// appendContent adds parts to the conversation, merging them into the last turn when it
+// has the same role. Messages without parts are skipped.
+func appendContent(contents []Content, role string, parts []Part) []Content {
+	if len(parts) == 0 {
+		return contents
+	}
+	if len(contents) > 0 && contents[len(contents)-1].Role == role {
+		last := &contents[len(contents)-1]
+		last.Parts = append(last.Parts, parts...)
+		return contents
+	}
+	return append(contents, Content{Role: role, Parts: parts})
+}
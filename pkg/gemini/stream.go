@@ -0,0 +1,81 @@
+package gemini
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+)
+
+// readStream parses a streamGenerateContent SSE stream and forwards text deltas to onChunk.
+// Each event is a complete response holding the next parts; function calls arrive whole.
+// Usage metadata is cumulative, so the last event's is reported. The stream has no end
+// marker: it is complete once an event has carried a finish reason.
+func readStream(body io.Reader, onChunk types.StreamCallback) error {
+	var usage *UsageMetadata
+	var finishReason string
+	var sent bool
+	var toolCalls int
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return retry.Temporary(fmt.Errorf("failed to read gemini stream: %w", err))
+		}
+		if err == io.EOF && line == "" {
+			if finishReason == "" {
+				return retry.Temporary(fmt.Errorf("gemini stream ended unexpectedly"))
+			}
+			return onChunk(types.StreamChunk{Done: true, DoneReason: doneReason(finishReason), Usage: usage.usage()})
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if !strings.HasPrefix(line, "data:") {
+			// Skip comments and the blank lines separating events
+			continue
+		}
+
+		var event GeminiResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return fmt.Errorf("failed to parse gemini stream event: %w", err)
+		}
+		if event.UsageMetadata != nil {
+			usage = event.UsageMetadata
+		}
+		if len(event.Candidates) == 0 {
+			if event.PromptFeedback != nil && event.PromptFeedback.BlockReason != "" {
+				return event.blocked()
+			}
+			continue
+		}
+
+		candidate := event.Candidates[0]
+		if !sent {
+			// A response withheld before anything was sent fails like a non-streamed one
+			if err := event.blocked(); err != nil {
+				return err
+			}
+		}
+		if candidate.FinishReason != "" {
+			finishReason = candidate.FinishReason
+		}
+
+		if text := candidate.Content.text(); text != "" {
+			if err := onChunk(types.StreamChunk{Content: text}); err != nil {
+				return err
+			}
+			sent = true
+		}
+		if calls := candidate.Content.toolCalls(toolCalls); len(calls) > 0 {
+			if err := onChunk(types.StreamChunk{ToolCalls: calls}); err != nil {
+				return err
+			}
+			toolCalls += len(calls)
+			sent = true
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+)
+
+// OllamaBackend implements the BackendHandler interface for an upstream Ollama server.
+// Requests arriving in the Ollama API format are passed through with Forward; requests
+// from the OpenAI and Anthropic endpoints are converted to /api/chat.
+type OllamaBackend struct {
+	baseURL   string
+	client    *http.Client
+	transport *retry.Transport
+}
+
+// NewOllamaBackend creates a new backend for the Ollama server at baseURL, such as
+// http://localhost:11434
+func NewOllamaBackend(baseURL string) *OllamaBackend {
+	transport := retry.NewTransport(retry.DefaultPolicy())
+	return &OllamaBackend{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		client:    &http.Client{Transport: transport},
+		transport: transport,
+	}
+}
+
+// SetRetryPolicy sets how requests failing with server or network errors are retried
+func (ob *OllamaBackend) SetRetryPolicy(policy retry.Policy) {
+	ob.transport.Policy = policy
+}
+
+// Generate handles text generation requests
+func (ob *OllamaBackend) Generate(ctx context.Context, req types.GenerateRequest) (*types.GenerateResponse, error) {
+	resp, err := ob.Chat(ctx, req.ToChatRequest())
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.GenerateResponse{
+		Model:      req.Model,
+		Content:    resp.Message.Content,
+		CreatedAt:  resp.CreatedAt,
+		Usage:      resp.Usage,
+		DoneReason: resp.DoneReason,
+	}, nil
+}
+
+// GenerateStream handles streaming text generation requests
+func (ob *OllamaBackend) GenerateStream(ctx context.Context, req types.GenerateRequest, onChunk types.StreamCallback) error {
+	return ob.ChatStream(ctx, req.ToChatRequest(), onChunk)
+}
+
+// Chat handles chat completion requests using /api/chat
+func (ob *OllamaBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.ChatResponse, error) {
+	httpResp, err := ob.doRequest(ctx, "/api/chat", buildRequest(req, false))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := httpResp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp types.OllamaChatResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+
+	return &types.ChatResponse{
+		Model: req.Model,
+		Message: types.ChatMessage{
+			Role:      "assistant",
+			Content:   resp.Message.Content,
+			ToolCalls: toolCalls(resp.Message.ToolCalls, 0),
+		},
+		CreatedAt:  resp.CreatedAt,
+		Usage:      &types.Usage{PromptTokens: resp.PromptEvalCount, CompletionTokens: resp.EvalCount},
+		DoneReason: types.OllamaDoneReason(resp.DoneReason),
+	}, nil
+}
+
+// ChatStream handles streaming chat completion requests using the /api/chat NDJSON stream
+func (ob *OllamaBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	httpResp, err := ob.doRequest(ctx, "/api/chat", buildRequest(req, true))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := httpResp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	return readStream(httpResp.Body, onChunk)
+}
+
+// Embed handles embedding requests using /api/embed, returning one vector per input in input order
+func (ob *OllamaBackend) Embed(ctx context.Context, req types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	httpResp, err := ob.doRequest(ctx, "/api/embed", types.OllamaEmbedRequest{
+		Model: req.Model,
+		Input: req.Input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := httpResp.Body.Close(); err != nil {
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var embedResp types.OllamaEmbedResponse
+	if err := json.Unmarshal(body, &embedResp); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings response: %w", err)
+	}
+	if len(embedResp.Embeddings) != len(req.Input) {
+		return nil, fmt.Errorf("ollama API returned %d embeddings for %d inputs", len(embedResp.Embeddings), len(req.Input))
+	}
+
+	return &types.EmbeddingResponse{
+		Model:      req.Model,
+		Embeddings: embedResp.Embeddings,
+		Usage: &types.Usage{
+			PromptTokens: embedResp.PromptEvalCount,
+		},
+	}, nil
+}
+
+// Forward sends a request in the Ollama API format to the server unchanged, and returns
+// the server's response whatever its status, so that errors and NDJSON streams reach the
+// client as the server sent them. body may be nil. The caller is responsible for closing
+// the response body.
+func (ob *OllamaBackend) Forward(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, ob.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	return ob.client.Do(httpReq)
+}
+
+// IsAvailable checks if the backend is available. The server needs no key, so an
+// unreachable one fails the request.
+func (ob *OllamaBackend) IsAvailable() bool {
+	return ob.baseURL != ""
+}
+
+// GetName returns the backend name
+func (ob *OllamaBackend) GetName() string {
+	return "ollama"
+}
+
+// buildRequest converts a chat request to an /api/chat request
+func buildRequest(req types.ChatRequest, stream bool) OllamaRequest {
+	messages := make([]types.OllamaMessage, len(req.Messages))
+	for i, msg := range req.Messages {
+		messages[i] = types.OllamaMessage{
+			Role:      msg.Role,
+			Content:   msg.Content,
+			Images:    msg.Images,
+			ToolCalls: msg.ToolCalls,
+			ToolName:  msg.ToolName,
+		}
+	}
+
+	// The computed limit only applies when the client didn't set num_predict
+	options := req.Options
+	if options.NumPredict == nil && req.MaxTokens > 0 {
+		maxTokens := req.MaxTokens
+		options.NumPredict = &maxTokens
+	}
+
+	// Ollama has no tool_choice, so the only choice it can honor is not to call tools
+	tools := req.Tools
+	if req.ToolChoice != nil && req.ToolChoice.Type == "none" {
+		tools = nil
+	}
+
+	ollamaReq := OllamaRequest{
+		Model:    req.Model,
+		Messages: messages,
+		Tools:    tools,
+		Stream:   stream,
+		Options:  options,
+	}
+	switch {
+	case req.Format.HasSchema():
+		ollamaReq.Format = req.Format.Schema
+	case req.Format != nil:
+		ollamaReq.Format = json.RawMessage(`"json"`)
+	}
+	return ollamaReq
+}
+
+// doRequest sends a request to an endpoint of the server and returns the successful HTTP
+// response. The caller is responsible for closing the response body.
+func (ob *OllamaBackend) doRequest(ctx context.Context, path string, req interface{}) (*http.Response, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := ob.Forward(ctx, "POST", path, jsonData)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		if err := resp.Body.Close(); err != nil {
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+		return nil, retry.WithStatus(resp.StatusCode, fmt.Errorf("ollama API error: %s", errorMessage(body)))
+	}
+
+	return resp, nil
+}
+
+// errorMessage returns the message of an Ollama error response, {"error": "..."}, or
+// the body itself when it isn't one
+func errorMessage(body []byte) string {
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+		return errResp.Error
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// toolCalls gives the tool calls of a response IDs, which Ollama doesn't assign,
+// numbering them from first
+func toolCalls(calls []types.ToolCall, first int) []types.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]types.ToolCall, len(calls))
+	for i, call := range calls {
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("call_%d", first+i)
+		}
+		if len(call.Function.Arguments) == 0 {
+			call.Function.Arguments = json.RawMessage("{}")
+		}
+		result[i] = call
+	}
+	return result
+}
+
+// OllamaRequest represents an /api/chat request
+type OllamaRequest struct {
+	Model    string                `json:"model"`
+	Messages []types.OllamaMessage `json:"messages"`
+	Tools    []types.Tool          `json:"tools,omitempty"`
+	Format   json.RawMessage       `json:"format,omitempty"`
+	Stream   bool                  `json:"stream"`
+	// Options uses the names of the Ollama options object
+	Options types.GenerationOptions `json:"options"`
+}
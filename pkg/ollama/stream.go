@@ -0,0 +1,64 @@
+package ollama
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+)
+
+// streamRecord is one line of an /api/chat NDJSON stream. A server failing mid-stream
+// sends a line with only an error.
+type streamRecord struct {
+	types.OllamaChatResponse
+	Error string `json:"error,omitempty"`
+}
+
+// readStream parses an /api/chat NDJSON stream and forwards its deltas to onChunk.
+// The record marked done carries the token counts and ends the stream.
+func readStream(body io.Reader, onChunk types.StreamCallback) error {
+	var toolCallCount int
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return retry.Temporary(fmt.Errorf("failed to read ollama stream: %w", err))
+		}
+		if err == io.EOF && len(line) == 0 {
+			return retry.Temporary(fmt.Errorf("ollama stream ended unexpectedly"))
+		}
+		if len(line) == 0 || line[0] == '\n' {
+			continue
+		}
+
+		var record streamRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("failed to parse ollama stream record: %w", err)
+		}
+		if record.Error != "" {
+			return fmt.Errorf("ollama API error: %s", record.Error)
+		}
+
+		chunk := types.StreamChunk{
+			Content:   record.Message.Content,
+			ToolCalls: toolCalls(record.Message.ToolCalls, toolCallCount),
+		}
+		toolCallCount += len(chunk.ToolCalls)
+		if chunk.Content != "" || len(chunk.ToolCalls) > 0 {
+			if err := onChunk(chunk); err != nil {
+				return err
+			}
+		}
+
+		if record.Done {
+			return onChunk(types.StreamChunk{
+				Done:       true,
+				DoneReason: types.OllamaDoneReason(record.DoneReason),
+				Usage:      &types.Usage{PromptTokens: record.PromptEvalCount, CompletionTokens: record.EvalCount},
+			})
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package openai
+
+import (
+	"net/http"
+	"strings"
+)
+
+// NewOpenAICompatibleBackend creates a backend for a server that speaks the OpenAI API at
+// baseURL, such as vLLM, LM Studio, llama.cpp server, Groq or OpenRouter. The backend
+// takes the name it is configured under, sends headers with every request, and sends a
+// key only once a key pool is set. Streams report token usage only once SetStreamUsage
+// enables it, since not every server accepts stream_options.
+func NewOpenAICompatibleBackend(name, baseURL string, headers map[string]string) *OpenAIBackend {
+	ob := NewOpenAIBackendWithBaseURL("", strings.TrimRight(baseURL, "/"))
+	ob.name = name
+	ob.keys.Optional = true
+	ob.keys.Base = &headerTransport{headers: headers}
+	ob.SetStreamUsage(false)
+	return ob
+}
+
+// headerTransport adds configured headers to upstream requests
+type headerTransport struct {
+	headers map[string]string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
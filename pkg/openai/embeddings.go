@@ -0,0 +1,96 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+)
+
+// The embeddings endpoint is called directly rather than through go-openai, whose
+// EmbeddingModel enum predates the text-embedding-3 models and drops unknown names.
+
+// embeddingRequest represents a request to the OpenAI embeddings API
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// embeddingResponse represents a response from the OpenAI embeddings API
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Model string `json:"model"`
+	Usage struct {
+		PromptTokens int `json:"prompt_tokens"`
+	} `json:"usage"`
+}
+
+// Embed handles embedding requests, returning one vector per input in input order
+func (ob *OpenAIBackend) Embed(ctx context.Context, req types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	jsonData, err := json.Marshal(embeddingRequest{
+		Model: req.Model,
+		Input: req.Input,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", ob.url("/embeddings", req.Model), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := ob.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, retry.WithStatus(resp.StatusCode, fmt.Errorf("openai API error: %s", string(body)))
+	}
+
+	var embeddingResp embeddingResponse
+	if err := json.Unmarshal(body, &embeddingResp); err != nil {
+		return nil, fmt.Errorf("failed to decode embeddings response: %w", err)
+	}
+	if len(embeddingResp.Data) != len(req.Input) {
+		return nil, fmt.Errorf("openai API returned %d embeddings for %d inputs", len(embeddingResp.Data), len(req.Input))
+	}
+
+	// The API documents data as ordered by index, but sort anyway so batches never get mismatched
+	sort.Slice(embeddingResp.Data, func(i, j int) bool {
+		return embeddingResp.Data[i].Index < embeddingResp.Data[j].Index
+	})
+
+	embeddings := make([][]float32, len(embeddingResp.Data))
+	for i, data := range embeddingResp.Data {
+		embeddings[i] = data.Embedding
+	}
+
+	return &types.EmbeddingResponse{
+		Model:      req.Model,
+		Embeddings: embeddings,
+		Usage: &types.Usage{
+			PromptTokens: embeddingResp.Usage.PromptTokens,
+		},
+	}, nil
+}
@@ -0,0 +1,53 @@
+package openai
+
+import (
+	"encoding/json"
+	"strings"
+
+	"go-llm-proxy/internal/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// JSON mode maps to response_format json_object, and schema formats map to json_schema
+
+// jsonModeInstruction is added when no message mentions JSON, which json_object mode requires
+const jsonModeInstruction = "Respond with JSON."
+
+// jsonSchemaFormat represents a json_schema response_format
+type jsonSchemaFormat struct {
+	Type       string `json:"type"`
+	JSONSchema struct {
+		Name   string          `json:"name"`
+		Schema json.RawMessage `json:"schema"`
+		Strict bool            `json:"strict"`
+	} `json:"json_schema"`
+}
+
+// applyFormat sets the response_format of a chat completion request
+func applyFormat(openaiReq *chatCompletionRequest, format *types.ResponseFormat) {
+	if format == nil {
+		return
+	}
+
+	if format.HasSchema() {
+		schemaFormat := jsonSchemaFormat{Type: "json_schema"}
+		schemaFormat.JSONSchema.Name = "response"
+		schemaFormat.JSONSchema.Schema = format.Schema
+		openaiReq.ResponseFormat = schemaFormat
+		return
+	}
+
+	openaiReq.ResponseFormat = openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+	}
+	for _, msg := range openaiReq.Messages {
+		if strings.Contains(strings.ToLower(msg.Content), "json") {
+			return
+		}
+	}
+	openaiReq.Messages = append([]openai.ChatCompletionMessage{{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: jsonModeInstruction,
+	}}, openaiReq.Messages...)
+}
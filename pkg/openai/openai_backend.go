@@ -1,118 +1,469 @@
 package openai
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
 
+	"go-llm-proxy/internal/keys"
+	"go-llm-proxy/internal/retry"
 	"go-llm-proxy/internal/types"
 
 	"github.com/sashabaranov/go-openai"
 )
 
-// OpenAIBackend implements the BackendHandler interface for OpenAI
+// OpenAIBackend implements the BackendHandler interface for OpenAI. The API is called
+// directly, with go-openai's request and response types, since its client predates
+// fields such as max_completion_tokens and json_schema response formats.
 type OpenAIBackend struct {
-	apiKey string
-	client *openai.Client
+	name       string
+	url        func(path, model string) string
+	httpClient *http.Client
+	transport  *retry.Transport
+	keys       *keys.Transport
+	// streamUsage is whether streams ask for usage with stream_options. It is cleared
+	// once the upstream rejects the field.
+	streamUsage atomic.Bool
+}
+
+// Endpoint describes where and how a backend speaking the OpenAI API is called
+type Endpoint struct {
+	// Name is the backend name
+	Name string
+	// Authorize sets an API key on upstream requests
+	Authorize keys.Authorizer
+	// URL returns the URL of an API path, such as /chat/completions, for a model
+	URL func(path, model string) string
+	// StreamUsage is whether the API takes stream_options, which streams need to report
+	// their token usage
+	StreamUsage bool
 }
 
 // NewOpenAIBackend creates a new OpenAI backend
 func NewOpenAIBackend(apiKey string) *OpenAIBackend {
-	client := openai.NewClient(apiKey)
-	return &OpenAIBackend{
-		apiKey: apiKey,
-		client: client,
-	}
+	return NewOpenAIBackendWithBaseURL(apiKey, openai.DefaultConfig(apiKey).BaseURL)
 }
 
-// Generate handles text generation requests
-func (ob *OpenAIBackend) Generate(ctx context.Context, req types.GenerateRequest) (*types.GenerateResponse, error) {
-	openaiReq := openai.ChatCompletionRequest{
-		Model: req.Model,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: req.Prompt,
-			},
+// NewOpenAIBackendWithBaseURL creates a new OpenAI backend that talks to the given base URL
+func NewOpenAIBackendWithBaseURL(apiKey, baseURL string) *OpenAIBackend {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return NewOpenAIBackendWithEndpoint(apiKey, Endpoint{
+		Name:      "openai",
+		Authorize: keys.BearerAuth,
+		URL: func(path, model string) string {
+			return baseURL + path
 		},
-	}
+		StreamUsage: true,
+	})
+}
 
-	// Only set MaxTokens for models that support it
-	// Newer models like GPT-4o use MaxCompletionTokens instead
-	if req.MaxTokens > 0 && !isNewerModel(req.Model) {
-		openaiReq.MaxTokens = req.MaxTokens
+// NewOpenAIBackendWithEndpoint creates a backend for an API that takes OpenAI requests at
+// another endpoint, such as Azure OpenAI
+func NewOpenAIBackendWithEndpoint(apiKey string, endpoint Endpoint) *OpenAIBackend {
+	// Requests are retried and take their keys from the pool. Keys are chosen below the
+	// retries, so a retry can move to another key.
+	keyTransport := &keys.Transport{
+		Pool:      keys.NewPool([]string{apiKey}, keys.RoundRobin),
+		Authorize: endpoint.Authorize,
+	}
+	transport := retry.NewTransport(retry.DefaultPolicy())
+	transport.Base = keyTransport
+	ob := &OpenAIBackend{
+		name:       endpoint.Name,
+		url:        endpoint.URL,
+		httpClient: &http.Client{Transport: transport},
+		transport:  transport,
+		keys:       keyTransport,
 	}
+	ob.streamUsage.Store(endpoint.StreamUsage)
+	return ob
+}
+
+// SetStreamUsage sets whether streams ask the upstream for their token usage with
+// stream_options, which not every OpenAI-compatible server accepts
+func (ob *OpenAIBackend) SetStreamUsage(enabled bool) {
+	ob.streamUsage.Store(enabled)
+}
+
+// SetKeyPool sets the API keys requests are spread over
+func (ob *OpenAIBackend) SetKeyPool(pool *keys.Pool) {
+	ob.keys.Pool = pool
+}
 
-	resp, err := ob.client.CreateChatCompletion(ctx, openaiReq)
+// KeyStats returns the usage of each API key
+func (ob *OpenAIBackend) KeyStats() []keys.Stats {
+	return ob.keys.Pool.Stats()
+}
+
+// SetRetryPolicy sets how requests failing with rate-limit, server or network errors are retried
+func (ob *OpenAIBackend) SetRetryPolicy(policy retry.Policy) {
+	ob.transport.Policy = policy
+}
+
+// Generate handles text generation requests
+func (ob *OpenAIBackend) Generate(ctx context.Context, req types.GenerateRequest) (*types.GenerateResponse, error) {
+	resp, err := ob.Chat(ctx, req.ToChatRequest())
 	if err != nil {
 		return nil, err
 	}
 
 	return &types.GenerateResponse{
-		Model:     req.Model,
-		Content:   resp.Choices[0].Message.Content,
-		CreatedAt: fmt.Sprintf("%d", resp.Created),
+		Model:      req.Model,
+		Content:    resp.Message.Content,
+		CreatedAt:  resp.CreatedAt,
+		Usage:      resp.Usage,
+		DoneReason: resp.DoneReason,
 	}, nil
 }
 
+// GenerateStream handles streaming text generation requests
+func (ob *OpenAIBackend) GenerateStream(ctx context.Context, req types.GenerateRequest, onChunk types.StreamCallback) error {
+	return ob.ChatStream(ctx, req.ToChatRequest(), onChunk)
+}
+
 // Chat handles chat completion requests
 func (ob *OpenAIBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.ChatResponse, error) {
-	var messages []openai.ChatCompletionMessage
-	for _, msg := range req.Messages {
-		messages = append(messages, openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		})
-	}
-
-	openaiReq := openai.ChatCompletionRequest{
-		Model:    req.Model,
-		Messages: messages,
-	}
-
-	// Only set MaxTokens for models that support it
-	// Newer models like GPT-4o use MaxCompletionTokens instead
-	if req.MaxTokens > 0 && !isNewerModel(req.Model) {
-		openaiReq.MaxTokens = req.MaxTokens
+	openaiReq, err := buildChatRequest(req)
+	if err != nil {
+		return nil, err
 	}
 
-	resp, err := ob.client.CreateChatCompletion(ctx, openaiReq)
+	resp, err := ob.createChatCompletion(ctx, openaiReq)
 	if err != nil {
 		return nil, err
 	}
+	// Compatible servers may answer without a choice, such as when a filter withheld it
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%s API returned no choices", ob.name)
+	}
 
 	return &types.ChatResponse{
 		Model: req.Model,
 		Message: types.ChatMessage{
-			Role:    resp.Choices[0].Message.Role,
-			Content: resp.Choices[0].Message.Content,
+			Role:      resp.Choices[0].Message.Role,
+			Content:   resp.Choices[0].Message.Content,
+			ToolCalls: convertToolCalls(resp.Choices[0].Message.ToolCalls),
 		},
 		CreatedAt: fmt.Sprintf("%d", resp.Created),
+		Usage: &types.Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+		},
+		DoneReason: types.OllamaDoneReason(string(resp.Choices[0].FinishReason)),
 	}, nil
 }
 
+// ChatStream handles streaming chat completion requests
+func (ob *OpenAIBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	openaiReq, err := buildChatRequest(req)
+	if err != nil {
+		return err
+	}
+	openaiReq.Stream = true
+	if ob.streamUsage.Load() {
+		// Usage is only reported by streams that ask for it, in a last event without choices
+		openaiReq.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+
+	httpResp, err := ob.doRequest(ctx, openaiReq)
+	if err != nil && openaiReq.StreamOptions != nil && rejectedRequest(err) {
+		// The upstream may not know stream_options, so try once more without it, and
+		// leave it out from then on if that succeeds
+		openaiReq.StreamOptions = nil
+		httpResp, err = ob.doRequest(ctx, openaiReq)
+		if err == nil {
+			log.Printf("%s rejected stream_options; streams will no longer report token usage", ob.name)
+			ob.streamUsage.Store(false)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := httpResp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	return ob.readStream(httpResp.Body, onChunk)
+}
+
+// createChatCompletion sends a chat completion request and decodes the response
+func (ob *OpenAIBackend) createChatCompletion(ctx context.Context, openaiReq chatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	httpResp, err := ob.doRequest(ctx, openaiReq)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	defer func() {
+		if err := httpResp.Body.Close(); err != nil {
+			// Log the error but don't fail the function
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+	}()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var resp openai.ChatCompletionResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return openai.ChatCompletionResponse{}, fmt.Errorf("failed to decode chat completion response: %w", err)
+	}
+	return resp, nil
+}
+
+// doRequest sends a chat completion request and returns the successful HTTP response.
+// The caller is responsible for closing the response body.
+func (ob *OpenAIBackend) doRequest(ctx context.Context, openaiReq chatCompletionRequest) (*http.Response, error) {
+	jsonData, err := json.Marshal(openaiReq)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", ob.url("/chat/completions", openaiReq.Model), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if openaiReq.Stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+
+	resp, err := ob.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		if err := resp.Body.Close(); err != nil {
+			fmt.Printf("Warning: failed to close response body: %v\n", err)
+		}
+		return nil, retry.WithStatus(resp.StatusCode, fmt.Errorf("%s API error: %s", ob.name, errorMessage(body)))
+	}
+
+	return resp, nil
+}
+
+// rejectedRequest reports whether err is the upstream refusing a request as invalid
+func rejectedRequest(err error) bool {
+	var statusErr *retry.StatusError
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusBadRequest || statusErr.StatusCode == http.StatusUnprocessableEntity)
+}
+
+// errorMessage returns the message of an OpenAI error response, {"error": {"message": "..."}},
+// or the body itself when it isn't one
+func errorMessage(body []byte) string {
+	var errResp struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
+		return errResp.Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
 // IsAvailable checks if the backend is available
 func (ob *OpenAIBackend) IsAvailable() bool {
-	return ob.apiKey != ""
+	return ob.keys.Optional || ob.keys.Pool.Len() > 0
 }
 
 // GetName returns the backend name
 func (ob *OpenAIBackend) GetName() string {
-	return "openai"
+	return ob.name
+}
+
+// SupportsOption reports whether the chat completions API can honor a generation option
+func (ob *OpenAIBackend) SupportsOption(name string) bool {
+	// Chat completions have no top_k parameter
+	return name != types.OptionTopK
+}
+
+// chatCompletionRequest is a chat completion request with the fields go-openai predates
+type chatCompletionRequest struct {
+	openai.ChatCompletionRequest
+	// MaxCompletionTokens replaces max_tokens for the newer models, which reject it
+	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
+	// Temperature and TopP shadow the fields of the embedded request, which omit zero
+	// values that the API would treat as the default of 1
+	Temperature *float32 `json:"temperature,omitempty"`
+	TopP        *float32 `json:"top_p,omitempty"`
+	// ResponseFormat shadows the field of the embedded request, so that it can hold
+	// json_schema formats
+	ResponseFormat interface{}    `json:"response_format,omitempty"`
+	StreamOptions  *streamOptions `json:"stream_options,omitempty"`
+}
+
+// streamOptions represents the stream_options of a streamed chat completion request
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// buildChatRequest converts a chat request to the OpenAI chat completion format
+func buildChatRequest(req types.ChatRequest) (chatCompletionRequest, error) {
+	var messages []openai.ChatCompletionMessage
+	for _, msg := range req.Messages {
+		var toolCalls []openai.ToolCall
+		for _, call := range msg.ToolCalls {
+			toolCalls = append(toolCalls, openai.ToolCall{
+				ID:   call.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      call.Function.Name,
+					Arguments: call.ArgumentsString(),
+				},
+			})
+		}
+		message := openai.ChatCompletionMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  toolCalls,
+			ToolCallID: msg.ToolCallID,
+		}
+		if len(msg.Images) > 0 {
+			parts, err := buildContentParts(msg)
+			if err != nil {
+				return chatCompletionRequest{}, err
+			}
+			message.Content = ""
+			message.MultiContent = parts
+		}
+		messages = append(messages, message)
+	}
+
+	var tools []openai.Tool
+	for _, tool := range req.Tools {
+		parameters := tool.Function.Parameters
+		if len(parameters) == 0 {
+			parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		tools = append(tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: openai.FunctionDefinition{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  parameters,
+			},
+		})
+	}
+
+	openaiReq := chatCompletionRequest{
+		ChatCompletionRequest: openai.ChatCompletionRequest{
+			Model:    req.Model,
+			Messages: messages,
+			Tools:    tools,
+		},
+	}
+	if len(tools) > 0 {
+		openaiReq.ToolChoice = toolChoice(req.ToolChoice)
+	}
+
+	// Newer models like GPT-4o take max_completion_tokens instead of max_tokens
+	if isNewerModel(req.Model) {
+		openaiReq.MaxCompletionTokens = req.MaxTokens
+	} else {
+		openaiReq.MaxTokens = req.MaxTokens
+	}
+
+	applyOptions(&openaiReq, req.Options)
+	applyFormat(&openaiReq, req.Format)
+	return openaiReq, nil
+}
+
+// maxStopSequences is the number of stop sequences chat completions accept
+const maxStopSequences = 4
+
+// applyOptions copies generation options onto a chat completion request
+func applyOptions(openaiReq *chatCompletionRequest, opts types.GenerationOptions) {
+	if opts.Temperature != nil {
+		temperature := float32(math.Min(*opts.Temperature, 2))
+		openaiReq.Temperature = &temperature
+	}
+	if opts.TopP != nil {
+		topP := float32(*opts.TopP)
+		openaiReq.TopP = &topP
+	}
+	if len(opts.Stop) > maxStopSequences {
+		log.Printf("Sending only the first %d of %d stop sequences to OpenAI", maxStopSequences, len(opts.Stop))
+		openaiReq.Stop = opts.Stop[:maxStopSequences]
+	} else {
+		openaiReq.Stop = opts.Stop
+	}
+	openaiReq.Seed = opts.Seed
+}
+
+// toolChoice converts a tool choice to the OpenAI tool_choice, which is a string or,
+// to force a specific tool, an object. It returns nil when the model may choose.
+func toolChoice(choice *types.ToolChoice) any {
+	if choice == nil {
+		return nil
+	}
+
+	switch choice.Type {
+	case "any":
+		return "required"
+	case "none":
+		return "none"
+	case "tool":
+		return openai.ToolChoice{
+			Type:     openai.ToolTypeFunction,
+			Function: openai.ToolFunction{Name: choice.Name},
+		}
+	default:
+		return nil
+	}
+}
+
+// buildContentParts converts a message with images to text and image_url content parts
+func buildContentParts(msg types.ChatMessage) ([]openai.ChatMessagePart, error) {
+	var parts []openai.ChatMessagePart
+	if msg.Content != "" {
+		parts = append(parts, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeText,
+			Text: msg.Content,
+		})
+	}
+	for _, data := range msg.Images {
+		image, err := types.ParseImage(data)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, openai.ChatMessagePart{
+			Type:     openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{URL: image.DataURL()},
+		})
+	}
+	return parts, nil
 }
 
-// isNewerModel checks if the model is a newer model that doesn't support MaxTokens
+// isNewerModel checks if the model is a newer model that takes MaxCompletionTokens
+// instead of MaxTokens, including its dated snapshots and variants such as gpt-4o-mini
 func isNewerModel(model string) bool {
-	// Models that require MaxCompletionTokens instead of MaxTokens
 	newerModels := []string{
 		"gpt-4o",
-		"gpt-4o-mini",
 		"gpt-5",
 		"gpt-4.1",
 		"gpt-4.5",
+		"o1",
+		"o3",
+		"o4",
 	}
 
 	for _, newerModel := range newerModels {
-		if model == newerModel {
+		if model == newerModel || strings.HasPrefix(model, newerModel+"-") {
 			return true
 		}
 	}
@@ -0,0 +1,103 @@
+package openai
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// streamEvent is one event of a chat completions stream. A server failing mid-stream
+// sends an event with only an error.
+type streamEvent struct {
+	openai.ChatCompletionStreamResponse
+	Usage *openai.Usage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// readStream parses a chat completions SSE stream and forwards its content deltas to
+// onChunk. Tool call fragments are assembled, and each call is forwarded as soon as its
+// arguments are complete. The stream ends with the [DONE] event. Servers that don't
+// report usage leave the token counts at zero.
+func (ob *OpenAIBackend) readStream(body io.Reader, onChunk types.StreamCallback) error {
+	var usage *types.Usage
+	var finishReason string
+	var toolCalls toolCallAccumulator
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return retry.Temporary(fmt.Errorf("failed to read %s stream: %w", ob.name, err))
+		}
+		// Some compatible servers close the stream without [DONE] once a choice has finished
+		ended := err == io.EOF && line == "" && finishReason != ""
+		if err == io.EOF && line == "" && !ended {
+			return retry.Temporary(fmt.Errorf("%s stream ended unexpectedly", ob.name))
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if ended || data == "[DONE]" {
+			// Servers that never finish the choice leave calls to forward here
+			if err := forwardToolCalls(toolCalls.flush(), onChunk); err != nil {
+				return err
+			}
+
+			return onChunk(types.StreamChunk{
+				Done:       true,
+				DoneReason: types.OllamaDoneReason(finishReason),
+				Usage:      usage,
+			})
+		}
+		if !strings.HasPrefix(line, "data:") {
+			// Skip comments and the blank lines separating events
+			continue
+		}
+
+		var event streamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("failed to parse %s stream event: %w", ob.name, err)
+		}
+		if event.Error != nil {
+			return fmt.Errorf("%s API error: %s", ob.name, event.Error.Message)
+		}
+		if event.Usage != nil {
+			usage = &types.Usage{PromptTokens: event.Usage.PromptTokens, CompletionTokens: event.Usage.CompletionTokens}
+		}
+
+		if len(event.Choices) == 0 {
+			continue
+		}
+		choice := event.Choices[0]
+		if err := forwardToolCalls(toolCalls.add(choice.Delta.ToolCalls), onChunk); err != nil {
+			return err
+		}
+		if choice.Delta.Content != "" {
+			if err := onChunk(types.StreamChunk{Content: choice.Delta.Content}); err != nil {
+				return err
+			}
+		}
+		if choice.FinishReason != "" {
+			finishReason = string(choice.FinishReason)
+			if err := forwardToolCalls(toolCalls.flush(), onChunk); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// forwardToolCalls sends complete tool calls to onChunk, if there are any
+func forwardToolCalls(calls []types.ToolCall, onChunk types.StreamCallback) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	return onChunk(types.StreamChunk{ToolCalls: calls})
+}
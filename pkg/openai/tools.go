@@ -0,0 +1,109 @@
+package openai
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"go-llm-proxy/internal/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// convertToolCalls converts OpenAI tool calls to our format
+func convertToolCalls(calls []openai.ToolCall) []types.ToolCall {
+	var toolCalls []types.ToolCall
+	for _, call := range calls {
+		toolCalls = append(toolCalls, types.ToolCall{
+			ID: call.ID,
+			Function: types.ToolCallFunction{
+				Name:      call.Function.Name,
+				Arguments: types.ToolArgumentsFromString(call.Function.Arguments),
+			},
+		})
+	}
+	return toolCalls
+}
+
+// toolCallAccumulator assembles streamed tool call fragments, which share an index
+// and carry the ID and name in the first fragment and pieces of the arguments after it.
+// Calls are streamed one after another, so a call is complete once a later index has
+// started and its arguments are whole JSON, or once the choice finishes.
+type toolCallAccumulator struct {
+	byIndex map[int]*partialToolCall
+	// sent holds the indexes of the calls already returned as complete
+	sent map[int]bool
+}
+
+type partialToolCall struct {
+	id        string
+	name      string
+	arguments strings.Builder
+}
+
+// add records the tool call fragments of a streamed delta and returns the calls it
+// completes
+func (a *toolCallAccumulator) add(fragments []openai.ToolCall) []types.ToolCall {
+	var complete []types.ToolCall
+	for _, fragment := range fragments {
+		if a.byIndex == nil {
+			a.byIndex = make(map[int]*partialToolCall)
+			a.sent = make(map[int]bool)
+		}
+		index := 0
+		if fragment.Index != nil {
+			index = *fragment.Index
+		}
+		if a.sent[index] {
+			continue
+		}
+
+		call, exists := a.byIndex[index]
+		if !exists {
+			complete = append(complete, a.take(func(i int) bool {
+				return i < index && json.Valid([]byte(a.byIndex[i].arguments.String()))
+			})...)
+			call = &partialToolCall{}
+			a.byIndex[index] = call
+		}
+		if fragment.ID != "" {
+			call.id = fragment.ID
+		}
+		if fragment.Function.Name != "" {
+			call.name = fragment.Function.Name
+		}
+		call.arguments.WriteString(fragment.Function.Arguments)
+	}
+	return complete
+}
+
+// flush returns the calls not yet returned, once no more fragments will arrive
+func (a *toolCallAccumulator) flush() []types.ToolCall {
+	return a.take(func(int) bool { return true })
+}
+
+// take removes the pending calls whose index matches and returns them in index order
+func (a *toolCallAccumulator) take(match func(index int) bool) []types.ToolCall {
+	indexes := make([]int, 0, len(a.byIndex))
+	for index := range a.byIndex {
+		if match(index) {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+
+	var toolCalls []types.ToolCall
+	for _, index := range indexes {
+		call := a.byIndex[index]
+		toolCalls = append(toolCalls, types.ToolCall{
+			ID: call.id,
+			Function: types.ToolCallFunction{
+				Name:      call.name,
+				Arguments: types.ToolArgumentsFromString(call.arguments.String()),
+			},
+		})
+		delete(a.byIndex, index)
+		a.sent[index] = true
+	}
+	return toolCalls
+}
@@ -0,0 +1,20 @@
+package fixtures
+
+import (
+	"embed"
+)
+
+// bedrockStreams holds InvokeModelWithResponseStream responses in the event stream encoding
+//
+//go:embed bedrock/*.bin
+var bedrockStreams embed.FS
+
+// BedrockStream returns a recorded Bedrock event stream by name: stream_text,
+// stream_tool_use, stream_throttled, or headers, a message with every header type
+func BedrockStream(name string) []byte {
+	data, err := bedrockStreams.ReadFile("bedrock/" + name + ".bin")
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -78,6 +78,7 @@ func GetExpectedModelConfigs() []types.ModelConfig {
 			Description:  "Anthropic Claude 3.5 Sonnet model",
 			MaxTokens:    200000,
 			Enabled:      true,
+			Vision:       true,
 		},
 		{
 			Name:         "claude-3.5-haiku",
@@ -88,6 +89,7 @@ func GetExpectedModelConfigs() []types.ModelConfig {
 			Description:  "Anthropic Claude 3.5 Haiku model",
 			MaxTokens:    200000,
 			Enabled:      true,
+			Vision:       true,
 		},
 		{
 			Name:         "claude-3.5-opus",
@@ -98,6 +100,7 @@ func GetExpectedModelConfigs() []types.ModelConfig {
 			Description:  "Anthropic Claude 3.5 Opus model",
 			MaxTokens:    200000,
 			Enabled:      true,
+			Vision:       true,
 		},
 		{
 			Name:         "claude-3.7-sonnet",
@@ -108,6 +111,7 @@ func GetExpectedModelConfigs() []types.ModelConfig {
 			Description:  "Anthropic Claude 3.7 Sonnet model",
 			MaxTokens:    8192,
 			Enabled:      true,
+			Vision:       true,
 		},
 		{
 			Name:         "claude-4.5-sonnet",
@@ -118,6 +122,7 @@ func GetExpectedModelConfigs() []types.ModelConfig {
 			Description:  "Anthropic Claude 4.5 Sonnet model",
 			MaxTokens:    200000,
 			Enabled:      true,
+			Vision:       true,
 		},
 		// OpenAI models
 		{
@@ -129,6 +134,7 @@ func GetExpectedModelConfigs() []types.ModelConfig {
 			Description:  "OpenAI GPT-4o model",
 			MaxTokens:    16384,
 			Enabled:      true,
+			Vision:       true,
 		},
 		{
 			Name:         "gpt-4o-mini",
@@ -139,6 +145,7 @@ func GetExpectedModelConfigs() []types.ModelConfig {
 			Description:  "OpenAI GPT-4o Mini model",
 			MaxTokens:    16384,
 			Enabled:      true,
+			Vision:       true,
 		},
 		{
 			Name:         "gpt-4",
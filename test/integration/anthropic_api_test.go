@@ -0,0 +1,247 @@
+package llmproxy_integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postMessages sends a Messages request to the router
+func postMessages(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestAnthropicCompatibleAPI tests the Anthropic-compatible inbound endpoints
+func TestAnthropicCompatibleAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Messages", func(t *testing.T) {
+		router := setupTestRouter(createTestProxy())
+
+		body := `{"model":"claude-3.5-sonnet","max_tokens":256,"system":[{"type":"text","text":"Be brief"}],"messages":[{"role":"user","content":[{"type":"text","text":"Hello"}]}]}`
+		req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response types.AnthropicResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, strings.HasPrefix(response.ID, "msg_"))
+		assert.Equal(t, "message", response.Type)
+		assert.Equal(t, "assistant", response.Role)
+		assert.Equal(t, "claude-3.5-sonnet", response.Model)
+		require.Len(t, response.Content, 1)
+		assert.Equal(t, "text", response.Content[0].Type)
+		assert.Equal(t, "Mock response", response.Content[0].Text)
+		require.NotNil(t, response.StopReason)
+		assert.Equal(t, "end_turn", *response.StopReason)
+	})
+
+	t.Run("MessagesByProviderModelID", func(t *testing.T) {
+		router := setupTestRouter(createTestProxy())
+
+		body := `{"model":"claude-3-5-haiku-20241022","max_tokens":256,"messages":[{"role":"user","content":"Hello"}]}`
+		req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response types.AnthropicResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "claude-3-5-haiku-20241022", response.Model)
+	})
+
+	t.Run("StreamingMessages", func(t *testing.T) {
+		router := setupTestRouter(createTestProxy())
+
+		reqBody, _ := json.Marshal(map[string]interface{}{
+			"model":      "claude-3.5-sonnet",
+			"max_tokens": 256,
+			"messages":   []map[string]string{{"role": "user", "content": "Hello"}},
+			"stream":     true,
+		})
+		req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBuffer(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+
+		var eventNames []string
+		var content strings.Builder
+		for _, event := range strings.Split(strings.TrimSpace(w.Body.String()), "\n\n") {
+			lines := strings.SplitN(event, "\n", 2)
+			require.Len(t, lines, 2, "event should have an event line and a data line: %s", event)
+			require.True(t, strings.HasPrefix(lines[0], "event: "))
+			require.True(t, strings.HasPrefix(lines[1], "data: "))
+
+			name := strings.TrimPrefix(lines[0], "event: ")
+			eventNames = append(eventNames, name)
+
+			var payload map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &payload))
+			assert.Equal(t, name, payload["type"])
+
+			if name == "content_block_delta" {
+				delta := payload["delta"].(map[string]interface{})
+				assert.Equal(t, "text_delta", delta["type"])
+				content.WriteString(delta["text"].(string))
+			}
+			if name == "message_start" {
+				// Input tokens are reported by the backend at the end, not estimated up front
+				usage := payload["message"].(map[string]interface{})["usage"].(map[string]interface{})
+				assert.Equal(t, float64(0), usage["input_tokens"])
+			}
+			if name == "message_delta" {
+				delta := payload["delta"].(map[string]interface{})
+				assert.Equal(t, "end_turn", delta["stop_reason"])
+				assert.Equal(t, map[string]interface{}{"input_tokens": float64(12), "output_tokens": float64(2)}, payload["usage"])
+			}
+		}
+
+		require.GreaterOrEqual(t, len(eventNames), 6)
+		assert.Equal(t, "message_start", eventNames[0])
+		assert.Equal(t, "content_block_start", eventNames[1])
+		assert.Equal(t, "content_block_stop", eventNames[len(eventNames)-3])
+		assert.Equal(t, "message_delta", eventNames[len(eventNames)-2])
+		assert.Equal(t, "message_stop", eventNames[len(eventNames)-1])
+		assert.Equal(t, "Mock response", content.String())
+	})
+
+	t.Run("RequestParameters", func(t *testing.T) {
+		testProxy := createTestProxy()
+		mockAnthropic := &MockRecordingBackend{MockBackend: MockBackend{name: "anthropic", available: true}}
+		testProxy.BackendManager.RegisterBackend(types.BackendAnthropic, mockAnthropic)
+		router := setupTestRouter(testProxy)
+
+		for _, stream := range []bool{false, true} {
+			mockAnthropic.chatRequests = nil
+			body := fmt.Sprintf(`{"model":"claude-3.5-sonnet","max_tokens":256,"stream":%t,
+				"temperature":0.5,"top_p":0.9,"top_k":40,"stop_sequences":["END"],
+				"tools":[{"name":"get_weather","input_schema":{"type":"object"}}],
+				"tool_choice":{"type":"tool","name":"get_weather"},
+				"messages":[{"role":"user","content":[
+					{"type":"image","source":{"type":"base64","media_type":"image/png","data":"%s"}},
+					{"type":"text","text":"What is this?"}]}]}`, stream, testPNG)
+			w := postMessages(router, body)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			require.NotEmpty(t, mockAnthropic.chatRequests)
+			sent := mockAnthropic.chatRequests[0]
+			assert.Equal(t, 256, sent.MaxTokens)
+			require.NotNil(t, sent.Options.Temperature)
+			assert.Equal(t, 0.5, *sent.Options.Temperature)
+			require.NotNil(t, sent.Options.TopP)
+			assert.Equal(t, 0.9, *sent.Options.TopP)
+			require.NotNil(t, sent.Options.TopK)
+			assert.Equal(t, 40, *sent.Options.TopK)
+			assert.Equal(t, []string{"END"}, sent.Options.Stop)
+			assert.Equal(t, &types.ToolChoice{Type: "tool", Name: "get_weather"}, sent.ToolChoice)
+			require.Len(t, sent.Messages, 1)
+			assert.Equal(t, "What is this?", sent.Messages[0].Content)
+			assert.Equal(t, []string{"data:image/png;base64," + testPNG}, sent.Messages[0].Images)
+		}
+	})
+
+	t.Run("InvalidParameters", func(t *testing.T) {
+		router := setupTestRouter(createTestProxy())
+
+		tests := []struct {
+			name    string
+			body    string
+			errPart string
+		}{
+			{"Temperature", `{"model":"claude-3.5-sonnet","max_tokens":256,"temperature":1.5,"messages":[{"role":"user","content":"Hi"}]}`, "temperature: must be between 0 and 1"},
+			{"TopK", `{"model":"claude-3.5-sonnet","max_tokens":256,"top_k":0,"messages":[{"role":"user","content":"Hi"}]}`, "top_k: must be at least 1"},
+			{"ToolChoiceType", `{"model":"claude-3.5-sonnet","max_tokens":256,"tool_choice":{"type":"sometimes"},"messages":[{"role":"user","content":"Hi"}]}`, `unsupported type "sometimes"`},
+			{"ToolChoiceName", `{"model":"claude-3.5-sonnet","max_tokens":256,"tool_choice":{"type":"tool","name":"get_time"},"messages":[{"role":"user","content":"Hi"}]}`, `tool "get_time" is not defined`},
+			{"RemoteImage", `{"model":"claude-3.5-sonnet","max_tokens":256,"messages":[{"role":"user","content":[{"type":"image","source":{"type":"url","url":"https://example.com/cat.png"}}]}]}`, "image URLs are not supported"},
+			{"ImageForTextModel", `{"model":"gpt-3.5-turbo","max_tokens":256,"messages":[{"role":"user","content":[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + testPNG + `"}}]}]}`, "does not support image input"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				for _, stream := range []string{"false", "true"} {
+					body := strings.Replace(tt.body, `{"model"`, `{"stream":`+stream+`,"model"`, 1)
+					w := postMessages(router, body)
+					assert.Equal(t, http.StatusBadRequest, w.Code)
+					var response types.AnthropicErrorResponse
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+					assert.Equal(t, "invalid_request_error", response.Error.Type)
+					assert.Contains(t, response.Error.Message, tt.errPart)
+				}
+			})
+		}
+	})
+
+	t.Run("StopReasonMaxTokens", func(t *testing.T) {
+		testProxy := createTestProxy()
+		testProxy.BackendManager.RegisterBackend(types.BackendAnthropic, &MockTruncatingBackend{MockBackend: MockBackend{name: "anthropic", available: true}})
+		router := setupTestRouter(testProxy)
+
+		w := postMessages(router, `{"model":"claude-3.5-sonnet","max_tokens":1,"messages":[{"role":"user","content":"Hi"}]}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response types.AnthropicResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.NotNil(t, response.StopReason)
+		assert.Equal(t, "max_tokens", *response.StopReason)
+
+		w = postMessages(router, `{"model":"claude-3.5-sonnet","max_tokens":1,"stream":true,"messages":[{"role":"user","content":"Hi"}]}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"stop_reason":"max_tokens"`)
+	})
+
+	t.Run("CountTokens", func(t *testing.T) {
+		router := setupTestRouter(createTestProxy())
+
+		body := `{"model":"claude-3.5-sonnet","system":"Be brief","messages":[{"role":"user","content":"Hello, how are you today?"}]}`
+		req := httptest.NewRequest("POST", "/v1/messages/count_tokens", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response types.AnthropicCountTokensResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Greater(t, response.InputTokens, 0)
+	})
+
+	t.Run("UnknownModel", func(t *testing.T) {
+		router := setupTestRouter(createTestProxy())
+
+		for _, stream := range []bool{false, true} {
+			reqBody, _ := json.Marshal(map[string]interface{}{
+				"model":      "unknown-model",
+				"max_tokens": 256,
+				"messages":   []map[string]string{{"role": "user", "content": "Hello"}},
+				"stream":     stream,
+			})
+			req := httptest.NewRequest("POST", "/v1/messages", bytes.NewBuffer(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			var response types.AnthropicErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "error", response.Type)
+			assert.Equal(t, "not_found_error", response.Error.Type)
+		}
+	})
+}
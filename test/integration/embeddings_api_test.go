@@ -0,0 +1,141 @@
+package llmproxy_integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-llm-proxy/internal/proxy"
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockEmbeddingBackend is a mock backend that also creates embeddings
+type MockEmbeddingBackend struct {
+	MockBackend
+	requests []types.EmbeddingRequest
+}
+
+// Embed returns a vector per input whose first element is the input's length
+func (m *MockEmbeddingBackend) Embed(ctx context.Context, req types.EmbeddingRequest) (*types.EmbeddingResponse, error) {
+	m.requests = append(m.requests, req)
+	embeddings := make([][]float32, len(req.Input))
+	for i, input := range req.Input {
+		embeddings[i] = []float32{float32(len(input)), 0.5}
+	}
+	return &types.EmbeddingResponse{
+		Model:      req.Model,
+		Embeddings: embeddings,
+		Usage:      &types.Usage{PromptTokens: len(req.Input)},
+	}, nil
+}
+
+// createEmbeddingTestProxy creates a test proxy whose OpenAI backend supports embeddings
+func createEmbeddingTestProxy() (*proxy.ProxyServerV2, *MockEmbeddingBackend) {
+	testProxy := createTestProxy()
+	mockOpenAI := &MockEmbeddingBackend{MockBackend: MockBackend{name: "openai", available: true}}
+	testProxy.BackendManager.RegisterBackend(types.BackendOpenAI, mockOpenAI)
+	testProxy.ModelRegistry.AddModel(types.ModelConfig{
+		Name:         "nomic-embed-text",
+		DisplayName:  "nomic-embed-text",
+		Backend:      types.BackendOpenAI,
+		BackendModel: "text-embedding-3-small",
+		Family:       "embedding",
+		MaxTokens:    8191,
+		Enabled:      true,
+		Embedding:    true,
+	})
+	return testProxy, mockOpenAI
+}
+
+// TestEmbeddingsAPI tests the Ollama embedding endpoints
+func TestEmbeddingsAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	postJSON := func(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("SinglePrompt", func(t *testing.T) {
+		testProxy, mockOpenAI := createEmbeddingTestProxy()
+		router := setupTestRouter(testProxy)
+
+		w := postJSON(router, "/api/embeddings", `{"model":"nomic-embed-text","prompt":"hello"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response types.OllamaEmbeddingsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, []float32{5, 0.5}, response.Embedding)
+
+		require.Len(t, mockOpenAI.requests, 1)
+		assert.Equal(t, "text-embedding-3-small", mockOpenAI.requests[0].Model)
+		assert.Equal(t, []string{"hello"}, mockOpenAI.requests[0].Input)
+	})
+
+	t.Run("BatchInput", func(t *testing.T) {
+		testProxy, mockOpenAI := createEmbeddingTestProxy()
+		router := setupTestRouter(testProxy)
+
+		w := postJSON(router, "/api/embed", `{"model":"nomic-embed-text","input":["a","abc"]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response types.OllamaEmbedResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "nomic-embed-text", response.Model)
+		assert.Equal(t, [][]float32{{1, 0.5}, {3, 0.5}}, response.Embeddings)
+		assert.Equal(t, 2, response.PromptEvalCount)
+
+		// The whole batch goes upstream in one request
+		require.Len(t, mockOpenAI.requests, 1)
+		assert.Equal(t, []string{"a", "abc"}, mockOpenAI.requests[0].Input)
+	})
+
+	t.Run("StringInput", func(t *testing.T) {
+		testProxy, _ := createEmbeddingTestProxy()
+		router := setupTestRouter(testProxy)
+
+		w := postJSON(router, "/api/embed", `{"model":"nomic-embed-text","input":"abcd"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response types.OllamaEmbedResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, [][]float32{{4, 0.5}}, response.Embeddings)
+	})
+
+	t.Run("ChatModelRejected", func(t *testing.T) {
+		testProxy, mockOpenAI := createEmbeddingTestProxy()
+		router := setupTestRouter(testProxy)
+
+		w := postJSON(router, "/api/embed", `{"model":"gpt-4o","input":"hello"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "does not support embeddings")
+		assert.Empty(t, mockOpenAI.requests)
+	})
+
+	t.Run("UnknownModel", func(t *testing.T) {
+		router := setupTestRouter(createTestProxy())
+
+		w := postJSON(router, "/api/embeddings", `{"model":"unknown-model","prompt":"hello"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "model not found")
+	})
+
+	t.Run("ChatWithEmbeddingModel", func(t *testing.T) {
+		testProxy, _ := createEmbeddingTestProxy()
+		router := setupTestRouter(testProxy)
+
+		w := postJSON(router, "/api/chat", `{"model":"nomic-embed-text","messages":[{"role":"user","content":"hi"}]}`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "only supports embeddings")
+	})
+}
@@ -0,0 +1,73 @@
+package llmproxy_integration_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStructuredOutputAPI tests that the Ollama format field reaches the backend
+// and that responses not matching it are rejected
+func TestStructuredOutputAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testProxy := createTestProxy()
+	mockOpenAI := &MockRecordingBackend{MockBackend: MockBackend{name: "openai", available: true}}
+	testProxy.BackendManager.RegisterBackend(types.BackendOpenAI, mockOpenAI)
+	router := setupTestRouter(testProxy)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("SchemaReachesBackend", func(t *testing.T) {
+		mockOpenAI.chatRequests = nil
+		w := post("/api/chat", `{
+			"model": "gpt-4o",
+			"stream": false,
+			"messages": [{"role": "user", "content": "Describe Ada"}],
+			"format": {"type": "object", "properties": {"name": {"type": "string"}}, "required": ["name"]}
+		}`)
+
+		// The mock answers with plain text, which never matches, so every attempt is used
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "did not match the requested format after 3 attempts")
+
+		require.Len(t, mockOpenAI.chatRequests, 3)
+		format := mockOpenAI.chatRequests[0].Format
+		require.NotNil(t, format)
+		assert.JSONEq(t, `{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`, string(format.Schema))
+	})
+
+	t.Run("JSONModeOnGenerate", func(t *testing.T) {
+		mockOpenAI.generateRequests = nil
+		w := post("/api/generate", `{"model":"gpt-4o","stream":false,"prompt":"Hello","format":"json"}`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+		require.NotEmpty(t, mockOpenAI.generateRequests)
+		require.NotNil(t, mockOpenAI.generateRequests[0].Format)
+		assert.False(t, mockOpenAI.generateRequests[0].Format.HasSchema())
+	})
+
+	t.Run("NoFormat", func(t *testing.T) {
+		w := post("/api/generate", `{"model":"gpt-4o","stream":false,"prompt":"Hello"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("InvalidFormat", func(t *testing.T) {
+		w := post("/api/chat", `{"model":"gpt-4o","stream":false,"messages":[{"role":"user","content":"Hello"}],"format":"yaml"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "format")
+	})
+}
@@ -0,0 +1,96 @@
+package llmproxy_integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPNG is a base64-encoded 1x1 PNG image
+const testPNG = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg=="
+
+// MockRecordingBackend is a mock backend that records the requests it receives
+type MockRecordingBackend struct {
+	MockBackend
+	chatRequests     []types.ChatRequest
+	generateRequests []types.GenerateRequest
+}
+
+func (m *MockRecordingBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.ChatResponse, error) {
+	m.chatRequests = append(m.chatRequests, req)
+	return m.MockBackend.Chat(ctx, req)
+}
+
+func (m *MockRecordingBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	m.chatRequests = append(m.chatRequests, req)
+	return m.MockBackend.ChatStream(ctx, req, onChunk)
+}
+
+func (m *MockRecordingBackend) Generate(ctx context.Context, req types.GenerateRequest) (*types.GenerateResponse, error) {
+	m.generateRequests = append(m.generateRequests, req)
+	return m.MockBackend.Generate(ctx, req)
+}
+
+func (m *MockRecordingBackend) GenerateStream(ctx context.Context, req types.GenerateRequest, onChunk types.StreamCallback) error {
+	m.generateRequests = append(m.generateRequests, req)
+	return m.MockBackend.GenerateStream(ctx, req, onChunk)
+}
+
+// TestImageInputAPI tests that Ollama images reach vision models and are rejected for others
+func TestImageInputAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testProxy := createTestProxy()
+	mockOpenAI := &MockRecordingBackend{MockBackend: MockBackend{name: "openai", available: true}}
+	testProxy.BackendManager.RegisterBackend(types.BackendOpenAI, mockOpenAI)
+	router := setupTestRouter(testProxy)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("ChatWithVisionModel", func(t *testing.T) {
+		w := post("/api/chat", `{"model":"gpt-4o","stream":false,"messages":[{"role":"user","content":"What is this?","images":["`+testPNG+`"]}]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		require.Len(t, mockOpenAI.chatRequests, 1)
+		assert.Equal(t, []string{testPNG}, mockOpenAI.chatRequests[0].Messages[0].Images)
+	})
+
+	t.Run("GenerateWithVisionModel", func(t *testing.T) {
+		w := post("/api/generate", `{"model":"gpt-4o","stream":false,"prompt":"What is this?","images":["`+testPNG+`"]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		require.Len(t, mockOpenAI.generateRequests, 1)
+		assert.Equal(t, []string{testPNG}, mockOpenAI.generateRequests[0].Images)
+	})
+
+	t.Run("ChatWithTextOnlyModel", func(t *testing.T) {
+		w := post("/api/chat", `{"model":"gpt-4","stream":false,"messages":[{"role":"user","content":"What is this?","images":["`+testPNG+`"]}]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "does not support image input")
+	})
+
+	t.Run("StreamingGenerateWithTextOnlyModel", func(t *testing.T) {
+		w := post("/api/generate", `{"model":"gpt-4","stream":true,"prompt":"What is this?","images":["`+testPNG+`"]}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var record types.OllamaGenerateResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &record))
+		assert.True(t, record.Done)
+		assert.Contains(t, record.Response, "does not support image input")
+	})
+}
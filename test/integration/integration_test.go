@@ -51,6 +51,14 @@ func setupTestRouter(proxy *proxy.ProxyServerV2) *gin.Engine {
 	router.GET("/api/tags", proxy.HandleTags)
 	router.GET("/api/version", proxy.HandleVersion)
 	router.GET("/api/show/:model", proxy.HandleShow)
+	router.POST("/api/show", proxy.HandleShow)
+	router.POST("/api/stop", proxy.HandleStop)
+	router.POST("/api/embeddings", proxy.HandleEmbeddings)
+	router.POST("/api/embed", proxy.HandleEmbed)
+	router.GET("/v1/models", proxy.HandleOpenAIModels)
+	router.POST("/v1/chat/completions", proxy.HandleOpenAIChatCompletions)
+	router.POST("/v1/messages", proxy.HandleAnthropicMessages)
+	router.POST("/v1/messages/count_tokens", proxy.HandleAnthropicCountTokens)
 	router.GET("/status", func(c *gin.Context) {
 		status := proxy.GetHealthStatus()
 		c.JSON(200, status)
@@ -88,6 +96,20 @@ func (m *MockBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.C
 	}, nil
 }
 
+func (m *MockBackend) GenerateStream(ctx context.Context, req types.GenerateRequest, onChunk types.StreamCallback) error {
+	if err := onChunk(types.StreamChunk{Content: "Mock response"}); err != nil {
+		return err
+	}
+	return onChunk(types.StreamChunk{Done: true})
+}
+
+func (m *MockBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	if err := onChunk(types.StreamChunk{Content: "Mock response"}); err != nil {
+		return err
+	}
+	return onChunk(types.StreamChunk{Done: true, Usage: &types.Usage{PromptTokens: 12, CompletionTokens: 2}})
+}
+
 func (m *MockBackend) IsAvailable() bool {
 	return m.available
 }
@@ -134,6 +156,29 @@ func TestProxyIntegration(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, "gpt-4o", modelResponse.Name)
 
+		// Ollama clients name the model in the body
+		req = httptest.NewRequest("POST", "/api/show", strings.NewReader(`{"model":"gpt-4o"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &modelResponse))
+		assert.Equal(t, "gpt-4o", modelResponse.Name)
+
+		req = httptest.NewRequest("POST", "/api/show", strings.NewReader(`{"model":"unknown-model"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// Without an upstream Ollama server there are no local processes to stop
+		req = httptest.NewRequest("POST", "/api/stop", strings.NewReader(`{"model":"gpt-4o"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "No local processes to stop")
+
 		// Test 4: Test chat endpoint (will use mock backend)
 		chatReq := types.OllamaChatRequest{
 			Model: "gpt-4o",
@@ -157,6 +202,7 @@ func TestProxyIntegration(t *testing.T) {
 		assert.True(t, chatResponse.Done)
 		assert.Equal(t, "assistant", chatResponse.Message.Role)
 		assert.NotEmpty(t, chatResponse.Message.Content)
+		assert.Greater(t, chatResponse.TotalDuration, int64(0))
 
 		// Test 5: Test streaming chat
 		chatReq.Stream = true
@@ -327,8 +373,25 @@ func TestStreamingIntegration(t *testing.T) {
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 
-		// Generate endpoint should return error for streaming
-		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+		// Parse streaming response
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		require.Len(t, lines, 2, "Should have one delta and one final record")
+
+		var delta types.OllamaGenerateResponse
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &delta))
+		assert.Equal(t, "gpt-4o", delta.Model)
+		assert.Equal(t, "Mock response", delta.Response)
+		assert.False(t, delta.Done)
+
+		var final types.OllamaGenerateResponse
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &final))
+		assert.Equal(t, "gpt-4o", final.Model)
+		assert.Empty(t, final.Response)
+		assert.True(t, final.Done)
+		assert.Greater(t, final.TotalDuration, int64(0))
 	})
 }
 
@@ -436,13 +499,11 @@ func createTestProxy() *proxy.ProxyServerV2 {
 
 	// Create config
 	config := &config.Config{
-		Port:               "11434",
-		GinMode:            "test",
-		AnthropicAPIKey:    "test-key",
-		OpenAIAPIKey:       "test-key",
-		DefaultMaxTokens:   4096,
-		StreamingChunkSize: 3,
-		StreamingDelay:     50,
+		Port:             "11434",
+		GinMode:          "test",
+		AnthropicAPIKey:  "test-key",
+		OpenAIAPIKey:     "test-key",
+		DefaultMaxTokens: 4096,
 	}
 
 	return &proxy.ProxyServerV2{
@@ -0,0 +1,275 @@
+package llmproxy_integration_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockTruncatingBackend is a mock backend whose responses stop at the token limit
+type MockTruncatingBackend struct {
+	MockBackend
+}
+
+func (m *MockTruncatingBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.ChatResponse, error) {
+	resp, err := m.MockBackend.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.DoneReason = "length"
+	return resp, nil
+}
+
+func (m *MockTruncatingBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	if err := onChunk(types.StreamChunk{Content: "Mock"}); err != nil {
+		return err
+	}
+	return onChunk(types.StreamChunk{Done: true, DoneReason: "length"})
+}
+
+// postChatCompletion sends a chat completion request to the router
+func postChatCompletion(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestOpenAICompatibleAPI tests the OpenAI-compatible inbound endpoints
+func TestOpenAICompatibleAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("ModelsList", func(t *testing.T) {
+		router := setupTestRouter(createTestProxy())
+
+		req := httptest.NewRequest("GET", "/v1/models", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response types.OpenAIModelList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "list", response.Object)
+		require.NotEmpty(t, response.Data)
+
+		var ids []string
+		for _, model := range response.Data {
+			assert.Equal(t, "model", model.Object)
+			assert.NotEmpty(t, model.OwnedBy)
+			ids = append(ids, model.ID)
+		}
+		assert.Contains(t, ids, "gpt-4o")
+	})
+
+	t.Run("ChatCompletion", func(t *testing.T) {
+		router := setupTestRouter(createTestProxy())
+
+		body := `{"model":"gpt-4o","messages":[{"role":"system","content":"Be brief"},{"role":"user","content":[{"type":"text","text":"Hello"}]}]}`
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response types.OpenAIChatResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, strings.HasPrefix(response.ID, "chatcmpl-"))
+		assert.Equal(t, "chat.completion", response.Object)
+		assert.Equal(t, "gpt-4o", response.Model)
+		require.Len(t, response.Choices, 1)
+		assert.Equal(t, "assistant", response.Choices[0].Message.Role)
+		assert.Equal(t, "Mock response", response.Choices[0].Message.Content.String())
+		assert.Equal(t, "stop", response.Choices[0].FinishReason)
+	})
+
+	t.Run("StreamingChatCompletion", func(t *testing.T) {
+		router := setupTestRouter(createTestProxy())
+
+		reqBody, _ := json.Marshal(map[string]interface{}{
+			"model":          "gpt-4o",
+			"messages":       []map[string]string{{"role": "user", "content": "Hello"}},
+			"stream":         true,
+			"stream_options": map[string]bool{"include_usage": true},
+		})
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBuffer(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+
+		events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+		require.GreaterOrEqual(t, len(events), 4)
+		assert.Equal(t, "data: [DONE]", events[len(events)-1])
+
+		var content strings.Builder
+		var sawFinish, sawUsage bool
+		for _, event := range events[:len(events)-1] {
+			require.True(t, strings.HasPrefix(event, "data: "), "event should be a data line: %s", event)
+
+			var chunk types.OpenAIChatChunk
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(event, "data: ")), &chunk))
+			assert.Equal(t, "chat.completion.chunk", chunk.Object)
+			assert.Equal(t, "gpt-4o", chunk.Model)
+
+			if chunk.Usage != nil {
+				sawUsage = true
+				assert.Empty(t, chunk.Choices)
+				continue
+			}
+			require.Len(t, chunk.Choices, 1)
+			content.WriteString(chunk.Choices[0].Delta.Content)
+			if chunk.Choices[0].FinishReason != nil {
+				sawFinish = true
+				assert.Equal(t, "stop", *chunk.Choices[0].FinishReason)
+			}
+		}
+		assert.Equal(t, "Mock response", content.String())
+		assert.True(t, sawFinish, "stream should carry a finish_reason")
+		assert.True(t, sawUsage, "stream should carry a usage chunk when requested")
+	})
+
+	t.Run("RequestParameters", func(t *testing.T) {
+		testProxy := createTestProxy()
+		mockOpenAI := &MockRecordingBackend{MockBackend: MockBackend{name: "openai", available: true}}
+		testProxy.BackendManager.RegisterBackend(types.BackendOpenAI, mockOpenAI)
+		router := setupTestRouter(testProxy)
+
+		for _, stream := range []bool{false, true} {
+			mockOpenAI.chatRequests = nil
+			body := fmt.Sprintf(`{"model":"gpt-4o","stream":%t,"temperature":0,"top_p":0.9,"stop":"END","seed":42,"max_tokens":64,
+				"messages":[{"role":"user","content":[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,%s","detail":"low"}}]}]}`, stream, testPNG)
+			w := postChatCompletion(router, body)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			require.NotEmpty(t, mockOpenAI.chatRequests)
+			sent := mockOpenAI.chatRequests[0]
+			assert.Equal(t, 64, sent.MaxTokens)
+			require.NotNil(t, sent.Options.Temperature)
+			assert.Equal(t, 0.0, *sent.Options.Temperature, "a zero temperature is kept")
+			require.NotNil(t, sent.Options.TopP)
+			assert.Equal(t, 0.9, *sent.Options.TopP)
+			assert.Equal(t, []string{"END"}, sent.Options.Stop)
+			require.NotNil(t, sent.Options.Seed)
+			assert.Equal(t, 42, *sent.Options.Seed)
+			assert.Nil(t, sent.Format)
+			require.Len(t, sent.Messages, 1)
+			assert.Equal(t, "What is this?", sent.Messages[0].Content)
+			assert.Equal(t, []string{"data:image/png;base64," + testPNG}, sent.Messages[0].Images)
+		}
+
+		mockOpenAI.chatRequests = nil
+		w := postChatCompletion(router, `{"model":"gpt-4o","stop":["a","b"],"messages":[{"role":"user","content":"Hi"}]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NotEmpty(t, mockOpenAI.chatRequests)
+		assert.Equal(t, []string{"a", "b"}, mockOpenAI.chatRequests[0].Options.Stop)
+	})
+
+	t.Run("ResponseFormat", func(t *testing.T) {
+		testProxy := createTestProxy()
+		mockOpenAI := &MockRecordingBackend{MockBackend: MockBackend{name: "openai", available: true}}
+		testProxy.BackendManager.RegisterBackend(types.BackendOpenAI, mockOpenAI)
+		router := setupTestRouter(testProxy)
+
+		// The mock answers with plain text, which never matches, so only the requests are checked
+		w := postChatCompletion(router, `{"model":"gpt-4o","response_format":{"type":"json_schema","json_schema":{"name":"person","schema":{"type":"object"},"strict":true}},"messages":[{"role":"user","content":"Hi"}]}`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		require.NotEmpty(t, mockOpenAI.chatRequests)
+		require.True(t, mockOpenAI.chatRequests[0].Format.HasSchema())
+		assert.JSONEq(t, `{"type":"object"}`, string(mockOpenAI.chatRequests[0].Format.Schema))
+
+		mockOpenAI.chatRequests = nil
+		w = postChatCompletion(router, `{"model":"gpt-4o","response_format":{"type":"json_object"},"messages":[{"role":"user","content":"Hi"}]}`)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		require.NotEmpty(t, mockOpenAI.chatRequests)
+		require.NotNil(t, mockOpenAI.chatRequests[0].Format)
+		assert.False(t, mockOpenAI.chatRequests[0].Format.HasSchema())
+
+		mockOpenAI.chatRequests = nil
+		w = postChatCompletion(router, `{"model":"gpt-4o","response_format":{"type":"text"},"messages":[{"role":"user","content":"Hi"}]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		require.NotEmpty(t, mockOpenAI.chatRequests)
+		assert.Nil(t, mockOpenAI.chatRequests[0].Format)
+	})
+
+	t.Run("InvalidParameters", func(t *testing.T) {
+		router := setupTestRouter(createTestProxy())
+
+		tests := []struct {
+			name    string
+			body    string
+			errPart string
+		}{
+			{"Temperature", `{"model":"gpt-4o","temperature":3,"messages":[{"role":"user","content":"Hi"}]}`, "temperature must be between 0 and 2"},
+			{"TopP", `{"model":"gpt-4o","top_p":1.5,"messages":[{"role":"user","content":"Hi"}]}`, "top_p must be between 0 and 1"},
+			{"ResponseFormat", `{"model":"gpt-4o","response_format":{"type":"yaml"},"messages":[{"role":"user","content":"Hi"}]}`, `unsupported response_format type "yaml"`},
+			{"Schema", `{"model":"gpt-4o","response_format":{"type":"json_schema","json_schema":{"name":"x","schema":{"$ref":"#"}}},"messages":[{"role":"user","content":"Hi"}]}`, "circular $ref"},
+			{"RemoteImage", `{"model":"gpt-4o","messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]}]}`, "image URLs are not supported"},
+			{"ImageForTextModel", `{"model":"gpt-3.5-turbo","messages":[{"role":"user","content":[{"type":"image_url","image_url":{"url":"data:image/png;base64,` + testPNG + `"}}]}]}`, "does not support image input"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				for _, stream := range []string{"false", "true"} {
+					body := strings.Replace(tt.body, `{"model"`, `{"stream":`+stream+`,"model"`, 1)
+					w := postChatCompletion(router, body)
+					assert.Equal(t, http.StatusBadRequest, w.Code)
+					var response types.OpenAIErrorResponse
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+					assert.Contains(t, response.Error.Message, tt.errPart)
+				}
+			})
+		}
+	})
+
+	t.Run("FinishReasonLength", func(t *testing.T) {
+		testProxy := createTestProxy()
+		testProxy.BackendManager.RegisterBackend(types.BackendOpenAI, &MockTruncatingBackend{MockBackend: MockBackend{name: "openai", available: true}})
+		router := setupTestRouter(testProxy)
+
+		w := postChatCompletion(router, `{"model":"gpt-4o","messages":[{"role":"user","content":"Hi"}]}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response types.OpenAIChatResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "length", response.Choices[0].FinishReason)
+
+		w = postChatCompletion(router, `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"Hi"}]}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"finish_reason":"length"`)
+	})
+
+	t.Run("UnknownModel", func(t *testing.T) {
+		router := setupTestRouter(createTestProxy())
+
+		for _, stream := range []bool{false, true} {
+			reqBody, _ := json.Marshal(map[string]interface{}{
+				"model":    "unknown-model",
+				"messages": []map[string]string{{"role": "user", "content": "Hello"}},
+				"stream":   stream,
+			})
+			req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewBuffer(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			var response types.OpenAIErrorResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, "invalid_request_error", response.Error.Type)
+			require.NotNil(t, response.Error.Code)
+			assert.Equal(t, "model_not_found", *response.Error.Code)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package llmproxy_integration_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGenerationOptionsAPI tests that Ollama options reach the backend request
+func TestGenerationOptionsAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testProxy := createTestProxy()
+	mockOpenAI := &MockRecordingBackend{MockBackend: MockBackend{name: "openai", available: true}}
+	testProxy.BackendManager.RegisterBackend(types.BackendOpenAI, mockOpenAI)
+	router := setupTestRouter(testProxy)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("ChatOptions", func(t *testing.T) {
+		w := post("/api/chat", `{
+			"model": "gpt-4o",
+			"stream": false,
+			"messages": [{"role": "user", "content": "Hello"}],
+			"options": {"temperature": 0.2, "stop": ["END"], "seed": 7, "num_predict": 64}
+		}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		require.Len(t, mockOpenAI.chatRequests, 1)
+		sent := mockOpenAI.chatRequests[0]
+		assert.Equal(t, 64, sent.MaxTokens)
+		require.NotNil(t, sent.Options.Temperature)
+		assert.Equal(t, 0.2, *sent.Options.Temperature)
+		assert.Equal(t, []string{"END"}, sent.Options.Stop)
+		require.NotNil(t, sent.Options.Seed)
+		assert.Equal(t, 7, *sent.Options.Seed)
+	})
+
+	t.Run("GenerateOptions", func(t *testing.T) {
+		w := post("/api/generate", `{"model":"gpt-4o","stream":false,"prompt":"Hello","options":{"top_p":0.5,"num_predict":32}}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		require.Len(t, mockOpenAI.generateRequests, 1)
+		sent := mockOpenAI.generateRequests[0]
+		assert.Equal(t, 32, sent.MaxTokens)
+		require.NotNil(t, sent.Options.TopP)
+		assert.Equal(t, 0.5, *sent.Options.TopP)
+	})
+
+	t.Run("InvalidOption", func(t *testing.T) {
+		w := post("/api/chat", `{"model":"gpt-4o","stream":false,"messages":[{"role":"user","content":"Hello"}],"options":{"temperature":"hot"}}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "temperature")
+	})
+}
@@ -0,0 +1,276 @@
+package llmproxy_integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-llm-proxy/internal/proxy"
+	"go-llm-proxy/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockToolBackend is a mock backend that always answers with a tool call
+type MockToolBackend struct {
+	MockBackend
+	requests []types.ChatRequest
+}
+
+var mockToolCall = types.ToolCall{
+	ID: "call_upstream",
+	Function: types.ToolCallFunction{
+		Name:      "get_weather",
+		Arguments: json.RawMessage(`{"city":"Paris"}`),
+	},
+}
+
+func (m *MockToolBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.ChatResponse, error) {
+	m.requests = append(m.requests, req)
+	return &types.ChatResponse{
+		Model: req.Model,
+		Message: types.ChatMessage{
+			Role:      "assistant",
+			ToolCalls: []types.ToolCall{mockToolCall},
+		},
+		CreatedAt: "1234567890",
+	}, nil
+}
+
+func (m *MockToolBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	m.requests = append(m.requests, req)
+	if err := onChunk(types.StreamChunk{Content: "Let me check."}); err != nil {
+		return err
+	}
+	if err := onChunk(types.StreamChunk{ToolCalls: []types.ToolCall{mockToolCall}}); err != nil {
+		return err
+	}
+	return onChunk(types.StreamChunk{Done: true})
+}
+
+// createToolTestProxy creates a test proxy whose OpenAI backend answers with tool calls
+func createToolTestProxy() (*proxy.ProxyServerV2, *MockToolBackend) {
+	testProxy := createTestProxy()
+	mockOpenAI := &MockToolBackend{MockBackend: MockBackend{name: "openai", available: true}}
+	testProxy.BackendManager.RegisterBackend(types.BackendOpenAI, mockOpenAI)
+	return testProxy, mockOpenAI
+}
+
+// TestToolCallingAPI tests tool call translation for each inbound API format
+func TestToolCallingAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	postJSON := func(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("OllamaChat", func(t *testing.T) {
+		testProxy, mockOpenAI := createToolTestProxy()
+		router := setupTestRouter(testProxy)
+
+		body := `{
+			"model": "gpt-4o",
+			"stream": false,
+			"tools": [{"type": "function", "function": {"name": "get_weather", "parameters": {"type": "object"}}}],
+			"messages": [
+				{"role": "user", "content": "Weather in Paris?"},
+				{"role": "assistant", "content": "", "tool_calls": [{"function": {"name": "get_weather", "arguments": {"city": "Paris"}}}]},
+				{"role": "tool", "content": "Sunny", "tool_name": "get_weather"}
+			]
+		}`
+		w := postJSON(router, "/api/chat", body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response types.OllamaChatResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Message.ToolCalls, 1)
+		assert.Equal(t, "get_weather", response.Message.ToolCalls[0].Function.Name)
+		assert.JSONEq(t, `{"city":"Paris"}`, string(response.Message.ToolCalls[0].Function.Arguments))
+
+		// The backend sees the tools and a tool result paired with the earlier call
+		require.Len(t, mockOpenAI.requests, 1)
+		sent := mockOpenAI.requests[0]
+		require.Len(t, sent.Tools, 1)
+		require.Len(t, sent.Messages, 3)
+		require.Len(t, sent.Messages[1].ToolCalls, 1)
+		assert.NotEmpty(t, sent.Messages[1].ToolCalls[0].ID)
+		assert.Equal(t, sent.Messages[1].ToolCalls[0].ID, sent.Messages[2].ToolCallID)
+	})
+
+	t.Run("OllamaStreamingChat", func(t *testing.T) {
+		testProxy, _ := createToolTestProxy()
+		router := setupTestRouter(testProxy)
+
+		w := postJSON(router, "/api/chat", `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"Weather in Paris?"}]}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var toolCalls []types.ToolCall
+		for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+			var record types.OllamaChatResponse
+			require.NoError(t, json.Unmarshal([]byte(line), &record))
+			toolCalls = append(toolCalls, record.Message.ToolCalls...)
+		}
+		require.Len(t, toolCalls, 1)
+		assert.Equal(t, "get_weather", toolCalls[0].Function.Name)
+	})
+
+	t.Run("OpenAIChatCompletion", func(t *testing.T) {
+		testProxy, mockOpenAI := createToolTestProxy()
+		router := setupTestRouter(testProxy)
+
+		body := `{
+			"model": "gpt-4o",
+			"tools": [{"type": "function", "function": {"name": "get_weather", "parameters": {"type": "object"}}}],
+			"messages": [
+				{"role": "user", "content": "Weather in Paris?"},
+				{"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+				{"role": "tool", "tool_call_id": "call_1", "content": "Sunny"}
+			]
+		}`
+		w := postJSON(router, "/v1/chat/completions", body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response types.OpenAIChatResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Choices, 1)
+		assert.Equal(t, "tool_calls", response.Choices[0].FinishReason)
+		require.Len(t, response.Choices[0].Message.ToolCalls, 1)
+		call := response.Choices[0].Message.ToolCalls[0]
+		assert.Equal(t, "call_upstream", call.ID)
+		assert.Equal(t, "function", call.Type)
+		assert.JSONEq(t, `{"city":"Paris"}`, call.Function.Arguments)
+
+		require.Len(t, mockOpenAI.requests, 1)
+		sent := mockOpenAI.requests[0]
+		assert.Equal(t, "call_1", sent.Messages[1].ToolCalls[0].ID)
+		assert.JSONEq(t, `{"city":"Paris"}`, string(sent.Messages[1].ToolCalls[0].Function.Arguments))
+		assert.Equal(t, "call_1", sent.Messages[2].ToolCallID)
+		assert.Equal(t, "get_weather", sent.Messages[2].ToolName)
+	})
+
+	t.Run("OpenAIStreamingChatCompletion", func(t *testing.T) {
+		testProxy, _ := createToolTestProxy()
+		router := setupTestRouter(testProxy)
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"Weather in Paris?"}]}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var toolCalls []types.OpenAIToolCall
+		var finishReason string
+		for _, event := range strings.Split(strings.TrimSpace(w.Body.String()), "\n\n") {
+			data := strings.TrimPrefix(event, "data: ")
+			if data == "[DONE]" {
+				continue
+			}
+			var chunk types.OpenAIChatChunk
+			require.NoError(t, json.Unmarshal([]byte(data), &chunk))
+			for _, choice := range chunk.Choices {
+				toolCalls = append(toolCalls, choice.Delta.ToolCalls...)
+				if choice.FinishReason != nil {
+					finishReason = *choice.FinishReason
+				}
+			}
+		}
+		require.Len(t, toolCalls, 1)
+		require.NotNil(t, toolCalls[0].Index)
+		assert.Equal(t, 0, *toolCalls[0].Index)
+		assert.Equal(t, "call_upstream", toolCalls[0].ID)
+		assert.JSONEq(t, `{"city":"Paris"}`, toolCalls[0].Function.Arguments)
+		assert.Equal(t, "tool_calls", finishReason)
+	})
+
+	t.Run("AnthropicMessages", func(t *testing.T) {
+		testProxy, mockOpenAI := createToolTestProxy()
+		router := setupTestRouter(testProxy)
+
+		body := `{
+			"model": "gpt-4o",
+			"max_tokens": 256,
+			"tools": [{"name": "get_weather", "input_schema": {"type": "object"}}],
+			"messages": [
+				{"role": "user", "content": "Weather in Paris?"},
+				{"role": "assistant", "content": [{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Paris"}}]},
+				{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "toolu_1", "content": [{"type": "text", "text": "Sunny"}]}]}
+			]
+		}`
+		w := postJSON(router, "/v1/messages", body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response types.AnthropicResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.NotNil(t, response.StopReason)
+		assert.Equal(t, "tool_use", *response.StopReason)
+		require.Len(t, response.Content, 1)
+		assert.Equal(t, "tool_use", response.Content[0].Type)
+		assert.Equal(t, "call_upstream", response.Content[0].ID)
+		assert.Equal(t, "get_weather", response.Content[0].Name)
+		assert.JSONEq(t, `{"city":"Paris"}`, string(response.Content[0].Input))
+
+		require.Len(t, mockOpenAI.requests, 1)
+		sent := mockOpenAI.requests[0]
+		require.Len(t, sent.Tools, 1)
+		assert.Equal(t, "get_weather", sent.Tools[0].Function.Name)
+		require.Len(t, sent.Messages, 3)
+		assert.Equal(t, "toolu_1", sent.Messages[1].ToolCalls[0].ID)
+		assert.Equal(t, "tool", sent.Messages[2].Role)
+		assert.Equal(t, "toolu_1", sent.Messages[2].ToolCallID)
+		assert.Equal(t, "Sunny", sent.Messages[2].Content)
+	})
+
+	t.Run("AnthropicStreamingMessages", func(t *testing.T) {
+		testProxy, _ := createToolTestProxy()
+		router := setupTestRouter(testProxy)
+
+		w := postJSON(router, "/v1/messages", `{"model":"gpt-4o","max_tokens":256,"stream":true,"messages":[{"role":"user","content":"Weather in Paris?"}]}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var eventNames []string
+		var blockTypes []string
+		var toolInput strings.Builder
+		var stopReason string
+		for _, event := range strings.Split(strings.TrimSpace(w.Body.String()), "\n\n") {
+			lines := strings.SplitN(event, "\n", 2)
+			require.Len(t, lines, 2)
+			eventNames = append(eventNames, strings.TrimPrefix(lines[0], "event: "))
+
+			var payload struct {
+				ContentBlock types.AnthropicContentBlock `json:"content_block"`
+				Delta        struct {
+					Type        string `json:"type"`
+					PartialJSON string `json:"partial_json"`
+					StopReason  string `json:"stop_reason"`
+				} `json:"delta"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &payload))
+			switch eventNames[len(eventNames)-1] {
+			case "content_block_start":
+				blockTypes = append(blockTypes, payload.ContentBlock.Type)
+			case "content_block_delta":
+				if payload.Delta.Type == "input_json_delta" {
+					toolInput.WriteString(payload.Delta.PartialJSON)
+				}
+			case "message_delta":
+				stopReason = payload.Delta.StopReason
+			}
+		}
+
+		assert.Equal(t, []string{
+			"message_start",
+			"content_block_start", "content_block_delta", "content_block_stop",
+			"content_block_start", "content_block_delta", "content_block_stop",
+			"message_delta", "message_stop",
+		}, eventNames)
+		assert.Equal(t, []string{"text", "tool_use"}, blockTypes)
+		assert.JSONEq(t, `{"city":"Paris"}`, toolInput.String())
+		assert.Equal(t, "tool_use", stopReason)
+	})
+}
@@ -21,11 +21,6 @@ func NewMockModelFetcher(cfg *config.Config) *MockModelFetcher {
 	}
 }
 
-// LoadConfigFromFile is a no-op for testing
-func (m *MockModelFetcher) LoadConfigFromFile(configPath string) error {
-	return nil
-}
-
 // FetchAllModels fetches models using the mock API client
 func (m *MockModelFetcher) FetchAllModels(ctx context.Context) ([]types.ModelConfig, error) {
 	var allModels []types.ModelConfig
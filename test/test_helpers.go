@@ -31,6 +31,20 @@ func (m *MockBackend) Chat(_ context.Context, req types.ChatRequest) (*types.Cha
 	}, nil
 }
 
+func (m *MockBackend) GenerateStream(_ context.Context, req types.GenerateRequest, onChunk types.StreamCallback) error {
+	if err := onChunk(types.StreamChunk{Content: "Mock response"}); err != nil {
+		return err
+	}
+	return onChunk(types.StreamChunk{Done: true})
+}
+
+func (m *MockBackend) ChatStream(_ context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	if err := onChunk(types.StreamChunk{Content: "Mock response"}); err != nil {
+		return err
+	}
+	return onChunk(types.StreamChunk{Done: true})
+}
+
 func (m *MockBackend) IsAvailable() bool {
 	return m.available
 }
@@ -0,0 +1,148 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/anthropic"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestNormalizeMessages tests rewriting of Ollama and OpenAI conversations into valid Anthropic ones
+func TestNormalizeMessages(t *testing.T) {
+	t.Run("SystemMessagesMoveToSystemField", func(t *testing.T) {
+		system, messages, err := anthropic.NormalizeMessages([]types.ChatMessage{
+			{Role: "system", Content: "You are terse."},
+			{Role: "user", Content: "Hi"},
+			{Role: "developer", Content: "Answer in French."},
+			{Role: "assistant", Content: "Bonjour"},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "You are terse.\n\nAnswer in French.", system)
+		require.Len(t, messages, 2)
+		assert.Equal(t, "user", messages[0].Role)
+		assert.Equal(t, "assistant", messages[1].Role)
+	})
+
+	t.Run("ConsecutiveTurnsAreMerged", func(t *testing.T) {
+		_, messages, err := anthropic.NormalizeMessages([]types.ChatMessage{
+			{Role: "user", Content: "First"},
+			{Role: "user", Content: "Second"},
+			{Role: "assistant", Content: "Reply"},
+			{Role: "assistant", Content: "More"},
+			{Role: "user", Content: "Third"},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, messages, 3)
+		require.Len(t, messages[0].Content, 2)
+		assert.Equal(t, "First", messages[0].Content[0].Text)
+		assert.Equal(t, "Second", messages[0].Content[1].Text)
+		require.Len(t, messages[1].Content, 2)
+		assert.Equal(t, "Third", messages[2].Content[0].Text)
+	})
+
+	t.Run("LeadingAssistantTurnGetsUserTurn", func(t *testing.T) {
+		_, messages, err := anthropic.NormalizeMessages([]types.ChatMessage{
+			{Role: "system", Content: "Be nice."},
+			{Role: "assistant", Content: "How can I help?"},
+			{Role: "user", Content: "Hi"},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, messages, 3)
+		assert.Equal(t, "user", messages[0].Role)
+		assert.NotEmpty(t, messages[0].Content[0].Text)
+		assert.Equal(t, "assistant", messages[1].Role)
+	})
+
+	t.Run("EmptyMessagesAreDropped", func(t *testing.T) {
+		_, messages, err := anthropic.NormalizeMessages([]types.ChatMessage{
+			{Role: "user", Content: "Hi"},
+			{Role: "assistant", Content: ""},
+			{Role: "user", Content: "  "},
+			{Role: "user", Content: "Still there?"},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, messages, 1)
+		require.Len(t, messages[0].Content, 2)
+		assert.Equal(t, "Still there?", messages[0].Content[1].Text)
+	})
+
+	t.Run("FinalAssistantTurnIsTrimmed", func(t *testing.T) {
+		_, messages, err := anthropic.NormalizeMessages([]types.ChatMessage{
+			{Role: "user", Content: "Write a list"},
+			{Role: "assistant", Content: "Here it is:\n"},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, messages, 2)
+		assert.Equal(t, "Here it is:", messages[1].Content[0].Text)
+	})
+
+	t.Run("ToolResultsComeFirst", func(t *testing.T) {
+		_, messages, err := anthropic.NormalizeMessages([]types.ChatMessage{
+			{Role: "user", Content: "Weather?"},
+			{Role: "assistant", ToolCalls: []types.ToolCall{
+				{ID: "call_a", Function: types.ToolCallFunction{Name: "get_weather", Arguments: json.RawMessage(`{}`)}},
+			}},
+			{Role: "user", Content: "Hurry up"},
+			{Role: "tool", Content: "Sunny", ToolCallID: "call_a"},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, messages, 3)
+		results := messages[2]
+		require.Len(t, results.Content, 2)
+		assert.Equal(t, "tool_result", results.Content[0].Type)
+		assert.Equal(t, "call_a", results.Content[0].ToolUseID)
+		assert.Equal(t, "Hurry up", results.Content[1].Text)
+	})
+
+	t.Run("SystemOnlyConversationIsRejected", func(t *testing.T) {
+		_, _, err := anthropic.NormalizeMessages([]types.ChatMessage{
+			{Role: "system", Content: "You are terse."},
+		})
+		assert.Error(t, err)
+	})
+}
+
+// TestAnthropicSystemPrompt tests that system messages are sent in the top-level system field
+func TestAnthropicSystemPrompt(t *testing.T) {
+	var body struct {
+		System   string `json:"system"`
+		Messages []struct {
+			Role string `json:"role"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","content":[{"type":"text","text":"Hi."}],"usage":{"input_tokens":10,"output_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	backend := anthropic.NewAnthropicBackendWithBaseURL("test-key", server.URL)
+	_, err := backend.Chat(context.Background(), types.ChatRequest{
+		Model: "claude-test",
+		Messages: []types.ChatMessage{
+			{Role: "system", Content: "You are terse."},
+			{Role: "user", Content: "Hello"},
+		},
+		MaxTokens: 100,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "You are terse.", body.System)
+	require.Len(t, body.Messages, 1)
+	assert.Equal(t, "user", body.Messages[0].Role)
+}
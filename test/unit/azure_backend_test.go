@@ -0,0 +1,155 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-llm-proxy/internal/backend"
+	"go-llm-proxy/internal/config"
+	"go-llm-proxy/internal/fetcher"
+	"go-llm-proxy/internal/keys"
+	"go-llm-proxy/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAzureServer stands in for an Azure OpenAI resource with a chat and an embedding deployment
+func newAzureServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api-key") != "azure-key" || r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"code":"401","message":"Access denied due to invalid subscription key."}}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/openai/deployments" && r.URL.Query().Get("api-version") == "2022-12-01":
+			_, _ = w.Write([]byte(`{"object":"list","data":[
+				{"id":"prod-gpt4o","model":"gpt-4o","status":"succeeded","object":"deployment"},
+				{"id":"embed-small","model":"text-embedding-3-small","status":"succeeded","object":"deployment"},
+				{"id":"staging-gpt41","model":"gpt-4.1","status":"creating","object":"deployment"}]}`))
+		case r.URL.Query().Get("api-version") != "2024-10-21":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/openai/deployments/prod-gpt4o/chat/completions":
+			var body struct {
+				Stream bool `json:"stream"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body.Stream {
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = w.Write([]byte("data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hel\"}}]}\n\n" +
+					"data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n" +
+					"data: [DONE]\n\n"))
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":"chatcmpl-1","created":1,"choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1}}`))
+		case r.URL.Path == "/openai/deployments/embed-small/embeddings":
+			_, _ = w.Write([]byte(`{"data":[{"index":0,"embedding":[0.1,0.2]}],"model":"text-embedding-3-small","usage":{"prompt_tokens":2}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":"DeploymentNotFound","message":"The API deployment for this resource does not exist."}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestAzureBackend tests serving Azure OpenAI deployments
+func TestAzureBackend(t *testing.T) {
+	server := newAzureServer(t)
+
+	factory := backend.NewBackendFactoryWithKeys(nil, nil, keys.RoundRobin)
+	factory.SetAzure(server.URL+"/", "2024-10-21", []string{"azure-key"})
+	manager := factory.CreateBackends()
+	assert.Equal(t, []types.BackendType{types.BackendAzure}, manager.GetAvailableBackends())
+
+	model := types.ModelConfig{Name: "gpt-4o", Backend: types.BackendAzure, BackendModel: "prod-gpt4o", Enabled: true}
+	// The handlers send the backend model, which names the deployment
+	req := types.ChatRequest{Model: "prod-gpt4o", Messages: []types.ChatMessage{{Role: "user", Content: "Hi"}}}
+
+	t.Run("Chat", func(t *testing.T) {
+		resp, err := manager.ProcessRequest(context.Background(), model, req)
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", resp.(*types.ChatResponse).Message.Content)
+	})
+
+	t.Run("ChatStream", func(t *testing.T) {
+		var content strings.Builder
+		err := manager.ProcessStreamRequest(context.Background(), model, req, func(chunk types.StreamChunk) error {
+			content.WriteString(chunk.Content)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", content.String())
+	})
+
+	t.Run("Schema", func(t *testing.T) {
+		// Schema requests go to the same deployment URL, with a json_schema response_format
+		schemaReq := req
+		schemaReq.Format = &types.ResponseFormat{Schema: json.RawMessage(`{"type":"string"}`)}
+		handler, _ := manager.GetBackend(types.BackendAzure)
+		resp, err := handler.Chat(context.Background(), schemaReq)
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", resp.Message.Content)
+	})
+
+	t.Run("Embeddings", func(t *testing.T) {
+		embedding := types.ModelConfig{Name: "nomic-embed-text", Backend: types.BackendAzure, BackendModel: "embed-small", Embedding: true, Enabled: true}
+		resp, err := manager.ProcessEmbeddingRequest(context.Background(), embedding, types.EmbeddingRequest{Model: "embed-small", Input: []string{"hello"}})
+		require.NoError(t, err)
+		assert.Equal(t, [][]float32{{0.1, 0.2}}, resp.Embeddings)
+	})
+
+	t.Run("UnknownDeployment", func(t *testing.T) {
+		missing := req
+		missing.Model = "missing"
+		_, err := manager.ProcessRequest(context.Background(), types.ModelConfig{Name: "missing", Backend: types.BackendAzure, BackendModel: "missing"}, missing)
+		assert.ErrorContains(t, err, "deployment")
+	})
+
+	t.Run("Deployments", func(t *testing.T) {
+		cfg := &config.Config{AzureOpenAI: config.AzureOpenAIConfig{
+			Endpoint:    server.URL,
+			APIKey:      "azure-key",
+			APIVersion:  "2024-10-21",
+			Deployments: map[string]string{"gpt-4o": "prod-gpt4o", "gpt-4o-latest": "prod-gpt4o", "gpt-5": "missing"},
+		}}
+		fetched, err := fetcher.NewModelFetcher(cfg).FetchAllModels(context.Background())
+		require.NoError(t, err)
+
+		byName := make(map[string]types.ModelConfig)
+		for _, model := range fetched {
+			byName[model.Name] = model
+		}
+		assert.Len(t, byName, 3, "deployments still being created aren't listed")
+
+		gpt4o := byName["gpt-4o"]
+		assert.Equal(t, types.BackendAzure, gpt4o.Backend)
+		assert.Equal(t, "prod-gpt4o", gpt4o.BackendModel)
+		assert.Equal(t, 128000, gpt4o.MaxTokens, "capabilities follow the deployed model")
+		assert.True(t, gpt4o.Vision)
+		assert.Equal(t, "prod-gpt4o", byName["gpt-4o-latest"].BackendModel)
+		assert.Equal(t, "embed-small", byName["embed-small"].BackendModel, "unmapped deployments keep their name")
+	})
+}
+
+// TestAzureOpenAIConfig tests validating the azure_openai section
+func TestAzureOpenAIConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.AzureOpenAI.Endpoint = "https://my-resource.openai.azure.com"
+	cfg.AzureOpenAI.APIKey = "azure-key"
+	cfg.EmbeddingModels = []config.EmbeddingModelConfig{{Name: "nomic-embed-text", Backend: "azure", BackendModel: "embed-small"}}
+	assert.NoError(t, cfg.Validate(), "an Azure resource is enough to start, and models can refer to it")
+
+	cfg.AzureOpenAI.Endpoint = "my-resource.openai.azure.com"
+	cfg.AzureOpenAI.APIKey = ""
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "azure_openai: endpoint must be an http or https URL")
+	assert.ErrorContains(t, err, "azure_openai: api_key is required")
+}
@@ -0,0 +1,222 @@
+package llmproxy_unit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-llm-proxy/internal/backend"
+	"go-llm-proxy/internal/streaming"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/anthropic"
+	"go-llm-proxy/pkg/openai"
+	"go-llm-proxy/test/helpers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestAnthropicChatStream tests that Anthropic SSE deltas are forwarded one by one
+func TestAnthropicChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, true, body["stream"])
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":5}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"ping"}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":", world"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":3}}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, event := range events {
+			var parsed struct {
+				Type string `json:"type"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(event), &parsed))
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", parsed.Type, event)
+		}
+	}))
+	defer server.Close()
+
+	backend := anthropic.NewAnthropicBackendWithBaseURL("test-key", server.URL)
+
+	var chunks []types.StreamChunk
+	err := backend.ChatStream(context.Background(), types.ChatRequest{
+		Model:     "claude-test",
+		Messages:  []types.ChatMessage{{Role: "user", Content: "Hi"}},
+		MaxTokens: 100,
+	}, func(chunk types.StreamChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, chunks, 3)
+	assert.Equal(t, "Hello", chunks[0].Content)
+	assert.Equal(t, ", world", chunks[1].Content)
+	assert.True(t, chunks[2].Done)
+	require.NotNil(t, chunks[2].Usage)
+	assert.Equal(t, 5, chunks[2].Usage.PromptTokens)
+	assert.Equal(t, 3, chunks[2].Usage.CompletionTokens)
+}
+
+// TestAnthropicChatStreamError tests that an error event aborts the stream
+func TestAnthropicChatStreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+	}))
+	defer server.Close()
+
+	backend := anthropic.NewAnthropicBackendWithBaseURL("test-key", server.URL)
+	err := backend.ChatStream(context.Background(), types.ChatRequest{
+		Model:    "claude-test",
+		Messages: []types.ChatMessage{{Role: "user", Content: "Hi"}},
+	}, func(chunk types.StreamChunk) error {
+		return nil
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "overloaded_error")
+}
+
+// TestOpenAIChatStream tests that OpenAI stream deltas are forwarded one by one
+func TestOpenAIChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{"Hel", "lo"} {
+			fmt.Fprintf(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", delta)
+		}
+		fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	backend := openai.NewOpenAIBackendWithBaseURL("test-key", server.URL)
+
+	var chunks []types.StreamChunk
+	err := backend.ChatStream(context.Background(), types.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []types.ChatMessage{{Role: "user", Content: "Hi"}},
+	}, func(chunk types.StreamChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, chunks, 3)
+	assert.Equal(t, "Hel", chunks[0].Content)
+	assert.Equal(t, "lo", chunks[1].Content)
+	assert.True(t, chunks[2].Done)
+}
+
+// TestStreamingChatForwardsDeltas tests that every NDJSON line is a real upstream delta
+func TestStreamingChatForwardsDeltas(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	backendManager := backend.NewBackendManager()
+	backendManager.RegisterBackend(types.BackendOpenAI, &MockStreamingBackend{
+		MockBackend: MockBackend{name: "openai", available: true},
+		deltas:      []string{"The ", "quick ", "fox"},
+	})
+	streamingHandler := streaming.NewStreamingHandler(backendManager, helpers.CreateTestModelRegistry())
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	streamingHandler.HandleStreamingChat(c, types.OllamaChatRequest{
+		Model:    "gpt-4o",
+		Messages: []types.OllamaMessage{{Role: "user", Content: "Hello"}},
+		Stream:   true,
+	})
+
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	lines := bytes.Split(bytes.TrimSpace(w.Body.Bytes()), []byte("\n"))
+	require.Len(t, lines, 4)
+
+	var contents []string
+	for i, line := range lines {
+		var chunk types.OllamaChatResponse
+		require.NoError(t, json.Unmarshal(line, &chunk))
+		assert.Equal(t, "gpt-4o", chunk.Model)
+		assert.Equal(t, "assistant", chunk.Message.Role)
+		assert.Equal(t, i == len(lines)-1, chunk.Done)
+		contents = append(contents, chunk.Message.Content)
+	}
+	assert.Equal(t, "The quick fox", strings.Join(contents, ""))
+}
+
+// TestStreamingGenerateFinalRecord tests generate deltas and the final record with timing and token counts
+func TestStreamingGenerateFinalRecord(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	backendManager := backend.NewBackendManager()
+	backendManager.RegisterBackend(types.BackendOpenAI, &MockStreamingBackend{
+		MockBackend: MockBackend{name: "openai", available: true},
+		deltas:      []string{"func ", "main()"},
+		usage:       &types.Usage{PromptTokens: 12, CompletionTokens: 4},
+	})
+	streamingHandler := streaming.NewStreamingHandler(backendManager, helpers.CreateTestModelRegistry())
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	streamingHandler.HandleStreamingGenerate(c, types.OllamaGenerateRequest{
+		Model:  "gpt-4o",
+		Prompt: "package main\n",
+		Stream: true,
+	})
+
+	lines := bytes.Split(bytes.TrimSpace(w.Body.Bytes()), []byte("\n"))
+	require.Len(t, lines, 3)
+
+	var first types.OllamaGenerateResponse
+	require.NoError(t, json.Unmarshal(lines[0], &first))
+	assert.Equal(t, "func ", first.Response)
+	assert.False(t, first.Done)
+
+	var final types.OllamaGenerateResponse
+	require.NoError(t, json.Unmarshal(lines[2], &final))
+	assert.True(t, final.Done)
+	assert.Empty(t, final.Response)
+	assert.Equal(t, 12, final.PromptEvalCount)
+	assert.Equal(t, 4, final.EvalCount)
+	assert.Greater(t, final.TotalDuration, int64(0))
+	assert.GreaterOrEqual(t, final.TotalDuration, final.EvalDuration)
+}
+
+// MockStreamingBackend is a mock backend that streams a fixed list of deltas
+type MockStreamingBackend struct {
+	MockBackend
+	deltas []string
+	usage  *types.Usage
+}
+
+func (m *MockStreamingBackend) GenerateStream(ctx context.Context, req types.GenerateRequest, onChunk types.StreamCallback) error {
+	return m.ChatStream(ctx, types.ChatRequest{Model: req.Model}, onChunk)
+}
+
+func (m *MockStreamingBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	for _, delta := range m.deltas {
+		if err := onChunk(types.StreamChunk{Content: delta}); err != nil {
+			return err
+		}
+	}
+	return onChunk(types.StreamChunk{Done: true, Usage: m.usage})
+}
@@ -0,0 +1,458 @@
+package llmproxy_unit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-llm-proxy/internal/awsauth"
+	"go-llm-proxy/internal/config"
+	"go-llm-proxy/internal/fetcher"
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/bedrock"
+	"go-llm-proxy/test/fixtures"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bedrockCredentials are the credentials the Bedrock stand-in accepts
+var bedrockCredentials = awsauth.Credentials{AccessKeyID: "AKIDBEDROCKTEST", SecretAccessKey: "bedrock-secret", SessionToken: "bedrock-session"}
+
+// bedrockServer stands in for the Bedrock runtime and control plane endpoints of
+// us-east-1, checking every request's signature and recording the last request body
+type bedrockServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	lastPath string
+	lastBody map[string]interface{}
+}
+
+func newBedrockServer(t *testing.T) *bedrockServer {
+	server := &bedrockServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !validBedrockSignature(r, body) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message":"The request signature we calculated does not match the signature you provided."}`))
+			return
+		}
+
+		server.mu.Lock()
+		server.lastPath = r.URL.EscapedPath()
+		server.lastBody = nil
+		_ = json.Unmarshal(body, &server.lastBody)
+		server.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.EscapedPath() {
+		case "/foundation-models":
+			_, _ = w.Write([]byte(`{"modelSummaries":[
+				{"modelId":"anthropic.claude-3-5-haiku-20241022-v1:0","modelName":"Claude 3.5 Haiku","providerName":"Anthropic","inputModalities":["TEXT"],"outputModalities":["TEXT"],"inferenceTypesSupported":["ON_DEMAND","INFERENCE_PROFILE"],"modelLifecycle":{"status":"ACTIVE"}},
+				{"modelId":"anthropic.claude-sonnet-4-20250514-v1:0","modelName":"Claude Sonnet 4","providerName":"Anthropic","inputModalities":["TEXT","IMAGE"],"outputModalities":["TEXT"],"inferenceTypesSupported":["INFERENCE_PROFILE"],"modelLifecycle":{"status":"ACTIVE"}},
+				{"modelId":"anthropic.claude-v2:1","modelName":"Claude","providerName":"Anthropic","inputModalities":["TEXT"],"outputModalities":["TEXT"],"inferenceTypesSupported":["ON_DEMAND"],"modelLifecycle":{"status":"LEGACY"}}]}`))
+		case "/inference-profiles":
+			// The list is split over two pages
+			if r.URL.Query().Get("nextToken") == "" {
+				_, _ = w.Write([]byte(`{"inferenceProfileSummaries":[
+					{"inferenceProfileId":"us.anthropic.claude-3-5-haiku-20241022-v1:0","inferenceProfileName":"US Anthropic Claude 3.5 Haiku","status":"ACTIVE","type":"SYSTEM_DEFINED",
+					 "models":[{"modelArn":"arn:aws:bedrock:us-east-1::foundation-model/anthropic.claude-3-5-haiku-20241022-v1:0"}]}],
+					"nextToken":"page-2"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"inferenceProfileSummaries":[
+				{"inferenceProfileId":"us.anthropic.claude-sonnet-4-20250514-v1:0","inferenceProfileName":"US Claude Sonnet 4","status":"ACTIVE","type":"SYSTEM_DEFINED",
+				 "models":[{"modelArn":"arn:aws:bedrock:us-east-1::foundation-model/anthropic.claude-sonnet-4-20250514-v1:0"},{"modelArn":"arn:aws:bedrock:us-west-2::foundation-model/anthropic.claude-sonnet-4-20250514-v1:0"}]},
+				{"inferenceProfileId":"us.meta.llama3-3-70b-instruct-v1:0","inferenceProfileName":"US Meta Llama 3.3 70B Instruct","status":"ACTIVE","type":"SYSTEM_DEFINED",
+				 "models":[{"modelArn":"arn:aws:bedrock:us-east-1::foundation-model/meta.llama3-3-70b-instruct-v1:0"}]}]}`))
+		case "/model/us.anthropic.claude-sonnet-4-20250514-v1%3A0/invoke":
+			_, _ = w.Write([]byte(`{"id":"msg_bdrk_01","type":"message","role":"assistant","model":"claude-sonnet-4-20250514",
+				"content":[{"type":"text","text":"Hello from Bedrock!"}],"stop_reason":"max_tokens","usage":{"input_tokens":14,"output_tokens":6}}`))
+		case "/model/us.anthropic.claude-sonnet-4-20250514-v1%3A0/invoke-with-response-stream":
+			w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+			stream := "stream_text"
+			if len(body) > 0 && bytes.Contains(body, []byte(`"tools"`)) {
+				stream = "stream_tool_use"
+			}
+			_, _ = w.Write(fixtures.BedrockStream(stream))
+		case "/model/throttled/invoke-with-response-stream":
+			w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+			_, _ = w.Write(fixtures.BedrockStream("stream_throttled"))
+		default:
+			w.Header().Set("x-amzn-ErrorType", "ResourceNotFoundException")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"Model not found."}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// validBedrockSignature signs a copy of a received request again, at the time it was
+// signed, and compares the signatures
+func validBedrockSignature(r *http.Request, body []byte) bool {
+	signedAt, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil || r.Header.Get("X-Amz-Security-Token") != bedrockCredentials.SessionToken {
+		return false
+	}
+	check := r.Clone(r.Context())
+	signer := &awsauth.Signer{
+		Credentials: awsauth.StaticProvider{Credentials: bedrockCredentials},
+		Region:      "us-east-1",
+		Service:     "bedrock",
+		Now:         func() time.Time { return signedAt },
+	}
+	if err := signer.Sign(check, body); err != nil {
+		return false
+	}
+	return check.Header.Get("Authorization") == r.Header.Get("Authorization")
+}
+
+func (s *bedrockServer) last() (string, map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastPath, s.lastBody
+}
+
+// TestBedrockBackend tests serving Anthropic models through InvokeModel and InvokeModelWithResponseStream
+func TestBedrockBackend(t *testing.T) {
+	server := newBedrockServer(t)
+	backend := bedrock.NewBedrockBackendWithEndpoint(server.URL, "us-east-1", awsauth.StaticProvider{Credentials: bedrockCredentials})
+
+	req := types.ChatRequest{
+		Model: "us.anthropic.claude-sonnet-4-20250514-v1:0",
+		Messages: []types.ChatMessage{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "Hi"},
+		},
+		MaxTokens: 256,
+	}
+
+	t.Run("Chat", func(t *testing.T) {
+		resp, err := backend.Chat(context.Background(), req)
+		require.NoError(t, err)
+		assert.Equal(t, "Hello from Bedrock!", resp.Message.Content)
+		assert.Equal(t, &types.Usage{PromptTokens: 14, CompletionTokens: 6}, resp.Usage)
+		assert.Equal(t, "length", resp.DoneReason)
+
+		path, body := server.last()
+		assert.Equal(t, "/model/us.anthropic.claude-sonnet-4-20250514-v1%3A0/invoke", path, "the model ID is escaped in the path")
+		assert.Equal(t, "bedrock-2023-05-31", body["anthropic_version"])
+		assert.Equal(t, "Be brief.", body["system"])
+		assert.NotContains(t, body, "model", "Bedrock takes the model from the URL")
+		assert.NotContains(t, body, "stream")
+	})
+
+	t.Run("ChatStream", func(t *testing.T) {
+		var content strings.Builder
+		var final types.StreamChunk
+		err := backend.ChatStream(context.Background(), req, func(chunk types.StreamChunk) error {
+			content.WriteString(chunk.Content)
+			if chunk.Done {
+				final = chunk
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Hello from Bedrock!", content.String())
+		assert.True(t, final.Done)
+		assert.Equal(t, "stop", final.DoneReason)
+		assert.Equal(t, &types.Usage{PromptTokens: 14, CompletionTokens: 6}, final.Usage)
+	})
+
+	t.Run("ChatStreamToolUse", func(t *testing.T) {
+		toolReq := req
+		toolReq.Tools = []types.Tool{{Type: "function", Function: types.ToolFunction{Name: "get_weather"}}}
+		var content strings.Builder
+		var toolCalls []types.ToolCall
+		err := backend.ChatStream(context.Background(), toolReq, func(chunk types.StreamChunk) error {
+			content.WriteString(chunk.Content)
+			toolCalls = append(toolCalls, chunk.ToolCalls...)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Let me check.", content.String())
+		require.Len(t, toolCalls, 1)
+		assert.Equal(t, "toolu_bdrk_01A9kT3vYp2NwQe7", toolCalls[0].ID)
+		assert.Equal(t, "get_weather", toolCalls[0].Function.Name)
+		assert.JSONEq(t, `{"city":"Paris"}`, string(toolCalls[0].Function.Arguments))
+	})
+
+	t.Run("StreamException", func(t *testing.T) {
+		throttled := req
+		throttled.Model = "throttled"
+		err := backend.ChatStream(context.Background(), throttled, func(types.StreamChunk) error { return nil })
+		assert.ErrorContains(t, err, "throttlingException: Too many requests")
+		assert.True(t, retry.IsTemporary(err), "throttling is retried")
+	})
+
+	t.Run("UnknownModel", func(t *testing.T) {
+		missing := req
+		missing.Model = "anthropic.claude-0"
+		_, err := backend.Chat(context.Background(), missing)
+		assert.ErrorContains(t, err, "Model not found.")
+	})
+
+	t.Run("BadCredentials", func(t *testing.T) {
+		wrong := bedrockCredentials
+		wrong.SecretAccessKey = "wrong"
+		backend := bedrock.NewBedrockBackendWithEndpoint(server.URL, "us-east-1", awsauth.StaticProvider{Credentials: wrong})
+		_, err := backend.Chat(context.Background(), req)
+		assert.ErrorContains(t, err, "signature")
+	})
+
+	t.Run("Models", func(t *testing.T) {
+		t.Setenv("AWS_ACCESS_KEY_ID", bedrockCredentials.AccessKeyID)
+		t.Setenv("AWS_SECRET_ACCESS_KEY", bedrockCredentials.SecretAccessKey)
+		t.Setenv("AWS_SESSION_TOKEN", bedrockCredentials.SessionToken)
+
+		cfg := &config.Config{Bedrock: config.BedrockConfig{
+			Region:          "us-east-1",
+			ControlEndpoint: server.URL,
+			ExcludePatterns: []string{"claude-v2*"},
+		}}
+		fetched, err := fetcher.NewModelFetcher(cfg).FetchAllModels(context.Background())
+		require.NoError(t, err)
+
+		byName := make(map[string]types.ModelConfig)
+		for _, model := range fetched {
+			byName[model.Name] = model
+		}
+		assert.Len(t, byName, 2, "excluded models and other providers' profiles aren't listed")
+
+		haiku := byName["claude-3-5-haiku-20241022"]
+		assert.Equal(t, types.BackendBedrock, haiku.Backend)
+		assert.Equal(t, "anthropic.claude-3-5-haiku-20241022-v1:0", haiku.BackendModel, "on-demand models are invoked by their ID")
+		assert.Equal(t, "Claude 3.5 Haiku", haiku.DisplayName)
+		assert.Equal(t, "claude", haiku.Family)
+		assert.False(t, haiku.Vision)
+
+		sonnet := byName["claude-sonnet-4-20250514"]
+		assert.Equal(t, "us.anthropic.claude-sonnet-4-20250514-v1:0", sonnet.BackendModel, "other models are invoked through their inference profile")
+		assert.True(t, sonnet.Vision)
+	})
+}
+
+// TestSigV4 tests signing requests against the AWS Signature Version 4 test suite
+func TestSigV4(t *testing.T) {
+	signer := &awsauth.Signer{
+		Credentials: awsauth.StaticProvider{Credentials: awsauth.Credentials{
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		}},
+		Region:  "us-east-1",
+		Service: "service",
+		Now:     func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		contentType   string
+		body          string
+		signedHeaders string
+		signature     string
+	}{
+		{"get-vanilla", "GET", "https://example.amazonaws.com/", "", "",
+			"host;x-amz-date", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"post-vanilla", "POST", "https://example.amazonaws.com/", "", "",
+			"host;x-amz-date", "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		{"get-vanilla-query-order-key-case", "GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", "",
+			"host;x-amz-date", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"post-x-www-form-urlencoded", "POST", "https://example.amazonaws.com/", "application/x-www-form-urlencoded", "Param1=value1",
+			"content-type;host;x-amz-date", "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			require.NoError(t, signer.Sign(req, []byte(tt.body)))
+
+			assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+			assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+				"SignedHeaders="+tt.signedHeaders+", Signature="+tt.signature, req.Header.Get("Authorization"))
+		})
+	}
+}
+
+// TestAWSCredentialChain tests looking up credentials in the sources of the standard chain
+func TestAWSCredentialChain(t *testing.T) {
+	// Keep the chain away from the machine's own credentials
+	home := t.TempDir()
+	for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE",
+		"AWS_SHARED_CREDENTIALS_FILE", "AWS_CONFIG_FILE", "AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI", "AWS_CONTAINER_AUTHORIZATION_TOKEN"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	ctx := context.Background()
+
+	t.Run("NoCredentials", func(t *testing.T) {
+		_, err := awsauth.DefaultChain("", "us-east-1").Retrieve(ctx)
+		assert.ErrorContains(t, err, "no AWS credentials found")
+	})
+
+	t.Run("Environment", func(t *testing.T) {
+		t.Setenv("AWS_ACCESS_KEY_ID", "AKIDENV")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+		creds, err := awsauth.DefaultChain("", "us-east-1").Retrieve(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, awsauth.Credentials{AccessKeyID: "AKIDENV", SecretAccessKey: "env-secret"}, creds)
+	})
+
+	t.Run("SharedFiles", func(t *testing.T) {
+		require.NoError(t, os.MkdirAll(filepath.Join(home, ".aws"), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".aws", "credentials"), []byte(
+			"[default]\naws_access_key_id = AKIDDEFAULT\naws_secret_access_key = default-secret\n\n"+
+				"# SSO profiles hold no keys\n[sso]\nsso_session = corp\n"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(home, ".aws", "config"), []byte(
+			"[profile bedrock]\nregion = us-east-1\naws_access_key_id = AKIDPROFILE\naws_secret_access_key = profile-secret\naws_session_token = profile-token\n"), 0o600))
+
+		creds, err := awsauth.DefaultChain("", "us-east-1").Retrieve(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "AKIDDEFAULT", creds.AccessKeyID)
+
+		creds, err = awsauth.DefaultChain("bedrock", "us-east-1").Retrieve(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, awsauth.Credentials{AccessKeyID: "AKIDPROFILE", SecretAccessKey: "profile-secret", SessionToken: "profile-token"}, creds)
+
+		t.Setenv("AWS_PROFILE", "sso")
+		_, err = awsauth.DefaultChain("", "us-east-1").Retrieve(ctx)
+		assert.ErrorContains(t, err, "profile sso has no access keys")
+	})
+
+	t.Run("Container", func(t *testing.T) {
+		expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if r.Header.Get("Authorization") != "container-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"AccessKeyId":"ASIACONTAINER","SecretAccessKey":"container-secret","Token":"container-session","Expiration":"` + expires.Format(time.RFC3339) + `"}`))
+		}))
+		defer server.Close()
+		t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", server.URL+"/v2/credentials")
+		t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "container-token")
+
+		chain := &awsauth.Chain{Providers: []awsauth.Provider{awsauth.EnvProvider{}, awsauth.ContainerProvider{}}}
+		creds, err := chain.Retrieve(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, awsauth.Credentials{AccessKeyID: "ASIACONTAINER", SecretAccessKey: "container-secret", SessionToken: "container-session", Expires: expires}, creds)
+
+		_, err = chain.Retrieve(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls, "credentials are kept until shortly before they expire")
+	})
+
+	t.Run("InstanceMetadata", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+				_, _ = w.Write([]byte("imds-token"))
+			case r.Header.Get("X-aws-ec2-metadata-token") != "imds-token":
+				w.WriteHeader(http.StatusUnauthorized)
+			case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+				_, _ = w.Write([]byte("proxy-role"))
+			case r.URL.Path == "/latest/meta-data/iam/security-credentials/proxy-role":
+				_, _ = w.Write([]byte(`{"Code":"Success","AccessKeyId":"ASIAINSTANCE","SecretAccessKey":"instance-secret","Token":"instance-session","Expiration":"2030-01-01T00:00:00Z"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+		t.Setenv("AWS_EC2_METADATA_DISABLED", "")
+		t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", server.URL)
+
+		creds, err := awsauth.IMDSProvider{}.Retrieve(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "ASIAINSTANCE", creds.AccessKeyID)
+		assert.Equal(t, "instance-session", creds.SessionToken)
+	})
+}
+
+// TestEventStreamDecoder tests decoding recorded Bedrock event streams
+func TestEventStreamDecoder(t *testing.T) {
+	t.Run("Stream", func(t *testing.T) {
+		decoder := bedrock.NewDecoder(bytes.NewReader(fixtures.BedrockStream("stream_text")))
+		var events []string
+		for {
+			message, err := decoder.Decode()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "event", message.Header(":message-type"))
+			assert.Equal(t, "chunk", message.Header(":event-type"))
+			assert.Equal(t, "application/json", message.Header(":content-type"))
+
+			var chunk struct {
+				Bytes []byte `json:"bytes"`
+			}
+			require.NoError(t, json.Unmarshal(message.Payload, &chunk))
+			var event struct {
+				Type string `json:"type"`
+			}
+			require.NoError(t, json.Unmarshal(chunk.Bytes, &event))
+			events = append(events, event.Type)
+		}
+		assert.Equal(t, []string{"message_start", "content_block_start", "content_block_delta", "content_block_delta",
+			"content_block_delta", "content_block_stop", "message_delta", "message_stop"}, events)
+	})
+
+	t.Run("HeaderTypes", func(t *testing.T) {
+		message, err := bedrock.NewDecoder(bytes.NewReader(fixtures.BedrockStream("headers"))).Decode()
+		require.NoError(t, err)
+		var uuid [16]byte
+		for i := range uuid {
+			uuid[i] = byte(i)
+		}
+		assert.Equal(t, map[string]interface{}{
+			"true":      true,
+			"false":     false,
+			"byte":      int8(-7),
+			"short":     int16(1234),
+			"int":       int32(-70000),
+			"long":      int64(1 << 40),
+			"bytes":     []byte{0, 1, 2},
+			"string":    "café",
+			"timestamp": time.UnixMilli(1700000000123).UTC(),
+			"uuid":      uuid,
+		}, message.Headers)
+		assert.Equal(t, []byte("payload"), message.Payload)
+	})
+
+	t.Run("Corrupted", func(t *testing.T) {
+		stream := append([]byte{}, fixtures.BedrockStream("stream_text")...)
+		stream[40] ^= 0xff
+		_, err := bedrock.NewDecoder(bytes.NewReader(stream)).Decode()
+		assert.ErrorContains(t, err, "message checksum mismatch")
+
+		stream = append([]byte{}, fixtures.BedrockStream("stream_text")...)
+		stream[2] ^= 0xff
+		_, err = bedrock.NewDecoder(bytes.NewReader(stream)).Decode()
+		assert.ErrorContains(t, err, "prelude checksum mismatch")
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		stream := fixtures.BedrockStream("stream_text")
+		_, err := bedrock.NewDecoder(bytes.NewReader(stream[:100])).Decode()
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+}
@@ -2,11 +2,14 @@ package llmproxy_unit_test
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"go-llm-proxy/internal/config"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestConfig tests the configuration management functionality
@@ -15,15 +18,23 @@ func TestConfig(t *testing.T) {
 		// Clear environment variables
 		os.Clearenv()
 
-		cfg := config.LoadConfig()
+		cfg := config.Default()
 
 		assert.Equal(t, "11434", cfg.Port)
 		assert.Equal(t, "release", cfg.GinMode)
 		assert.Equal(t, "", cfg.AnthropicAPIKey)
 		assert.Equal(t, "", cfg.OpenAIAPIKey)
 		assert.Equal(t, 4096, cfg.DefaultMaxTokens)
-		assert.Equal(t, 3, cfg.StreamingChunkSize)
-		assert.Equal(t, 50, cfg.StreamingDelay)
+		assert.Equal(t, config.UnsupportedOptionsDrop, cfg.UnsupportedOptions)
+		assert.Equal(t, 2, cfg.FormatRetries)
+		assert.Equal(t, 600, cfg.RequestTimeout)
+		assert.Equal(t, 3, cfg.RetryMaxAttempts)
+		assert.Equal(t, 30*time.Second, cfg.RetryPolicy().MaxElapsed)
+		assert.Contains(t, cfg.EmbeddingModels, config.EmbeddingModelConfig{
+			Name:         "nomic-embed-text",
+			Backend:      "openai",
+			BackendModel: "text-embedding-3-small",
+		})
 	})
 
 	t.Run("LoadConfigWithEnvironment", func(t *testing.T) {
@@ -33,18 +44,38 @@ func TestConfig(t *testing.T) {
 		_ = os.Setenv("ANTHROPIC_API_KEY", "test-anthropic-key")
 		_ = os.Setenv("OPENAI_API_KEY", "test-openai-key")
 		_ = os.Setenv("DEFAULT_MAX_TOKENS", "8192")
-		_ = os.Setenv("STREAMING_CHUNK_SIZE", "5")
-		_ = os.Setenv("STREAMING_DELAY_MS", "100")
+		_ = os.Setenv("FORMAT_RETRIES", "5")
 
-		cfg := config.LoadConfig()
+		cfg, err := config.Load(nil)
+		require.NoError(t, err)
 
 		assert.Equal(t, "8080", cfg.Port)
 		assert.Equal(t, "debug", cfg.GinMode)
 		assert.Equal(t, "test-anthropic-key", cfg.AnthropicAPIKey)
 		assert.Equal(t, "test-openai-key", cfg.OpenAIAPIKey)
 		assert.Equal(t, 8192, cfg.DefaultMaxTokens)
-		assert.Equal(t, 5, cfg.StreamingChunkSize)
-		assert.Equal(t, 100, cfg.StreamingDelay)
+		assert.Equal(t, 5, cfg.FormatRetries)
+
+		// Clean up
+		os.Clearenv()
+	})
+
+	t.Run("LoadConfigWithKeyLists", func(t *testing.T) {
+		_ = os.Setenv("ANTHROPIC_API_KEYS", "key-1, key-2,,key-3")
+		_ = os.Setenv("KEY_SELECTION", "least_used")
+
+		cfg, err := config.Load(nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"key-1", "key-2", "key-3"}, cfg.AnthropicAPIKeys)
+		assert.Equal(t, "key-1", cfg.AnthropicAPIKey, "the first listed key is used when ANTHROPIC_API_KEY is unset")
+		assert.True(t, cfg.HasAnthropic())
+		assert.False(t, cfg.HasOpenAI())
+		assert.Equal(t, "least_used", cfg.KeySelection)
+		assert.NoError(t, cfg.IsValid())
+
+		cfg.KeySelection = "random"
+		assert.Error(t, cfg.IsValid())
 
 		// Clean up
 		os.Clearenv()
@@ -83,6 +114,34 @@ func TestConfig(t *testing.T) {
 		err = cfg.IsValid()
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "port must be specified")
+
+		// Test with an unknown unsupported option policy
+		cfg.Port = "11434"
+		cfg.UnsupportedOptions = "ignore"
+		err = cfg.IsValid()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported_options")
+
+		// Test with a negative number of format retries
+		cfg.UnsupportedOptions = config.UnsupportedOptionsDrop
+		cfg.FormatRetries = -1
+		err = cfg.IsValid()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "format_retries")
+
+		// Test with a non-positive model timeout
+		cfg.FormatRetries = 0
+		cfg.ModelTimeouts = map[string]time.Duration{"gpt-5": 0}
+		err = cfg.IsValid()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "model_timeouts")
+
+		// Test with a model falling back to itself
+		cfg.ModelTimeouts = nil
+		cfg.ModelFallbacks = map[string][]string{"gpt-5": {"gpt-4o", "gpt-5"}}
+		err = cfg.IsValid()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "model_fallbacks")
 	})
 
 	t.Run("HasAnthropic", func(t *testing.T) {
@@ -102,6 +161,114 @@ func TestConfig(t *testing.T) {
 	})
 }
 
+// TestLoadConfig tests layering the configuration file, environment and flags, and strict validation
+func TestLoadConfig(t *testing.T) {
+	writeConfig := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("Precedence", func(t *testing.T) {
+		os.Clearenv()
+		defer os.Clearenv()
+		path := writeConfig(t, `
+port: "9000"
+default_max_tokens: 1000
+format_retries: 1
+openai_api_key: "file-key"
+model_filters:
+  openai:
+    include_patterns: ["o*"]
+`)
+		_ = os.Setenv("MODEL_CONFIG_PATH", path)
+		_ = os.Setenv("DEFAULT_MAX_TOKENS", "2000")
+		_ = os.Setenv("FORMAT_RETRIES", "2")
+
+		cfg, err := config.Load([]string{"--format-retries", "3"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "9000", cfg.Port, "the file overrides defaults")
+		assert.Equal(t, 2000, cfg.DefaultMaxTokens, "the environment overrides the file")
+		assert.Equal(t, 3, cfg.FormatRetries, "flags override the environment")
+		assert.Equal(t, "file-key", cfg.OpenAIAPIKey)
+		assert.Equal(t, []string{"o*"}, cfg.ModelFilters.OpenAI.IncludePatterns)
+		assert.True(t, cfg.ModelFilters.OpenAI.Enabled, "settings the file leaves out keep their defaults")
+		assert.Equal(t, []string{"claude-*"}, cfg.ModelFilters.Anthropic.IncludePatterns)
+		assert.Equal(t, 3, cfg.RetryMaxAttempts)
+		assert.Equal(t, path, cfg.Path)
+	})
+
+	t.Run("ConfigFlag", func(t *testing.T) {
+		os.Clearenv()
+		path := writeConfig(t, `anthropic_api_key: "file-key"`)
+
+		cfg, err := config.Load([]string{"--config", path, "--port=8081"})
+		require.NoError(t, err)
+		assert.Equal(t, "file-key", cfg.AnthropicAPIKey)
+		assert.Equal(t, "8081", cfg.Port)
+
+		_, err = config.Load([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml")})
+		assert.ErrorContains(t, err, "failed to read config file")
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		os.Clearenv()
+		path := writeConfig(t, `
+openai_api_key: "key"
+model_filter:
+  openai:
+    enabled: false
+`)
+		_, err := config.Load([]string{"--config", path})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "field model_filter not found")
+	})
+
+	t.Run("MalformedValues", func(t *testing.T) {
+		os.Clearenv()
+		defer os.Clearenv()
+		_ = os.Setenv("OPENAI_API_KEY", "key")
+		_ = os.Setenv("RETRY_MAX_ATTEMPTS", "three")
+
+		_, err := config.Load([]string{"--format-retries", "x"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `RETRY_MAX_ATTEMPTS: "three" is not an integer`)
+		assert.Contains(t, err.Error(), `--format-retries: "x" is not an integer`)
+
+		_, err = config.Load([]string{"--no-such-flag"})
+		assert.Error(t, err)
+	})
+
+	t.Run("ReportsEveryProblem", func(t *testing.T) {
+		os.Clearenv()
+		path := writeConfig(t, `
+port: "http"
+gin_mode: "verbose"
+default_max_tokens: 0
+model_filters:
+  anthropic:
+    include_patterns: ["claude-["]
+embedding_models:
+  - name: "nomic-embed-text"
+    backend: "cohere"
+    backend_model: "embed-english-v3.0"
+`)
+		_, err := config.Load([]string{"--config", path})
+		require.Error(t, err)
+		for _, problem := range []string{
+			"at least one API key must be provided",
+			"port must be a number",
+			"gin_mode must be debug, release or test",
+			"default_max_tokens must be positive",
+			`model_filters.anthropic: invalid pattern "claude-["`,
+			`embedding_models[0]: unknown backend "cohere"`,
+		} {
+			assert.Contains(t, err.Error(), problem)
+		}
+	})
+}
+
 // TestGetEnv tests the config.GetEnv helper function
 func TestGetEnv(t *testing.T) {
 	t.Run("GetEnvWithValue", func(t *testing.T) {
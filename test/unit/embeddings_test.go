@@ -0,0 +1,123 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-llm-proxy/internal/backend"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/openai"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAIEmbed tests that batched inputs are sent upstream and returned in input order
+func TestOpenAIEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/embeddings", r.URL.Path)
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+
+		var body struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "text-embedding-3-small", body.Model)
+		assert.Equal(t, []string{"first", "second"}, body.Input)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"object": "list",
+			"data": [
+				{"object": "embedding", "index": 1, "embedding": [0.3, 0.4]},
+				{"object": "embedding", "index": 0, "embedding": [0.1, 0.2]}
+			],
+			"model": "text-embedding-3-small",
+			"usage": {"prompt_tokens": 4, "total_tokens": 4}
+		}`))
+	}))
+	defer server.Close()
+
+	backend := openai.NewOpenAIBackendWithBaseURL("test-key", server.URL)
+	resp, err := backend.Embed(context.Background(), types.EmbeddingRequest{
+		Model: "text-embedding-3-small",
+		Input: []string{"first", "second"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{0.1, 0.2}, {0.3, 0.4}}, resp.Embeddings)
+	require.NotNil(t, resp.Usage)
+	assert.Equal(t, 4, resp.Usage.PromptTokens)
+}
+
+// TestOpenAIEmbedError tests that upstream errors are surfaced
+func TestOpenAIEmbedError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"message":"bad model"}}`))
+	}))
+	defer server.Close()
+
+	backend := openai.NewOpenAIBackendWithBaseURL("test-key", server.URL)
+	_, err := backend.Embed(context.Background(), types.EmbeddingRequest{
+		Model: "unknown",
+		Input: []string{"text"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad model")
+}
+
+// TestOllamaEmbedInput tests that /api/embed accepts a single string or a batch
+func TestOllamaEmbedInput(t *testing.T) {
+	var single types.OllamaEmbedRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"model":"nomic-embed-text","input":"hello"}`), &single))
+	assert.Equal(t, types.OllamaEmbedInput{"hello"}, single.Input)
+
+	var batch types.OllamaEmbedRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"model":"nomic-embed-text","input":["a","b"]}`), &batch))
+	assert.Equal(t, types.OllamaEmbedInput{"a", "b"}, batch.Input)
+
+	var invalid types.OllamaEmbedRequest
+	assert.Error(t, json.Unmarshal([]byte(`{"model":"nomic-embed-text","input":42}`), &invalid))
+}
+
+// TestBackendManagerEmbeddingCapability tests that embedding and chat models are kept apart
+func TestBackendManagerEmbeddingCapability(t *testing.T) {
+	manager := backend.NewBackendManager()
+	manager.RegisterBackend(types.BackendOpenAI, &MockBackend{name: "openai", available: true})
+
+	embeddingModel := types.ModelConfig{
+		Name:         "nomic-embed-text",
+		Backend:      types.BackendOpenAI,
+		BackendModel: "text-embedding-3-small",
+		Enabled:      true,
+		Embedding:    true,
+	}
+	chatModel := types.ModelConfig{
+		Name:         "gpt-4o",
+		Backend:      types.BackendOpenAI,
+		BackendModel: "gpt-4o",
+		Enabled:      true,
+	}
+
+	t.Run("ChatWithEmbeddingModel", func(t *testing.T) {
+		_, err := manager.ProcessRequest(context.Background(), embeddingModel, types.ChatRequest{Model: "text-embedding-3-small"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "only supports embeddings")
+	})
+
+	t.Run("EmbedWithChatModel", func(t *testing.T) {
+		_, err := manager.ProcessEmbeddingRequest(context.Background(), chatModel, types.EmbeddingRequest{Model: "gpt-4o", Input: []string{"text"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not support embeddings")
+	})
+
+	t.Run("BackendWithoutEmbeddings", func(t *testing.T) {
+		_, err := manager.ProcessEmbeddingRequest(context.Background(), embeddingModel, types.EmbeddingRequest{Model: "text-embedding-3-small", Input: []string{"text"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "backend openai does not support embeddings")
+	})
+}
@@ -0,0 +1,190 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-llm-proxy/internal/backend"
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/streaming"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/test/helpers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockFailingBackend is a mock backend whose requests fail, optionally after streaming a chunk
+type MockFailingBackend struct {
+	MockBackend
+	err          error
+	partialChunk bool
+	calls        int
+}
+
+func (m *MockFailingBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.ChatResponse, error) {
+	m.calls++
+	return nil, m.err
+}
+
+func (m *MockFailingBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	m.calls++
+	if m.partialChunk {
+		if err := onChunk(types.StreamChunk{Content: "Partial"}); err != nil {
+			return err
+		}
+	}
+	return m.err
+}
+
+// MockNoTopKFailingBackend is a failing mock backend without top_k, like OpenAI
+type MockNoTopKFailingBackend struct {
+	MockFailingBackend
+}
+
+func (m *MockNoTopKFailingBackend) SupportsOption(name string) bool {
+	return name != types.OptionTopK
+}
+
+// newFallbackManager creates a manager whose Anthropic backend fails with err and whose
+// OpenAI backend answers, with the test models available as fallbacks
+func newFallbackManager(err error, partialChunk bool) (*backend.BackendManager, *MockFailingBackend) {
+	failing := &MockFailingBackend{MockBackend: MockBackend{name: "anthropic", available: true}, err: err, partialChunk: partialChunk}
+	manager := backend.NewBackendManager()
+	manager.SetRetryPolicy(retry.Policy{MaxAttempts: 1})
+	manager.RegisterBackend(types.BackendAnthropic, failing)
+	manager.RegisterBackend(types.BackendOpenAI, &MockBackend{name: "openai", available: true})
+	manager.SetModelLookup(helpers.CreateTestModelRegistry())
+	return manager, failing
+}
+
+// TestModelFallbacks tests which failures send a request on to the model's fallbacks
+func TestModelFallbacks(t *testing.T) {
+	modelConfig := types.ModelConfig{
+		Name:         "claude-test",
+		Backend:      types.BackendAnthropic,
+		BackendModel: "claude-test-upstream",
+		Fallbacks:    []string{"missing-model", "gpt-4o"},
+	}
+	req := types.ChatRequest{Model: "claude-test-upstream", MaxTokens: 100}
+	overloaded := retry.WithStatus(529, errors.New("overloaded"))
+
+	t.Run("FallsBackOnOverload", func(t *testing.T) {
+		manager, failing := newFallbackManager(overloaded, false)
+
+		var served string
+		ctx := backend.WithServedModelCallback(context.Background(), func(model string) { served = model })
+		resp, err := manager.ProcessRequest(ctx, modelConfig, req)
+		require.NoError(t, err)
+
+		chatResp := resp.(*types.ChatResponse)
+		assert.Equal(t, "gpt-4o", chatResp.Model, "the request is adapted to the fallback's upstream model")
+		assert.Equal(t, "gpt-4o", served)
+		assert.Equal(t, 1, failing.calls)
+	})
+
+	t.Run("NoFallbackOnClientError", func(t *testing.T) {
+		manager, _ := newFallbackManager(retry.WithStatus(http.StatusBadRequest, errors.New("invalid request")), false)
+
+		_, err := manager.ProcessRequest(context.Background(), modelConfig, req)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid request")
+	})
+
+	t.Run("PrimaryErrorWhenAllFail", func(t *testing.T) {
+		manager, _ := newFallbackManager(overloaded, false)
+		onlyMissing := modelConfig
+		onlyMissing.Fallbacks = []string{"missing-model"}
+
+		_, err := manager.ProcessRequest(context.Background(), onlyMissing, req)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "overloaded")
+	})
+
+	t.Run("StreamFallsBackBeforeOutput", func(t *testing.T) {
+		manager, _ := newFallbackManager(overloaded, false)
+
+		var chunks []types.StreamChunk
+		err := manager.ProcessStreamRequest(context.Background(), modelConfig, req, func(chunk types.StreamChunk) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, chunks, 2)
+		assert.Equal(t, "Mock response", chunks[0].Content)
+	})
+
+	t.Run("FallbackResolvesClientOptions", func(t *testing.T) {
+		// The OpenAI primary drops top_k, which the Anthropic fallback supports
+		primary := &MockNoTopKFailingBackend{MockFailingBackend{MockBackend: MockBackend{name: "openai", available: true}, err: overloaded}}
+		fallback := &MockRecordingBackend{MockBackend: MockBackend{name: "anthropic", available: true}}
+		manager := backend.NewBackendManager()
+		manager.SetRetryPolicy(retry.Policy{MaxAttempts: 1})
+		manager.RegisterBackend(types.BackendOpenAI, primary)
+		manager.RegisterBackend(types.BackendAnthropic, fallback)
+		manager.SetModelLookup(helpers.CreateTestModelRegistry())
+
+		gptConfig := types.ModelConfig{Name: "gpt-test", Backend: types.BackendOpenAI, BackendModel: "gpt-test", Fallbacks: []string{"claude-3.5-sonnet"}}
+		topK, temperature := 40, 0.3
+		clientOpts := types.GenerationOptions{TopK: &topK, Temperature: &temperature}
+		opts, err := manager.ResolveOptions(gptConfig, clientOpts)
+		require.NoError(t, err)
+		require.Nil(t, opts.TopK)
+
+		optsReq := types.ChatRequest{Model: "gpt-test", MaxTokens: 100, Options: opts, ClientOptions: clientOpts}
+		_, err = manager.ProcessRequest(context.Background(), gptConfig, optsReq)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, primary.calls)
+		sent := fallback.lastChat.Options
+		require.NotNil(t, sent.TopK, "options the primary's backend dropped reach the fallback")
+		assert.Equal(t, 40, *sent.TopK)
+		require.NotNil(t, sent.Temperature)
+		assert.Equal(t, 0.3, *sent.Temperature)
+	})
+
+	t.Run("StreamKeepsCommittedModel", func(t *testing.T) {
+		manager, _ := newFallbackManager(overloaded, true)
+
+		var chunks []types.StreamChunk
+		err := manager.ProcessStreamRequest(context.Background(), modelConfig, req, func(chunk types.StreamChunk) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+		require.Error(t, err)
+		require.Len(t, chunks, 1)
+		assert.Equal(t, "Partial", chunks[0].Content)
+	})
+}
+
+// TestStreamingServedModelHeader tests that streams name the fallback that served them
+func TestStreamingServedModelHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	manager, _ := newFallbackManager(retry.WithStatus(http.StatusServiceUnavailable, errors.New("unavailable")), false)
+	registry := helpers.CreateTestModelRegistry()
+	registry.AddModel(types.ModelConfig{
+		Name:         "claude-test",
+		Backend:      types.BackendAnthropic,
+		BackendModel: "claude-test-upstream",
+		MaxTokens:    8192,
+		Enabled:      true,
+		Fallbacks:    []string{"gpt-4o"},
+	})
+	streamingHandler := streaming.NewStreamingHandler(manager, registry)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	streamingHandler.HandleStreamingChat(c, types.OllamaChatRequest{
+		Model:    "claude-test",
+		Messages: []types.OllamaMessage{{Role: "user", Content: "Hello"}},
+		Stream:   true,
+	})
+
+	assert.Equal(t, "gpt-4o", w.Header().Get(backend.ServedModelHeader))
+	assert.Contains(t, w.Body.String(), "Mock response")
+}
@@ -0,0 +1,219 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go-llm-proxy/internal/config"
+	"go-llm-proxy/internal/fetcher"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/gemini"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// geminiServer stands in for the Gemini API, answering each model with a canned response
+// and recording the last request body
+type geminiServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	lastBody map[string]interface{}
+}
+
+// geminiResponses holds the canned generateContent responses, by model
+var geminiResponses = map[string]string{
+	"gemini-2.5-flash": `{"candidates":[{"content":{"role":"model","parts":[
+		{"text":"Thinking it over","thought":true},
+		{"text":"It is sunny."},
+		{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]},"finishReason":"MAX_TOKENS"}],
+		"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":5,"thoughtsTokenCount":3}}`,
+	"gemini-blocked-prompt": `{"promptFeedback":{"blockReason":"PROHIBITED_CONTENT"},"usageMetadata":{"promptTokenCount":4}}`,
+	"gemini-blocked-answer": `{"candidates":[{"content":{"role":"model"},"finishReason":"SAFETY"}]}`,
+}
+
+func newGeminiServer(t *testing.T) *geminiServer {
+	server := &geminiServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "gemini-key" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":{"code":403,"message":"API key not valid.","status":"PERMISSION_DENIED"}}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet && r.URL.Path == "/v1beta/models" {
+			// The list is split over two pages
+			if r.URL.Query().Get("pageToken") == "" {
+				_, _ = w.Write([]byte(`{"models":[
+					{"name":"models/gemini-2.5-flash","displayName":"Gemini 2.5 Flash","description":"Fast and versatile","inputTokenLimit":1048576,"supportedGenerationMethods":["generateContent","countTokens"]},
+					{"name":"models/text-embedding-004","displayName":"Text Embedding 004","inputTokenLimit":2048,"supportedGenerationMethods":["embedContent"]}],
+					"nextPageToken":"page-2"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"models":[
+				{"name":"models/gemini-2.0-flash-lite","inputTokenLimit":1048576,"supportedGenerationMethods":["generateContent"]},
+				{"name":"models/gemma-3-27b-it","inputTokenLimit":131072,"supportedGenerationMethods":["generateContent"]}]}`))
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		server.mu.Lock()
+		server.lastBody = nil
+		_ = json.Unmarshal(body, &server.lastBody)
+		server.mu.Unlock()
+
+		model, method, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1beta/models/"), ":")
+		switch {
+		case method == "streamGenerateContent" && r.URL.Query().Get("alt") == "sse":
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Hel"}]}}],"usageMetadata":{"promptTokenCount":12}}` + "\r\n\r\n" +
+				`data: {"candidates":[{"content":{"role":"model","parts":[{"text":"lo"},{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":4}}` + "\r\n\r\n"))
+		case method == "generateContent" && geminiResponses[model] != "":
+			_, _ = w.Write([]byte(geminiResponses[model]))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":404,"message":"models/` + model + ` is not found","status":"NOT_FOUND"}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *geminiServer) body() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastBody
+}
+
+// TestGeminiBackend tests serving Gemini models through generateContent and streamGenerateContent
+func TestGeminiBackend(t *testing.T) {
+	server := newGeminiServer(t)
+	backend := gemini.NewGeminiBackendWithBaseURL("gemini-key", server.URL)
+
+	temperature := 0.2
+	req := types.ChatRequest{
+		Model: "gemini-2.5-flash",
+		Messages: []types.ChatMessage{
+			{Role: "system", Content: "Be brief."},
+			{Role: "user", Content: "Weather in Paris?"},
+			{Role: "assistant", ToolCalls: []types.ToolCall{{ID: "call_1", Function: types.ToolCallFunction{Name: "get_weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}}}},
+			{Role: "tool", ToolCallID: "call_1", Content: "sunny"},
+			{Role: "user", Content: "Thanks"},
+		},
+		Tools:     []types.Tool{{Type: "function", Function: types.ToolFunction{Name: "get_weather", Parameters: json.RawMessage(`{"type":"object"}`)}}},
+		MaxTokens: 256,
+		Options:   types.GenerationOptions{Temperature: &temperature, Stop: []string{"1", "2", "3", "4", "5", "6"}},
+		Format:    &types.ResponseFormat{Schema: json.RawMessage(`{"type":"object"}`)},
+	}
+
+	t.Run("Chat", func(t *testing.T) {
+		resp, err := backend.Chat(context.Background(), req)
+		require.NoError(t, err)
+
+		assert.Equal(t, "It is sunny.", resp.Message.Content, "thoughts aren't part of the answer")
+		require.Len(t, resp.Message.ToolCalls, 1)
+		assert.Equal(t, "get_weather", resp.Message.ToolCalls[0].Function.Name)
+		assert.JSONEq(t, `{"city":"Paris"}`, string(resp.Message.ToolCalls[0].Function.Arguments))
+		assert.NotEmpty(t, resp.Message.ToolCalls[0].ID)
+		assert.Equal(t, &types.Usage{PromptTokens: 12, CompletionTokens: 8}, resp.Usage)
+		assert.Equal(t, "length", resp.DoneReason)
+	})
+
+	t.Run("RequestMapping", func(t *testing.T) {
+		_, err := backend.Chat(context.Background(), req)
+		require.NoError(t, err)
+
+		sent, err := json.Marshal(server.body())
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"systemInstruction": {"parts": [{"text": "Be brief."}]},
+			"contents": [
+				{"role": "user", "parts": [{"text": "Weather in Paris?"}]},
+				{"role": "model", "parts": [{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}}]},
+				{"role": "user", "parts": [
+					{"functionResponse": {"name": "get_weather", "response": {"content": "sunny"}}},
+					{"text": "Thanks"}]}
+			],
+			"tools": [{"functionDeclarations": [{"name": "get_weather", "parametersJsonSchema": {"type": "object"}}]}],
+			"generationConfig": {
+				"temperature": 0.2,
+				"stopSequences": ["1", "2", "3", "4", "5"],
+				"maxOutputTokens": 256,
+				"responseMimeType": "application/json",
+				"responseJsonSchema": {"type": "object"}
+			}
+		}`, string(sent))
+	})
+
+	t.Run("ChatStream", func(t *testing.T) {
+		var content strings.Builder
+		var toolCalls []types.ToolCall
+		var final types.StreamChunk
+		err := backend.ChatStream(context.Background(), req, func(chunk types.StreamChunk) error {
+			content.WriteString(chunk.Content)
+			toolCalls = append(toolCalls, chunk.ToolCalls...)
+			if chunk.Done {
+				final = chunk
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, "Hello", content.String())
+		require.Len(t, toolCalls, 1)
+		assert.Equal(t, "get_weather", toolCalls[0].Function.Name)
+		assert.True(t, final.Done)
+		assert.Equal(t, "stop", final.DoneReason)
+		assert.Equal(t, &types.Usage{PromptTokens: 12, CompletionTokens: 4}, final.Usage, "the last usage metadata is reported")
+	})
+
+	t.Run("Blocked", func(t *testing.T) {
+		blocked := req
+		blocked.Model = "gemini-blocked-prompt"
+		_, err := backend.Chat(context.Background(), blocked)
+		assert.ErrorContains(t, err, "gemini blocked the prompt: PROHIBITED_CONTENT")
+
+		blocked.Model = "gemini-blocked-answer"
+		_, err = backend.Chat(context.Background(), blocked)
+		assert.ErrorContains(t, err, "gemini blocked the response: SAFETY")
+	})
+
+	t.Run("UnknownModel", func(t *testing.T) {
+		missing := req
+		missing.Model = "gemini-0.1"
+		_, err := backend.Chat(context.Background(), missing)
+		assert.ErrorContains(t, err, "is not found")
+	})
+
+	t.Run("Models", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.GeminiAPIKey = "gemini-key"
+		cfg.ModelCachePath = ""
+		cfg.EmbeddingModels = nil
+		apiClient := fetcher.NewAPIClientWithBaseURLs(server.URL, server.URL, server.URL)
+		fetched, err := fetcher.NewModelFetcherWithAPIClient(cfg, apiClient).FetchAllModels(context.Background())
+		require.NoError(t, err)
+
+		byName := make(map[string]types.ModelConfig)
+		for _, model := range fetched {
+			byName[model.Name] = model
+		}
+		assert.Len(t, byName, 2, "both pages are read; embedding models and models outside the filters aren't listed")
+
+		flash := byName["gemini-2.5-flash"]
+		assert.Equal(t, types.BackendGemini, flash.Backend)
+		assert.Equal(t, "gemini-2.5-flash", flash.BackendModel)
+		assert.Equal(t, "Gemini 2.5 Flash", flash.DisplayName)
+		assert.Equal(t, "gemini", flash.Family)
+		assert.Equal(t, 1048576, flash.MaxTokens)
+		assert.True(t, flash.Vision)
+		assert.Equal(t, "Gemini 2.0 Flash Lite", byName["gemini-2.0-flash-lite"].DisplayName)
+	})
+}
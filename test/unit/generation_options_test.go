@@ -0,0 +1,152 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-llm-proxy/internal/backend"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/anthropic"
+	"go-llm-proxy/pkg/openai"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+func intPtr(v int) *int           { return &v }
+
+// TestParseOllamaOptions tests reading generation options from an Ollama options object
+func TestParseOllamaOptions(t *testing.T) {
+	var options map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"temperature": 0,
+		"top_p": 0.9,
+		"top_k": 40,
+		"stop": ["\n\n", "END"],
+		"seed": 42,
+		"num_predict": 128,
+		"num_ctx": 8192
+	}`), &options))
+
+	opts, err := types.ParseOllamaOptions(options)
+	require.NoError(t, err)
+	assert.Equal(t, floatPtr(0), opts.Temperature)
+	assert.Equal(t, floatPtr(0.9), opts.TopP)
+	assert.Equal(t, intPtr(40), opts.TopK)
+	assert.Equal(t, []string{"\n\n", "END"}, opts.Stop)
+	assert.Equal(t, intPtr(42), opts.Seed)
+	assert.Equal(t, 128, opts.MaxTokens(4000))
+	assert.Equal(t, []string{"num_predict", "seed", "stop", "temperature", "top_k", "top_p"}, opts.Names())
+
+	// A single stop string is accepted too
+	opts, err = types.ParseOllamaOptions(map[string]interface{}{"stop": "END"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"END"}, opts.Stop)
+
+	// Ollama's "no limit" num_predict keeps the computed limit
+	opts, err = types.ParseOllamaOptions(map[string]interface{}{"num_predict": float64(-1)})
+	require.NoError(t, err)
+	assert.Equal(t, 4000, opts.MaxTokens(4000))
+
+	for name, options := range map[string]map[string]interface{}{
+		"StringTemperature":   {"temperature": "hot"},
+		"NegativeTemperature": {"temperature": float64(-1)},
+		"TopPAboveOne":        {"top_p": float64(1.5)},
+		"FractionalSeed":      {"seed": 1.5},
+		"NumericStop":         {"stop": []interface{}{float64(1)}},
+	} {
+		_, err := types.ParseOllamaOptions(options)
+		assert.Error(t, err, name)
+	}
+}
+
+// TestResolveOptions tests dropping and rejecting options a backend can't honor
+func TestResolveOptions(t *testing.T) {
+	manager := backend.NewBackendManager()
+	manager.RegisterBackend(types.BackendOpenAI, openai.NewOpenAIBackend("test-key"))
+	manager.RegisterBackend(types.BackendAnthropic, anthropic.NewAnthropicBackend("test-key"))
+
+	openaiModel := types.ModelConfig{Name: "gpt-4o", Backend: types.BackendOpenAI}
+	anthropicModel := types.ModelConfig{Name: "claude", Backend: types.BackendAnthropic}
+	opts := types.GenerationOptions{Temperature: floatPtr(0.5), TopK: intPtr(40), Seed: intPtr(1)}
+
+	resolved, err := manager.ResolveOptions(openaiModel, opts)
+	require.NoError(t, err)
+	assert.Nil(t, resolved.TopK)
+	assert.Equal(t, intPtr(1), resolved.Seed)
+
+	resolved, err = manager.ResolveOptions(anthropicModel, opts)
+	require.NoError(t, err)
+	assert.Nil(t, resolved.Seed)
+	assert.Equal(t, intPtr(40), resolved.TopK)
+
+	manager.SetRejectUnsupportedOptions(true)
+	_, err = manager.ResolveOptions(openaiModel, opts)
+	assert.ErrorContains(t, err, "option top_k is not supported by the openai backend")
+}
+
+// TestAnthropicGenerationOptions tests that options are sent as Messages API parameters
+func TestAnthropicGenerationOptions(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","content":[{"type":"text","text":"Hi."}],"usage":{"input_tokens":10,"output_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	backend := anthropic.NewAnthropicBackendWithBaseURL("test-key", server.URL)
+	_, err := backend.Generate(context.Background(), types.GenerateRequest{
+		Model:     "claude-test",
+		Prompt:    "Hello",
+		MaxTokens: 100,
+		Options: types.GenerationOptions{
+			Temperature: floatPtr(1.5),
+			TopP:        floatPtr(0.9),
+			TopK:        intPtr(40),
+			Stop:        []string{"END"},
+		},
+	})
+	require.NoError(t, err)
+
+	// Temperatures above Anthropic's maximum are clamped
+	assert.Equal(t, 1.0, body["temperature"])
+	assert.Equal(t, 0.9, body["top_p"])
+	assert.Equal(t, 40.0, body["top_k"])
+	assert.Equal(t, []interface{}{"END"}, body["stop_sequences"])
+}
+
+// TestOpenAIGenerationOptions tests that options are sent as chat completion parameters
+func TestOpenAIGenerationOptions(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","created":1,"choices":[{"index":0,"message":{"role":"assistant","content":"Hi."},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	backend := openai.NewOpenAIBackendWithBaseURL("test-key", server.URL)
+	_, err := backend.Chat(context.Background(), types.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+		Options: types.GenerationOptions{
+			Temperature: floatPtr(0),
+			Seed:        intPtr(42),
+			Stop:        []string{"a", "b", "c", "d", "e"},
+		},
+	})
+	require.NoError(t, err)
+
+	// A zero temperature must still be sent, not omitted
+	require.Contains(t, body, "temperature")
+	assert.InDelta(t, 0, body["temperature"], 1e-6)
+	assert.Equal(t, 42.0, body["seed"])
+	assert.Equal(t, []interface{}{"a", "b", "c", "d"}, body["stop"])
+}
@@ -0,0 +1,135 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/anthropic"
+	"go-llm-proxy/pkg/openai"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPNG is a base64-encoded 1x1 PNG image
+const testPNG = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg=="
+
+// TestParseImage tests decoding of raw base64 images and data URLs
+func TestParseImage(t *testing.T) {
+	image, err := types.ParseImage(testPNG)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", image.MediaType)
+	assert.Equal(t, testPNG, image.Data)
+	assert.Equal(t, "data:image/png;base64,"+testPNG, image.DataURL())
+
+	fromURL, err := types.ParseImage("data:image/png;base64," + testPNG)
+	require.NoError(t, err)
+	assert.Equal(t, image, fromURL)
+
+	_, err = types.ParseImage("not base64!")
+	assert.Error(t, err)
+
+	// Valid base64 that isn't an image
+	_, err = types.ParseImage("aGVsbG8gd29ybGQ=")
+	assert.ErrorContains(t, err, "unsupported image type")
+}
+
+// TestValidateImages tests that images are only accepted by vision-capable models
+func TestValidateImages(t *testing.T) {
+	messages := []types.ChatMessage{{Role: "user", Content: "What is this?", Images: []string{testPNG}}}
+
+	err := types.ValidateImages(types.ModelConfig{Name: "gpt-4"}, messages)
+	assert.ErrorContains(t, err, "does not support image input")
+
+	assert.NoError(t, types.ValidateImages(types.ModelConfig{Name: "gpt-4o", Vision: true}, messages))
+	assert.NoError(t, types.ValidateImages(types.ModelConfig{Name: "gpt-4"}, []types.ChatMessage{{Role: "user", Content: "Hi"}}))
+
+	messages[0].Images = []string{"aGVsbG8gd29ybGQ="}
+	assert.Error(t, types.ValidateImages(types.ModelConfig{Name: "gpt-4o", Vision: true}, messages))
+}
+
+// TestAnthropicImageBlocks tests that images are sent as base64 image blocks ahead of the text
+func TestAnthropicImageBlocks(t *testing.T) {
+	var body struct {
+		Messages []struct {
+			Content []struct {
+				Type   string `json:"type"`
+				Text   string `json:"text"`
+				Source struct {
+					Type      string `json:"type"`
+					MediaType string `json:"media_type"`
+					Data      string `json:"data"`
+				} `json:"source"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","content":[{"type":"text","text":"A pixel."}],"usage":{"input_tokens":10,"output_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	backend := anthropic.NewAnthropicBackendWithBaseURL("test-key", server.URL)
+	resp, err := backend.Generate(context.Background(), types.GenerateRequest{
+		Model:     "claude-test",
+		Prompt:    "What is this?",
+		Images:    []string{testPNG},
+		MaxTokens: 100,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "A pixel.", resp.Content)
+
+	require.Len(t, body.Messages, 1)
+	content := body.Messages[0].Content
+	require.Len(t, content, 2)
+	assert.Equal(t, "image", content[0].Type)
+	assert.Equal(t, "base64", content[0].Source.Type)
+	assert.Equal(t, "image/png", content[0].Source.MediaType)
+	assert.Equal(t, testPNG, content[0].Source.Data)
+	assert.Equal(t, "text", content[1].Type)
+	assert.Equal(t, "What is this?", content[1].Text)
+}
+
+// TestOpenAIImageParts tests that images are sent as image_url content parts with data URLs
+func TestOpenAIImageParts(t *testing.T) {
+	var body struct {
+		Messages []struct {
+			Content []struct {
+				Type     string `json:"type"`
+				Text     string `json:"text"`
+				ImageURL struct {
+					URL string `json:"url"`
+				} `json:"image_url"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","created":1,"choices":[{"index":0,"message":{"role":"assistant","content":"A pixel."},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	backend := openai.NewOpenAIBackendWithBaseURL("test-key", server.URL)
+	resp, err := backend.Chat(context.Background(), types.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []types.ChatMessage{{Role: "user", Content: "What is this?", Images: []string{testPNG}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "A pixel.", resp.Message.Content)
+
+	require.Len(t, body.Messages, 1)
+	content := body.Messages[0].Content
+	require.Len(t, content, 2)
+	assert.Equal(t, "text", content[0].Type)
+	assert.Equal(t, "What is this?", content[0].Text)
+	assert.Equal(t, "image_url", content[1].Type)
+	assert.Equal(t, "data:image/png;base64,"+testPNG, content[1].ImageURL.URL)
+}
@@ -0,0 +1,184 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go-llm-proxy/internal/keys"
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/anthropic"
+	"go-llm-proxy/pkg/openai"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestKeyPoolSelection tests the order in which keys are handed out
+func TestKeyPoolSelection(t *testing.T) {
+	t.Run("RoundRobin", func(t *testing.T) {
+		pool := keys.NewPool([]string{"key-a", "key-b", "", "key-a", "key-c"}, keys.RoundRobin)
+		require.Equal(t, 3, pool.Len(), "empty and repeated keys are skipped")
+
+		var picked []string
+		for i := 0; i < 4; i++ {
+			lease, err := pool.Acquire()
+			require.NoError(t, err)
+			picked = append(picked, lease.Key())
+			lease.Release()
+		}
+		assert.Equal(t, []string{"key-a", "key-b", "key-c", "key-a"}, picked)
+	})
+
+	t.Run("LeastUsed", func(t *testing.T) {
+		pool := keys.NewPool([]string{"key-a", "key-b"}, keys.LeastUsed)
+
+		first, err := pool.Acquire()
+		require.NoError(t, err)
+		second, err := pool.Acquire()
+		require.NoError(t, err)
+		assert.NotEqual(t, first.Key(), second.Key(), "a key with a request in flight is used last")
+
+		second.Release()
+		third, err := pool.Acquire()
+		require.NoError(t, err)
+		assert.Equal(t, second.Key(), third.Key())
+	})
+
+	t.Run("SkipsQuarantinedKeys", func(t *testing.T) {
+		pool := keys.NewPool([]string{"key-a", "key-b"}, keys.RoundRobin)
+
+		lease, err := pool.Acquire()
+		require.NoError(t, err)
+		lease.Failed("401 Unauthorized", keys.InvalidKeyQuarantine, false)
+		lease.Release()
+
+		for i := 0; i < 3; i++ {
+			next, err := pool.Acquire()
+			require.NoError(t, err)
+			assert.Equal(t, "key-b", next.Key())
+			next.Release()
+		}
+	})
+
+	t.Run("AllQuarantined", func(t *testing.T) {
+		pool := keys.NewPool([]string{"key-a", "key-b"}, keys.RoundRobin)
+		for _, cooldown := range []time.Duration{time.Minute, time.Second} {
+			lease, err := pool.Acquire()
+			require.NoError(t, err)
+			lease.Failed("429 Too Many Requests", cooldown, true)
+			lease.Release()
+		}
+
+		// The key released soonest still serves requests
+		lease, err := pool.Acquire()
+		require.NoError(t, err)
+		assert.Equal(t, "key-b", lease.Key())
+	})
+
+	t.Run("NoKeys", func(t *testing.T) {
+		_, err := keys.NewPool(nil, keys.RoundRobin).Acquire()
+		assert.Error(t, err)
+	})
+
+	t.Run("ParseStrategy", func(t *testing.T) {
+		strategy, err := keys.ParseStrategy("")
+		require.NoError(t, err)
+		assert.Equal(t, keys.RoundRobin, strategy)
+
+		_, err = keys.ParseStrategy("random")
+		assert.Error(t, err)
+	})
+}
+
+// TestKeyPoolQuarantine tests that keys refused by the upstream are set aside
+// and that requests move on to the other keys
+func TestKeyPoolQuarantine(t *testing.T) {
+	var mu sync.Mutex
+	used := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("x-api-key")
+		mu.Lock()
+		used[key]++
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch key {
+		case "sk-ant-revoked-0001":
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`))
+		case "sk-ant-drained-0002":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"invalid_request_error","message":"Your credit balance is too low"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"id":"msg_1","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":3,"output_tokens":1}}`))
+		}
+	}))
+	defer server.Close()
+
+	backend := anthropic.NewAnthropicBackendWithBaseURL("", server.URL)
+	backend.SetRetryPolicy(retry.Policy{MaxAttempts: 1})
+	backend.SetKeyPool(keys.NewPool([]string{"sk-ant-revoked-0001", "sk-ant-drained-0002", "sk-ant-working-0003"}, keys.RoundRobin))
+	require.True(t, backend.IsAvailable())
+
+	req := types.ChatRequest{Model: "claude-test", Messages: []types.ChatMessage{{Role: "user", Content: "Hi"}}, MaxTokens: 10}
+	var failures int
+	for i := 0; i < 6; i++ {
+		if _, err := backend.Chat(context.Background(), req); err != nil {
+			failures++
+		}
+	}
+
+	// Each bad key fails once, then every request goes to the working key
+	assert.Equal(t, 2, failures)
+	assert.Equal(t, 1, used["sk-ant-revoked-0001"])
+	assert.Equal(t, 1, used["sk-ant-drained-0002"])
+	assert.Equal(t, 4, used["sk-ant-working-0003"])
+
+	stats := backend.KeyStats()
+	require.Len(t, stats, 3)
+	assert.Equal(t, "...0001", stats[0].Key, "keys are masked")
+	assert.True(t, stats[0].Quarantined)
+	assert.Equal(t, int64(1), stats[0].Failures)
+	assert.True(t, stats[1].Quarantined)
+	assert.Contains(t, stats[1].LastError, "quota exhausted")
+	assert.False(t, stats[2].Quarantined)
+	assert.Equal(t, int64(4), stats[2].Requests)
+	assert.Equal(t, 0, stats[2].InFlight)
+}
+
+// TestKeyPoolRetriesWithAnotherKey tests that a rate-limited request is retried with the next key
+func TestKeyPoolRetriesWithAnotherKey(t *testing.T) {
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") == "Bearer sk-limited-0001" {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"type":"requests","message":"Rate limit reached"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","created":1,"choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	backend := openai.NewOpenAIBackendWithBaseURL("", server.URL)
+	backend.SetRetryPolicy(fastRetryPolicy())
+	backend.SetKeyPool(keys.NewPool([]string{"sk-limited-0001", "sk-spare-0002"}, keys.RoundRobin))
+
+	resp, err := backend.Chat(context.Background(), types.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []types.ChatMessage{{Role: "user", Content: "Hi"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", resp.Message.Content)
+	assert.Equal(t, []string{"Bearer sk-limited-0001", "Bearer sk-spare-0002"}, authorizations)
+
+	stats := backend.KeyStats()
+	assert.Equal(t, int64(1), stats[0].RateLimited)
+}
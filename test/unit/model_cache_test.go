@@ -0,0 +1,116 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"go-llm-proxy/internal/config"
+	"go-llm-proxy/internal/fetcher"
+	"go-llm-proxy/internal/models"
+	"go-llm-proxy/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCatalogServer serves OpenAI's model list until it is taken offline
+func newCatalogServer(t *testing.T) (*httptest.Server, *atomic.Bool) {
+	offline := &atomic.Bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if offline.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"gpt-4o","object":"model"},{"id":"gpt-4o-mini","object":"model"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, offline
+}
+
+// TestModelCatalogCache tests starting from the cached model lists when the backends can't be reached
+func TestModelCatalogCache(t *testing.T) {
+	server, offline := newCatalogServer(t)
+	apiClient := fetcher.NewAPIClientWithBaseURLs(server.URL, server.URL, server.URL)
+
+	cfg := config.Default()
+	cfg.OpenAIAPIKey = "test-key"
+	cfg.ModelCachePath = filepath.Join(t.TempDir(), "cache", "models.json")
+	cfg.EmbeddingModels = nil
+
+	// A successful fetch fills the cache
+	fetched, err := fetcher.NewModelFetcherWithAPIClient(cfg, apiClient).FetchAllModels(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, fetched, 2)
+	assert.FileExists(t, cfg.ModelCachePath)
+
+	t.Run("ServesCachedModels", func(t *testing.T) {
+		offline.Store(true)
+		defer offline.Store(false)
+
+		registry, err := models.NewModelRegistryFromSource(fetcher.NewModelFetcherWithAPIClient(cfg, apiClient), newRefreshManager())
+		require.NoError(t, err, "the proxy starts from the cache")
+		_, exists := registry.GetModel("gpt-4o-mini")
+		assert.True(t, exists)
+
+		status := registry.Status()
+		assert.True(t, status.Degraded)
+		assert.Contains(t, status.Error, "serving the model list cached at")
+		assert.Nil(t, status.LastFetched)
+
+		// The background retry recovers once the backend is back
+		offline.Store(false)
+		require.NoError(t, registry.Refresh(context.Background()))
+		assert.False(t, registry.Status().Degraded)
+		assert.NotNil(t, registry.Status().LastFetched)
+	})
+
+	t.Run("CurrentFiltersApplyToCachedModels", func(t *testing.T) {
+		offline.Store(true)
+		defer offline.Store(false)
+
+		filtered := *cfg
+		filtered.ModelFilters.OpenAI.ExcludePatterns = []string{"*mini*"}
+		models, err := fetcher.NewModelFetcherWithAPIClient(&filtered, apiClient).FetchAllModels(context.Background())
+
+		var partial *fetcher.PartialError
+		require.ErrorAs(t, err, &partial)
+		var stale *fetcher.StaleError
+		assert.ErrorAs(t, partial.Failures[types.BackendOpenAI], &stale)
+		require.Len(t, models, 1)
+		assert.Equal(t, "gpt-4o", models[0].Name)
+	})
+
+	t.Run("NoCache", func(t *testing.T) {
+		offline.Store(true)
+		defer offline.Store(false)
+
+		uncached := *cfg
+		uncached.ModelCachePath = filepath.Join(t.TempDir(), "missing.json")
+		_, err := models.NewModelRegistryFromSource(fetcher.NewModelFetcherWithAPIClient(&uncached, apiClient), newRefreshManager())
+		assert.ErrorContains(t, err, "no models could be fetched")
+	})
+
+	t.Run("StaticModelsNeedNoFetch", func(t *testing.T) {
+		offline.Store(true)
+		defer offline.Store(false)
+
+		static := *cfg
+		static.ModelCachePath = ""
+		static.EmbeddingModels = []config.EmbeddingModelConfig{{Name: "nomic-embed-text", Backend: "openai", BackendModel: "text-embedding-3-small"}}
+		static.Models = []config.VirtualModelConfig{
+			{Name: "llama3", Backend: "openai", BackendModel: "gpt-4o"},
+			{Name: "mistral", Target: "gpt-4o-mini"},
+		}
+
+		registry, err := models.NewModelRegistryFromSource(fetcher.NewModelFetcherWithAPIClient(&static, apiClient), newRefreshManager())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"nomic-embed-text", "llama3"}, modelNames(registry),
+			"models declared by backend and backend_model are served; those targeting a fetched model aren't")
+		assert.True(t, registry.Status().Degraded)
+	})
+}
@@ -329,6 +329,20 @@ func (m *MockBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.C
 	}, nil
 }
 
+func (m *MockBackend) GenerateStream(ctx context.Context, req types.GenerateRequest, onChunk types.StreamCallback) error {
+	if err := onChunk(types.StreamChunk{Content: "Mock response"}); err != nil {
+		return err
+	}
+	return onChunk(types.StreamChunk{Done: true})
+}
+
+func (m *MockBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	if err := onChunk(types.StreamChunk{Content: "Mock response"}); err != nil {
+		return err
+	}
+	return onChunk(types.StreamChunk{Done: true})
+}
+
 func (m *MockBackend) IsAvailable() bool {
 	return m.available
 }
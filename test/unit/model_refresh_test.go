@@ -0,0 +1,159 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go-llm-proxy/internal/backend"
+	"go-llm-proxy/internal/fetcher"
+	"go-llm-proxy/internal/models"
+	"go-llm-proxy/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// StubModelSource returns a scripted model list or error for each fetch
+type StubModelSource struct {
+	mu      sync.Mutex
+	results []stubFetch
+	fetches int
+}
+
+type stubFetch struct {
+	names []string
+	err   error
+}
+
+func (s *StubModelSource) FetchAllModels(ctx context.Context) ([]types.ModelConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := s.results[min(s.fetches, len(s.results)-1)]
+	s.fetches++
+
+	var modelConfigs []types.ModelConfig
+	for _, name := range result.names {
+		modelConfigs = append(modelConfigs, types.ModelConfig{Name: name, Backend: types.BackendOpenAI, BackendModel: name, Enabled: true})
+	}
+	return modelConfigs, result.err
+}
+
+func (s *StubModelSource) Fetches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+// newRefreshManager creates a backend manager with OpenAI available
+func newRefreshManager() *backend.BackendManager {
+	manager := backend.NewBackendManager()
+	manager.RegisterBackend(types.BackendOpenAI, &MockBackend{name: "openai", available: true})
+	return manager
+}
+
+// modelNames returns the names of the registry's models
+func modelNames(registry *models.ModelRegistry) []string {
+	var names []string
+	for _, model := range registry.GetAllModels() {
+		names = append(names, model.Name)
+	}
+	return names
+}
+
+// TestModelRegistryRefresh tests replacing the registry's models with a fresh fetch
+func TestModelRegistryRefresh(t *testing.T) {
+	partial := &fetcher.PartialError{Failures: map[types.BackendType]error{types.BackendAnthropic: errors.New("503 Service Unavailable")}}
+	source := &StubModelSource{results: []stubFetch{
+		{names: []string{"gpt-4o", "gpt-4o-mini"}},
+		{names: []string{"gpt-4o", "gpt-4.1"}},
+		{err: errors.New("no models could be fetched from any backend")},
+		{names: []string{"gpt-4o"}, err: partial},
+	}}
+
+	registry, err := models.NewModelRegistryFromSource(source, newRefreshManager())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"gpt-4o", "gpt-4o-mini"}, modelNames(registry))
+
+	require.NoError(t, registry.Refresh(context.Background()))
+	assert.ElementsMatch(t, []string{"gpt-4o", "gpt-4.1"}, modelNames(registry), "added and removed models are applied")
+
+	assert.Error(t, registry.Refresh(context.Background()))
+	assert.ElementsMatch(t, []string{"gpt-4o", "gpt-4.1"}, modelNames(registry), "a failed refresh keeps the last good models")
+
+	err = registry.Refresh(context.Background())
+	assert.ErrorAs(t, err, &partial)
+	assert.ElementsMatch(t, []string{"gpt-4o", "gpt-4.1"}, modelNames(registry), "a refresh with a failing backend keeps the last good models")
+}
+
+// TestModelRegistryPartialStartup tests that a backend failing at startup doesn't prevent the others from serving
+func TestModelRegistryPartialStartup(t *testing.T) {
+	source := &StubModelSource{results: []stubFetch{{
+		names: []string{"gpt-4o"},
+		err:   &fetcher.PartialError{Failures: map[types.BackendType]error{types.BackendAnthropic: errors.New("timeout")}},
+	}}}
+
+	registry, err := models.NewModelRegistryFromSource(source, newRefreshManager())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gpt-4o"}, modelNames(registry))
+
+	_, err = models.NewModelRegistryFromSource(&StubModelSource{results: []stubFetch{{err: errors.New("no models")}}}, newRefreshManager())
+	assert.Error(t, err)
+}
+
+// TestModelRegistryBackgroundRefresh tests the periodic refresh and stopping it
+func TestModelRegistryBackgroundRefresh(t *testing.T) {
+	source := &StubModelSource{results: []stubFetch{{names: []string{"gpt-4o"}}, {names: []string{"gpt-4o", "gpt-4.1"}}}}
+	registry, err := models.NewModelRegistryFromSource(source, newRefreshManager())
+	require.NoError(t, err)
+
+	registry.StartRefresh(10*time.Millisecond, 10*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		_, exists := registry.GetModel("gpt-4.1")
+		return exists
+	}, time.Second, 5*time.Millisecond)
+
+	registry.Stop()
+	registry.Stop()
+	time.Sleep(20 * time.Millisecond)
+	fetches := source.Fetches()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, fetches, source.Fetches(), "no refresh runs once stopped")
+}
+
+// TestModelRegistryConcurrentAccess tests reads, writes and refreshes from many goroutines; run with -race
+func TestModelRegistryConcurrentAccess(t *testing.T) {
+	source := &StubModelSource{results: []stubFetch{{names: []string{"gpt-4o", "gpt-4o-mini"}}}}
+	registry, err := models.NewModelRegistryFromSource(source, newRefreshManager())
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("custom-%d", i)
+			for j := 0; j < 100; j++ {
+				registry.AddModel(types.ModelConfig{Name: name, Backend: types.BackendOpenAI, Enabled: true})
+				registry.DisableModel(name)
+				registry.EnableModel(name)
+				_, _ = registry.GetModel("gpt-4o")
+				_, _ = registry.ResolveModel("gpt-4o-mini")
+				_ = registry.GetAllModels()
+				_ = registry.GetModelsByBackend(types.BackendOpenAI)
+				registry.RemoveModel(name)
+				if j%25 == 0 {
+					_ = registry.Refresh(context.Background())
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	_, exists := registry.GetModel("gpt-4o")
+	assert.True(t, exists)
+}
@@ -0,0 +1,404 @@
+package llmproxy_unit_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-llm-proxy/internal/config"
+	"go-llm-proxy/internal/proxy"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/ollama"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upstreamOllama stands in for an Ollama server, recording the requests it receives
+type upstreamOllama struct {
+	*httptest.Server
+	mu       sync.Mutex
+	models   []string
+	requests map[string]map[string]interface{}
+}
+
+func newUpstreamOllama(t *testing.T) *upstreamOllama {
+	server := &upstreamOllama{
+		models:   []string{"llama3.2:latest", "llava:7b", "nomic-embed-text:latest", "qwen2.5-coder:32b"},
+		requests: make(map[string]map[string]interface{}),
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		server.mu.Lock()
+		server.requests[r.Method+" "+r.URL.Path] = body
+		server.mu.Unlock()
+
+		model, _ := body["model"].(string)
+		if name, ok := body["name"].(string); ok && model == "" {
+			model = name
+		}
+		stream := body["stream"] != false
+		if (r.URL.Path == "/api/chat" || r.URL.Path == "/api/generate" || r.URL.Path == "/api/embed" || r.URL.Path == "/api/show") && !server.has(model) {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("model %q not found, try pulling it first", model)})
+			return
+		}
+
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/tags":
+			_ = json.NewEncoder(w).Encode(server.tags())
+		case "POST /api/chat":
+			tools := body["tools"] != nil
+			switch {
+			case stream && tools:
+				writeNDJSON(w,
+					`{"model":%q,"created_at":"2025-06-01T10:00:00Z","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Paris"}}}]},"done":false}`,
+					`{"model":%q,"created_at":"2025-06-01T10:00:01Z","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":80,"eval_count":12}`)
+			case stream:
+				writeNDJSON(w,
+					`{"model":%q,"created_at":"2025-06-01T10:00:00Z","message":{"role":"assistant","content":"Hello"},"done":false}`,
+					`{"model":%q,"created_at":"2025-06-01T10:00:00Z","message":{"role":"assistant","content":" there"},"done":false}`,
+					`{"model":%q,"created_at":"2025-06-01T10:00:01Z","message":{"role":"assistant","content":""},"done":true,"done_reason":"length","total_duration":5000,"prompt_eval_count":9,"eval_count":2}`)
+			default:
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				_, _ = fmt.Fprintf(w, `{"model":%q,"created_at":"2025-06-01T10:00:00Z","message":{"role":"assistant","content":"Hello there"},"done":true,"done_reason":"stop","prompt_eval_count":9,"eval_count":2}`, model)
+			}
+		case "POST /api/generate":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = fmt.Fprintf(w, `{"model":%q,"created_at":"2025-06-01T10:00:00Z","response":"Hi","done":true,"context":[1,2,3]}`, model)
+		case "POST /api/embed":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = fmt.Fprintf(w, `{"model":%q,"embeddings":[[0.1,0.2],[0.3,0.4]],"prompt_eval_count":4}`, model)
+		case "POST /api/show":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = w.Write([]byte(`{"modelfile":"FROM llama3.2","details":{"format":"gguf","family":"llama","parameter_size":"3.2B"},"capabilities":["completion","tools"]}`))
+		case "POST /api/pull":
+			server.mu.Lock()
+			server.models = append(server.models, body["model"].(string)+":latest")
+			server.mu.Unlock()
+			writeNDJSON(w, `{"status":"pulling manifest"}`, `{"status":"success"}`)
+		case "POST /api/stop":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = fmt.Fprintf(w, `{"status":"stopped","model":%q}`, model)
+		case "GET /api/ps":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = w.Write([]byte(`{"models":[{"name":"llama3.2:latest","model":"llama3.2:latest","size_vram":2019393189}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// writeNDJSON writes a stream of records, filling in the model of those that name one
+func writeNDJSON(w http.ResponseWriter, records ...string) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	for _, record := range records {
+		if strings.Contains(record, "%q") {
+			record = fmt.Sprintf(record, "llama3.2:latest")
+		}
+		_, _ = w.Write([]byte(record + "\n"))
+		w.(http.Flusher).Flush()
+	}
+}
+
+func (s *upstreamOllama) has(model string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range s.models {
+		if name == model {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *upstreamOllama) tags() types.OllamaTagsResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	details := map[string]*types.OllamaModelDetails{
+		"llava:7b":                {Format: "gguf", Family: "llama", Families: []string{"llama", "clip"}, ParameterSize: "7B", QuantizationLevel: "Q4_0"},
+		"nomic-embed-text:latest": {Format: "gguf", Family: "nomic-bert", Families: []string{"nomic-bert"}, ParameterSize: "137M", QuantizationLevel: "F16"},
+	}
+	var tags types.OllamaTagsResponse
+	for _, name := range s.models {
+		model := types.OllamaModel{Name: name, Model: name, ModifiedAt: "2025-05-30T08:00:00Z", Size: 2019393189, Digest: "a80c4f17acd5" + name}
+		model.Details = details[name]
+		if model.Details == nil {
+			model.Details = &types.OllamaModelDetails{Format: "gguf", Family: "llama", Families: []string{"llama"}, ParameterSize: "3.2B", QuantizationLevel: "Q4_K_M"}
+		}
+		tags.Models = append(tags.Models, model)
+	}
+	return tags
+}
+
+func (s *upstreamOllama) lastRequest(route string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[route]
+}
+
+// TestOllamaBackend tests serving the models of an upstream Ollama server alongside the other backends
+func TestOllamaBackend(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	upstream := newUpstreamOllama(t)
+
+	cfg := config.Default()
+	cfg.ModelCachePath = ""
+	cfg.EmbeddingModels = nil
+	cfg.Ollama = config.OllamaConfig{BaseURL: upstream.URL, ModelPrefix: "local/", ExcludePatterns: []string{"qwen2.5-coder:*"}}
+	cfg.Models = []config.VirtualModelConfig{{Name: "brief", Target: "local/llama3.2", System: "Answer in one sentence."}}
+	require.NoError(t, cfg.Validate())
+
+	server, err := proxy.BuildProxyServerV2(cfg)
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+	router := setupTestRouter(server)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Tags", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/tags", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var tags types.OllamaTagsResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tags))
+		byName := make(map[string]types.OllamaModel)
+		for _, model := range tags.Models {
+			byName[model.Name] = model
+		}
+		assert.Len(t, byName, 4, "excluded models aren't listed")
+		llava := byName["local/llava:7b"]
+		assert.Equal(t, int64(2019393189), llava.Size, "the size and digest reported upstream are kept")
+		assert.Equal(t, "a80c4f17acd5llava:7b", llava.Digest)
+		require.NotNil(t, llava.Details)
+		assert.Equal(t, "7B", llava.Details.ParameterSize)
+
+		model, ok := server.ModelRegistry.GetModel("local/llama3.2:latest")
+		require.True(t, ok, "the latest tag can be left out")
+		assert.Equal(t, types.BackendOllama, model.Backend)
+		assert.Equal(t, "llama3.2:latest", model.BackendModel)
+		model, _ = server.ModelRegistry.GetModel("local/llava:7b")
+		assert.True(t, model.Vision)
+		model, _ = server.ModelRegistry.GetModel("local/nomic-embed-text")
+		assert.True(t, model.Embedding)
+	})
+
+	t.Run("ChatStream", func(t *testing.T) {
+		w := post("/api/chat", `{"model":"local/llama3.2","messages":[{"role":"user","content":"Hi"}],"options":{"num_ctx":8192},"keep_alive":"10m"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+		sent := upstream.lastRequest("POST /api/chat")
+		assert.Equal(t, "llama3.2:latest", sent["model"], "the model is named as the server knows it")
+		assert.Equal(t, map[string]interface{}{"num_ctx": float64(8192)}, sent["options"], "options the proxy doesn't map are passed on")
+		assert.Equal(t, "10m", sent["keep_alive"])
+		assert.NotContains(t, sent, "stream", "the server's default of streaming applies")
+
+		var content strings.Builder
+		var records []types.OllamaChatResponse
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			var record types.OllamaChatResponse
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+			assert.Equal(t, "local/llama3.2", record.Model, "the client sees the name it asked for")
+			content.WriteString(record.Message.Content)
+			records = append(records, record)
+		}
+		require.Len(t, records, 3)
+		assert.Equal(t, "Hello there", content.String())
+		assert.Equal(t, int64(5000), records[2].TotalDuration, "the server's metrics are kept")
+		assert.Equal(t, "length", records[2].DoneReason)
+	})
+
+	t.Run("Generate", func(t *testing.T) {
+		w := post("/api/generate", `{"model":"local/llava:7b","prompt":"Hi","stream":false,"raw":true}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"model":"local/llava:7b","created_at":"2025-06-01T10:00:00Z","response":"Hi","done":true,"context":[1,2,3]}`, w.Body.String())
+		assert.Equal(t, true, upstream.lastRequest("POST /api/generate")["raw"])
+	})
+
+	t.Run("Embed", func(t *testing.T) {
+		w := post("/api/embed", `{"model":"local/nomic-embed-text","input":["a","b"],"truncate":false}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp types.OllamaEmbedResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, [][]float32{{0.1, 0.2}, {0.3, 0.4}}, resp.Embeddings)
+		assert.Equal(t, "nomic-embed-text:latest", upstream.lastRequest("POST /api/embed")["model"])
+	})
+
+	t.Run("UpstreamError", func(t *testing.T) {
+		server.ModelRegistry.AddModel(types.ModelConfig{Name: "local/gone", Backend: types.BackendOllama, BackendModel: "gone:latest", Enabled: true})
+		w := post("/api/chat", `{"model":"local/gone","messages":[{"role":"user","content":"Hi"}]}`)
+		assert.Equal(t, http.StatusNotFound, w.Code, "the server's status is passed on")
+		assert.JSONEq(t, `{"error":"model \"gone:latest\" not found, try pulling it first"}`, w.Body.String())
+	})
+
+	t.Run("ConvertedForVirtualModels", func(t *testing.T) {
+		w := post("/api/chat", `{"model":"brief","messages":[{"role":"user","content":"Hi"}],"stream":false}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp types.OllamaChatResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "brief", resp.Model)
+		assert.Equal(t, "Hello there", resp.Message.Content)
+
+		sent := upstream.lastRequest("POST /api/chat")
+		assert.Equal(t, "llama3.2:latest", sent["model"])
+		assert.Equal(t, false, sent["stream"])
+		messages := sent["messages"].([]interface{})
+		assert.Equal(t, "Answer in one sentence.", messages[0].(map[string]interface{})["content"], "the system prompt is added")
+	})
+
+	t.Run("OpenAIEndpoint", func(t *testing.T) {
+		w := post("/v1/chat/completions", `{"model":"local/llama3.2","messages":[{"role":"user","content":"Hi"}],"max_tokens":50}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Hello there")
+		options := upstream.lastRequest("POST /api/chat")["options"].(map[string]interface{})
+		assert.Equal(t, float64(50), options["num_predict"])
+	})
+
+	t.Run("Management", func(t *testing.T) {
+		w := post("/api/pull", `{"model":"mistral"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "{\"status\":\"pulling manifest\"}\n{\"status\":\"success\"}\n", w.Body.String())
+		assert.Eventually(t, func() bool {
+			_, ok := server.ModelRegistry.GetModel("local/mistral")
+			return ok
+		}, 5*time.Second, 10*time.Millisecond, "pulled models are served once the catalog is fetched again")
+
+		req := httptest.NewRequest("GET", "/api/ps", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"size_vram":2019393189`)
+
+		w = post("/api/stop", `{"model":"llama3.2:latest"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"status":"stopped","model":"llama3.2:latest"}`, w.Body.String(), "stop requests reach the server")
+	})
+
+	t.Run("Show", func(t *testing.T) {
+		w := post("/api/show", `{"model":"local/llava:7b","verbose":true}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"modelfile":"FROM llama3.2"`, "the server's description is passed on")
+		sent := upstream.lastRequest("POST /api/show")
+		assert.Equal(t, "llava:7b", sent["model"], "the model is named as the server knows it")
+		assert.Equal(t, true, sent["verbose"])
+
+		// Models the catalog leaves out are still described by the server
+		w = post("/api/show", `{"name":"qwen2.5-coder:32b"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "qwen2.5-coder:32b", upstream.lastRequest("POST /api/show")["name"])
+
+		w = post("/api/show", `{"model":"missing"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code, "the server's status is passed on")
+
+		w = post("/api/show", `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// TestOllamaBackendConversion tests serving chat requests from the other endpoints through /api/chat
+func TestOllamaBackendConversion(t *testing.T) {
+	upstream := newUpstreamOllama(t)
+	backend := ollama.NewOllamaBackend(upstream.URL + "/")
+
+	temperature := 0.2
+	req := types.ChatRequest{
+		Model:     "llama3.2:latest",
+		Messages:  []types.ChatMessage{{Role: "user", Content: "Weather in Paris?"}},
+		Tools:     []types.Tool{{Type: "function", Function: types.ToolFunction{Name: "get_weather"}}},
+		MaxTokens: 100,
+		Options:   types.GenerationOptions{Temperature: &temperature},
+		Format:    &types.ResponseFormat{},
+	}
+
+	t.Run("Chat", func(t *testing.T) {
+		resp, err := backend.Chat(context.Background(), req)
+		require.NoError(t, err)
+		assert.Equal(t, "Hello there", resp.Message.Content)
+		assert.Equal(t, &types.Usage{PromptTokens: 9, CompletionTokens: 2}, resp.Usage)
+
+		sent := upstream.lastRequest("POST /api/chat")
+		assert.Equal(t, "json", sent["format"])
+		assert.Equal(t, map[string]interface{}{"temperature": 0.2, "num_predict": float64(100)}, sent["options"])
+	})
+
+	t.Run("ChatStreamToolCalls", func(t *testing.T) {
+		var toolCalls []types.ToolCall
+		var final types.StreamChunk
+		err := backend.ChatStream(context.Background(), req, func(chunk types.StreamChunk) error {
+			toolCalls = append(toolCalls, chunk.ToolCalls...)
+			if chunk.Done {
+				final = chunk
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, toolCalls, 1)
+		assert.Equal(t, "call_0", toolCalls[0].ID, "calls get IDs, which Ollama doesn't assign")
+		assert.JSONEq(t, `{"city":"Paris"}`, string(toolCalls[0].Function.Arguments))
+		assert.Equal(t, &types.Usage{PromptTokens: 80, CompletionTokens: 12}, final.Usage)
+	})
+
+	t.Run("Embed", func(t *testing.T) {
+		resp, err := backend.Embed(context.Background(), types.EmbeddingRequest{Model: "nomic-embed-text:latest", Input: []string{"a", "b"}})
+		require.NoError(t, err)
+		assert.Len(t, resp.Embeddings, 2)
+		assert.Equal(t, 4, resp.Usage.PromptTokens)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		missing := req
+		missing.Model = "gone"
+		_, err := backend.Chat(context.Background(), missing)
+		assert.EqualError(t, err, `ollama API error: model "gone" not found, try pulling it first`)
+	})
+}
+
+// TestOllamaConfig tests validating the ollama section
+func TestOllamaConfig(t *testing.T) {
+	valid := config.Default()
+	valid.EmbeddingModels = nil
+	valid.Ollama = config.OllamaConfig{BaseURL: "http://localhost:11435"}
+	valid.Models = []config.VirtualModelConfig{{Name: "coder", Backend: "ollama", BackendModel: "qwen2.5-coder:7b"}}
+	assert.NoError(t, valid.Validate(), "an Ollama server is enough to start, and models can refer to it")
+
+	invalid := config.Default()
+	invalid.OpenAIAPIKey = "test-key"
+	invalid.Ollama = config.OllamaConfig{ModelPrefix: "local/", IncludePatterns: []string{"llama["}}
+	err := invalid.Validate()
+	assert.ErrorContains(t, err, "ollama: base_url must be an http or https URL")
+	assert.ErrorContains(t, err, "ollama: invalid pattern")
+
+	reserved := config.Default()
+	reserved.OpenAICompatible = []config.OpenAICompatibleConfig{{Name: "ollama", BaseURL: "http://localhost:11435/v1"}}
+	assert.ErrorContains(t, reserved.Validate(), "is reserved")
+
+	// Without an upstream server, model management stays with the backends
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/pull", bytes.NewBufferString(`{"model":"mistral"}`))
+	(&proxy.ProxyServerV2{BackendManager: newRefreshManager()}).HandlePull(c)
+	assert.Contains(t, w.Body.String(), "Models are managed by backends")
+}
@@ -0,0 +1,194 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/anthropic"
+	"go-llm-proxy/pkg/azure"
+	"go-llm-proxy/pkg/openai"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOllamaDoneReason tests the mapping of backend stop reasons to Ollama done reasons
+func TestOllamaDoneReason(t *testing.T) {
+	tests := map[string]string{
+		"end_turn":      "stop",
+		"stop_sequence": "stop",
+		"tool_use":      "stop",
+		"max_tokens":    "length",
+		"stop":          "stop",
+		"tool_calls":    "stop",
+		"length":        "length",
+		"":              "stop",
+	}
+	for reason, expected := range tests {
+		assert.Equal(t, expected, types.OllamaDoneReason(reason), reason)
+	}
+}
+
+// TestOllamaResponseUsage tests that usage and done reason reach the final Ollama record
+func TestOllamaResponseUsage(t *testing.T) {
+	usage := &types.Usage{PromptTokens: 12, CompletionTokens: 34}
+
+	chatResp := types.ConvertChatToOllamaResponse(&types.ChatResponse{
+		Message:    types.ChatMessage{Role: "assistant", Content: "Hi"},
+		Usage:      usage,
+		DoneReason: "length",
+	}, "gpt-4o")
+	assert.Equal(t, 12, chatResp.PromptEvalCount)
+	assert.Equal(t, 34, chatResp.EvalCount)
+	assert.Equal(t, "length", chatResp.DoneReason)
+
+	generateResp := types.ConvertGenerateToOllamaResponse(&types.GenerateResponse{
+		Content:    "Hi",
+		Usage:      usage,
+		DoneReason: "stop",
+	}, "gpt-4o")
+	assert.Equal(t, 12, generateResp.PromptEvalCount)
+	assert.Equal(t, 34, generateResp.EvalCount)
+	assert.Equal(t, "stop", generateResp.DoneReason)
+
+	// Responses without usage leave the counts out
+	emptyResp := types.ConvertGenerateToOllamaResponse(&types.GenerateResponse{Content: "Hi"}, "gpt-4o")
+	assert.Zero(t, emptyResp.EvalCount)
+}
+
+// TestBackendUsageAndDoneReason tests that both backends report usage and stop reasons
+func TestBackendUsageAndDoneReason(t *testing.T) {
+	t.Run("Anthropic", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"msg_1","content":[{"type":"text","text":"Hi"}],"stop_reason":"max_tokens","usage":{"input_tokens":10,"output_tokens":2}}`))
+		}))
+		defer server.Close()
+
+		backend := anthropic.NewAnthropicBackendWithBaseURL("test-key", server.URL)
+		resp, err := backend.Generate(context.Background(), types.GenerateRequest{Model: "claude-test", Prompt: "Hello", MaxTokens: 2})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Usage)
+		assert.Equal(t, 10, resp.Usage.PromptTokens)
+		assert.Equal(t, 2, resp.Usage.CompletionTokens)
+		assert.Equal(t, "length", resp.DoneReason)
+	})
+
+	t.Run("OpenAI", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"chatcmpl-1","created":1,"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":7,"completion_tokens":1}}`))
+		}))
+		defer server.Close()
+
+		backend := openai.NewOpenAIBackendWithBaseURL("test-key", server.URL)
+		resp, err := backend.Generate(context.Background(), types.GenerateRequest{Model: "gpt-4o", Prompt: "Hello"})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Usage)
+		assert.Equal(t, 7, resp.Usage.PromptTokens)
+		assert.Equal(t, 1, resp.Usage.CompletionTokens)
+		assert.Equal(t, "stop", resp.DoneReason)
+	})
+
+	t.Run("OpenAIStream", func(t *testing.T) {
+		reportUsage := true
+		var body map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+			fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"length\"}]}\n\n")
+			if reportUsage {
+				fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":1,\"total_tokens\":8}}\n\n")
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}))
+		defer server.Close()
+
+		backend := openai.NewOpenAIBackendWithBaseURL("test-key", server.URL)
+		stream := func() types.StreamChunk {
+			var last types.StreamChunk
+			err := backend.ChatStream(context.Background(), types.ChatRequest{
+				Model:    "gpt-4o",
+				Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+			}, func(chunk types.StreamChunk) error {
+				last = chunk
+				return nil
+			})
+			require.NoError(t, err)
+			return last
+		}
+
+		last := stream()
+		assert.Equal(t, map[string]interface{}{"include_usage": true}, body["stream_options"])
+		assert.True(t, last.Done)
+		assert.Equal(t, "length", last.DoneReason)
+		assert.Equal(t, &types.Usage{PromptTokens: 7, CompletionTokens: 1}, last.Usage, "the usage the server reports is passed on")
+
+		reportUsage = false
+		last = stream()
+		assert.True(t, last.Done)
+		assert.Nil(t, last.Usage, "counts the server doesn't report are left out rather than estimated")
+	})
+
+	t.Run("StreamOptions", func(t *testing.T) {
+		// The server rejects stream_options when rejectOptions is set, as strict servers do
+		rejectOptions := false
+		var bodies []map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			bodies = append(bodies, body)
+			if _, ok := body["stream_options"]; ok && rejectOptions {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error":{"message":"Unrecognized request argument supplied: stream_options"}}`))
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"},\"finish_reason\":\"stop\"}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+		}))
+		defer server.Close()
+
+		stream := func(backend *openai.OpenAIBackend) {
+			bodies = nil
+			err := backend.ChatStream(context.Background(), types.ChatRequest{
+				Model:    "llama3",
+				Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}},
+			}, func(types.StreamChunk) error { return nil })
+			require.NoError(t, err)
+		}
+
+		backend := openai.NewOpenAICompatibleBackend("vllm", server.URL, nil)
+		stream(backend)
+		require.Len(t, bodies, 1)
+		assert.NotContains(t, bodies[0], "stream_options", "compatible servers are only asked for usage when configured to be")
+
+		backend.SetStreamUsage(true)
+		stream(backend)
+		require.Len(t, bodies, 1)
+		assert.Contains(t, bodies[0], "stream_options")
+
+		rejectOptions = true
+		stream(backend)
+		require.Len(t, bodies, 2, "a rejected stream_options is retried without it")
+		assert.Contains(t, bodies[0], "stream_options")
+		assert.NotContains(t, bodies[1], "stream_options")
+
+		stream(backend)
+		require.Len(t, bodies, 1, "once rejected, stream_options is no longer sent")
+		assert.NotContains(t, bodies[0], "stream_options")
+		rejectOptions = false
+		stream(azure.NewAzureBackend("azure-key", server.URL, "2024-06-01").OpenAIBackend)
+		require.Len(t, bodies, 1)
+		assert.NotContains(t, bodies[0], "stream_options", "api-versions before stream_options don't get it")
+		stream(azure.NewAzureBackend("azure-key", server.URL, "").OpenAIBackend)
+		require.Len(t, bodies, 1)
+		assert.Contains(t, bodies[0], "stream_options")
+	})
+}
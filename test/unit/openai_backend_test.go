@@ -1,6 +1,11 @@
 package llmproxy_unit_test
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"go-llm-proxy/internal/types"
@@ -17,7 +22,7 @@ func TestOpenAIBackendMaxTokensHandling(t *testing.T) {
 	backend := openai.NewOpenAIBackend("test-key")
 	require.NotNil(t, backend)
 
-	t.Run("NewerModelsShouldNotUseMaxTokens", func(t *testing.T) {
+	t.Run("NewerModelsUseMaxCompletionTokens", func(t *testing.T) {
 		newerModels := []string{
 			"gpt-4o",
 			"gpt-4o-mini",
@@ -50,6 +55,105 @@ func TestOpenAIBackendMaxTokensHandling(t *testing.T) {
 	})
 }
 
+// TestOpenAIBackendMaxTokensRequest tests the token limit sent upstream for each kind of model
+func TestOpenAIBackendMaxTokensRequest(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		if body["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"},\"finish_reason\":\"length\"}]}\n\n")
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","created":1,"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"length"}]}`))
+	}))
+	defer server.Close()
+
+	backend := openai.NewOpenAIBackendWithBaseURL("test-key", server.URL)
+	request := func(model string, maxTokens int) types.ChatRequest {
+		return types.ChatRequest{Model: model, Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}}, MaxTokens: maxTokens}
+	}
+
+	tests := []struct {
+		model    string
+		field    string
+		excluded string
+	}{
+		{"gpt-4o", "max_completion_tokens", "max_tokens"},
+		{"gpt-4o-mini", "max_completion_tokens", "max_tokens"},
+		{"gpt-4o-2024-08-06", "max_completion_tokens", "max_tokens"},
+		{"gpt-4.1", "max_completion_tokens", "max_tokens"},
+		{"gpt-5", "max_completion_tokens", "max_tokens"},
+		{"o3-mini", "max_completion_tokens", "max_tokens"},
+		{"gpt-3.5-turbo", "max_tokens", "max_completion_tokens"},
+		{"gpt-4", "max_tokens", "max_completion_tokens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			resp, err := backend.Chat(context.Background(), request(tt.model, 1000))
+			require.NoError(t, err)
+			assert.Equal(t, float64(1000), body[tt.field])
+			assert.NotContains(t, body, tt.excluded)
+			assert.Equal(t, "length", resp.DoneReason)
+
+			err = backend.ChatStream(context.Background(), request(tt.model, 500), func(types.StreamChunk) error { return nil })
+			require.NoError(t, err)
+			assert.Equal(t, float64(500), body[tt.field], "streamed requests carry the limit too")
+			assert.NotContains(t, body, tt.excluded)
+		})
+	}
+
+	t.Run("NoLimit", func(t *testing.T) {
+		_, err := backend.Chat(context.Background(), request("gpt-4o", 0))
+		require.NoError(t, err)
+		assert.NotContains(t, body, "max_tokens")
+		assert.NotContains(t, body, "max_completion_tokens")
+	})
+}
+
+// TestOpenAIBackendSamplingRequest tests that explicit sampling values, zero included, are sent upstream
+func TestOpenAIBackendSamplingRequest(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","created":1,"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	backend := openai.NewOpenAIBackendWithBaseURL("test-key", server.URL)
+	request := func(opts types.GenerationOptions) types.ChatRequest {
+		return types.ChatRequest{Model: "gpt-4", Messages: []types.ChatMessage{{Role: "user", Content: "Hello"}}, Options: opts}
+	}
+	zero, half := 0.0, 0.5
+
+	t.Run("Zero", func(t *testing.T) {
+		_, err := backend.Chat(context.Background(), request(types.GenerationOptions{Temperature: &zero, TopP: &zero}))
+		require.NoError(t, err)
+		assert.Equal(t, float64(0), body["temperature"])
+		assert.Equal(t, float64(0), body["top_p"])
+	})
+
+	t.Run("NonZero", func(t *testing.T) {
+		_, err := backend.Chat(context.Background(), request(types.GenerationOptions{Temperature: &half, TopP: &half}))
+		require.NoError(t, err)
+		assert.Equal(t, 0.5, body["temperature"])
+		assert.Equal(t, 0.5, body["top_p"])
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		_, err := backend.Chat(context.Background(), request(types.GenerationOptions{}))
+		require.NoError(t, err)
+		assert.NotContains(t, body, "temperature")
+		assert.NotContains(t, body, "top_p")
+	})
+}
+
 // TestOpenAIBackendChatRequest tests chat request handling
 func TestOpenAIBackendChatRequest(t *testing.T) {
 	backend := openai.NewOpenAIBackend("test-key")
@@ -0,0 +1,157 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go-llm-proxy/internal/backend"
+	"go-llm-proxy/internal/config"
+	"go-llm-proxy/internal/fetcher"
+	"go-llm-proxy/internal/keys"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/openai"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compatibleServer is an OpenAI-compatible server that records the headers it receives
+type compatibleServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	headers []http.Header
+}
+
+func newCompatibleServer(t *testing.T) *compatibleServer {
+	server := &compatibleServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		server.headers = append(server.headers, r.Header.Clone())
+		server.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/models":
+			_, _ = w.Write([]byte(`{"object":"list","data":[
+				{"id":"meta-llama/Llama-3.1-8B-Instruct","object":"model","max_model_len":131072},
+				{"id":"Qwen/Qwen2.5-Coder-7B","object":"model"},
+				{"id":"BAAI/bge-m3","object":"model"}]}`))
+		case "/v1/chat/completions":
+			_, _ = w.Write([]byte(`{"id":"chatcmpl-1","created":1,"choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *compatibleServer) lastHeader() http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.headers[len(s.headers)-1]
+}
+
+// TestOpenAICompatibleBackends tests named backends that speak the OpenAI API at their own base URLs
+func TestOpenAICompatibleBackends(t *testing.T) {
+	vllm := newCompatibleServer(t)
+	openrouter := newCompatibleServer(t)
+
+	factory := backend.NewBackendFactoryWithKeys(nil, nil, keys.RoundRobin)
+	factory.AddOpenAICompatible("vllm", vllm.URL+"/v1", nil, nil, false)
+	factory.AddOpenAICompatible("openrouter", openrouter.URL+"/v1/", []string{"sk-or-0001"}, map[string]string{"HTTP-Referer": "https://example.com"}, false)
+	manager := factory.CreateBackends()
+	assert.ElementsMatch(t, []types.BackendType{"vllm", "openrouter"}, manager.GetAvailableBackends(), "backends without keys are available")
+
+	req := types.ChatRequest{Model: "Qwen/Qwen2.5-Coder-7B", Messages: []types.ChatMessage{{Role: "user", Content: "Hi"}}}
+
+	t.Run("Keyless", func(t *testing.T) {
+		resp, err := manager.ProcessRequest(context.Background(), types.ModelConfig{Name: "qwen", Backend: "vllm", BackendModel: "Qwen/Qwen2.5-Coder-7B"}, req)
+		require.NoError(t, err)
+		assert.Equal(t, "Hello", resp.(*types.ChatResponse).Message.Content)
+		assert.Empty(t, vllm.lastHeader().Get("Authorization"), "no empty bearer token is sent")
+
+		handler, _ := manager.GetBackend("vllm")
+		assert.Equal(t, "vllm", handler.GetName())
+	})
+
+	t.Run("KeyAndHeaders", func(t *testing.T) {
+		_, err := manager.ProcessRequest(context.Background(), types.ModelConfig{Name: "qwen", Backend: "openrouter", BackendModel: "Qwen/Qwen2.5-Coder-7B"}, req)
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer sk-or-0001", openrouter.lastHeader().Get("Authorization"))
+		assert.Equal(t, "https://example.com", openrouter.lastHeader().Get("HTTP-Referer"))
+	})
+
+	t.Run("NoChoices", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"chatcmpl-1","created":1,"choices":[]}`))
+		}))
+		defer server.Close()
+
+		_, err := openai.NewOpenAICompatibleBackend("lmstudio", server.URL+"/v1", nil).Chat(context.Background(), req)
+		assert.EqualError(t, err, "lmstudio API returned no choices")
+	})
+
+	t.Run("ModelListing", func(t *testing.T) {
+		cfg := &config.Config{
+			OpenAICompatible: []config.OpenAICompatibleConfig{
+				{Name: "vllm", BaseURL: vllm.URL + "/v1", ExcludePatterns: []string{"BAAI/*"}},
+				{Name: "openrouter", BaseURL: openrouter.URL + "/v1", APIKey: "sk-or-0001", ModelPrefix: "or/", IncludePatterns: []string{"meta-llama/*"}},
+			},
+			EmbeddingModels: []config.EmbeddingModelConfig{{Name: "bge-m3", Backend: "vllm", BackendModel: "BAAI/bge-m3"}},
+		}
+		fetched, err := fetcher.NewModelFetcher(cfg).FetchAllModels(context.Background())
+		require.NoError(t, err)
+
+		byName := make(map[string]types.ModelConfig)
+		for _, model := range fetched {
+			byName[model.Name] = model
+		}
+		assert.Len(t, byName, 4)
+
+		llama := byName["meta-llama/Llama-3.1-8B-Instruct"]
+		assert.Equal(t, types.BackendType("vllm"), llama.Backend)
+		assert.Equal(t, 131072, llama.MaxTokens, "the context size reported by the server is used")
+		assert.Equal(t, "llama", llama.Family)
+		assert.Contains(t, byName, "Qwen/Qwen2.5-Coder-7B")
+		assert.Contains(t, byName, "bge-m3")
+
+		prefixed := byName["or/meta-llama/Llama-3.1-8B-Instruct"]
+		assert.Equal(t, types.BackendType("openrouter"), prefixed.Backend)
+		assert.Equal(t, "meta-llama/Llama-3.1-8B-Instruct", prefixed.BackendModel)
+		assert.Equal(t, "Bearer sk-or-0001", openrouter.lastHeader().Get("Authorization"))
+	})
+}
+
+// TestOpenAICompatibleConfig tests validating the openai_compatible section
+func TestOpenAICompatibleConfig(t *testing.T) {
+	valid := config.Default()
+	valid.OpenAICompatible = []config.OpenAICompatibleConfig{{Name: "lmstudio", BaseURL: "http://localhost:1234/v1"}}
+	valid.Models = []config.VirtualModelConfig{{Name: "coder", Backend: "lmstudio", BackendModel: "qwen2.5-coder-7b"}}
+	valid.EmbeddingModels = nil
+	assert.NoError(t, valid.Validate(), "a keyless backend is enough to start, and models can refer to it")
+
+	invalid := map[string]config.OpenAICompatibleConfig{
+		"is reserved":                           {Name: "openai", BaseURL: "http://localhost:8000/v1"},
+		"must be lower-case letters":            {Name: "LM Studio", BaseURL: "http://localhost:1234/v1"},
+		"base_url must be an http or https URL": {Name: "vllm", BaseURL: "localhost:8000"},
+		"invalid pattern":                       {Name: "groq", BaseURL: "https://api.groq.com/openai/v1", IncludePatterns: []string{"llama-["}},
+	}
+	for problem, backend := range invalid {
+		cfg := config.Default()
+		cfg.OpenAIAPIKey = "test-key"
+		cfg.OpenAICompatible = []config.OpenAICompatibleConfig{backend}
+		assert.ErrorContains(t, cfg.Validate(), problem)
+	}
+
+	duplicate := config.Default()
+	duplicate.OpenAICompatible = []config.OpenAICompatibleConfig{
+		{Name: "vllm", BaseURL: "http://gpu-1:8000/v1"},
+		{Name: "vllm", BaseURL: "http://gpu-2:8000/v1"},
+	}
+	assert.ErrorContains(t, duplicate.Validate(), "more than once")
+}
@@ -4,7 +4,6 @@ import (
 	"os"
 	"testing"
 
-	"go-llm-proxy/internal/proxy"
 	"go-llm-proxy/internal/types"
 	"go-llm-proxy/test/helpers"
 
@@ -20,7 +19,7 @@ func TestProxyServerV2Creation(t *testing.T) {
 		t.Skip("Skipping TestProxyServerV2Creation: No API keys available (proxy now fails fast without keys)")
 	}
 
-	proxy := proxy.NewProxyServerV2()
+	proxy := newTestProxyServer(t)
 
 	assert.NotNil(t, proxy)
 	assert.NotNil(t, proxy.Config)
@@ -0,0 +1,213 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-llm-proxy/internal/backend"
+	"go-llm-proxy/internal/config"
+	"go-llm-proxy/internal/proxy"
+	"go-llm-proxy/internal/streaming"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/test/helpers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReloadTestServer creates a proxy server with a mock backend, identified by its port
+func newReloadTestServer(port string) *proxy.ProxyServerV2 {
+	manager := backend.NewBackendManager()
+	manager.RegisterBackend(types.BackendOpenAI, &MockBackend{name: "openai", available: true})
+	registry := helpers.CreateTestModelRegistry()
+
+	return &proxy.ProxyServerV2{
+		Config:           &config.Config{Port: port, GinMode: "test"},
+		ModelRegistry:    registry,
+		BackendManager:   manager,
+		StreamingHandler: streaming.NewStreamingHandler(manager, registry),
+	}
+}
+
+// touchUntil keeps moving the modification time of the file at path forward until condition
+// holds, so that a watcher notices a change whenever it starts comparing
+func touchUntil(t *testing.T, path string, condition func() bool, msg string) {
+	later := time.Now().Add(time.Minute)
+	assert.Eventually(t, func() bool {
+		later = later.Add(time.Second)
+		if err := os.Chtimes(path, later, later); err != nil {
+			return false
+		}
+		return condition()
+	}, 10*time.Second, 50*time.Millisecond, msg)
+}
+
+// TestReloader tests swapping the proxy server when the configuration is reloaded
+func TestReloader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("SwapsServer", func(t *testing.T) {
+		initial, next := newReloadTestServer("1"), newReloadTestServer("2")
+		reloader := proxy.NewReloader(initial, func() (*proxy.ProxyServerV2, error) { return next, nil })
+
+		router := gin.New()
+		router.POST("/admin/reload", reloader.HandleReload)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/admin/reload", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"reloaded"`)
+		assert.Same(t, next, reloader.Current())
+
+		status := reloader.Status()
+		assert.Equal(t, 1, status.Reloads)
+		assert.NotNil(t, status.LastSuccess)
+		assert.Empty(t, status.LastError)
+	})
+
+	t.Run("FailedReloadKeepsServer", func(t *testing.T) {
+		initial := newReloadTestServer("1")
+		reloader := proxy.NewReloader(initial, func() (*proxy.ProxyServerV2, error) {
+			return nil, errors.New("invalid configuration:\nport must be a number between 1 and 65535")
+		})
+
+		router := gin.New()
+		router.POST("/admin/reload", reloader.HandleReload)
+		router.GET("/status", reloader.HandleStatus)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/admin/reload", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "port must be a number")
+		assert.Same(t, initial, reloader.Current())
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+		assert.Contains(t, w.Body.String(), `"failures":1`)
+		assert.Contains(t, w.Body.String(), "port must be a number")
+	})
+
+	t.Run("InFlightRequestsKeepTheirServer", func(t *testing.T) {
+		reloader := proxy.NewReloader(newReloadTestServer("1"), func() (*proxy.ProxyServerV2, error) {
+			return newReloadTestServer("2"), nil
+		})
+
+		started, release := make(chan struct{}, 1), make(chan struct{})
+		router := gin.New()
+		router.GET("/port", reloader.Handle(func(p *proxy.ProxyServerV2, c *gin.Context) {
+			if c.Query("wait") != "" {
+				started <- struct{}{}
+				<-release
+			}
+			c.String(200, p.Config.Port)
+		}))
+
+		inFlight := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			router.ServeHTTP(inFlight, httptest.NewRequest("GET", "/port?wait=1", nil))
+			close(done)
+		}()
+		<-started
+
+		require.NoError(t, reloader.Reload())
+		close(release)
+		<-done
+		assert.Equal(t, "1", inFlight.Body.String(), "a request finishes on the server it started on")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/port", nil))
+		assert.Equal(t, "2", w.Body.String(), "new requests use the reloaded server")
+	})
+
+	t.Run("WatchFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("port: \"1\"\n"), 0o600))
+
+		initial := newReloadTestServer("1")
+		initial.Config.Path = path
+		initial.Config.ConfigWatch = 1
+
+		reloader := proxy.NewReloader(initial, func() (*proxy.ProxyServerV2, error) {
+			server := newReloadTestServer("2")
+			server.Config.Path = path
+			return server, nil
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go reloader.WatchFile(ctx)
+
+		touchUntil(t, path, func() bool { return reloader.Current().Config.Port == "2" },
+			"the changed configuration file wasn't reloaded")
+	})
+
+	t.Run("RequireAdmin", func(t *testing.T) {
+		server := newReloadTestServer("1")
+		reloader := proxy.NewReloader(server, func() (*proxy.ProxyServerV2, error) { return server, nil })
+		router := gin.New()
+		router.POST("/admin/reload", reloader.RequireAdmin, reloader.HandleReload)
+
+		reload := func(remoteAddr, authorization string) int {
+			req := httptest.NewRequest("POST", "/admin/reload", nil)
+			req.RemoteAddr = remoteAddr
+			req.Header.Set("X-Forwarded-For", "127.0.0.1")
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		// Without a token only local requests are accepted, whatever the forwarding headers say
+		assert.Equal(t, http.StatusOK, reload("127.0.0.1:40000", ""))
+		assert.Equal(t, http.StatusOK, reload("[::1]:40000", ""))
+		assert.Equal(t, http.StatusForbidden, reload("192.0.2.1:40000", ""))
+
+		// With a token it is required from everywhere
+		server.Config.AdminToken = "secret"
+		assert.Equal(t, http.StatusOK, reload("192.0.2.1:40000", "Bearer secret"))
+		assert.Equal(t, http.StatusUnauthorized, reload("127.0.0.1:40000", ""))
+		assert.Equal(t, http.StatusUnauthorized, reload("192.0.2.1:40000", "Bearer wrong"))
+		assert.Equal(t, http.StatusUnauthorized, reload("192.0.2.1:40000", "secret"))
+	})
+
+	t.Run("WatchFileStartsOnReload", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("port: \"1\"\n"), 0o600))
+
+		// Watching is off at startup and turned on by the first reload
+		initial := newReloadTestServer("1")
+		initial.Config.Path = path
+
+		// The first reload, made by hand, serves port 2, and those the watcher makes port 3
+		var loads atomic.Int32
+		reloader := proxy.NewReloader(initial, func() (*proxy.ProxyServerV2, error) {
+			port := "3"
+			if loads.Add(1) == 1 {
+				port = "2"
+			}
+			server := newReloadTestServer(port)
+			server.Config.Path = path
+			server.Config.ConfigWatch = 1
+			return server, nil
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go reloader.WatchFile(ctx)
+
+		require.NoError(t, reloader.Reload())
+		require.Equal(t, "2", reloader.Current().Config.Port)
+
+		touchUntil(t, path, func() bool { return reloader.Current().Config.Port == "3" },
+			"the changed configuration file wasn't reloaded")
+	})
+}
@@ -0,0 +1,91 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-llm-proxy/internal/backend"
+	"go-llm-proxy/internal/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockBlockingBackend is a mock backend that waits for its request to be cancelled
+type MockBlockingBackend struct {
+	MockBackend
+	deadline time.Time
+}
+
+func (m *MockBlockingBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.ChatResponse, error) {
+	m.deadline, _ = ctx.Deadline()
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (m *MockBlockingBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	if err := onChunk(types.StreamChunk{Content: "partial"}); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// TestRequestCancellation tests that requests stop when the client goes away or the timeout passes
+func TestRequestCancellation(t *testing.T) {
+	modelConfig := types.ModelConfig{Name: "gpt-4o", Backend: types.BackendOpenAI}
+	req := types.ChatRequest{Model: "gpt-4o"}
+
+	newManager := func() (*backend.BackendManager, *MockBlockingBackend) {
+		mock := &MockBlockingBackend{MockBackend: MockBackend{name: "openai", available: true}}
+		manager := backend.NewBackendManager()
+		manager.RegisterBackend(types.BackendOpenAI, mock)
+		return manager, mock
+	}
+
+	t.Run("ClientDisconnect", func(t *testing.T) {
+		manager, _ := newManager()
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		_, err := manager.ProcessRequest(ctx, modelConfig, req)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, backend.RequestStats{Disconnects: 1}, manager.Stats())
+	})
+
+	t.Run("StreamDisconnect", func(t *testing.T) {
+		manager, _ := newManager()
+		ctx, cancel := context.WithCancel(context.Background())
+
+		err := manager.ProcessStreamRequest(ctx, modelConfig, req, func(chunk types.StreamChunk) error {
+			// The client goes away after the first chunk
+			cancel()
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, int64(1), manager.Stats().Disconnects)
+	})
+
+	t.Run("DefaultTimeout", func(t *testing.T) {
+		manager, _ := newManager()
+		manager.SetRequestTimeout(10 * time.Millisecond)
+
+		_, err := manager.ProcessRequest(context.Background(), modelConfig, req)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Contains(t, err.Error(), "request to gpt-4o timed out")
+		assert.Equal(t, backend.RequestStats{Timeouts: 1}, manager.Stats())
+	})
+
+	t.Run("ModelTimeoutOverridesDefault", func(t *testing.T) {
+		manager, mock := newManager()
+		manager.SetRequestTimeout(time.Hour)
+		withTimeout := modelConfig
+		withTimeout.Timeout = 10 * time.Millisecond
+
+		start := time.Now()
+		_, err := manager.ProcessRequest(context.Background(), withTimeout, req)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.WithinDuration(t, start.Add(10*time.Millisecond), mock.deadline, 50*time.Millisecond)
+	})
+}
@@ -0,0 +1,285 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-llm-proxy/internal/backend"
+	"go-llm-proxy/internal/retry"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/anthropic"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastRetryPolicy retries without noticeable delays
+func fastRetryPolicy() retry.Policy {
+	return retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, MaxElapsed: time.Second}
+}
+
+// TestServerDelay tests reading the wait time from Retry-After and rate-limit headers
+func TestServerDelay(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected time.Duration
+		found    bool
+	}{
+		{"RetryAfterSeconds", map[string]string{"Retry-After": "3"}, 3 * time.Second, true},
+		{"RetryAfterDate", map[string]string{"Retry-After": now.Add(5 * time.Second).Format(http.TimeFormat)}, 5 * time.Second, true},
+		{"RetryAfterMs", map[string]string{"retry-after-ms": "250", "Retry-After": "1"}, 250 * time.Millisecond, true},
+		{"AnthropicReset", map[string]string{
+			"anthropic-ratelimit-tokens-remaining": "0",
+			"anthropic-ratelimit-tokens-reset":     now.Add(7 * time.Second).Format(time.RFC3339),
+		}, 7 * time.Second, true},
+		{"OpenAIReset", map[string]string{
+			"x-ratelimit-remaining-requests": "0",
+			"x-ratelimit-reset-requests":     "1m2s",
+			"x-ratelimit-remaining-tokens":   "1000",
+			"x-ratelimit-reset-tokens":       "9m",
+		}, 62 * time.Second, true},
+		{"LimitNotExhausted", map[string]string{"x-ratelimit-remaining-tokens": "5", "x-ratelimit-reset-tokens": "2s"}, 0, false},
+		{"NoHeaders", map[string]string{}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for key, value := range tt.headers {
+				header.Set(key, value)
+			}
+			delay, found := retry.ServerDelay(header, now)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.expected, delay)
+		})
+	}
+}
+
+// TestRetryBackoff tests that backoff grows exponentially, with jitter, up to the cap
+func TestRetryBackoff(t *testing.T) {
+	policy := retry.Policy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for i := 0; i < 20; i++ {
+		first := policy.Backoff(1)
+		assert.GreaterOrEqual(t, first, 50*time.Millisecond)
+		assert.LessOrEqual(t, first, 100*time.Millisecond)
+
+		second := policy.Backoff(2)
+		assert.GreaterOrEqual(t, second, 100*time.Millisecond)
+		assert.LessOrEqual(t, second, 200*time.Millisecond)
+
+		capped := policy.Backoff(4)
+		assert.GreaterOrEqual(t, capped, 150*time.Millisecond)
+		assert.LessOrEqual(t, capped, 300*time.Millisecond)
+	}
+}
+
+// TestRetryTransport tests retrying of transient HTTP failures
+func TestRetryTransport(t *testing.T) {
+	newServer := func(statuses ...int) (*httptest.Server, *[]string) {
+		var bodies []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			status := statuses[len(statuses)-1]
+			if len(bodies) <= len(statuses) {
+				status = statuses[len(bodies)-1]
+			}
+			w.WriteHeader(status)
+		}))
+		return server, &bodies
+	}
+	client := &http.Client{Transport: retry.NewTransport(fastRetryPolicy())}
+
+	t.Run("RetriesUntilSuccess", func(t *testing.T) {
+		server, bodies := newServer(529, http.StatusTooManyRequests, http.StatusOK)
+		defer server.Close()
+
+		resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"a":1}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		// The body is sent again with every attempt
+		assert.Equal(t, []string{`{"a":1}`, `{"a":1}`, `{"a":1}`}, *bodies)
+	})
+
+	t.Run("GivesUpAfterMaxAttempts", func(t *testing.T) {
+		server, bodies := newServer(http.StatusServiceUnavailable)
+		defer server.Close()
+
+		resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Len(t, *bodies, 3)
+	})
+
+	t.Run("ClientErrorsAreNotRetried", func(t *testing.T) {
+		server, bodies := newServer(http.StatusBadRequest)
+		defer server.Close()
+
+		resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Len(t, *bodies, 1)
+	})
+
+	t.Run("ServerDelayBeyondLimit", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		start := time.Now()
+		resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		// Waiting a minute would exceed the policy's total time, so the 429 is returned at once
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("ServerDelayBeyondMaxDelay", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		// Without a limit on the total time, only MaxDelay bounds the wait
+		policy := fastRetryPolicy()
+		policy.MaxElapsed = 0
+		client := &http.Client{Transport: retry.NewTransport(policy)}
+
+		start := time.Now()
+		resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, 1, requests)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("ServerDelayWithinMaxDelay", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if requests == 1 {
+				w.Header().Set("retry-after-ms", "2")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 2, requests)
+	})
+}
+
+// TestAnthropicRetriesOverload tests that an overloaded Anthropic API is retried
+func TestAnthropicRetriesOverload(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(529)
+			_, _ = w.Write([]byte(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","content":[{"type":"text","text":"Hi"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`))
+	}))
+	defer server.Close()
+
+	backend := anthropic.NewAnthropicBackendWithBaseURL("test-key", server.URL)
+	backend.SetRetryPolicy(fastRetryPolicy())
+
+	resp, err := backend.Generate(context.Background(), types.GenerateRequest{Model: "claude-test", Prompt: "Hello", MaxTokens: 10})
+	require.NoError(t, err)
+	assert.Equal(t, "Hi", resp.Content)
+	assert.Equal(t, 2, requests)
+}
+
+// MockFlakyStreamingBackend is a mock backend whose streams fail with a transient error
+type MockFlakyStreamingBackend struct {
+	MockBackend
+	// failures is the number of streams that fail before one succeeds
+	failures int
+	// partial makes failing streams send a chunk before failing
+	partial bool
+	calls   int
+}
+
+func (m *MockFlakyStreamingBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	m.calls++
+	if m.calls <= m.failures {
+		if m.partial {
+			if err := onChunk(types.StreamChunk{Content: "partial"}); err != nil {
+				return err
+			}
+		}
+		return retry.Temporary(errors.New("overloaded"))
+	}
+	if err := onChunk(types.StreamChunk{Content: "complete"}); err != nil {
+		return err
+	}
+	return onChunk(types.StreamChunk{Done: true})
+}
+
+// TestStreamRetries tests that streams are retried only while nothing has been forwarded
+func TestStreamRetries(t *testing.T) {
+	modelConfig := types.ModelConfig{Name: "gpt-4o", Backend: types.BackendOpenAI}
+	run := func(mock *MockFlakyStreamingBackend) ([]string, error) {
+		manager := backend.NewBackendManager()
+		manager.SetRetryPolicy(fastRetryPolicy())
+		manager.RegisterBackend(types.BackendOpenAI, mock)
+
+		var contents []string
+		err := manager.ProcessStreamRequest(context.Background(), modelConfig, types.ChatRequest{Model: "gpt-4o"}, func(chunk types.StreamChunk) error {
+			if chunk.Content != "" {
+				contents = append(contents, chunk.Content)
+			}
+			return nil
+		})
+		return contents, err
+	}
+
+	t.Run("RetriedBeforeFirstChunk", func(t *testing.T) {
+		mock := &MockFlakyStreamingBackend{MockBackend: MockBackend{name: "openai", available: true}, failures: 2}
+		contents, err := run(mock)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"complete"}, contents)
+		assert.Equal(t, 3, mock.calls)
+	})
+
+	t.Run("NotRetriedAfterFirstChunk", func(t *testing.T) {
+		mock := &MockFlakyStreamingBackend{MockBackend: MockBackend{name: "openai", available: true}, failures: 1, partial: true}
+		contents, err := run(mock)
+		require.Error(t, err)
+		assert.Equal(t, []string{"partial"}, contents)
+		assert.Equal(t, 1, mock.calls)
+	})
+
+	t.Run("GivesUpAfterMaxAttempts", func(t *testing.T) {
+		mock := &MockFlakyStreamingBackend{MockBackend: MockBackend{name: "openai", available: true}, failures: 5}
+		_, err := run(mock)
+		require.Error(t, err)
+		assert.Equal(t, 3, mock.calls)
+	})
+}
@@ -196,6 +196,14 @@ func (m *MockErrorBackend) Chat(ctx context.Context, req types.ChatRequest) (*ty
 	return nil, assert.AnError
 }
 
+func (m *MockErrorBackend) GenerateStream(ctx context.Context, req types.GenerateRequest, onChunk types.StreamCallback) error {
+	return assert.AnError
+}
+
+func (m *MockErrorBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	return assert.AnError
+}
+
 func (m *MockErrorBackend) IsAvailable() bool {
 	return m.available
 }
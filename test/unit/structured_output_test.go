@@ -0,0 +1,384 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-llm-proxy/internal/backend"
+	"go-llm-proxy/internal/schema"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/anthropic"
+	"go-llm-proxy/pkg/openai"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPersonSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}, "maxItems": 2}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"$defs": {"tag": {"type": "string", "enum": ["a", "b"]}}
+}`
+
+// TestSchemaValidate tests validation of documents against a JSON Schema
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		errPart  string
+	}{
+		{"Valid", `{"name":"Ada","age":36,"tags":["a"]}`, ""},
+		{"MissingRequired", `{"name":"Ada"}`, `missing required property "age"`},
+		{"WrongType", `{"name":"Ada","age":"36"}`, "$.age: expected integer, got string"},
+		{"NotInteger", `{"name":"Ada","age":3.5}`, "expected integer"},
+		{"BelowMinimum", `{"name":"Ada","age":-1}`, "less than the minimum"},
+		{"EmptyString", `{"name":"","age":1}`, "at least 1 characters"},
+		{"AdditionalProperty", `{"name":"Ada","age":1,"extra":true}`, `property "extra" is not allowed`},
+		{"EnumThroughRef", `{"name":"Ada","age":1,"tags":["c"]}`, "$.tags[0]: value is not one of the allowed values"},
+		{"TooManyItems", `{"name":"Ada","age":1,"tags":["a","b","a"]}`, "at most 2 items"},
+		{"NotJSON", `{"name":`, "invalid JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate(json.RawMessage(testPersonSchema), []byte(tt.document))
+			if tt.errPart == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errPart)
+		})
+	}
+
+	t.Run("Combinators", func(t *testing.T) {
+		oneOf := json.RawMessage(`{"oneOf":[{"type":"string"},{"type":"number"}]}`)
+		assert.NoError(t, schema.Validate(oneOf, []byte(`"x"`)))
+		assert.Error(t, schema.Validate(oneOf, []byte(`true`)))
+
+		anyOf := json.RawMessage(`{"anyOf":[{"type":"null"},{"type":"string","pattern":"^[a-z]+$"}]}`)
+		assert.NoError(t, schema.Validate(anyOf, []byte(`null`)))
+		assert.NoError(t, schema.Validate(anyOf, []byte(`"abc"`)))
+		assert.Error(t, schema.Validate(anyOf, []byte(`"ABC"`)))
+	})
+
+	t.Run("RecursiveRef", func(t *testing.T) {
+		// A pointer back to the same schema for the same value fails instead of recursing forever
+		err := schema.Validate(json.RawMessage(`{"$ref":"#"}`), []byte(`{}`))
+		assert.ErrorContains(t, err, `circular $ref "#"`)
+		err = schema.Validate(json.RawMessage(`{"anyOf":[{"type":"string"},{"$ref":"#/$defs/a"}],"$defs":{"a":{"allOf":[{"$ref":"#"}]}}}`), []byte(`1`))
+		assert.Error(t, err)
+
+		// Recursive schemas that descend into the value are fine
+		tree := json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"},"children":{"type":"array","items":{"$ref":"#"}}}}`)
+		assert.NoError(t, schema.Check(tree))
+		assert.NoError(t, schema.Validate(tree, []byte(`{"name":"a","children":[{"name":"b","children":[{"name":"c"}]}]}`)))
+		assert.Error(t, schema.Validate(tree, []byte(`{"children":[{"name":1}]}`)))
+	})
+}
+
+// TestSchemaCheck tests that schemas whose $ref pointers can't be followed are rejected
+func TestSchemaCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		errPart string
+	}{
+		{"Valid", testPersonSchema, ""},
+		{"SelfReference", `{"$ref":"#"}`, `circular $ref at "#"`},
+		{"ReferenceCycle", `{"$ref":"#/$defs/a","$defs":{"a":{"$ref":"#/$defs/b"},"b":{"$ref":"#/$defs/a"}}}`, "circular $ref"},
+		{"CycleThroughCombinator", `{"properties":{"x":{"$ref":"#/$defs/a"}},"$defs":{"a":{"anyOf":[{"type":"null"},{"$ref":"#/$defs/a"}]}}}`, "circular $ref"},
+		{"Unresolvable", `{"properties":{"x":{"$ref":"#/$defs/missing"}}}`, `unresolvable $ref "#/$defs/missing"`},
+		{"Remote", `{"items":{"$ref":"https://example.com/schema.json"}}`, "only local references are supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Check(json.RawMessage(tt.schema))
+			if tt.errPart == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.errPart)
+		})
+	}
+
+	_, err := types.ParseOllamaFormat(json.RawMessage(`{"$ref":"#"}`))
+	assert.Error(t, err, "formats that can't be validated are rejected with the request")
+}
+
+// TestParseOllamaFormat tests parsing of the Ollama format field
+func TestParseOllamaFormat(t *testing.T) {
+	format, err := types.ParseOllamaFormat(nil)
+	require.NoError(t, err)
+	assert.Nil(t, format)
+
+	format, err = types.ParseOllamaFormat(json.RawMessage(`""`))
+	require.NoError(t, err)
+	assert.Nil(t, format)
+
+	format, err = types.ParseOllamaFormat(json.RawMessage(`"json"`))
+	require.NoError(t, err)
+	require.NotNil(t, format)
+	assert.False(t, format.HasSchema())
+	assert.NoError(t, format.Validate(`[1, 2]`))
+	assert.Error(t, format.Validate(`not json`))
+
+	format, err = types.ParseOllamaFormat(json.RawMessage(testPersonSchema))
+	require.NoError(t, err)
+	assert.True(t, format.HasSchema())
+	assert.NoError(t, format.Validate(`{"name":"Ada","age":36}`))
+	assert.Error(t, format.Validate(`{"name":"Ada"}`))
+
+	_, err = types.ParseOllamaFormat(json.RawMessage(`"yaml"`))
+	assert.Error(t, err)
+
+	_, err = types.ParseOllamaFormat(json.RawMessage(`[1]`))
+	assert.Error(t, err)
+}
+
+// TestAnthropicStructuredOutput tests that formats are requested as a forced tool call
+// and that the tool input comes back as the message content
+func TestAnthropicStructuredOutput(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg_1","content":[{"type":"tool_use","id":"toolu_1","name":"json_response","input":{"value":["a","b"]}}],"usage":{"input_tokens":10,"output_tokens":5}}`))
+	}))
+	defer server.Close()
+
+	format, err := types.ParseOllamaFormat(json.RawMessage(`{"type":"array","items":{"type":"string"}}`))
+	require.NoError(t, err)
+
+	backend := anthropic.NewAnthropicBackendWithBaseURL("test-key", server.URL)
+	resp, err := backend.Chat(context.Background(), types.ChatRequest{
+		Model:     "claude-test",
+		Messages:  []types.ChatMessage{{Role: "user", Content: "List two letters"}},
+		MaxTokens: 100,
+		Format:    format,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"type": "tool", "name": "json_response"}, body["tool_choice"])
+	tools := body["tools"].([]interface{})
+	require.Len(t, tools, 1)
+	// Array schemas are wrapped, since tool inputs must be objects
+	inputSchema := tools[0].(map[string]interface{})["input_schema"].(map[string]interface{})
+	assert.Equal(t, "object", inputSchema["type"])
+	assert.Contains(t, inputSchema["properties"], "value")
+
+	assert.JSONEq(t, `["a","b"]`, resp.Message.Content)
+	assert.Empty(t, resp.Message.ToolCalls)
+}
+
+// TestAnthropicStructuredOutputWithTools tests that a format leaves the request's own tools
+// and tool choice in place, taking only the final answer
+func TestAnthropicStructuredOutputWithTools(t *testing.T) {
+	format, err := types.ParseOllamaFormat(json.RawMessage(`{"type":"object","properties":{"summary":{"type":"string"}}}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		choice *types.ToolChoice
+		tools  []string
+		want   anthropic.ToolChoice
+	}{
+		{"NoChoice", nil, []string{"get_weather", "json_response"}, anthropic.ToolChoice{Type: "any"}},
+		{"Auto", &types.ToolChoice{Type: "auto"}, []string{"get_weather", "json_response"}, anthropic.ToolChoice{Type: "any"}},
+		{"Any", &types.ToolChoice{Type: "any"}, []string{"get_weather", "json_response"}, anthropic.ToolChoice{Type: "any"}},
+		{"Tool", &types.ToolChoice{Type: "tool", Name: "get_weather"}, []string{"get_weather", "json_response"}, anthropic.ToolChoice{Type: "tool", Name: "get_weather"}},
+		{"None", &types.ToolChoice{Type: "none"}, []string{"json_response"}, anthropic.ToolChoice{Type: "tool", Name: "json_response"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := anthropic.BuildChatRequest(types.ChatRequest{
+				Model:      "claude-test",
+				Messages:   []types.ChatMessage{{Role: "user", Content: "Summarize the weather in Oslo"}},
+				MaxTokens:  100,
+				Tools:      []types.Tool{weatherTool},
+				ToolChoice: tt.choice,
+				Format:     format,
+			})
+			require.NoError(t, err)
+
+			var tools []string
+			for _, tool := range req.Tools {
+				tools = append(tools, tool.Name)
+			}
+			assert.Equal(t, tt.tools, tools)
+			require.NotNil(t, req.ToolChoice)
+			assert.Equal(t, tt.want, *req.ToolChoice)
+		})
+	}
+
+	t.Run("ToolCall", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"id":"msg_1","content":[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Oslo"}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5}}`))
+		}))
+		defer server.Close()
+
+		backend := anthropic.NewAnthropicBackendWithBaseURL("test-key", server.URL)
+		resp, err := backend.Chat(context.Background(), types.ChatRequest{
+			Model:     "claude-test",
+			Messages:  []types.ChatMessage{{Role: "user", Content: "Summarize the weather in Oslo"}},
+			MaxTokens: 100,
+			Tools:     []types.Tool{weatherTool},
+			Format:    format,
+		})
+		require.NoError(t, err)
+
+		// Calls of the request's own tools come back as they are, for the client to answer
+		require.Len(t, resp.Message.ToolCalls, 1)
+		assert.Equal(t, "get_weather", resp.Message.ToolCalls[0].Function.Name)
+		assert.Empty(t, resp.Message.Content)
+	})
+}
+
+// TestOpenAIStructuredOutput tests the response_format sent for JSON mode and for schemas
+func TestOpenAIStructuredOutput(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","created":1,"choices":[{"index":0,"message":{"role":"assistant","content":"{\"name\":\"Ada\",\"age\":36}"},"finish_reason":"stop"}],"usage":{"prompt_tokens":9,"completion_tokens":8}}`))
+	}))
+	defer server.Close()
+
+	backend := openai.NewOpenAIBackendWithBaseURL("test-key", server.URL)
+
+	t.Run("JSONMode", func(t *testing.T) {
+		_, err := backend.Chat(context.Background(), types.ChatRequest{
+			Model:    "gpt-4o",
+			Messages: []types.ChatMessage{{Role: "user", Content: "Describe Ada"}},
+			Format:   &types.ResponseFormat{},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]interface{}{"type": "json_object"}, body["response_format"])
+		// JSON mode requires the conversation to mention JSON
+		messages := body["messages"].([]interface{})
+		require.Len(t, messages, 2)
+		assert.Equal(t, "system", messages[0].(map[string]interface{})["role"])
+	})
+
+	t.Run("Schema", func(t *testing.T) {
+		resp, err := backend.Chat(context.Background(), types.ChatRequest{
+			Model:    "gpt-4o",
+			Messages: []types.ChatMessage{{Role: "user", Content: "Describe Ada"}},
+			Format:   &types.ResponseFormat{Schema: json.RawMessage(testPersonSchema)},
+		})
+		require.NoError(t, err)
+
+		responseFormat := body["response_format"].(map[string]interface{})
+		assert.Equal(t, "json_schema", responseFormat["type"])
+		jsonSchema := responseFormat["json_schema"].(map[string]interface{})
+		assert.Equal(t, "response", jsonSchema["name"])
+		assert.Equal(t, "object", jsonSchema["schema"].(map[string]interface{})["type"])
+		assert.Len(t, body["messages"], 1)
+
+		assert.JSONEq(t, `{"name":"Ada","age":36}`, resp.Message.Content)
+		require.NotNil(t, resp.Usage)
+		assert.Equal(t, 8, resp.Usage.CompletionTokens)
+	})
+}
+
+// MockFormatBackend is a mock backend that answers with a fixed list of replies in turn
+type MockFormatBackend struct {
+	MockBackend
+	replies []string
+	calls   int
+}
+
+func (m *MockFormatBackend) next() string {
+	reply := m.replies[m.calls%len(m.replies)]
+	m.calls++
+	return reply
+}
+
+func (m *MockFormatBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.ChatResponse, error) {
+	return &types.ChatResponse{
+		Model:   req.Model,
+		Message: types.ChatMessage{Role: "assistant", Content: m.next()},
+	}, nil
+}
+
+func (m *MockFormatBackend) ChatStream(ctx context.Context, req types.ChatRequest, onChunk types.StreamCallback) error {
+	if err := onChunk(types.StreamChunk{Content: m.next()}); err != nil {
+		return err
+	}
+	return onChunk(types.StreamChunk{Done: true})
+}
+
+// TestFormatRetries tests that responses not matching the format are retried
+func TestFormatRetries(t *testing.T) {
+	modelConfig := types.ModelConfig{Name: "gpt-4o", Backend: types.BackendOpenAI}
+	format := &types.ResponseFormat{Schema: json.RawMessage(testPersonSchema)}
+	req := types.ChatRequest{Model: "gpt-4o", Format: format}
+
+	newManager := func(replies ...string) (*backend.BackendManager, *MockFormatBackend) {
+		mock := &MockFormatBackend{MockBackend: MockBackend{name: "openai", available: true}, replies: replies}
+		manager := backend.NewBackendManager()
+		manager.RegisterBackend(types.BackendOpenAI, mock)
+		return manager, mock
+	}
+
+	t.Run("RetriesUntilValid", func(t *testing.T) {
+		manager, mock := newManager(`not json`, `{"name":"Ada"}`, `{"name":"Ada","age":36}`)
+
+		resp, err := manager.ProcessRequest(context.Background(), modelConfig, req)
+		require.NoError(t, err)
+		assert.Equal(t, `{"name":"Ada","age":36}`, resp.(*types.ChatResponse).Message.Content)
+		assert.Equal(t, 3, mock.calls)
+	})
+
+	t.Run("GivesUp", func(t *testing.T) {
+		manager, mock := newManager(`{"name":"Ada"}`)
+		manager.SetFormatRetries(1)
+
+		_, err := manager.ProcessRequest(context.Background(), modelConfig, req)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "after 2 attempts")
+		assert.Equal(t, 2, mock.calls)
+	})
+
+	t.Run("StreamIsReplayedOnceValid", func(t *testing.T) {
+		manager, mock := newManager(`{"age":1}`, `{"name":"Ada","age":36}`)
+
+		var chunks []types.StreamChunk
+		err := manager.ProcessStreamRequest(context.Background(), modelConfig, req, func(chunk types.StreamChunk) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, mock.calls)
+
+		// Only the chunks of the valid attempt reach the client
+		require.Len(t, chunks, 2)
+		assert.Equal(t, `{"name":"Ada","age":36}`, chunks[0].Content)
+		assert.True(t, chunks[1].Done)
+	})
+
+	t.Run("NoFormat", func(t *testing.T) {
+		manager, mock := newManager(`not json`)
+
+		_, err := manager.ProcessRequest(context.Background(), modelConfig, types.ChatRequest{Model: "gpt-4o"})
+		require.NoError(t, err, fmt.Sprintf("calls: %d", mock.calls))
+		assert.Equal(t, 1, mock.calls)
+	})
+}
@@ -0,0 +1,412 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/pkg/anthropic"
+	"go-llm-proxy/pkg/gemini"
+	"go-llm-proxy/pkg/openai"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var weatherTool = types.Tool{
+	Type: "function",
+	Function: types.ToolFunction{
+		Name:        "get_weather",
+		Description: "Get the weather for a city",
+		Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`),
+	},
+}
+
+// toolConversation returns a conversation where the assistant called two tools in parallel
+func toolConversation() []types.ChatMessage {
+	return []types.ChatMessage{
+		{Role: "user", Content: "Weather in Paris and Rome?"},
+		{Role: "assistant", ToolCalls: []types.ToolCall{
+			{ID: "call_a", Function: types.ToolCallFunction{Name: "get_weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}},
+			{ID: "call_b", Function: types.ToolCallFunction{Name: "get_weather", Arguments: json.RawMessage(`{"city":"Rome"}`)}},
+		}},
+		{Role: "tool", Content: "Sunny", ToolCallID: "call_a"},
+		{Role: "tool", Content: "Rainy", ToolCallID: "call_b"},
+	}
+}
+
+// TestAssignToolCallIDs tests that Ollama tool calls and results are paired without client IDs
+func TestAssignToolCallIDs(t *testing.T) {
+	messages := types.AssignToolCallIDs([]types.ChatMessage{
+		{Role: "user", Content: "Weather and time in Paris?"},
+		{Role: "assistant", ToolCalls: []types.ToolCall{
+			{Function: types.ToolCallFunction{Name: "get_weather", Arguments: json.RawMessage(`{"city":"Paris"}`)}},
+			{Function: types.ToolCallFunction{Name: "get_time", Arguments: json.RawMessage(`{"city":"Paris"}`)}},
+		}},
+		{Role: "tool", Content: "10:00", ToolName: "get_time"},
+		{Role: "tool", Content: "Sunny"},
+	})
+
+	require.Len(t, messages, 4)
+	assert.Equal(t, "call_1_0", messages[1].ToolCalls[0].ID)
+	assert.Equal(t, "call_1_1", messages[1].ToolCalls[1].ID)
+
+	// Named results match by name, unnamed results take the earliest unanswered call
+	assert.Equal(t, "call_1_1", messages[2].ToolCallID)
+	assert.Equal(t, "call_1_0", messages[3].ToolCallID)
+	assert.Equal(t, "get_weather", messages[3].ToolName)
+
+	// IDs are positional, so converting the same history again gives the same IDs
+	again := types.AssignToolCallIDs(messages)
+	assert.Equal(t, messages, again)
+}
+
+// TestAnthropicToolUse tests tool definitions, tool_use and tool_result translation for Anthropic
+func TestAnthropicToolUse(t *testing.T) {
+	var body struct {
+		Tools []struct {
+			Name        string          `json:"name"`
+			InputSchema json.RawMessage `json:"input_schema"`
+		} `json:"tools"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content []struct {
+				Type      string          `json:"type"`
+				ID        string          `json:"id"`
+				Input     json.RawMessage `json:"input"`
+				ToolUseID string          `json:"tool_use_id"`
+				Content   string          `json:"content"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"id": "msg_1",
+			"content": [
+				{"type": "text", "text": "Checking."},
+				{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Oslo"}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 10, "output_tokens": 5}
+		}`))
+	}))
+	defer server.Close()
+
+	backend := anthropic.NewAnthropicBackendWithBaseURL("test-key", server.URL)
+	resp, err := backend.Chat(context.Background(), types.ChatRequest{
+		Model:     "claude-test",
+		Messages:  toolConversation(),
+		Tools:     []types.Tool{weatherTool},
+		MaxTokens: 100,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, body.Tools, 1)
+	assert.Equal(t, "get_weather", body.Tools[0].Name)
+	assert.JSONEq(t, string(weatherTool.Function.Parameters), string(body.Tools[0].InputSchema))
+
+	// Both tool results go back in a single user turn
+	require.Len(t, body.Messages, 3)
+	assistant := body.Messages[1]
+	require.Len(t, assistant.Content, 2)
+	assert.Equal(t, "tool_use", assistant.Content[0].Type)
+	assert.Equal(t, "call_a", assistant.Content[0].ID)
+	assert.JSONEq(t, `{"city":"Paris"}`, string(assistant.Content[0].Input))
+	results := body.Messages[2]
+	assert.Equal(t, "user", results.Role)
+	require.Len(t, results.Content, 2)
+	assert.Equal(t, "tool_result", results.Content[0].Type)
+	assert.Equal(t, "call_a", results.Content[0].ToolUseID)
+	assert.Equal(t, "Sunny", results.Content[0].Content)
+	assert.Equal(t, "call_b", results.Content[1].ToolUseID)
+
+	assert.Equal(t, "Checking.", resp.Message.Content)
+	require.Len(t, resp.Message.ToolCalls, 1)
+	assert.Equal(t, "toolu_1", resp.Message.ToolCalls[0].ID)
+	assert.Equal(t, "get_weather", resp.Message.ToolCalls[0].Function.Name)
+	assert.JSONEq(t, `{"city":"Oslo"}`, string(resp.Message.ToolCalls[0].Function.Arguments))
+}
+
+// TestAnthropicToolUseStream tests that streamed tool input is assembled into a single tool call chunk
+func TestAnthropicToolUseStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":5}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":" \"Oslo\"}"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":7}}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+	}))
+	defer server.Close()
+
+	backend := anthropic.NewAnthropicBackendWithBaseURL("test-key", server.URL)
+
+	var chunks []types.StreamChunk
+	err := backend.ChatStream(context.Background(), types.ChatRequest{
+		Model:     "claude-test",
+		Messages:  []types.ChatMessage{{Role: "user", Content: "Weather in Oslo?"}},
+		Tools:     []types.Tool{weatherTool},
+		MaxTokens: 100,
+	}, func(chunk types.StreamChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, chunks, 2)
+	require.Len(t, chunks[0].ToolCalls, 1)
+	assert.Equal(t, "toolu_1", chunks[0].ToolCalls[0].ID)
+	assert.Equal(t, "get_weather", chunks[0].ToolCalls[0].Function.Name)
+	assert.JSONEq(t, `{"city":"Oslo"}`, string(chunks[0].ToolCalls[0].Function.Arguments))
+	assert.True(t, chunks[1].Done)
+}
+
+// TestOpenAIToolCalls tests tool definitions, tool_calls and tool messages for OpenAI
+func TestOpenAIToolCalls(t *testing.T) {
+	var body struct {
+		Tools []struct {
+			Type     string `json:"type"`
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		} `json:"tools"`
+		Messages []struct {
+			Role      string `json:"role"`
+			ToolCalls []struct {
+				ID       string `json:"id"`
+				Function struct {
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+			ToolCallID string `json:"tool_call_id"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"id": "chatcmpl-1",
+			"created": 1,
+			"choices": [{
+				"index": 0,
+				"message": {
+					"role": "assistant",
+					"content": null,
+					"tool_calls": [{"id": "call_x", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Oslo\"}"}}]
+				},
+				"finish_reason": "tool_calls"
+			}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5}
+		}`))
+	}))
+	defer server.Close()
+
+	backend := openai.NewOpenAIBackendWithBaseURL("test-key", server.URL)
+	resp, err := backend.Chat(context.Background(), types.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: toolConversation(),
+		Tools:    []types.Tool{weatherTool},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, body.Tools, 1)
+	assert.Equal(t, "function", body.Tools[0].Type)
+	assert.Equal(t, "get_weather", body.Tools[0].Function.Name)
+	require.Len(t, body.Messages, 4)
+	require.Len(t, body.Messages[1].ToolCalls, 2)
+	assert.Equal(t, "call_a", body.Messages[1].ToolCalls[0].ID)
+	assert.JSONEq(t, `{"city":"Paris"}`, body.Messages[1].ToolCalls[0].Function.Arguments)
+	assert.Equal(t, "tool", body.Messages[2].Role)
+	assert.Equal(t, "call_a", body.Messages[2].ToolCallID)
+
+	require.Len(t, resp.Message.ToolCalls, 1)
+	assert.Equal(t, "call_x", resp.Message.ToolCalls[0].ID)
+	assert.JSONEq(t, `{"city":"Oslo"}`, string(resp.Message.ToolCalls[0].Function.Arguments))
+}
+
+// TestOpenAIToolCallStream tests that streamed tool call fragments are assembled by index
+func TestOpenAIToolCallStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		deltas := []string{
+			`{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}`,
+			`{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"get_time","arguments":"{}"}}]}`,
+			`{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}`,
+			`{"tool_calls":[{"index":0,"function":{"arguments":"\"Oslo\"}"}}]}`,
+		}
+		for _, delta := range deltas {
+			fmt.Fprintf(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":%s}]}\n\n", delta)
+		}
+		fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"tool_calls\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	backend := openai.NewOpenAIBackendWithBaseURL("test-key", server.URL)
+
+	var chunks []types.StreamChunk
+	err := backend.ChatStream(context.Background(), types.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []types.ChatMessage{{Role: "user", Content: "Weather and time in Oslo?"}},
+		Tools:    []types.Tool{weatherTool},
+	}, func(chunk types.StreamChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, chunks, 2)
+	require.Len(t, chunks[0].ToolCalls, 2)
+	assert.Equal(t, "call_1", chunks[0].ToolCalls[0].ID)
+	assert.Equal(t, "get_weather", chunks[0].ToolCalls[0].Function.Name)
+	assert.JSONEq(t, `{"city":"Oslo"}`, string(chunks[0].ToolCalls[0].Function.Arguments))
+	assert.Equal(t, "call_2", chunks[0].ToolCalls[1].ID)
+	assert.JSONEq(t, `{}`, string(chunks[0].ToolCalls[1].Function.Arguments))
+	assert.True(t, chunks[1].Done)
+}
+
+// TestOpenAIToolCallStreamEarly tests that each streamed tool call is forwarded as soon as it
+// is complete, rather than when the stream ends
+func TestOpenAIToolCallStreamEarly(t *testing.T) {
+	firstForwarded := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		send := func(delta, finishReason string) {
+			fmt.Fprintf(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"choices\":[{\"index\":0,\"delta\":%s,\"finish_reason\":%s}]}\n\n", delta, finishReason)
+			w.(http.Flusher).Flush()
+		}
+		send(`{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}`, "null")
+		send(`{"tool_calls":[{"index":0,"function":{"arguments":"\"Oslo\"}"}}]}`, "null")
+		send(`{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"get_weather","arguments":"{\"city\":"}}]}`, "null")
+
+		// The first call is complete once the second starts, so it is forwarded before the stream goes on
+		select {
+		case <-firstForwarded:
+		case <-time.After(5 * time.Second):
+			t.Error("the first tool call was not forwarded when the second started")
+		}
+		send(`{"tool_calls":[{"index":1,"function":{"arguments":"\"Bergen\"}"}}]}`, "null")
+		send(`{}`, `"tool_calls"`)
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	backend := openai.NewOpenAIBackendWithBaseURL("test-key", server.URL)
+
+	var chunks []types.StreamChunk
+	err := backend.ChatStream(context.Background(), types.ChatRequest{
+		Model:    "gpt-4o",
+		Messages: []types.ChatMessage{{Role: "user", Content: "Weather in Oslo and Bergen?"}},
+		Tools:    []types.Tool{weatherTool},
+	}, func(chunk types.StreamChunk) error {
+		chunks = append(chunks, chunk)
+		if len(chunk.ToolCalls) > 0 && chunk.ToolCalls[0].ID == "call_1" {
+			close(firstForwarded)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, chunks, 3)
+	require.Len(t, chunks[0].ToolCalls, 1)
+	assert.Equal(t, "call_1", chunks[0].ToolCalls[0].ID)
+	assert.JSONEq(t, `{"city":"Oslo"}`, string(chunks[0].ToolCalls[0].Function.Arguments))
+	require.Len(t, chunks[1].ToolCalls, 1, "the last call is forwarded when the choice finishes")
+	assert.Equal(t, "call_2", chunks[1].ToolCalls[0].ID)
+	assert.JSONEq(t, `{"city":"Bergen"}`, string(chunks[1].ToolCalls[0].Function.Arguments))
+	assert.True(t, chunks[2].Done)
+	assert.Equal(t, "stop", chunks[2].DoneReason)
+}
+
+// TestToolChoice tests that a tool choice reaches each backend in its own terms
+func TestToolChoice(t *testing.T) {
+	tests := []struct {
+		name      string
+		choice    *types.ToolChoice
+		anthropic string
+		openai    string
+		gemini    string
+	}{
+		{"Unset", nil, `null`, `null`, `null`},
+		{"Auto", &types.ToolChoice{Type: "auto"}, `{"type":"auto"}`, `null`, `null`},
+		{"Any", &types.ToolChoice{Type: "any"}, `{"type":"any"}`, `"required"`, `{"functionCallingConfig":{"mode":"ANY"}}`},
+		{"None", &types.ToolChoice{Type: "none"}, `{"type":"none"}`, `"none"`, `{"functionCallingConfig":{"mode":"NONE"}}`},
+		{"Tool", &types.ToolChoice{Type: "tool", Name: "get_weather"},
+			`{"type":"tool","name":"get_weather"}`,
+			`{"type":"function","function":{"name":"get_weather"}}`,
+			`{"functionCallingConfig":{"mode":"ANY","allowedFunctionNames":["get_weather"]}}`},
+	}
+
+	var openaiBody struct {
+		ToolChoice json.RawMessage `json:"tool_choice"`
+	}
+	openaiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		openaiBody.ToolChoice = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&openaiBody))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer openaiServer.Close()
+	openaiBackend := openai.NewOpenAIBackendWithBaseURL("test-key", openaiServer.URL)
+
+	geminiServer := newGeminiServer(t)
+	geminiBackend := gemini.NewGeminiBackendWithBaseURL("gemini-key", geminiServer.URL)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := types.ChatRequest{
+				Messages:   []types.ChatMessage{{Role: "user", Content: "Weather in Paris?"}},
+				Tools:      []types.Tool{weatherTool},
+				MaxTokens:  100,
+				ToolChoice: tt.choice,
+			}
+
+			anthropicReq, err := anthropic.BuildChatRequest(req)
+			require.NoError(t, err)
+			sent, err := json.Marshal(anthropicReq.ToolChoice)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.anthropic, string(sent))
+
+			req.Model = "gpt-4o"
+			_, err = openaiBackend.Chat(context.Background(), req)
+			require.NoError(t, err)
+			if len(openaiBody.ToolChoice) == 0 {
+				openaiBody.ToolChoice = json.RawMessage(`null`)
+			}
+			assert.JSONEq(t, tt.openai, string(openaiBody.ToolChoice))
+
+			req.Model = "gemini-2.5-flash"
+			_, err = geminiBackend.Chat(context.Background(), req)
+			require.NoError(t, err)
+			sent, err = json.Marshal(geminiServer.body()["toolConfig"])
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.gemini, string(sent))
+		})
+	}
+
+	t.Run("WithoutTools", func(t *testing.T) {
+		// A tool choice means nothing without tools, and the APIs reject it
+		anthropicReq, err := anthropic.BuildChatRequest(types.ChatRequest{
+			Messages:   []types.ChatMessage{{Role: "user", Content: "Hi"}},
+			ToolChoice: &types.ToolChoice{Type: "any"},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, anthropicReq.ToolChoice)
+	})
+}
@@ -0,0 +1,165 @@
+package llmproxy_unit_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-llm-proxy/internal/backend"
+	"go-llm-proxy/internal/config"
+	"go-llm-proxy/internal/types"
+	"go-llm-proxy/test/helpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockRecordingBackend is a mock backend that keeps the last requests it received
+type MockRecordingBackend struct {
+	MockBackend
+	lastChat     types.ChatRequest
+	lastGenerate types.GenerateRequest
+}
+
+func (m *MockRecordingBackend) Chat(ctx context.Context, req types.ChatRequest) (*types.ChatResponse, error) {
+	m.lastChat = req
+	return m.MockBackend.Chat(ctx, req)
+}
+
+func (m *MockRecordingBackend) Generate(ctx context.Context, req types.GenerateRequest) (*types.GenerateResponse, error) {
+	m.lastGenerate = req
+	return m.MockBackend.Generate(ctx, req)
+}
+
+// TestVirtualModelConfig tests loading and validating the models section of config.yaml
+func TestVirtualModelConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+models:
+  - name: "llama3"
+    display_name: "Llama 3 (Claude)"
+    target: "claude-sonnet-4"
+    system: "You are a helpful assistant."
+    max_tokens: 1024
+    options:
+      temperature: 0.2
+      top_k: 40
+  - name: "codellama:7b"
+    backend: "openai"
+    backend_model: "gpt-4o-mini"
+`), 0o600))
+
+	cfg, err := config.Load([]string{"--config", configPath, "--anthropic-api-key", "test-key"})
+	require.NoError(t, err)
+	require.Len(t, cfg.Models, 2)
+	assert.Equal(t, "claude-sonnet-4", cfg.Models[0].Target)
+	assert.Equal(t, 1024, cfg.Models[0].MaxTokens)
+
+	opts, err := cfg.Models[0].GenerationOptions()
+	require.NoError(t, err)
+	assert.Equal(t, floatPtr(0.2), opts.Temperature)
+	assert.Equal(t, intPtr(40), opts.TopK, "whole numbers decoded from YAML are accepted")
+
+	invalid := map[string]config.VirtualModelConfig{
+		"NoName":         {Target: "gpt-4o"},
+		"NoTarget":       {Name: "llama3"},
+		"BothTargets":    {Name: "llama3", Target: "gpt-4o", Backend: "openai", BackendModel: "gpt-4o"},
+		"NoBackend":      {Name: "llama3", BackendModel: "gpt-4o"},
+		"BadOption":      {Name: "llama3", Target: "gpt-4o", Options: map[string]interface{}{"temperature": "hot"}},
+		"NumPredict":     {Name: "llama3", Target: "gpt-4o", Options: map[string]interface{}{"num_predict": 100}},
+		"NegativeTokens": {Name: "llama3", Target: "gpt-4o", MaxTokens: -1},
+	}
+	for name, model := range invalid {
+		cfg := &config.Config{AnthropicAPIKey: "test-key", Port: "11434", Models: []config.VirtualModelConfig{model}}
+		assert.ErrorContains(t, cfg.IsValid(), "models:", name)
+	}
+
+	duplicate := &config.Config{AnthropicAPIKey: "test-key", Port: "11434", Models: []config.VirtualModelConfig{
+		{Name: "llama3", Target: "gpt-4o"},
+		{Name: "llama3", Target: "gpt-4o-mini"},
+	}}
+	assert.ErrorContains(t, duplicate.IsValid(), "more than once")
+}
+
+// TestVirtualModelDefaults tests that a model's system prompt and default options fill in
+// what requests leave unset
+func TestVirtualModelDefaults(t *testing.T) {
+	mock := &MockRecordingBackend{MockBackend: MockBackend{name: "openai", available: true}}
+	manager := backend.NewBackendManager()
+	manager.RegisterBackend(types.BackendOpenAI, mock)
+
+	modelConfig := types.ModelConfig{
+		Name:         "llama3",
+		Backend:      types.BackendOpenAI,
+		BackendModel: "gpt-4o",
+		System:       "You are terse.",
+		Options:      types.GenerationOptions{Temperature: floatPtr(0.2), Seed: intPtr(7)},
+	}
+
+	t.Run("Chat", func(t *testing.T) {
+		_, err := manager.ProcessRequest(context.Background(), modelConfig, types.ChatRequest{
+			Model:    "gpt-4o",
+			Messages: []types.ChatMessage{{Role: "user", Content: "Hi"}},
+			Options:  types.GenerationOptions{Temperature: floatPtr(0.9)},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, mock.lastChat.Messages, 2)
+		assert.Equal(t, types.ChatMessage{Role: "system", Content: "You are terse."}, mock.lastChat.Messages[0])
+		assert.Equal(t, floatPtr(0.9), mock.lastChat.Options.Temperature, "options set by the request win")
+		assert.Equal(t, intPtr(7), mock.lastChat.Options.Seed)
+	})
+
+	t.Run("ChatWithOwnSystemPrompt", func(t *testing.T) {
+		_, err := manager.ProcessRequest(context.Background(), modelConfig, types.ChatRequest{
+			Model: "gpt-4o",
+			Messages: []types.ChatMessage{
+				{Role: "system", Content: "You are verbose."},
+				{Role: "user", Content: "Hi"},
+			},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, mock.lastChat.Messages, 2)
+		assert.Equal(t, "You are verbose.", mock.lastChat.Messages[0].Content)
+	})
+
+	t.Run("Generate", func(t *testing.T) {
+		_, err := manager.ProcessRequest(context.Background(), modelConfig, types.GenerateRequest{Model: "gpt-4o", Prompt: "Hi"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "You are terse.", mock.lastGenerate.System)
+		assert.Equal(t, floatPtr(0.2), mock.lastGenerate.Options.Temperature)
+
+		messages := mock.lastGenerate.ToChatRequest().Messages
+		require.Len(t, messages, 2)
+		assert.Equal(t, "system", messages[0].Role)
+	})
+}
+
+// TestVirtualModelMaxTokens tests that a model's default output limit replaces the estimate's cap
+func TestVirtualModelMaxTokens(t *testing.T) {
+	messages := []types.ChatMessage{{Role: "user", Content: "Hi"}}
+
+	model := types.ModelConfig{MaxTokens: 200000}
+	assert.Equal(t, 4000, types.CalculateMaxTokensForRequest(model, messages))
+
+	model.DefaultMaxTokens = 1024
+	assert.Equal(t, 1024, types.CalculateMaxTokensForRequest(model, messages))
+
+	model.DefaultMaxTokens = 16000
+	assert.Equal(t, 16000, types.CalculateMaxTokensForRequest(model, messages))
+}
+
+// TestModelRegistryLatestTag tests that the ":latest" tag Ollama clients add is ignored
+func TestModelRegistryLatestTag(t *testing.T) {
+	registry := helpers.CreateTestModelRegistry()
+
+	model, exists := registry.GetModel("gpt-4o:latest")
+	require.True(t, exists)
+	assert.Equal(t, "gpt-4o", model.Name)
+
+	_, exists = registry.GetModel("gpt-4o:7b")
+	assert.False(t, exists)
+}